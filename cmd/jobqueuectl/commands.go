@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// command is a subcommand of jobqueuectl.
+type command struct {
+	name string
+	args string
+	help string
+	run  func(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"list", "[filter flags]", "list the jobs matching the filter flags", listJobs},
+	{"show", "<job-id>", "show a job with its payload, result, error, and attempts", showJob},
+	{"reset", "[filter flags] [-dry-run] [job-id...]", "reset jobs by ID or filter so they are processed again", resetJobs},
+	{"delete", "[filter flags] [-dry-run] [job-id...]", "delete jobs by ID or filter", deleteJobs},
+	{"retry-failed", "-type <job-type> [filter flags] [-dry-run]", "reset the failed jobs of a job type", retryFailedJobs},
+	{"reschedule", "(-at <time> | -in <duration>) <job-id>...", "set the start time of jobs", rescheduleJobs},
+	{"bundle", "<bundle-id>", "show a job bundle with its jobs", showJobBundle},
+	{"status", "", "print the job counts per state, job type, and origin", showStatus},
+}
+
+// cli is the state shared by the commands.
+type cli struct {
+	db     jobworker.DataBase
+	out    io.Writer
+	format string
+}
+
+// run runs the command of args[0] with the arguments args[1:]
+// against db and writes its output in format to out.
+func run(ctx context.Context, db jobworker.DataBase, format string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("no command")
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		flags.Usage = func() {
+			fmt.Fprintf(flags.Output(), "Usage: jobqueuectl %s %s\n\n%s\n", cmd.name, cmd.args, cmd.help)
+			flags.PrintDefaults()
+		}
+		return cmd.run(ctx, &cli{db: db, out: out, format: format}, flags, args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// addFilterFlags adds the flags setting the fields of filter to flags.
+func addFilterFlags(flags *flag.FlagSet, filter *jobqueue.JobFilter) {
+	flags.StringVar(&filter.Type, "type", "", "only jobs of this job `type`")
+	flags.StringVar(&filter.Origin, "origin", "", "only jobs of this `origin`")
+	flags.Func("bundle", "only jobs of the job bundle with this `ID`", func(s string) error {
+		id, err := uu.IDFromString(s)
+		filter.BundleID = id.Nullable()
+		return err
+	})
+	flags.Func("state", "only jobs in this `state`: pending, delayed, running, retrying, failed, cancelled, or succeeded", func(s string) error {
+		filter.State = jobqueue.JobState(s)
+		if !filter.State.Valid() {
+			return fmt.Errorf("invalid state %q", s)
+		}
+		return nil
+	})
+	flags.StringVar(&filter.ErrorPattern, "error", "", "only jobs with an error message matching this `regexp`")
+	flags.BoolVar(&filter.NewestFirst, "newest", false, "newest jobs first, with -limit the newest jobs")
+	flags.IntVar(&filter.Limit, "limit", 0, "maximum number of jobs, 0 means no limit")
+}
+
+// parseIDs parses the job or job bundle IDs of args.
+func parseIDs(args []string) (ids uu.IDs, err error) {
+	for _, arg := range args {
+		id, err := uu.IDFromString(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q: %w", arg, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func listJobs(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	var filter jobqueue.JobFilter
+	addFilterFlags(flags, &filter)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	jobs, err := c.db.GetJobs(ctx, &filter)
+	if err != nil {
+		return err
+	}
+	return c.printJobs(jobs)
+}
+
+func showJob(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected one job ID")
+	}
+	ids, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	job, err := c.db.GetJob(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	attempts, err := c.db.GetJobAttempts(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	return c.printJob(job, attempts)
+}
+
+// selectJobs returns the jobs of the IDs of args or of filter.
+// It returns an error if both or none of them are given.
+func (c *cli) selectJobs(ctx context.Context, filter *jobqueue.JobFilter, args []string) ([]*jobqueue.Job, error) {
+	switch {
+	case len(args) > 0 && !filter.IsZero():
+		return nil, errors.New("either job IDs or filter flags, not both")
+	case len(args) == 0 && *filter == (jobqueue.JobFilter{NewestFirst: filter.NewestFirst, Limit: filter.Limit}):
+		// Without a filter all jobs would be selected
+		return nil, errors.New("no job IDs or filter flags")
+	case len(args) == 0:
+		return c.db.GetJobs(ctx, filter)
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*jobqueue.Job, len(ids))
+	for i, id := range ids {
+		jobs[i], err = c.db.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// changeJobs selects jobs like selectJobs, calls change with their IDs
+// unless dryRun is true, and prints the jobs and what happened to them.
+func (c *cli) changeJobs(ctx context.Context, filter *jobqueue.JobFilter, args []string, dryRun bool, action string, change func(uu.IDs) error) error {
+	jobs, err := c.selectJobs(ctx, filter, args)
+	if err != nil {
+		return err
+	}
+	if !dryRun && len(jobs) > 0 {
+		ids := make(uu.IDs, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+		}
+		err = change(ids)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.printJobs(jobs)
+	if err != nil || c.format == formatJSON {
+		return err
+	}
+	if dryRun {
+		_, err = fmt.Fprintf(c.out, "%d jobs would be %s (dry run)\n", len(jobs), action)
+	} else {
+		_, err = fmt.Fprintf(c.out, "%d jobs %s\n", len(jobs), action)
+	}
+	return err
+}
+
+func resetJobs(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	var filter jobqueue.JobFilter
+	addFilterFlags(flags, &filter)
+	dryRun := flags.Bool("dry-run", false, "only list the jobs that would be reset")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return c.changeJobs(ctx, &filter, flags.Args(), *dryRun, "reset", func(ids uu.IDs) error {
+		return c.db.ResetJobs(ctx, ids)
+	})
+}
+
+func deleteJobs(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	var filter jobqueue.JobFilter
+	addFilterFlags(flags, &filter)
+	dryRun := flags.Bool("dry-run", false, "only list the jobs that would be deleted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return c.changeJobs(ctx, &filter, flags.Args(), *dryRun, "deleted", func(ids uu.IDs) error {
+		for _, id := range ids {
+			err := c.db.DeleteJob(ctx, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func retryFailedJobs(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	var filter jobqueue.JobFilter
+	addFilterFlags(flags, &filter)
+	dryRun := flags.Bool("dry-run", false, "only list the jobs that would be reset")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if filter.Type == "" {
+		return errors.New("missing -type")
+	}
+	if filter.State != "" && filter.State != jobqueue.JobStateFailed {
+		return fmt.Errorf("retry-failed can't select jobs in state %s", filter.State)
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	filter.State = jobqueue.JobStateFailed
+	return c.changeJobs(ctx, &filter, nil, *dryRun, "reset", func(ids uu.IDs) error {
+		return c.db.ResetJobs(ctx, ids)
+	})
+}
+
+func rescheduleJobs(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	var startAt time.Time
+	flags.Func("at", "start `time` in RFC 3339 format like 2006-01-02T15:04:05Z", func(s string) (err error) {
+		startAt, err = time.Parse(time.RFC3339, s)
+		return err
+	})
+	in := flags.Duration("in", 0, "start after this `duration` from now like 90m")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case startAt.IsZero() == (*in == 0):
+		return errors.New("expected either -at or -in")
+	case *in != 0:
+		startAt = time.Now().Add(*in)
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no job IDs")
+	}
+	ids, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	// A running job would be claimed again while its worker is still running it
+	for _, id := range ids {
+		job, err := c.db.GetJob(ctx, id)
+		if err != nil {
+			return err
+		}
+		if job.StartedAndNotStopped() {
+			return fmt.Errorf("can't reschedule running job %s", id)
+		}
+	}
+	jobs := make([]*jobqueue.Job, len(ids))
+	for i, id := range ids {
+		err = c.db.SetJobStart(ctx, id, startAt)
+		if err != nil {
+			return err
+		}
+		jobs[i], err = c.db.GetJob(ctx, id)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.printJobs(jobs)
+	if err != nil || c.format == formatJSON {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "%d jobs rescheduled to %s\n", len(jobs), formatTime(startAt))
+	return err
+}
+
+func showJobBundle(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected one job bundle ID")
+	}
+	ids, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	jobBundle, err := c.db.GetJobBundle(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	return c.printJobBundle(jobBundle)
+}
+
+func showStatus(ctx context.Context, c *cli, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	status, err := c.db.GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return c.printStatus(status)
+}
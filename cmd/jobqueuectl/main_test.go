@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+	"github.com/domonda/go-jobqueue/memqueue"
+)
+
+func newTestDB(t *testing.T) jobworker.DataBase {
+	t.Helper()
+	db := memqueue.NewDataBase()
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func addTestJob(t *testing.T, db jobworker.DataBase, jobType, origin, errorMsg string) *jobqueue.Job {
+	t.Helper()
+	// Jobs that fail get a higher priority to be claimed before the others
+	var priority int64
+	if errorMsg != "" {
+		priority = 1
+	}
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), jobType, origin, `{"n":1}`, priority, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, db.AddJob(t.Context(), job))
+	if errorMsg != "" {
+		// Claimed before the error so that an attempt is recorded
+		pool := jobworker.NewPool(db)
+		pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+		claimed, err := db.StartNextJobOrNil(t.Context(), pool.Claim())
+		require.NoError(t, err)
+		require.Equal(t, job.ID, claimed.ID)
+		require.NoError(t, db.SetJobError(t.Context(), job.ID, errorMsg, nil))
+	}
+	return job
+}
+
+// runOutput runs the command of args and returns its output.
+func runOutput(t *testing.T, db jobworker.DataBase, format string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, run(t.Context(), db, format, args, &out))
+	return out.String()
+}
+
+func jobIDsOfJSON(t *testing.T, output string) (ids uu.IDs) {
+	t.Helper()
+	var jobs []*jobqueue.Job
+	require.NoError(t, json.Unmarshal([]byte(output), &jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestListJobs(t *testing.T) {
+	db := newTestDB(t)
+	mail := addTestJob(t, db, "send-mail", "test", "")
+	failed := addTestJob(t, db, "send-mail", "test", "smtp timeout")
+	other := addTestJob(t, db, "render-pdf", "other", "")
+
+	assert.Equal(t, uu.IDs{mail.ID, failed.ID, other.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list")))
+	assert.Equal(t, uu.IDs{mail.ID, failed.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-type", "send-mail")))
+	assert.Equal(t, uu.IDs{other.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-origin", "other")))
+	assert.Equal(t, uu.IDs{failed.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-state", "failed")))
+	assert.Equal(t, uu.IDs{failed.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-error", "time(out)?$")))
+	assert.Equal(t, uu.IDs{mail.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-limit", "1")))
+	assert.Equal(t, uu.IDs{other.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-newest", "-limit", "1")))
+	assert.Equal(t, "[]\n", runOutput(t, db, formatJSON, "list", "-type", "unknown"))
+
+	table := runOutput(t, db, formatTable, "list", "-type", "send-mail")
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+TYPE\s+ORIGIN\s+STATE\s+`, lines[0])
+	assert.Contains(t, lines[1], mail.ID.String())
+	assert.Contains(t, lines[1], "pending")
+	assert.Contains(t, lines[2], "failed")
+	assert.True(t, strings.HasSuffix(lines[2], "smtp timeout"))
+
+	var out bytes.Buffer
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"list", "-state", "done"}, &out), "invalid state")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"list", "-error", "("}, &out), "invalid error pattern")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"unknown"}, &out), "unknown command")
+}
+
+func TestShowJob(t *testing.T) {
+	db := newTestDB(t)
+	job := addTestJob(t, db, "send-mail", "test", "smtp timeout")
+
+	table := runOutput(t, db, formatTable, "show", job.ID.String())
+	assert.Regexp(t, `(?m)^State:\s+failed$`, table)
+	assert.Regexp(t, `(?m)^Error:\s+smtp timeout$`, table)
+	assert.Contains(t, table, "Payload:\n{\n  \"n\": 1\n}\n")
+	assert.Contains(t, table, "Attempts:\n")
+
+	var shown jobWithAttempts
+	require.NoError(t, json.Unmarshal([]byte(runOutput(t, db, formatJSON, "show", job.ID.String())), &shown))
+	assert.Equal(t, job.ID, shown.Job.ID)
+	assert.Equal(t, "smtp timeout", shown.Job.ErrorMsg.String())
+	require.Len(t, shown.Attempts, 1)
+	assert.Equal(t, jobqueue.JobAttemptFailed, shown.Attempts[0].Outcome)
+
+	var out bytes.Buffer
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"show"}, &out), "missing ID")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"show", "invalid"}, &out), "invalid ID")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"show", uu.NewID(t.Context()).String()}, &out), "missing job")
+}
+
+func TestResetJobs(t *testing.T) {
+	db := newTestDB(t)
+	timeout := addTestJob(t, db, "send-mail", "test", "smtp timeout")
+	refused := addTestJob(t, db, "send-mail", "test", "connection refused")
+
+	output := runOutput(t, db, formatTable, "reset", "-error", "timeout", "-dry-run")
+	assert.Contains(t, output, timeout.ID.String())
+	assert.NotContains(t, output, refused.ID.String())
+	assert.True(t, strings.HasSuffix(output, "1 jobs would be reset (dry run)\n"))
+	assert.True(t, getJob(t, db, timeout.ID).HasError(), "dry run doesn't reset")
+
+	output = runOutput(t, db, formatTable, "reset", "-error", "timeout")
+	assert.True(t, strings.HasSuffix(output, "1 jobs reset\n"))
+	assert.Equal(t, jobqueue.JobStatePending, getJob(t, db, timeout.ID).State(time.Now()))
+	assert.True(t, getJob(t, db, refused.ID).HasError())
+
+	assert.Equal(t, uu.IDs{refused.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "reset", refused.ID.String())))
+	assert.False(t, getJob(t, db, refused.ID).HasError())
+
+	var out bytes.Buffer
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reset"}, &out), "no IDs or filter")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reset", "-limit", "1"}, &out), "only a limit")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reset", "-newest", "-limit", "1"}, &out), "only an order and a limit")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reset", "-type", "send-mail", timeout.ID.String()}, &out), "IDs and filter")
+}
+
+func TestRetryFailedJobs(t *testing.T) {
+	db := newTestDB(t)
+	failed := addTestJob(t, db, "send-mail", "test", "smtp timeout")
+	otherType := addTestJob(t, db, "render-pdf", "test", "out of memory")
+
+	assert.Equal(t, uu.IDs{failed.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "retry-failed", "-type", "send-mail")))
+	assert.False(t, getJob(t, db, failed.ID).HasError())
+	assert.True(t, getJob(t, db, otherType.ID).HasError())
+
+	var out bytes.Buffer
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"retry-failed"}, &out), "missing type")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"retry-failed", "-type", "send-mail", "-state", "pending"}, &out), "other state")
+}
+
+func TestDeleteJobs(t *testing.T) {
+	db := newTestDB(t)
+	addTestJob(t, db, "send-mail", "test", "")
+	kept := addTestJob(t, db, "render-pdf", "test", "")
+
+	output := runOutput(t, db, formatTable, "delete", "-type", "send-mail")
+	assert.True(t, strings.HasSuffix(output, "1 jobs deleted\n"))
+	assert.Equal(t, uu.IDs{kept.ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list")))
+
+	runOutput(t, db, formatTable, "delete", kept.ID.String())
+	assert.Equal(t, "[]\n", runOutput(t, db, formatJSON, "list"))
+}
+
+func TestRescheduleJobs(t *testing.T) {
+	db := newTestDB(t)
+	job := addTestJob(t, db, "send-mail", "test", "smtp timeout")
+
+	startAt := time.Date(2100, 1, 2, 3, 4, 5, 0, time.UTC)
+	output := runOutput(t, db, formatTable, "reschedule", "-at", startAt.Format(time.RFC3339), job.ID.String())
+	assert.True(t, strings.HasSuffix(output, "1 jobs rescheduled to 2100-01-02T03:04:05Z\n"))
+	rescheduled := getJob(t, db, job.ID)
+	assert.True(t, rescheduled.StartAt.Get().Equal(startAt))
+	assert.Equal(t, jobqueue.JobStateDelayed, rescheduled.State(time.Now()))
+
+	runOutput(t, db, formatJSON, "reschedule", "-in", "2h", job.ID.String())
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), getJob(t, db, job.ID).StartAt.Get(), time.Minute)
+
+	var out bytes.Buffer
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reschedule", job.ID.String()}, &out), "missing time")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reschedule", "-in", "1h", "-at", startAt.Format(time.RFC3339), job.ID.String()}, &out), "both times")
+	assert.Error(t, run(t.Context(), db, formatTable, []string{"reschedule", "-in", "1h"}, &out), "missing IDs")
+
+	pending := addTestJob(t, db, "render-pdf", "test", "")
+	pool := jobworker.NewPool(db)
+	pool.Register("render-pdf", func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	running, err := db.StartNextJobOrNil(t.Context(), pool.Claim())
+	require.NoError(t, err)
+	require.Equal(t, pending.ID, running.ID)
+	err = run(t.Context(), db, formatTable, []string{"reschedule", "-in", "1h", job.ID.String(), running.ID.String()}, &out)
+	assert.ErrorContains(t, err, "can't reschedule running job")
+	assert.True(t, getJob(t, db, running.ID).StartedAndNotStopped(), "running job not changed")
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), getJob(t, db, job.ID).StartAt.Get(), time.Minute, "no job changed")
+}
+
+func TestShowJobBundle(t *testing.T) {
+	db := newTestDB(t)
+	descs := []jobqueue.JobDesc{
+		{Type: "send-mail", Payload: `{}`, Origin: "test"},
+		{Type: "send-mail", Payload: `{}`, Origin: "test"},
+	}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "newsletter", "test", descs, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, db.AddJobBundle(t.Context(), bundle))
+	addTestJob(t, db, "send-mail", "test", "")
+
+	table := runOutput(t, db, formatTable, "bundle", bundle.ID.String())
+	assert.Regexp(t, `(?m)^Type:\s+newsletter$`, table)
+	assert.Regexp(t, `(?m)^Jobs stopped:\s+0/2$`, table)
+	assert.Contains(t, table, bundle.Jobs[0].ID.String())
+	assert.Contains(t, table, bundle.Jobs[1].ID.String())
+
+	var shown jobqueue.JobBundle
+	require.NoError(t, json.Unmarshal([]byte(runOutput(t, db, formatJSON, "bundle", bundle.ID.String())), &shown))
+	assert.Equal(t, bundle.ID, shown.ID)
+	assert.Len(t, shown.Jobs, 2)
+
+	assert.Equal(t, uu.IDs{bundle.Jobs[0].ID, bundle.Jobs[1].ID}, jobIDsOfJSON(t, runOutput(t, db, formatJSON, "list", "-bundle", bundle.ID.String())))
+}
+
+func TestShowStatus(t *testing.T) {
+	db := newTestDB(t)
+	addTestJob(t, db, "send-mail", "test", "")
+	addTestJob(t, db, "send-mail", "test", "smtp timeout")
+	addTestJob(t, db, "render-pdf", "other", "")
+
+	table := runOutput(t, db, formatTable, "status")
+	assert.True(t, strings.HasPrefix(table, "3 jobs, 0 job bundles\n\n"))
+	assert.Regexp(t, `(?m)^\s+PENDING\s+DELAYED\s+RUNNING\s+RETRYING\s+FAILED\s+CANCELLED\s+SUCCEEDED\s+OLDEST PENDING\s+OLDEST HEARTBEAT$`, table)
+	assert.Regexp(t, `(?m)^all jobs\s+2\s+0\s+0\s+0\s+1\s+0\s+0\s`, table)
+	assert.Regexp(t, `(?m)^type send-mail\s+1\s+0\s+0\s+0\s+1\s`, table)
+	assert.Regexp(t, `(?m)^origin other\s+1\s`, table)
+
+	var status jobqueue.Status
+	require.NoError(t, json.Unmarshal([]byte(runOutput(t, db, formatJSON, "status")), &status))
+	assert.Equal(t, 3, status.NumJobs)
+	assert.Equal(t, 1, status.ByType["send-mail"].Failed)
+}
+
+func TestConnectAndRunValidatesFlags(t *testing.T) {
+	assert.ErrorContains(t, connectAndRun(t.Context(), "postgres://localhost/db", "yaml", []string{"status"}), "invalid output format")
+	assert.ErrorContains(t, connectAndRun(t.Context(), "", formatTable, []string{"status"}), "no database URL")
+}
+
+func getJob(t *testing.T, db jobworker.DataBase, jobID uu.ID) *jobqueue.Job {
+	t.Helper()
+	job, err := db.GetJob(t.Context(), jobID)
+	require.NoError(t, err)
+	return job
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// Output formats of the -o flag.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// maxTableErrorLen is the maximum length of an error
+// message in a table of jobs, longer ones are truncated.
+const maxTableErrorLen = 60
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable writes rows of tab separated cells as aligned columns.
+func (c *cli) printTable(rows ...[]string) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		_, err := fmt.Fprintln(w, strings.Join(row, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJobs(jobs []*jobqueue.Job) error {
+	if c.format == formatJSON {
+		if jobs == nil {
+			jobs = []*jobqueue.Job{} // [] instead of null
+		}
+		return c.printJSON(jobs)
+	}
+	now := time.Now()
+	rows := [][]string{{"ID", "TYPE", "ORIGIN", "STATE", "PRIORITY", "RETRIES", "CREATED", "START AT", "STOPPED", "ERROR"}}
+	for _, job := range jobs {
+		rows = append(rows, []string{
+			job.ID.String(),
+			job.Type,
+			job.Origin,
+			string(job.State(now)),
+			fmt.Sprint(job.Priority),
+			fmt.Sprintf("%d/%d", job.CurrentRetryCount, job.MaxRetryCount),
+			formatTime(job.CreatedAt),
+			formatNullableTime(job.StartAt),
+			formatNullableTime(job.StoppedAt),
+			truncate(job.ErrorMsg.String(), maxTableErrorLen),
+		})
+	}
+	return c.printTable(rows...)
+}
+
+// jobWithAttempts is the JSON output of the show command.
+type jobWithAttempts struct {
+	Job      *jobqueue.Job          `json:"job"`
+	Attempts []*jobqueue.JobAttempt `json:"attempts"`
+}
+
+func (c *cli) printJob(job *jobqueue.Job, attempts []*jobqueue.JobAttempt) error {
+	if c.format == formatJSON {
+		if attempts == nil {
+			attempts = []*jobqueue.JobAttempt{}
+		}
+		return c.printJSON(jobWithAttempts{Job: job, Attempts: attempts})
+	}
+	err := c.printTable(
+		[]string{"ID:", job.ID.String()},
+		[]string{"Bundle:", formatNullableID(job.BundleID)},
+		[]string{"Type:", job.Type},
+		[]string{"Origin:", job.Origin},
+		[]string{"State:", string(job.State(time.Now()))},
+		[]string{"Priority:", fmt.Sprint(job.Priority)},
+		[]string{"Retries:", fmt.Sprintf("%d/%d", job.CurrentRetryCount, job.MaxRetryCount)},
+		[]string{"Unique key:", orDash(job.UniqueKey.String())},
+		[]string{"Schedule:", orDash(job.ScheduleName.String())},
+		[]string{"Depends on:", orDash(formatIDs(job.DependsOn))},
+		[]string{"Trace parent:", orDash(job.TraceContext.TraceParent)},
+		[]string{"Created:", formatTime(job.CreatedAt)},
+		[]string{"Start at:", formatNullableTime(job.StartAt)},
+		[]string{"Started:", formatNullableTime(job.StartedAt)},
+		[]string{"Worker alive:", formatNullableTime(job.WorkerAliveAt)},
+		[]string{"Stopped:", formatNullableTime(job.StoppedAt)},
+		[]string{"Cancel requested:", formatNullableTime(job.CancelRequestedAt)},
+		[]string{"Cancelled:", formatNullableTime(job.CancelledAt)},
+		[]string{"Error:", orDash(job.ErrorMsg.String())},
+	)
+	if err != nil {
+		return err
+	}
+	for _, section := range []struct {
+		name string
+		json []byte
+	}{
+		{"Payload", job.Payload},
+		{"Result", job.Result},
+		{"Error data", job.ErrorData},
+	} {
+		if nullable.JSON(section.json).IsNull() {
+			continue
+		}
+		_, err = fmt.Fprintf(c.out, "\n%s:\n%s\n", section.name, indentJSON(section.json))
+		if err != nil {
+			return err
+		}
+	}
+	if len(attempts) == 0 {
+		return nil
+	}
+	_, err = fmt.Fprintln(c.out, "\nAttempts:")
+	if err != nil {
+		return err
+	}
+	rows := [][]string{{"ATTEMPT", "OUTCOME", "STARTED", "DURATION", "WORKER", "ERROR"}}
+	for _, attempt := range attempts {
+		rows = append(rows, []string{
+			fmt.Sprint(attempt.Attempt),
+			string(attempt.Outcome),
+			formatTime(attempt.StartedAt),
+			attempt.Duration().String(),
+			attempt.Worker,
+			truncate(attempt.ErrorMsg.String(), maxTableErrorLen),
+		})
+	}
+	return c.printTable(rows...)
+}
+
+func (c *cli) printJobBundle(jobBundle *jobqueue.JobBundle) error {
+	if c.format == formatJSON {
+		return c.printJSON(jobBundle)
+	}
+	err := c.printTable(
+		[]string{"ID:", jobBundle.ID.String()},
+		[]string{"Type:", jobBundle.Type},
+		[]string{"Origin:", jobBundle.Origin},
+		[]string{"Jobs stopped:", fmt.Sprintf("%d/%d", jobBundle.NumJobsStopped, jobBundle.NumJobs)},
+		[]string{"Created:", formatTime(jobBundle.CreatedAt)},
+		[]string{"Updated:", formatTime(jobBundle.UpdatedAt)},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.out)
+	if err != nil {
+		return err
+	}
+	return c.printJobs(jobBundle.Jobs)
+}
+
+func (c *cli) printStatus(status *jobqueue.Status) error {
+	if c.format == formatJSON {
+		return c.printJSON(status)
+	}
+	_, err := fmt.Fprintf(c.out, "%d jobs, %d job bundles\n\n", status.NumJobs, status.NumJobBundles)
+	if err != nil {
+		return err
+	}
+	header := []string{""}
+	for _, state := range jobqueue.JobStates {
+		header = append(header, strings.ToUpper(string(state)))
+	}
+	header = append(header, "OLDEST PENDING", "OLDEST HEARTBEAT")
+	statsRow := func(name string, stats jobqueue.JobStats) []string {
+		row := []string{name}
+		for _, state := range jobqueue.JobStates {
+			row = append(row, fmt.Sprint(stats.Count(state)))
+		}
+		return append(row, formatAge(stats.OldestPendingAge), formatAge(stats.OldestHeartbeatAge))
+	}
+	rows := [][]string{header, statsRow("all jobs", status.Jobs)}
+	for _, byKey := range []struct {
+		prefix string
+		stats  map[string]jobqueue.JobStats
+	}{
+		{"type ", status.ByType},
+		{"origin ", status.ByOrigin},
+	} {
+		for _, key := range sortedKeys(byKey.stats) {
+			rows = append(rows, statsRow(byKey.prefix+key, byKey.stats[key]))
+		}
+	}
+	return c.printTable(rows...)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func formatNullableTime(t nullable.Time) string {
+	if t.IsNull() {
+		return "-"
+	}
+	return formatTime(t.Get())
+}
+
+func formatNullableID(id uu.NullableID) string {
+	if id.IsNull() {
+		return "-"
+	}
+	return id.Get().String()
+}
+
+func formatIDs(ids uu.IDs) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatAge(age time.Duration) string {
+	if age == 0 {
+		return "-"
+	}
+	return age.Round(time.Second).String()
+}
+
+// truncate returns the first line of s shortened
+// to maxLen runes including a trailing ellipsis.
+func truncate(s string, maxLen int) string {
+	s, _, _ = strings.Cut(s, "\n")
+	if runes := []rune(s); len(runes) > maxLen {
+		return string(runes[:maxLen-1]) + "…"
+	}
+	return s
+}
+
+// indentJSON returns data indented for humans
+// or unchanged if it is not valid JSON.
+func indentJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if json.Indent(&buf, data, "", "  ") != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,309 @@
+// Package cron parses cron expressions and calculates their next fire times.
+//
+// It is used by jobworker.RegisterSchedule and has no dependencies
+// outside the standard library.
+//
+// An expression consists of five fields separated by whitespace:
+//
+//	┌───────────── minute (0-59)
+//	│ ┌─────────── hour (0-23)
+//	│ │ ┌───────── day of month (1-31)
+//	│ │ │ ┌─────── month (1-12 or JAN-DEC)
+//	│ │ │ │ ┌───── day of week (0-7 or SUN-SAT, 0 and 7 are Sunday)
+//	│ │ │ │ │
+//	* * * * *
+//
+// Every field is a comma separated list of values, ranges like 1-5,
+// and steps like */15 or 10-40/10. A step after a single value like 5/15
+// runs from that value to the maximum of the field.
+// In the day of month and day of week fields ? is an alias for *.
+// If both day fields are restricted, a day matches if either of them matches,
+// like in the classic cron implementations.
+//
+// The macros @yearly (or @annually), @monthly, @weekly, @daily (or @midnight),
+// and @hourly can be used instead of the five fields.
+package cron
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression is a parsed cron expression.
+type Expression struct {
+	source string
+
+	minute, hour, dayOfMonth, month, dayOfWeek uint64 // bit sets of the matching values
+
+	// If one of the day fields is * then both have to match,
+	// else one of them, see dayMatches.
+	dayOfMonthStar, dayOfWeekStar bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string // optional names for the values starting at min
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dayOfWeekField  = field{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// Parse parses a cron expression, see the package documentation for the syntax.
+func Parse(expr string) (*Expression, error) {
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron macro %q", spec)
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expr, len(fields))
+	}
+
+	e := &Expression{source: expr}
+	var err error
+	if e.minute, _, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if e.hour, _, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if e.dayOfMonth, e.dayOfMonthStar, err = dayOfMonthField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if e.month, _, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if e.dayOfWeek, e.dayOfWeekStar, err = dayOfWeekField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	// 7 is an alias for Sunday
+	if e.dayOfWeek&(1<<7) != 0 {
+		e.dayOfWeek = e.dayOfWeek&^(1<<7) | 1
+	}
+	return e, nil
+}
+
+// MustParse is like Parse but panics on an error.
+func MustParse(expr string) *Expression {
+	e, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String returns the expression as it was passed to Parse.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// parse returns the bit set of the values of the field
+// and if the field is a * (or ?) without a step.
+func (f *field) parse(s string) (set uint64, star bool, err error) {
+	for part := range strings.SplitSeq(s, ",") {
+		partSet, partStar, err := f.parsePart(part)
+		if err != nil {
+			return 0, false, err
+		}
+		set |= partSet
+		star = star || partStar
+	}
+	return set, star, nil
+}
+
+func (f *field) parsePart(s string) (set uint64, star bool, err error) {
+	rangeStr, stepStr, hasStep := strings.Cut(s, "/")
+	step := 1
+	if hasStep {
+		step, err = strconv.Atoi(stepStr)
+		if err != nil || step <= 0 {
+			return 0, false, fmt.Errorf("invalid %s step %q", f.name, stepStr)
+		}
+	}
+
+	var first, last int
+	switch {
+	case rangeStr == "*" || (rangeStr == "?" && (f == &dayOfMonthField || f == &dayOfWeekField)):
+		first, last = f.min, f.max
+		if f == &dayOfWeekField {
+			last = 6 // don't add Sunday twice
+		}
+		star = !hasStep
+
+	case strings.Contains(rangeStr, "-"):
+		firstStr, lastStr, _ := strings.Cut(rangeStr, "-")
+		if first, err = f.value(firstStr); err != nil {
+			return 0, false, err
+		}
+		if last, err = f.value(lastStr); err != nil {
+			return 0, false, err
+		}
+		if first > last {
+			return 0, false, fmt.Errorf("invalid %s range %q", f.name, rangeStr)
+		}
+
+	default:
+		if first, err = f.value(rangeStr); err != nil {
+			return 0, false, err
+		}
+		last = first
+		if hasStep {
+			last = f.max
+		}
+	}
+
+	for v := first; v <= last; v += step {
+		set |= 1 << v
+	}
+	return set, star, nil
+}
+
+func (f *field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s value %q, must be in range [%d, %d]", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
+
+func (e *Expression) dayMatches(t time.Time) bool {
+	dayOfMonth := has(e.dayOfMonth, t.Day())
+	dayOfWeek := has(e.dayOfWeek, int(t.Weekday()))
+	if e.dayOfMonthStar || e.dayOfWeekStar {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first fire time of the expression after t
+// in the location of t, or the zero time if there is none
+// within the next five years (for example for February 30).
+//
+// Local times that don't exist because of a daylight saving time
+// transition are skipped.
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// Truncate works on the absolute time, unlike time.Date
+	// it keeps the offset of an ambiguous local time
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if !has(e.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(e.hour, t.Hour()) {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// Normalized back into the same hour by a DST transition
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if !has(e.minute, t.Minute()) {
+			// Jump directly to the next matching minute of the hour
+			// or to the start of the next hour
+			rest := e.minute >> (t.Minute() + 1) << (t.Minute() + 1)
+			if rest == 0 {
+				t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			} else {
+				t = t.Add(time.Duration(bits.TrailingZeros64(rest)-t.Minute()) * time.Minute)
+			}
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Between returns the fire times of the expression after from
+// and before or equal to until, in the location of from.
+// At most max fire times are returned, the latest ones if there are more.
+//
+// Only the occurrences in a window before until are calculated.
+// The window starts with max minutes, the shortest possible period,
+// and is doubled until it contains max fire times or reaches from,
+// so a from long before until doesn't walk all occurrences since then.
+func (e *Expression) Between(from, until time.Time, max int) []time.Time {
+	if max <= 0 || !until.After(from) {
+		return nil
+	}
+	span := until.Sub(from)
+	window := min(time.Duration(max)*time.Minute, span)
+	for {
+		start := from
+		if window < span {
+			start = until.Add(-window).In(from.Location())
+		}
+		times, complete := e.latestBetween(start, until, max)
+		if complete || window == span {
+			return times
+		}
+		if window > span/2 {
+			window = span
+		} else {
+			window *= 2
+		}
+	}
+}
+
+// latestBetween returns the latest max fire times after from
+// and before or equal to until.
+// complete is true if there are max or more fire times.
+func (e *Expression) latestBetween(from, until time.Time, max int) (times []time.Time, complete bool) {
+	// Ring buffer of the latest fire times, the oldest one is at times[n%max]
+	times = make([]time.Time, 0, max)
+	n := 0
+	for t := e.Next(from); !t.IsZero() && !t.After(until); t = e.Next(t) {
+		if len(times) < max {
+			times = append(times, t)
+		} else {
+			times[n%max] = t
+		}
+		n++
+	}
+	if n <= max {
+		return times, n == max
+	}
+	return slices.Concat(times[n%max:], times[:n%max]), true
+}
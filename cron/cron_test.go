@@ -0,0 +1,134 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue/cron"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"? * * * *",
+		"* * * FOO *",
+		"@every-minute",
+	} {
+		_, err := cron.Parse(expr)
+		assert.Error(t, err, "expression %q", expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-01-01T00:00:00Z", "2024-01-01T00:01:00Z"},
+		{"* * * * *", "2024-01-01T00:00:30Z", "2024-01-01T00:01:00Z"},
+		{"*/5 * * * *", "2024-01-01T00:03:00Z", "2024-01-01T00:05:00Z"},
+		{"*/5 * * * *", "2024-01-01T00:55:00Z", "2024-01-01T01:00:00Z"},
+		{"10-40/10 * * * *", "2024-01-01T00:35:00Z", "2024-01-01T00:40:00Z"},
+		{"10-40/10 * * * *", "2024-01-01T00:40:00Z", "2024-01-01T01:10:00Z"},
+		{"5/20 * * * *", "2024-01-01T00:46:00Z", "2024-01-01T01:05:00Z"},
+		{"0,30 9-17 * * *", "2024-01-01T17:30:00Z", "2024-01-02T09:00:00Z"},
+		{"0 0 * * *", "2024-02-28T12:00:00Z", "2024-02-29T00:00:00Z"},
+		{"0 0 1 * *", "2024-01-31T00:00:00Z", "2024-02-01T00:00:00Z"},
+		{"0 0 31 * *", "2024-04-01T00:00:00Z", "2024-05-31T00:00:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 12 * * MON-FRI", "2024-01-05T12:00:00Z", "2024-01-08T12:00:00Z"}, // Friday to Monday
+		{"0 12 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"},       // 7 is Sunday
+		{"0 12 * * sun", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"},
+		{"0 0 * jun-aug *", "2024-01-01T00:00:00Z", "2024-06-01T00:00:00Z"},
+		{"0 0 ? * 1", "2024-01-01T00:00:00Z", "2024-01-08T00:00:00Z"},
+		// Both day fields restricted: either matches
+		{"0 0 15 * FRI", "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"},
+		{"0 0 15 * FRI", "2024-01-12T00:00:00Z", "2024-01-15T00:00:00Z"},
+		// Day of week with a step is restricted
+		{"0 0 1 * */7", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},
+		{"@yearly", "2024-06-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"@monthly", "2024-06-15T00:00:00Z", "2024-07-01T00:00:00Z"},
+		{"@weekly", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},
+		{"@daily", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z"},
+		{"@Hourly", "2024-01-01T00:59:59Z", "2024-01-01T01:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" from "+tt.from, func(t *testing.T) {
+			expr, err := cron.Parse(tt.expr)
+			require.NoError(t, err)
+			from, err := time.Parse(time.RFC3339, tt.from)
+			require.NoError(t, err)
+			want, err := time.Parse(time.RFC3339, tt.want)
+			require.NoError(t, err)
+			assert.Equal(t, want, expr.Next(from))
+		})
+	}
+}
+
+func TestNextImpossible(t *testing.T) {
+	expr := cron.MustParse("0 0 30 2 *")
+	assert.True(t, expr.Next(time.Now()).IsZero())
+}
+
+func TestNextTimezone(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	require.NoError(t, err)
+
+	expr := cron.MustParse("0 2 * * *")
+	// 02:00 does not exist on 2024-03-31 in Vienna, clocks jump from 02:00 to 03:00
+	next := expr.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, vienna))
+	assert.Equal(t, time.Date(2024, 4, 1, 2, 0, 0, 0, vienna), next)
+
+	expr = cron.MustParse("30 * * * *")
+	// 02:30 exists twice on 2024-10-27 in Vienna
+	first := expr.Next(time.Date(2024, 10, 27, 1, 45, 0, 0, vienna))
+	assert.Equal(t, "2024-10-27T02:30:00+02:00", first.Format(time.RFC3339))
+	second := expr.Next(first)
+	assert.Equal(t, "2024-10-27T02:30:00+01:00", second.Format(time.RFC3339))
+	assert.Equal(t, time.Hour, second.Sub(first))
+}
+
+func TestBetween(t *testing.T) {
+	expr := cron.MustParse("0 * * * *")
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	times := expr.Between(from, from.Add(3*time.Hour), 10)
+	assert.Equal(t, []time.Time{from.Add(time.Hour), from.Add(2 * time.Hour), from.Add(3 * time.Hour)}, times)
+
+	times = expr.Between(from, from.Add(3*time.Hour), 2)
+	assert.Equal(t, []time.Time{from.Add(2 * time.Hour), from.Add(3 * time.Hour)}, times, "latest fire times")
+
+	assert.Empty(t, expr.Between(from, from.Add(time.Minute), 10))
+
+	// Only the latest occurrences are calculated, not the ones since from
+	everyMinute := cron.MustParse("* * * * *")
+	until := from.AddDate(100, 0, 0)
+	times = everyMinute.Between(from, until, 3)
+	assert.Equal(t, []time.Time{until.Add(-2 * time.Minute), until.Add(-time.Minute), until}, times)
+
+	// The window is extended for sparse expressions
+	yearly := cron.MustParse("@yearly")
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	require.NoError(t, err)
+	times = yearly.Between(from.In(vienna), until, 2)
+	assert.Equal(t, []time.Time{
+		time.Date(2123, 1, 1, 0, 0, 0, 0, vienna),
+		time.Date(2124, 1, 1, 0, 0, 0, 0, vienna),
+	}, times, "in the location of from")
+	assert.Len(t, yearly.Between(from, until, 1000), 100, "all occurrences since from")
+}
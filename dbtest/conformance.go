@@ -0,0 +1,1985 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// NotificationTimeout is how long the suite waits for
+// an asynchronously delivered notification.
+var NotificationTimeout = 10 * time.Second
+
+// RunConformance runs the conformance test suite as sub-tests of t
+// against the jobworker.DataBase implementation returned by newDB.
+//
+// newDB is called once per sub-test and must return a ready to use DataBase.
+// It should register a t.Cleanup function that closes the returned DataBase.
+// The suite closes it itself only in the sub-test for the closed behavior.
+func RunConformance(t *testing.T, newDB func(t *testing.T) jobworker.DataBase) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(*testing.T, *fixture)
+	}{
+		{"ClaimOrder", testClaimOrder},
+		{"ClaimStartAt", testClaimStartAt},
+		{"NextJobStartDelay", testNextJobStartDelay},
+		{"ConcurrentClaims", testConcurrentClaims},
+		{"ClaimSkipJobTypes", testClaimSkipJobTypes},
+		{"ClusterMaxConcurrency", testClusterMaxConcurrency},
+		{"RateLimit", testRateLimit},
+		{"ClaimJobs", testClaimJobs},
+		{"ClaimJobsLimit", testClaimJobsLimit},
+		{"ClaimJobsMixedLimits", testClaimJobsMixedLimits},
+		{"UnclaimJobs", testUnclaimJobs},
+		{"SetJobResult", testSetJobResult},
+		{"SetJobErrorClampsRetryCount", testSetJobErrorClampsRetryCount},
+		{"ScheduleRetry", testScheduleRetry},
+		{"SnoozeJob", testSnoozeJob},
+		{"SetJobStart", testSetJobStart},
+		{"ResetJob", testResetJob},
+		{"JobAttempts", testJobAttempts},
+		{"SetJobWorkerAlive", testSetJobWorkerAlive},
+		{"WorkerHeartbeat", testWorkerHeartbeat},
+		{"WorkerHeartbeatDisabled", testWorkerHeartbeatDisabled},
+		{"RetrySchedulerHeartbeat", testRetrySchedulerHeartbeat},
+		{"CancelJobNotStarted", testCancelJobNotStarted},
+		{"CancelJobRunning", testCancelJobRunning},
+		{"CancelJobRunningSucceeded", testCancelJobRunningSucceeded},
+		{"JobBundleCompletion", testJobBundleCompletion},
+		{"JobBundleReset", testJobBundleReset},
+		{"DependencyBlocksClaim", testDependencyBlocksClaim},
+		{"DependencyFailureBlock", testDependencyFailureBlock},
+		{"DependencyFailureRun", testDependencyFailureRun},
+		{"DependencyFailureCancel", testDependencyFailureCancel},
+		{"DependencyConstraints", testDependencyConstraints},
+		{"DeleteDependency", testDeleteDependency},
+		{"UniqueKey", testUniqueKey},
+		{"AddJobs", testAddJobs},
+		{"TraceContext", testTraceContext},
+		{"JobSchedule", testJobSchedule},
+		{"JobAvailableListener", testJobAvailableListener},
+		{"GetJobNotFound", testGetJobNotFound},
+		{"QueryMethods", testQueryMethods},
+		{"GetJobs", testGetJobs},
+		{"Status", testStatus},
+		{"DeleteMethods", testDeleteMethods},
+		{"Close", testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newFixture(t, newDB))
+		})
+	}
+}
+
+// fixture is the per sub-test state: the DataBase under test,
+// a Pool with a worker for a job type unique to the sub-test
+// that claims the jobs, and an origin unique to the sub-test.
+type fixture struct {
+	db      jobworker.DataBase
+	pool    *jobworker.Pool
+	jobType string
+	origin  string
+}
+
+func newFixture(t *testing.T, newDB func(t *testing.T) jobworker.DataBase) *fixture {
+	t.Helper()
+
+	db := newDB(t)
+	require.NotNil(t, db, "newDB must return a DataBase")
+
+	suffix := uu.NewID(t.Context()).String()
+	f := &fixture{
+		db:      db,
+		pool:    jobworker.NewPool(db),
+		jobType: "dbtest-job-" + suffix,
+		origin:  "dbtest-" + suffix,
+	}
+	f.pool.Register(f.jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+
+	// Registered after newDB, so it runs before the cleanup of newDB closes db.
+	// Errors are ignored because the Close sub-test leaves a closed db behind.
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = db.DeleteJobBundlesFromOrigin(ctx, f.origin)
+		_ = db.DeleteJobsFromOrigin(ctx, f.origin)
+	})
+
+	return f
+}
+
+// addJob adds a job of the fixture's job type and origin.
+func (f *fixture) addJob(t *testing.T, priority int64, startAt nullable.Time, maxRetryCount int) *jobqueue.Job {
+	t.Helper()
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), f.jobType, f.origin, `{"test":true}`, priority, startAt, maxRetryCount)
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJob(t.Context(), job))
+	return job
+}
+
+// addDependentJob adds a job depending on the jobs with dependsOn.
+func (f *fixture) addDependentJob(t *testing.T, priority int64, policy jobqueue.DependencyFailurePolicy, dependsOn ...uu.ID) *jobqueue.Job {
+	t.Helper()
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), f.jobType, f.origin, `{"test":true}`, priority, nullable.Time{})
+	require.NoError(t, err)
+	job.DependsOn = dependsOn
+	job.OnDependencyFailure = policy
+	require.NoError(t, f.db.AddJob(t.Context(), job))
+	return job
+}
+
+// getJob loads a job that must exist.
+func (f *fixture) getJob(t *testing.T, jobID uu.ID) *jobqueue.Job {
+	t.Helper()
+	job, err := f.db.GetJob(t.Context(), jobID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	return job
+}
+
+// claim calls StartNextJobOrNil once and fails the test on error.
+func (f *fixture) claim(t *testing.T) *jobqueue.Job {
+	t.Helper()
+	job, err := f.db.StartNextJobOrNil(t.Context(), f.pool.Claim())
+	require.NoError(t, err)
+	if job != nil {
+		require.Equal(t, f.jobType, job.Type, "only registered job types may be claimed")
+	}
+	return job
+}
+
+// claimJob claims the next job and requires it to be the job with jobID.
+func (f *fixture) claimJob(t *testing.T, jobID uu.ID) *jobqueue.Job {
+	t.Helper()
+	job := f.claim(t)
+	require.NotNil(t, job, "expected job %s to be claimable", jobID)
+	require.Equal(t, jobID, job.ID)
+	return job
+}
+
+// startThreads starts a worker thread of pool
+// and finishes it at the end of the test.
+// Register a t.Cleanup function releasing a blocked worker
+// after calling startThreads, so that it runs first.
+func startThreads(t *testing.T, pool *jobworker.Pool) {
+	t.Helper()
+	require.NoError(t, pool.StartThreads(t.Context(), 1))
+	t.Cleanup(func() { pool.FinishThreads(context.Background()) })
+}
+
+// listener records the ServiceListener notifications for the fixture's origin.
+type listener struct {
+	origin         string
+	mtx            sync.Mutex
+	jobsStopped    map[uu.ID][]bool // willRetry of every notification per job
+	bundlesStopped map[uu.ID]int
+}
+
+func (f *fixture) addListener(t *testing.T) *listener {
+	t.Helper()
+	l := &listener{
+		origin:         f.origin,
+		jobsStopped:    make(map[uu.ID][]bool),
+		bundlesStopped: make(map[uu.ID]int),
+	}
+	require.NoError(t, f.db.AddListener(t.Context(), l))
+	return l
+}
+
+func (l *listener) OnJobStopped(ctx context.Context, jobID uu.ID, jobType, jobOrigin string, willRetry bool) {
+	if jobOrigin != l.origin {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.jobsStopped[jobID] = append(l.jobsStopped[jobID], willRetry)
+}
+
+func (l *listener) OnJobBundleStopped(ctx context.Context, jobBundleID uu.ID, jobBundleType, jobBundleOrigin string) {
+	if jobBundleOrigin != l.origin {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.bundlesStopped[jobBundleID]++
+}
+
+func (l *listener) jobStopped(jobID uu.ID) []bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return slices.Clone(l.jobsStopped[jobID])
+}
+
+func (l *listener) bundleStopped(jobBundleID uu.ID) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.bundlesStopped[jobBundleID]
+}
+
+func eventually(t *testing.T, condition func() bool, msgAndArgs ...any) {
+	t.Helper()
+	require.Eventually(t, condition, NotificationTimeout, 10*time.Millisecond, msgAndArgs...)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Tests
+
+// testClaimOrder checks that jobs are claimed by priority descending, then by
+// creation time ascending, and that a claim marks the job as started.
+func testClaimOrder(t *testing.T, f *fixture) {
+	low := f.addJob(t, 0, nullable.Time{}, 0)
+	high := f.addJob(t, 10, nullable.Time{}, 0)
+	lowSecond := f.addJob(t, 0, nullable.Time{}, 0)
+	negative := f.addJob(t, -5, nullable.Time{}, 0)
+	highSecond := f.addJob(t, 10, nullable.Time{}, 0)
+
+	for _, expected := range []*jobqueue.Job{high, highSecond, low, lowSecond, negative} {
+		job := f.claimJob(t, expected.ID)
+		assert.True(t, job.Started(), "claimed job is started")
+		assert.False(t, job.Stopped(), "claimed job is not stopped")
+		assert.Equal(t, f.pool.Claim().Heartbeat, job.WorkerAliveAt.IsNotNull(),
+			"worker_alive_at is set by the claim if heartbeats are enabled")
+
+		loaded := f.getJob(t, expected.ID)
+		assert.True(t, loaded.StartedAndNotStopped(), "claim is persisted")
+	}
+	assert.Nil(t, f.claim(t), "a started job must not be claimed again")
+}
+
+// testClaimStartAt checks that a job is not claimed before its start_at.
+func testClaimStartAt(t *testing.T, f *fixture) {
+	future := f.addJob(t, 100, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)
+	past := f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(-time.Hour)), 0)
+
+	f.claimJob(t, past.ID)
+	assert.Nil(t, f.claim(t), "job with start_at in the future must not be claimed")
+	assert.False(t, f.getJob(t, future.ID).Started())
+}
+
+// testNextJobStartDelay checks that GetNextJobStartDelay returns the delay
+// until the earliest start_at in the future of a claimable job.
+func testNextJobStartDelay(t *testing.T, f *fixture) {
+	nextDelay := func() (time.Duration, bool) {
+		t.Helper()
+		delay, ok, err := f.db.GetNextJobStartDelay(t.Context(), f.pool.Claim())
+		require.NoError(t, err)
+		return delay, ok
+	}
+
+	_, ok := nextDelay()
+	assert.False(t, ok, "no delayed job")
+
+	f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(-time.Hour)), 0)
+	_, ok = nextDelay()
+	assert.False(t, ok, "start_at in the past is not delayed")
+
+	// Jobs of types without a registered worker are not claimed
+	unregistered, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType+"-unregistered", f.origin, `{}`, nullable.TimeFrom(time.Now().Add(time.Minute)))
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJob(t.Context(), unregistered))
+	_, ok = nextDelay()
+	assert.False(t, ok, "job type not registered")
+
+	f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)
+	delay, ok := nextDelay()
+	require.True(t, ok)
+	assert.InDelta(t, time.Hour, delay, float64(time.Minute))
+
+	f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(10*time.Minute)), 0)
+	delay, ok = nextDelay()
+	require.True(t, ok)
+	assert.InDelta(t, 10*time.Minute, delay, float64(time.Minute), "earliest start_at")
+}
+
+// testConcurrentClaims checks that concurrent StartNextJobOrNil calls
+// never claim the same job twice and together claim every job.
+func testConcurrentClaims(t *testing.T, f *fixture) {
+	const (
+		numJobs    = 50
+		numWorkers = 8
+	)
+	var jobIDs uu.IDs
+	for i := range numJobs {
+		jobIDs = append(jobIDs, f.addJob(t, int64(i%3), nullable.Time{}, 0).ID)
+	}
+
+	var (
+		claimedMtx sync.Mutex
+		claimed    = make(map[uu.ID]int)
+		wg         sync.WaitGroup
+		errCount   atomic.Int64
+	)
+	for range numWorkers {
+		wg.Go(func() {
+			for {
+				job, err := f.db.StartNextJobOrNil(t.Context(), f.pool.Claim())
+				if err != nil {
+					errCount.Add(1)
+					return
+				}
+				if job == nil {
+					return
+				}
+				claimedMtx.Lock()
+				claimed[job.ID]++
+				claimedMtx.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	require.Zero(t, errCount.Load(), "StartNextJobOrNil errors")
+	assert.Len(t, claimed, numJobs, "every job claimed")
+	for _, id := range jobIDs {
+		assert.Equal(t, 1, claimed[id], "job %s claimed exactly once", id)
+	}
+}
+
+// testClaimSkipJobTypes checks that StartNextJobOrNil
+// does not claim jobs of the passed skipJobTypes.
+func testClaimSkipJobTypes(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 0)
+
+	skipped, err := f.db.StartNextJobOrNil(t.Context(), f.pool.Claim(), f.jobType)
+	require.NoError(t, err)
+	assert.Nil(t, skipped, "job type skipped")
+
+	skipped, err = f.db.StartNextJobOrNil(t.Context(), f.pool.Claim(), "dbtest-other-type")
+	require.NoError(t, err)
+	require.NotNil(t, skipped, "other job type skipped")
+	assert.Equal(t, job.ID, skipped.ID)
+}
+
+// testClusterMaxConcurrency checks that StartNextJobOrNil doesn't claim
+// a job while the jobworker.Claim.ClusterMaxConcurrency limit of started
+// and not stopped jobs of its type is reached.
+func testClusterMaxConcurrency(t *testing.T, f *fixture) {
+	require.NoError(t, f.pool.SetClusterMaxConcurrency(f.jobType, 2))
+
+	first := f.addJob(t, 2, nullable.Time{}, 0)
+	second := f.addJob(t, 1, nullable.Time{}, 0)
+	third := f.addJob(t, 0, nullable.Time{}, 0)
+
+	f.claimJob(t, first.ID)
+	f.claimJob(t, second.ID)
+	assert.Nil(t, f.claim(t), "limit of 2 running jobs reached")
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), first.ID, nil))
+	f.claimJob(t, third.ID)
+}
+
+// testRateLimit checks that StartNextJobOrNil doesn't claim a job while
+// the jobworker.Claim.RateLimits limit of its type is reached in the current window,
+// and that GetNextJobStartDelay returns the end of the window.
+func testRateLimit(t *testing.T, f *fixture) {
+	const window = 2 * time.Second
+	require.NoError(t, f.pool.SetRateLimit(f.jobType, 2, window))
+
+	first := f.addJob(t, 2, nullable.Time{}, 0)
+	second := f.addJob(t, 1, nullable.Time{}, 0)
+	third := f.addJob(t, 0, nullable.Time{}, 0)
+
+	f.claimJob(t, first.ID)
+	// Stopped jobs still count in the window
+	require.NoError(t, f.db.SetJobResult(t.Context(), first.ID, nil))
+	f.claimJob(t, second.ID)
+	assert.Nil(t, f.claim(t), "limit of 2 jobs per window reached")
+
+	delay, ok, err := f.db.GetNextJobStartDelay(t.Context(), f.pool.Claim())
+	require.NoError(t, err)
+	require.True(t, ok, "end of the window")
+	assert.LessOrEqual(t, delay, window)
+
+	var claimed *jobqueue.Job
+	eventually(t, func() bool {
+		claimed = f.claim(t)
+		return claimed != nil
+	}, "claimable after the window ended")
+	assert.Equal(t, third.ID, claimed.ID)
+}
+
+// testClaimJobs checks that StartNextJobsOrNil claims
+// up to n jobs in the order of StartNextJobOrNil.
+func testClaimJobs(t *testing.T, f *fixture) {
+	claimJobs := func(n int) []*jobqueue.Job {
+		t.Helper()
+		jobs, err := f.db.StartNextJobsOrNil(t.Context(), f.pool.Claim(), n)
+		require.NoError(t, err)
+		return jobs
+	}
+
+	low := f.addJob(t, 0, nullable.Time{}, 0)
+	high := f.addJob(t, 2, nullable.Time{}, 0)
+	mid := f.addJob(t, 1, nullable.Time{}, 0)
+	future := f.addJob(t, 3, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)
+
+	jobs := claimJobs(2)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, high.ID, jobs[0].ID, "highest priority first")
+	assert.Equal(t, mid.ID, jobs[1].ID)
+	for _, job := range jobs {
+		assert.Equal(t, f.jobType, job.Type)
+		assert.True(t, f.getJob(t, job.ID).StartedAndNotStopped(), "claim is persisted")
+	}
+
+	jobs = claimJobs(10)
+	require.Len(t, jobs, 1, "fewer jobs available than requested")
+	assert.Equal(t, low.ID, jobs[0].ID)
+
+	assert.Empty(t, claimJobs(10), "no job available")
+	assert.False(t, f.getJob(t, future.ID).Started(), "job with start_at in the future must not be claimed")
+
+	skipped, err := f.db.StartNextJobsOrNil(t.Context(), f.pool.Claim(), 10, f.jobType)
+	require.NoError(t, err)
+	assert.Empty(t, skipped, "job type skipped")
+}
+
+// testClaimJobsLimit checks that StartNextJobsOrNil doesn't claim
+// more jobs than the jobworker.Claim.ClusterMaxConcurrency limit allows.
+func testClaimJobsLimit(t *testing.T, f *fixture) {
+	require.NoError(t, f.pool.SetClusterMaxConcurrency(f.jobType, 2))
+	for range 4 {
+		f.addJob(t, 0, nullable.Time{}, 0)
+	}
+
+	var claimed int
+	for range 4 {
+		jobs, err := f.db.StartNextJobsOrNil(t.Context(), f.pool.Claim(), 4)
+		require.NoError(t, err)
+		claimed += len(jobs)
+	}
+	assert.Equal(t, 2, claimed, "limit of 2 running jobs")
+}
+
+// testClaimJobsMixedLimits checks that a limited job type doesn't stop
+// StartNextJobsOrNil from claiming several jobs of other job types,
+// and that jobs of other types are claimed while its limit is reached.
+func testClaimJobsMixedLimits(t *testing.T, f *fixture) {
+	limitedType := f.jobType + "-limited"
+	f.pool.Register(limitedType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	require.NoError(t, f.pool.SetClusterMaxConcurrency(limitedType, 1))
+	addLimitedJob := func(priority int64) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), limitedType, f.origin, `{"test":true}`, priority, nullable.Time{})
+		require.NoError(t, err)
+		require.NoError(t, f.db.AddJob(t.Context(), job))
+		return job
+	}
+	claimJobIDs := func() (ids uu.IDs) {
+		t.Helper()
+		jobs, err := f.db.StartNextJobsOrNil(t.Context(), f.pool.Claim(), 4)
+		require.NoError(t, err)
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	first := f.addJob(t, 3, nullable.Time{}, 0)
+	second := f.addJob(t, 2, nullable.Time{}, 0)
+	limited := addLimitedJob(1)
+	limitedOverLimit := addLimitedJob(1)
+	last := f.addJob(t, 0, nullable.Time{}, 0)
+
+	claimed := claimJobIDs()
+	require.GreaterOrEqual(t, len(claimed), 2, "jobs before the limited job claimed together")
+	assert.Equal(t, uu.IDs{first.ID, second.ID}, claimed[:2])
+	for ids := claimJobIDs(); len(ids) > 0; ids = claimJobIDs() {
+		claimed = append(claimed, ids...)
+	}
+	assert.ElementsMatch(t, uu.IDs{first.ID, second.ID, limited.ID, last.ID}, claimed, "other jobs claimed while the limit is reached")
+	assert.False(t, f.getJob(t, limitedOverLimit.ID).Started(), "limit of 1 running job")
+}
+
+// testUnclaimJobs checks that UnclaimJobs returns claimed jobs to the queue
+// without changing their retry count, cancellation request, or attempts,
+// and ignores jobs that are not running.
+func testUnclaimJobs(t *testing.T, f *fixture) {
+	var available atomic.Int64
+	require.NoError(t, f.db.SetJobAvailableListener(t.Context(), func() { available.Add(1) }))
+	t.Cleanup(func() { _ = f.db.SetJobAvailableListener(context.Background(), nil) })
+
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, time.Now().Add(-time.Second), 1, "dbtest retry", nil))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+	succeeded := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, succeeded.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeded.ID, nil))
+	waiting := f.addJob(t, 0, nullable.Time{}, 0)
+
+	available.Store(0)
+	require.NoError(t, f.db.UnclaimJobs(t.Context(), uu.IDs{job.ID, succeeded.ID, waiting.ID}))
+	eventually(t, func() bool { return available.Load() > 0 }, "job available callback")
+
+	loaded := f.getJob(t, job.ID)
+	assert.False(t, loaded.Started(), "unclaimed")
+	assert.True(t, loaded.WorkerAliveAt.IsNull())
+	assert.Equal(t, 1, loaded.CurrentRetryCount, "retry count kept")
+	assert.True(t, loaded.CancelRequestedAt.IsNotNull(), "cancellation request kept")
+	attempts, err := f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Len(t, attempts, 1, "no attempt recorded")
+
+	assert.True(t, f.getJob(t, succeeded.ID).Succeeded(), "stopped job ignored")
+	assert.False(t, f.getJob(t, waiting.ID).Started(), "not started job ignored")
+
+	claimed := f.claim(t)
+	require.NotNil(t, claimed)
+	assert.Contains(t, uu.IDs{job.ID, waiting.ID}, claimed.ID, "unclaimed job can be claimed again")
+}
+
+// testSetJobResult checks that a result stops the job successfully and that
+// an empty result is stored as an empty JSON object.
+func testSetJobResult(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	withResult := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, withResult.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), withResult.ID, nullable.JSON(`{"answer":42}`)))
+
+	loaded := f.getJob(t, withResult.ID)
+	assert.True(t, loaded.Succeeded())
+	assert.True(t, loaded.IsFinished())
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at is cleared on stop")
+	assert.JSONEq(t, `{"answer":42}`, string(loaded.Result))
+
+	withoutResult := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, withoutResult.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), withoutResult.ID, nil))
+
+	loaded = f.getJob(t, withoutResult.ID)
+	assert.True(t, loaded.Succeeded())
+	assert.JSONEq(t, `{}`, string(loaded.Result), "empty result is stored as {}")
+
+	eventually(t, func() bool {
+		return slices.Equal(l.jobStopped(withResult.ID), []bool{false}) &&
+			slices.Equal(l.jobStopped(withoutResult.ID), []bool{false})
+	}, "one job_stopped notification per job without retry")
+}
+
+// testSetJobErrorClampsRetryCount checks that SetJobError is terminal:
+// current_retry_count is clamped to max_retry_count even with retries left.
+func testSetJobErrorClampsRetryCount(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), job.ID, "dbtest error", nullable.JSON(`{"code":1}`)))
+
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.Stopped())
+	assert.True(t, loaded.HasError())
+	assert.True(t, loaded.IsFinished(), "SetJobError is terminal")
+	assert.False(t, loaded.Succeeded())
+	assert.Equal(t, "dbtest error", loaded.ErrorMsg.Get())
+	assert.JSONEq(t, `{"code":1}`, string(loaded.ErrorData))
+	assert.Equal(t, 3, loaded.MaxRetryCount)
+	assert.Equal(t, 3, loaded.CurrentRetryCount, "current_retry_count clamped to max_retry_count")
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at is cleared on stop")
+
+	eventually(t, func() bool {
+		return slices.Equal(l.jobStopped(job.ID), []bool{false})
+	}, "job_stopped notification without retry")
+}
+
+// testScheduleRetry checks that ScheduleRetry returns a job to the queue
+// with the passed retry count and start time, clearing its previous state.
+func testScheduleRetry(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+
+	retryAt := time.Now().Add(time.Hour)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, retryAt, 1, "error", nil))
+
+	loaded := f.getJob(t, job.ID)
+	assert.False(t, loaded.Started(), "started_at cleared")
+	assert.False(t, loaded.Stopped(), "stopped_at cleared")
+	assert.False(t, loaded.HasError(), "error_msg cleared")
+	assert.True(t, loaded.ErrorData.IsNull(), "error_data cleared")
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at cleared")
+	assert.Equal(t, 1, loaded.CurrentRetryCount)
+	require.True(t, loaded.StartAt.IsNotNull())
+	assert.WithinDuration(t, retryAt, loaded.StartAt.Get(), time.Millisecond)
+
+	assert.Nil(t, f.claim(t), "retry must not start before its start_at")
+
+	// A retry that is due is claimed again
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, time.Now().Add(-time.Second), 2, "error", nil))
+	claimed := f.claimJob(t, job.ID)
+	assert.Equal(t, 2, claimed.CurrentRetryCount)
+}
+
+// testSnoozeJob checks that SnoozeJob returns a job to the queue
+// with the passed start time, keeping its retry count,
+// and records the ended attempt as snoozed.
+func testSnoozeJob(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, time.Now().Add(-time.Second), 1, "error", nil))
+	f.claimJob(t, job.ID)
+
+	snoozeUntil := time.Now().Add(time.Hour)
+	require.NoError(t, f.db.SnoozeJob(t.Context(), job.ID, snoozeUntil))
+
+	loaded := f.getJob(t, job.ID)
+	assert.False(t, loaded.Started(), "started_at cleared")
+	assert.False(t, loaded.Stopped(), "stopped_at cleared")
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at cleared")
+	assert.Equal(t, 1, loaded.CurrentRetryCount, "retry count kept")
+	require.True(t, loaded.StartAt.IsNotNull())
+	assert.WithinDuration(t, snoozeUntil, loaded.StartAt.Get(), time.Millisecond)
+
+	assert.Nil(t, f.claim(t), "snoozed job must not start before its start_at")
+
+	attempts, err := f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, jobqueue.JobAttemptRetried, attempts[0].Outcome)
+	assert.Equal(t, jobqueue.JobAttemptSnoozed, attempts[1].Outcome)
+	assert.Equal(t, 1, attempts[1].RetryCount)
+	assert.True(t, attempts[1].ErrorMsg.IsNull())
+}
+
+// testSetJobStart checks that SetJobStart reschedules a stopped job
+// without changing its retry count, and that it handles the attempt
+// of a running job and the bundle counter like ResetJob.
+func testSetJobStart(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, time.Now().Add(-time.Second), 1, "error", nil))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), job.ID, "dbtest error", nil))
+
+	startAt := time.Now().Add(time.Hour)
+	require.NoError(t, f.db.SetJobStart(t.Context(), job.ID, startAt))
+
+	loaded := f.getJob(t, job.ID)
+	assert.False(t, loaded.Started())
+	assert.False(t, loaded.Stopped())
+	assert.False(t, loaded.HasError())
+	assert.Equal(t, 3, loaded.CurrentRetryCount, "SetJobStart keeps current_retry_count")
+	require.True(t, loaded.StartAt.IsNotNull())
+	assert.WithinDuration(t, startAt, loaded.StartAt.Get(), time.Millisecond)
+	assert.Nil(t, f.claim(t))
+
+	require.NoError(t, f.db.SetJobStart(t.Context(), job.ID, time.Now().Add(-time.Second)))
+	f.claimJob(t, job.ID)
+
+	// A running job's attempt ends as reset
+	require.NoError(t, f.db.SetJobStart(t.Context(), job.ID, time.Now().Add(-time.Second)))
+	attempts, err := f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, attempts)
+	assert.Equal(t, jobqueue.JobAttemptReset, attempts[len(attempts)-1].Outcome)
+
+	// A succeeded bundle job is uncounted in its bundle and can run again
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc}, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+	bundleJob := bundle.Jobs[0]
+	f.claimJob(t, bundleJob.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), bundleJob.ID, nil))
+	require.NoError(t, f.db.SetJobStart(t.Context(), bundleJob.ID, time.Now().Add(-time.Second)))
+	loadedBundle, err := f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, loadedBundle.NumJobsStopped, "rescheduled job uncounted")
+	f.claimJob(t, bundleJob.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), bundleJob.ID, nil))
+	loadedBundle, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loadedBundle.NumJobsStopped)
+}
+
+// testResetJob checks that ResetJob and ResetJobs return jobs to their
+// initial state so they are claimed again.
+func testResetJob(t *testing.T, f *fixture) {
+	succeeded := f.addJob(t, 0, nullable.Time{}, 0)
+	failed := f.addJob(t, 0, nullable.Time{}, 2)
+	running := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, succeeded.ID)
+	f.claimJob(t, failed.ID)
+	f.claimJob(t, running.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeded.ID, nil))
+	require.NoError(t, f.db.SetJobError(t.Context(), failed.ID, "dbtest error", nullable.JSON(`{}`)))
+
+	require.NoError(t, f.db.ResetJob(t.Context(), succeeded.ID))
+	require.NoError(t, f.db.ResetJobs(t.Context(), uu.IDs{failed.ID, running.ID}))
+
+	for _, id := range []uu.ID{succeeded.ID, failed.ID, running.ID} {
+		loaded := f.getJob(t, id)
+		assert.False(t, loaded.Started(), "started_at cleared")
+		assert.False(t, loaded.Stopped(), "stopped_at cleared")
+		assert.False(t, loaded.HasError(), "error_msg cleared")
+		assert.True(t, loaded.ErrorData.IsNull(), "error_data cleared")
+		assert.True(t, loaded.Result.IsNull(), "result cleared")
+		assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at cleared")
+		assert.Zero(t, loaded.CurrentRetryCount, "current_retry_count reset")
+	}
+
+	// All three are claimable again
+	claimed := make(map[uu.ID]bool)
+	for range 3 {
+		job := f.claim(t)
+		require.NotNil(t, job)
+		claimed[job.ID] = true
+	}
+	assert.True(t, claimed[succeeded.ID] && claimed[failed.ID] && claimed[running.ID])
+}
+
+// testJobAttempts checks that every write ending a running job
+// records an attempt, that writes to a job that is not running don't,
+// and that the attempts are deleted with their job.
+func testJobAttempts(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 1)
+
+	attempts, err := f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, attempts, "no attempts before the job is started")
+
+	assert.Equal(t, f.pool.Claim().Worker, f.claimJob(t, job.ID).Worker.String(), "worker of the claim")
+	require.NoError(t, f.db.SnoozeJob(t.Context(), job.ID, time.Now().Add(-time.Second)))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), job.ID, time.Now().Add(-time.Second), 1, "dbtest retry", nullable.JSON(`{"retry":1}`)))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), job.ID, "dbtest error", nil))
+
+	// Resetting a stopped job ends no attempt
+	require.NoError(t, f.db.ResetJob(t.Context(), job.ID))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.ResetJobs(t.Context(), uu.IDs{job.ID}))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), job.ID))
+	require.NoError(t, f.db.ResetJob(t.Context(), job.ID))
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+	// Ending the stopped job again records nothing
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), job.ID))
+
+	attempts, err = f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	want := []struct {
+		outcome    jobqueue.JobAttemptOutcome
+		retryCount int
+		errorMsg   string
+	}{
+		{jobqueue.JobAttemptSnoozed, 0, ""},
+		{jobqueue.JobAttemptRetried, 0, "dbtest retry"},
+		{jobqueue.JobAttemptFailed, 1, "dbtest error"},
+		{jobqueue.JobAttemptReset, 0, ""},
+		{jobqueue.JobAttemptCancelled, 0, ""},
+		{jobqueue.JobAttemptSucceeded, 0, ""},
+	}
+	require.Len(t, attempts, len(want))
+	for i, a := range attempts {
+		assert.Equal(t, job.ID, a.JobID)
+		assert.Equal(t, i+1, a.Attempt, "attempts ordered by number")
+		assert.Equal(t, want[i].outcome, a.Outcome, "attempt %d", a.Attempt)
+		assert.Equal(t, want[i].retryCount, a.RetryCount, "attempt %d", a.Attempt)
+		assert.Equal(t, want[i].errorMsg, a.ErrorMsg.StringOr(""), "attempt %d", a.Attempt)
+		assert.Equal(t, jobworker.WorkerName, a.Worker)
+		assert.False(t, a.StartedAt.IsZero())
+		assert.GreaterOrEqual(t, a.Duration(), time.Duration(0))
+	}
+	assert.JSONEq(t, `{"retry":1}`, string(attempts[1].ErrorData))
+
+	// The attempt is recorded with the worker that claimed the job,
+	// not with the process that ended it, like an operator resetting the job
+	require.NoError(t, f.db.ResetJob(t.Context(), job.ID))
+	otherClaim := *f.pool.Claim()
+	otherClaim.Worker = "dbtest-other-worker"
+	claimed, err := f.db.StartNextJobOrNil(t.Context(), &otherClaim)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	require.Equal(t, job.ID, claimed.ID)
+	require.NoError(t, f.db.ResetJob(t.Context(), job.ID))
+	assert.True(t, f.getJob(t, job.ID).Worker.IsNull(), "worker cleared by the reset")
+	attempts, err = f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, len(want)+1)
+	assert.Equal(t, jobqueue.JobAttemptReset, attempts[len(want)].Outcome)
+	assert.Equal(t, "dbtest-other-worker", attempts[len(want)].Worker, "worker of the claim")
+
+	require.NoError(t, f.db.DeleteJob(t.Context(), job.ID))
+	attempts, err = f.db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, attempts, "attempts deleted with the job")
+}
+
+// testSetJobWorkerAlive checks that the heartbeat only updates
+// worker_alive_at while the job is started and not stopped.
+func testSetJobWorkerAlive(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 0)
+
+	require.NoError(t, f.db.SetJobWorkerAlive(t.Context(), job.ID))
+	assert.True(t, f.getJob(t, job.ID).WorkerAliveAt.IsNull(), "no heartbeat before the job is started")
+
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.SetJobWorkerAlive(t.Context(), job.ID))
+	loaded := f.getJob(t, job.ID)
+	require.True(t, loaded.WorkerAliveAt.IsNotNull(), "heartbeat while the job is processed")
+	assert.True(t, loaded.WorkerAlive(time.Minute))
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+	require.NoError(t, f.db.SetJobWorkerAlive(t.Context(), job.ID))
+	assert.True(t, f.getJob(t, job.ID).WorkerAliveAt.IsNull(), "no heartbeat after the job is stopped")
+}
+
+// testWorkerHeartbeat checks that the worker thread of a Pool keeps
+// advancing worker_alive_at while it processes a job
+// and that worker_alive_at is cleared when the job is stopped.
+func testWorkerHeartbeat(t *testing.T, f *fixture) {
+	release := make(chan struct{})
+	pool := jobworker.NewPool(f.db, jobworker.WithHeartbeatInterval(50*time.Millisecond))
+	pool.Register(f.jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return "ok", nil
+	})
+	startThreads(t, pool)
+	var releaseOnce sync.Once
+	releaseWorker := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(releaseWorker)
+
+	job := f.addJob(t, 0, nullable.Time{}, 0)
+	var firstAlive time.Time
+	eventually(t, func() bool {
+		loaded := f.getJob(t, job.ID)
+		if !loaded.StartedAndNotStopped() || loaded.WorkerAliveAt.IsNull() {
+			return false
+		}
+		firstAlive = loaded.WorkerAliveAt.Get()
+		return true
+	}, "worker_alive_at set when the job is claimed")
+	eventually(t, func() bool {
+		loaded := f.getJob(t, job.ID)
+		return loaded.WorkerAliveAt.IsNotNull() && loaded.WorkerAliveAt.Get().After(firstAlive)
+	}, "worker_alive_at advanced by the heartbeat while processing")
+	assert.True(t, f.getJob(t, job.ID).WorkerAlive(time.Minute))
+
+	releaseWorker()
+	eventually(t, func() bool { return f.getJob(t, job.ID).Succeeded() }, "job succeeded")
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at cleared after completion")
+	assert.False(t, loaded.WorkerAlive(time.Hour), "a stopped job is not being processed")
+}
+
+// testWorkerHeartbeatDisabled checks that worker_alive_at of a job
+// processed by a Pool without heartbeat stays null, so the job
+// is never mistaken for the job of a crashed worker and reset.
+func testWorkerHeartbeatDisabled(t *testing.T, f *fixture) {
+	release := make(chan struct{})
+	pool := jobworker.NewPool(f.db, jobworker.WithHeartbeatInterval(0))
+	pool.Register(f.jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return "ok", nil
+	})
+	startThreads(t, pool)
+	t.Cleanup(func() { close(release) })
+
+	job := f.addJob(t, 0, nullable.Time{}, 0)
+	eventually(t, func() bool { return f.getJob(t, job.ID).StartedAndNotStopped() }, "job claimed")
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "no worker_alive_at without heartbeat")
+	assert.False(t, loaded.WorkerAlive(time.Hour), "a job without heartbeat is never reported alive")
+}
+
+// testRetrySchedulerHeartbeat checks that a failed job stays started
+// with an advancing worker_alive_at while its ScheduleRetryFunc runs,
+// so that no other process resets the job in the meantime.
+func testRetrySchedulerHeartbeat(t *testing.T, f *fixture) {
+	var (
+		schedulerEntered = make(chan struct{})
+		releaseScheduler = make(chan struct{})
+		enteredOnce      sync.Once
+		releaseOnce      sync.Once
+	)
+	pool := jobworker.NewPool(f.db, jobworker.WithHeartbeatInterval(50*time.Millisecond))
+	pool.Register(f.jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, errors.New("dbtest error")
+	})
+	pool.RegisterScheduleRetry(f.jobType, func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
+		enteredOnce.Do(func() { close(schedulerEntered) })
+		select {
+		case <-releaseScheduler:
+		case <-ctx.Done():
+		}
+		return time.Now().Add(time.Hour), nil
+	})
+	startThreads(t, pool)
+	release := func() { releaseOnce.Do(func() { close(releaseScheduler) }) }
+	t.Cleanup(release)
+
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	select {
+	case <-schedulerEntered:
+	case <-time.After(NotificationTimeout):
+		t.Fatal("retry scheduler not called")
+	}
+
+	loaded := f.getJob(t, job.ID)
+	require.True(t, loaded.StartedAndNotStopped(), "job running while the retry scheduler runs")
+	require.True(t, loaded.WorkerAliveAt.IsNotNull(), "heartbeat while the retry scheduler runs")
+	require.False(t, loaded.HasError(), "no error before the retry is scheduled")
+	firstAlive := loaded.WorkerAliveAt.Get()
+	eventually(t, func() bool {
+		loaded := f.getJob(t, job.ID)
+		return loaded.StartedAndNotStopped() && loaded.WorkerAliveAt.IsNotNull() && loaded.WorkerAliveAt.Get().After(firstAlive)
+	}, "worker_alive_at advanced while the retry scheduler runs")
+
+	release()
+	eventually(t, func() bool {
+		loaded := f.getJob(t, job.ID)
+		return !loaded.Started() && loaded.CurrentRetryCount == 1 && loaded.WorkerAliveAt.IsNull()
+	}, "retry scheduled after the retry scheduler returned")
+}
+
+// testCancelJobNotStarted checks that CancelJob stops a job that was not
+// started yet as cancelled, counts it in its bundle, and that ResetJob
+// makes it runnable again.
+func testCancelJobNotStarted(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc}, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+	job := bundle.Jobs[0]
+
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.Cancelled())
+	assert.True(t, loaded.IsFinished())
+	assert.False(t, loaded.Succeeded())
+	assert.False(t, loaded.Started(), "a job cancelled before it was started is not started")
+	assert.Nil(t, f.claim(t), "a cancelled job must not be claimed")
+
+	loadedBundle, err := f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loadedBundle.NumJobsStopped, "cancelled job counted in its bundle")
+
+	eventually(t, func() bool {
+		return slices.Equal(l.jobStopped(job.ID), []bool{false}) && l.bundleStopped(bundle.ID) == 1
+	}, "job_stopped and job_bundle_stopped notifications")
+
+	// Cancelling again changes nothing
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), job.ID))
+	loadedBundle, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loadedBundle.NumJobsStopped, "cancelled job counted only once")
+
+	// ResetJob clears the cancellation and un-counts the job
+	require.NoError(t, f.db.ResetJob(t.Context(), job.ID))
+	loaded = f.getJob(t, job.ID)
+	assert.True(t, loaded.CancelRequestedAt.IsNull())
+	assert.False(t, loaded.Stopped())
+	loadedBundle, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Zero(t, loadedBundle.NumJobsStopped)
+	f.claimJob(t, job.ID)
+
+	// Finished jobs can't be cancelled anymore
+	require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+	loaded = f.getJob(t, job.ID)
+	assert.True(t, loaded.CancelRequestedAt.IsNull(), "finished job not changed by CancelJob")
+	assert.True(t, loaded.Succeeded())
+
+	require.NoError(t, f.db.CancelJob(t.Context(), uu.NewID(t.Context())), "cancelling a missing job is no error")
+}
+
+// testCancelJobRunning checks that CancelJob notifies the cancel requested
+// listener about a running job without stopping it, and that SetJobCancelled
+// then stops it as cancelled.
+func testCancelJobRunning(t *testing.T, f *fixture) {
+	var (
+		requestedMtx sync.Mutex
+		requested    uu.IDs
+	)
+	require.NoError(t, f.db.SetJobCancelRequestedListener(t.Context(), func(jobID uu.ID) {
+		requestedMtx.Lock()
+		defer requestedMtx.Unlock()
+		requested = append(requested, jobID)
+	}))
+	t.Cleanup(func() { _ = f.db.SetJobCancelRequestedListener(context.Background(), nil) })
+
+	job := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, job.ID)
+
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.CancelRequestedAt.IsNotNull())
+	assert.True(t, loaded.StartedAndNotStopped(), "running job keeps running until its worker stops it")
+	assert.False(t, loaded.Cancelled())
+
+	eventually(t, func() bool {
+		requestedMtx.Lock()
+		defer requestedMtx.Unlock()
+		return slices.Contains(requested, job.ID)
+	}, "job cancel requested callback")
+
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), job.ID))
+	loaded = f.getJob(t, job.ID)
+	assert.True(t, loaded.Cancelled())
+	assert.True(t, loaded.CancelledAt.IsNotNull())
+	assert.False(t, loaded.HasError())
+	assert.True(t, loaded.WorkerAliveAt.IsNull(), "worker_alive_at is cleared on stop")
+
+	// A retry that was scheduled before the cancellation was noticed
+	// keeps the cancellation request for the worker that claims it.
+	retried := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, retried.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), retried.ID))
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), retried.ID, time.Now().Add(-time.Second), 1, "error", nil))
+	claimed := f.claimJob(t, retried.ID)
+	assert.True(t, claimed.CancelRequestedAt.IsNotNull(), "ScheduleRetry keeps cancel_requested_at")
+}
+
+// testCancelJobRunningSucceeded checks that a job whose cancellation was
+// requested while it was running, but that succeeded before its worker
+// noticed, is a succeeded job: it unblocks its dependents
+// and is deleted by DeleteFinishedJobs.
+func testCancelJobRunningSucceeded(t *testing.T, f *fixture) {
+	job := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, job.ID)
+	f.claimJob(t, job.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), job.ID))
+	require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+
+	loaded := f.getJob(t, job.ID)
+	assert.True(t, loaded.Succeeded())
+	assert.False(t, loaded.Cancelled())
+	assert.True(t, loaded.CancelRequestedAt.IsNull(), "SetJobResult clears cancel_requested_at")
+	assert.Equal(t, jobqueue.JobStateSucceeded, loaded.State(time.Now()))
+
+	jobs, err := f.db.GetJobs(t.Context(), &jobqueue.JobFilter{Origin: f.origin, State: jobqueue.JobStateCancelled})
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "not selected as cancelled")
+
+	f.claimJob(t, dependent.ID)
+
+	require.NoError(t, f.db.DeleteFinishedJobs(t.Context()))
+	_, err = f.db.GetJob(t.Context(), job.ID)
+	assert.True(t, errs.IsErrNotFound(err), "succeeded job deleted")
+}
+
+// testJobBundleCompletion checks that every terminal stop of a bundled job
+// is counted in num_jobs_stopped, that the bundle is reported as stopped
+// once all its jobs have stopped, and that ResetJob un-counts a job.
+func testJobBundleCompletion(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{"bundled":true}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc, desc, desc}, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+
+	loaded, err := f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded.NumJobs)
+	assert.Zero(t, loaded.NumJobsStopped)
+	require.Len(t, loaded.Jobs, 3)
+	for _, job := range loaded.Jobs {
+		assert.Equal(t, bundle.ID, job.BundleID.Get())
+	}
+
+	// Claim all jobs, let one fail so a default service listener
+	// doesn't delete the completed bundle.
+	jobs := bundle.Jobs
+	for range jobs {
+		require.NotNil(t, f.claim(t))
+	}
+	require.NoError(t, f.db.SetJobResult(t.Context(), jobs[0].ID, nil))
+	require.NoError(t, f.db.SetJobError(t.Context(), jobs[1].ID, "dbtest error", nil))
+
+	loaded, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded.NumJobsStopped)
+	assert.Zero(t, l.bundleStopped(bundle.ID), "bundle not stopped before all its jobs")
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), jobs[2].ID, nil))
+	loaded, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded.NumJobsStopped)
+	assert.True(t, loaded.HasError())
+
+	eventually(t, func() bool { return l.bundleStopped(bundle.ID) == 1 }, "job_bundle_stopped notification")
+	eventually(t, func() bool {
+		for _, job := range jobs {
+			if !slices.Equal(l.jobStopped(job.ID), []bool{false}) {
+				return false
+			}
+		}
+		return true
+	}, "job_stopped notification for every bundled job")
+
+	// Resetting a counted job decrements num_jobs_stopped
+	// and completing it again stops the bundle again.
+	require.NoError(t, f.db.ResetJob(t.Context(), jobs[1].ID))
+	loaded, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded.NumJobsStopped)
+
+	f.claimJob(t, jobs[1].ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), jobs[1].ID, "dbtest error again", nil))
+	loaded, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded.NumJobsStopped)
+	eventually(t, func() bool { return l.bundleStopped(bundle.ID) == 2 }, "second job_bundle_stopped notification")
+
+	// Deleting the bundle deletes its jobs
+	require.NoError(t, f.db.DeleteJobBundle(t.Context(), bundle.ID))
+	_, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	assert.True(t, errs.IsErrNotFound(err), "deleted bundle not found")
+	for _, job := range jobs {
+		_, err = f.db.GetJob(t.Context(), job.ID)
+		assert.True(t, errs.IsErrNotFound(err), "bundled job deleted with its bundle")
+	}
+}
+
+// testJobBundleReset checks that a bundled job scheduled for a retry
+// is not counted in num_jobs_stopped and that ResetJob and ResetJobs
+// only decrement the counter for counted jobs, so that the bundle
+// can complete when its jobs are processed again.
+func testJobBundleReset(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc, desc, desc}, nullable.Time{})
+	require.NoError(t, err)
+	retried := bundle.Jobs[2]
+	retried.MaxRetryCount = 3
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+	numJobsStopped := func() int {
+		t.Helper()
+		loaded, err := f.db.GetJobBundle(t.Context(), bundle.ID)
+		require.NoError(t, err)
+		return loaded.NumJobsStopped
+	}
+
+	for range bundle.Jobs {
+		require.NotNil(t, f.claim(t))
+	}
+	require.NoError(t, f.db.SetJobError(t.Context(), bundle.Jobs[0].ID, "dbtest error", nil))
+	require.NoError(t, f.db.SetJobResult(t.Context(), bundle.Jobs[1].ID, nil))
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), retried.ID, time.Now().Add(-time.Second), 1, "dbtest retry", nil))
+	assert.Equal(t, 2, numJobsStopped(), "job scheduled for a retry not counted")
+
+	require.NoError(t, f.db.ResetJob(t.Context(), retried.ID))
+	assert.Equal(t, 2, numJobsStopped(), "resetting a job that is not counted doesn't decrement")
+
+	require.NoError(t, f.db.ResetJobs(t.Context(), uu.IDs{bundle.Jobs[0].ID, bundle.Jobs[1].ID}))
+	assert.Equal(t, 0, numJobsStopped(), "ResetJobs decrements for every counted job")
+
+	for range bundle.Jobs {
+		job := f.claim(t)
+		require.NotNil(t, job)
+		require.NoError(t, f.db.SetJobResult(t.Context(), job.ID, nil))
+	}
+	assert.Equal(t, 3, numJobsStopped())
+	eventually(t, func() bool { return l.bundleStopped(bundle.ID) == 1 }, "job_bundle_stopped notification")
+}
+
+// testDependencyBlocksClaim checks that a job is only claimed
+// after all jobs it depends on succeeded.
+func testDependencyBlocksClaim(t *testing.T, f *fixture) {
+	first := f.addJob(t, 0, nullable.Time{}, 0)
+	second := f.addJob(t, 0, nullable.Time{}, 0)
+	// Higher priority than its dependencies, but has to wait for them
+	dependent := f.addDependentJob(t, 10, "", second.ID, first.ID)
+
+	loaded := f.getJob(t, dependent.ID)
+	assert.ElementsMatch(t, uu.IDs{first.ID, second.ID}, loaded.DependsOn)
+	assert.Equal(t, jobqueue.DependencyFailureBlock, loaded.OnDependencyFailure, "empty policy stored as block")
+	assert.Empty(t, f.getJob(t, first.ID).DependsOn)
+
+	f.claimJob(t, first.ID)
+	f.claimJob(t, second.ID)
+	assert.Nil(t, f.claim(t), "dependencies still running")
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), first.ID, nil))
+	assert.Nil(t, f.claim(t), "one dependency still running")
+
+	var available atomic.Int64
+	require.NoError(t, f.db.SetJobAvailableListener(t.Context(), func() { available.Add(1) }))
+	t.Cleanup(func() { _ = f.db.SetJobAvailableListener(context.Background(), nil) })
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), second.ID, nil))
+	eventually(t, func() bool { return available.Load() > 0 }, "job available callback after the last dependency succeeded")
+	f.claimJob(t, dependent.ID)
+}
+
+func testDependencyFailureBlock(t *testing.T, f *fixture) {
+	dependency := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, dependency.ID)
+
+	f.claimJob(t, dependency.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), dependency.ID, "dbtest error", nil))
+	assert.Nil(t, f.claim(t), "blocked by the failed dependency")
+	assert.False(t, f.getJob(t, dependent.ID).Stopped(), "blocked job is not stopped")
+
+	// A cancelled dependency also blocks
+	cancelled := f.addJob(t, 0, nullable.Time{}, 0)
+	blockedByCancelled := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, cancelled.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), cancelled.ID))
+	assert.Nil(t, f.claim(t), "blocked by the cancelled dependency")
+
+	// Resetting the failed dependency lets it succeed on the next try
+	require.NoError(t, f.db.ResetJob(t.Context(), dependency.ID))
+	f.claimJob(t, dependency.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), dependency.ID, nil))
+	f.claimJob(t, dependent.ID)
+
+	// Deleting the cancelled dependency does not unblock the job
+	require.NoError(t, f.db.DeleteJob(t.Context(), cancelled.ID))
+	loaded := f.getJob(t, blockedByCancelled.ID)
+	assert.Empty(t, loaded.DependsOn)
+	assert.True(t, loaded.Cancelled(), "cancelled with the deleted dependency")
+	assert.Nil(t, f.claim(t))
+}
+
+func testDependencyFailureRun(t *testing.T, f *fixture) {
+	failing := f.addJob(t, 0, nullable.Time{}, 0)
+	succeeding := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent := f.addDependentJob(t, 10, jobqueue.DependencyFailureRun, failing.ID, succeeding.ID)
+
+	f.claimJob(t, failing.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), failing.ID, "dbtest error", nil))
+	f.claimJob(t, succeeding.ID)
+	assert.Nil(t, f.claim(t), "runs only after all dependencies finished")
+
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeding.ID, nil))
+	f.claimJob(t, dependent.ID)
+
+	// A dependency that will be retried has not finally failed yet
+	retried := f.addJob(t, 0, nullable.Time{}, 1)
+	dependentOnRetried := f.addDependentJob(t, 0, jobqueue.DependencyFailureRun, retried.ID)
+	f.claimJob(t, retried.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), retried.ID, time.Now().Add(time.Hour), 1, "error", nil))
+	assert.Nil(t, f.claim(t), "dependency scheduled for retry")
+	assert.False(t, f.getJob(t, dependentOnRetried.ID).Stopped())
+}
+
+func testDependencyFailureCancel(t *testing.T, f *fixture) {
+	l := f.addListener(t)
+
+	firstID := uu.NewID(t.Context())
+	secondID := uu.NewID(t.Context())
+	descs := []jobqueue.JobDesc{
+		{Type: f.jobType, Payload: `{}`, Origin: f.origin, ID: firstID},
+		{Type: f.jobType, Payload: `{}`, Origin: f.origin, ID: secondID, DependsOn: uu.IDs{firstID}, OnDependencyFailure: jobqueue.DependencyFailureCancel},
+		{Type: f.jobType, Payload: `{}`, Origin: f.origin, DependsOn: uu.IDs{secondID}, OnDependencyFailure: jobqueue.DependencyFailureCancel},
+		{Type: f.jobType, Payload: `{}`, Origin: f.origin, DependsOn: uu.IDs{secondID}, OnDependencyFailure: jobqueue.DependencyFailureBlock},
+	}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, descs, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+	assert.Equal(t, uu.IDs{secondID}, f.getJob(t, bundle.Jobs[2].ID).DependsOn)
+
+	f.claimJob(t, firstID)
+	assert.Nil(t, f.claim(t), "all other jobs depend on the running job")
+	require.NoError(t, f.db.SetJobError(t.Context(), firstID, "dbtest error", nil))
+
+	for _, job := range bundle.Jobs[1:3] {
+		loaded := f.getJob(t, job.ID)
+		assert.True(t, loaded.Cancelled(), "cancelled because of the failed dependency")
+		assert.False(t, loaded.Started())
+	}
+	assert.False(t, f.getJob(t, bundle.Jobs[3].ID).Stopped(), "the cancellation only cascades to the cancel policy")
+	assert.Nil(t, f.claim(t))
+
+	loadedBundle, err := f.db.GetJobBundle(t.Context(), bundle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loadedBundle.NumJobsStopped, "cancelled jobs counted in their bundle")
+	eventually(t, func() bool {
+		return slices.Equal(l.jobStopped(bundle.Jobs[2].ID), []bool{false})
+	}, "job_stopped notification for the cascaded cancellation")
+
+	// Depending on an already failed job cancels the new job right away
+	late := f.addDependentJob(t, 0, jobqueue.DependencyFailureCancel, firstID)
+	assert.True(t, f.getJob(t, late.ID).Cancelled())
+
+	// Cancelling a job that was not started yet cancels its dependents
+	cancelled := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent := f.addDependentJob(t, 0, jobqueue.DependencyFailureCancel, cancelled.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), cancelled.ID))
+	assert.True(t, f.getJob(t, dependent.ID).Cancelled())
+
+	// A running job cancels its dependents when its worker has stopped
+	running := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent = f.addDependentJob(t, 0, jobqueue.DependencyFailureCancel, running.ID)
+	f.claimJob(t, running.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), running.ID))
+	assert.False(t, f.getJob(t, dependent.ID).Stopped(), "dependency still running")
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), running.ID))
+	assert.True(t, f.getJob(t, dependent.ID).Cancelled())
+
+	// A succeeded dependency doesn't cancel anything
+	succeeding := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent = f.addDependentJob(t, 0, jobqueue.DependencyFailureCancel, succeeding.ID)
+	f.claimJob(t, succeeding.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeding.ID, nil))
+	require.NoError(t, f.db.SetJobCancelled(t.Context(), succeeding.ID), "no-op for a stopped job")
+	f.claimJob(t, dependent.ID)
+}
+
+// testDeleteDependency checks that deleting a dependency that did not succeed
+// cancels its not started dependents with the block or cancel policy
+// instead of unblocking them, and that deleting a succeeded dependency
+// or a dependency of a job with the run policy doesn't cancel anything.
+func testDeleteDependency(t *testing.T, f *fixture) {
+	failed := f.addJob(t, 0, nullable.Time{}, 0)
+	blocked := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, failed.ID)
+	cascaded := f.addDependentJob(t, 0, jobqueue.DependencyFailureCancel, blocked.ID)
+	running := f.addDependentJob(t, 0, jobqueue.DependencyFailureRun, failed.ID)
+	f.claimJob(t, failed.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), failed.ID, "dbtest error", nil))
+
+	require.NoError(t, f.db.DeleteJob(t.Context(), failed.ID))
+	assert.True(t, f.getJob(t, blocked.ID).Cancelled(), "block policy cancelled by the deleted dependency")
+	assert.True(t, f.getJob(t, cascaded.ID).Cancelled(), "cancellation cascades to the cancel policy")
+	f.claimJob(t, running.ID)
+	assert.Nil(t, f.claim(t))
+
+	// A dependency that was deleted before it was started can't succeed either
+	pending := f.addJob(t, 0, nullable.Time{}, 0)
+	blocked = f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, pending.ID)
+	require.NoError(t, f.db.DeleteJob(t.Context(), pending.ID))
+	assert.True(t, f.getJob(t, blocked.ID).Cancelled())
+
+	// Deleting the bundle of a dependency cancels its dependents outside the bundle
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin,
+		[]jobqueue.JobDesc{{Type: f.jobType, Payload: `{}`, Origin: f.origin}},
+		nullable.Time{},
+	)
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+	blocked = f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, bundle.Jobs[0].ID)
+	require.NoError(t, f.db.DeleteJobBundle(t.Context(), bundle.ID))
+	assert.True(t, f.getJob(t, blocked.ID).Cancelled())
+
+	// Deleting a succeeded dependency keeps its dependent runnable
+	succeeded := f.addJob(t, 0, nullable.Time{}, 0)
+	dependent := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, succeeded.ID)
+	f.claimJob(t, succeeded.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeded.ID, nil))
+	require.NoError(t, f.db.DeleteJob(t.Context(), succeeded.ID))
+	assert.False(t, f.getJob(t, dependent.ID).Stopped())
+	f.claimJob(t, dependent.ID)
+}
+
+func testDependencyConstraints(t *testing.T, f *fixture) {
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType, f.origin, `{}`, nullable.Time{})
+	require.NoError(t, err)
+
+	job.DependsOn = uu.IDs{uu.NewID(t.Context())}
+	assert.Error(t, f.db.AddJob(t.Context(), job), "dependency does not exist")
+
+	job.DependsOn = uu.IDs{job.ID}
+	assert.Error(t, f.db.AddJob(t.Context(), job), "job depends on itself")
+
+	dependency := f.addJob(t, 0, nullable.Time{}, 0)
+	job.DependsOn = uu.IDs{dependency.ID}
+	job.OnDependencyFailure = "invalid"
+	assert.Error(t, f.db.AddJob(t.Context(), job), "invalid policy")
+
+	_, err = f.db.GetJob(t.Context(), job.ID)
+	assert.True(t, errs.IsErrNotFound(err), "failed AddJob calls must not insert the job")
+
+	// Duplicate dependencies are stored once
+	job.OnDependencyFailure = jobqueue.DependencyFailureRun
+	job.DependsOn = uu.IDs{dependency.ID, dependency.ID}
+	require.NoError(t, f.db.AddJob(t.Context(), job))
+	loaded := f.getJob(t, job.ID)
+	assert.Equal(t, uu.IDs{dependency.ID}, loaded.DependsOn)
+	assert.Equal(t, jobqueue.DependencyFailureRun, loaded.OnDependencyFailure)
+}
+
+func testUniqueKey(t *testing.T, f *fixture) {
+	newJob := func(payload string, policy jobqueue.DuplicateJobPolicy) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType, f.origin, payload, nullable.Time{})
+		require.NoError(t, err)
+		job.UniqueKey.Set("dbtest-key")
+		job.OnDuplicate = policy
+		return job
+	}
+
+	original := newJob(`{"version":1}`, "")
+	require.NoError(t, f.db.AddJob(t.Context(), original))
+	assert.Equal(t, "dbtest-key", f.getJob(t, original.ID).UniqueKey.Get())
+
+	duplicate := newJob(`{"version":2}`, jobqueue.DuplicateJobFail)
+	err := f.db.AddJob(t.Context(), duplicate)
+	require.ErrorIs(t, err, jobqueue.ErrDuplicateJob)
+	var duplicateErr *jobqueue.DuplicateJobError
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, original.ID, duplicateErr.ExistingJobID)
+	assert.Equal(t, "dbtest-key", duplicateErr.UniqueKey)
+	_, err = f.db.GetJob(t.Context(), duplicate.ID)
+	assert.True(t, errs.IsErrNotFound(err), "duplicate not inserted")
+
+	useExisting := newJob(`{"version":3}`, jobqueue.DuplicateJobUseExisting)
+	require.NoError(t, f.db.AddJob(t.Context(), useExisting))
+	assert.Equal(t, original.ID, useExisting.ID, "ID of the existing job")
+	assert.JSONEq(t, `{"version":1}`, string(f.getJob(t, original.ID).Payload), "existing job not changed")
+
+	startAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	replace := newJob(`{"version":4}`, jobqueue.DuplicateJobReplace)
+	replace.StartAt.Set(startAt)
+	require.NoError(t, f.db.AddJob(t.Context(), replace))
+	assert.Equal(t, original.ID, replace.ID, "ID of the replaced job")
+	loaded := f.getJob(t, original.ID)
+	assert.JSONEq(t, `{"version":4}`, string(loaded.Payload), "payload replaced")
+	assert.True(t, loaded.StartAt.Get().Equal(startAt), "start_at replaced")
+
+	// Other job types can use the same key
+	otherType := newJob(`{}`, "")
+	otherType.Type = f.jobType + "-other"
+	require.NoError(t, f.db.AddJob(t.Context(), otherType))
+	assert.NotEqual(t, original.ID, otherType.ID)
+
+	// A running job is not replaced
+	f.claimJob(t, original.ID)
+	replace = newJob(`{"version":5}`, jobqueue.DuplicateJobReplace)
+	require.ErrorIs(t, f.db.AddJob(t.Context(), replace), jobqueue.ErrDuplicateJob)
+	assert.JSONEq(t, `{"version":4}`, string(f.getJob(t, original.ID).Payload))
+	useExisting = newJob(`{"version":6}`, jobqueue.DuplicateJobUseExisting)
+	require.NoError(t, f.db.AddJob(t.Context(), useExisting))
+	assert.Equal(t, original.ID, useExisting.ID)
+
+	// The key can be used again after the job stopped
+	require.NoError(t, f.db.SetJobResult(t.Context(), original.ID, nil))
+	again := newJob(`{"version":7}`, "")
+	require.NoError(t, f.db.AddJob(t.Context(), again))
+	assert.NotEqual(t, original.ID, again.ID)
+	err = f.db.ResetJob(t.Context(), original.ID)
+	require.ErrorIs(t, err, jobqueue.ErrDuplicateJob, "reset would duplicate the unfinished job")
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, again.ID, duplicateErr.ExistingJobID)
+	require.ErrorIs(t, f.db.ResetJobs(t.Context(), uu.IDs{original.ID}), jobqueue.ErrDuplicateJob)
+	require.ErrorIs(t, f.db.SetJobStart(t.Context(), original.ID, time.Now()), jobqueue.ErrDuplicateJob)
+	assert.True(t, f.getJob(t, original.ID).Succeeded(), "failed reset changed nothing")
+
+	// A duplicate fails the whole bundle regardless of its policy
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc, desc}, nullable.Time{})
+	require.NoError(t, err)
+	bundle.Jobs[1].UniqueKey.Set("dbtest-key")
+	bundle.Jobs[1].OnDuplicate = jobqueue.DuplicateJobUseExisting
+	require.ErrorIs(t, f.db.AddJobBundle(t.Context(), bundle), jobqueue.ErrDuplicateJob)
+	_, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+	assert.True(t, errs.IsErrNotFound(err), "bundle not inserted")
+	_, err = f.db.GetJob(t.Context(), bundle.Jobs[0].ID)
+	assert.True(t, errs.IsErrNotFound(err), "no job of the bundle inserted")
+}
+
+func testJobSchedule(t *testing.T, f *fixture) {
+	ctx := t.Context()
+	name := f.origin
+	// Registered after the fixture cleanup, so it runs before it
+	t.Cleanup(func() { _ = f.db.DeleteJobSchedule(context.Background(), name) })
+
+	newJob := func(fireTime time.Time) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJob(uu.NewID(ctx), f.jobType, f.origin, `{}`, nullable.TimeFrom(fireTime))
+		require.NoError(t, err)
+		job.ScheduleName.Set(name)
+		job.ScheduleFireTime.Set(fireTime)
+		return job
+	}
+	notFound := func(jobID uu.ID) bool {
+		_, err := f.db.GetJob(ctx, jobID)
+		return errs.IsErrNotFound(err)
+	}
+
+	schedule, err := f.db.SaveJobSchedule(ctx, &jobqueue.JobSchedule{Name: name, CronExpr: "0 * * * *", Timezone: "UTC"})
+	require.NoError(t, err)
+	assert.Equal(t, name, schedule.Name)
+	assert.Equal(t, "0 * * * *", schedule.CronExpr)
+	assert.Equal(t, "UTC", schedule.Timezone)
+	assert.Equal(t, jobqueue.ScheduleCatchUpOnce, schedule.CatchUp, "default catch-up")
+	assert.True(t, schedule.LastFireTime.IsNull())
+
+	hour := time.Now().Truncate(time.Hour)
+	due, next := newJob(hour.Add(-time.Hour)), newJob(hour.Add(time.Hour))
+	schedule, err = f.db.AddScheduledJobs(ctx, name, []*jobqueue.Job{due, next})
+	require.NoError(t, err)
+	assert.True(t, schedule.LastFireTime.Get().Equal(next.ScheduleFireTime.Get()), "last fire time advanced")
+	loaded := f.getJob(t, due.ID)
+	assert.Equal(t, name, loaded.ScheduleName.Get())
+	assert.True(t, loaded.ScheduleFireTime.Get().Equal(due.ScheduleFireTime.Get()))
+	f.getJob(t, next.ID)
+
+	// Adding the same occurrences again, like another worker process would, adds nothing
+	dueAgain, nextAgain := newJob(due.ScheduleFireTime.Get()), newJob(next.ScheduleFireTime.Get())
+	schedule, err = f.db.AddScheduledJobs(ctx, name, []*jobqueue.Job{dueAgain, nextAgain})
+	require.NoError(t, err)
+	assert.True(t, schedule.LastFireTime.Get().Equal(next.ScheduleFireTime.Get()))
+	assert.True(t, notFound(dueAgain.ID), "occurrence not added twice")
+	assert.True(t, notFound(nextAgain.ID), "occurrence not added twice")
+
+	// The unique fire time also guards AddJob
+	require.NoError(t, f.db.AddJob(ctx, newJob(next.ScheduleFireTime.Get())))
+	assert.True(t, notFound(nextAgain.ID))
+
+	later := newJob(hour.Add(2 * time.Hour))
+	schedule, err = f.db.AddScheduledJobs(ctx, name, []*jobqueue.Job{later})
+	require.NoError(t, err)
+	assert.True(t, schedule.LastFireTime.Get().Equal(later.ScheduleFireTime.Get()))
+
+	// Saving an unchanged definition keeps the added occurrences
+	schedule, err = f.db.SaveJobSchedule(ctx, &jobqueue.JobSchedule{Name: name, CronExpr: "0 * * * *", Timezone: "UTC", CatchUp: jobqueue.ScheduleCatchUpAll})
+	require.NoError(t, err)
+	assert.Equal(t, jobqueue.ScheduleCatchUpAll, schedule.CatchUp)
+	assert.True(t, schedule.LastFireTime.Get().Equal(later.ScheduleFireTime.Get()))
+
+	// A changed cron expression replaces the occurrences added in advance
+	schedule, err = f.db.SaveJobSchedule(ctx, &jobqueue.JobSchedule{Name: name, CronExpr: "30 * * * *", Timezone: "UTC"})
+	require.NoError(t, err)
+	assert.Equal(t, "30 * * * *", schedule.CronExpr)
+	assert.False(t, schedule.LastFireTime.Get().After(time.Now()), "last fire time limited to now")
+	assert.True(t, notFound(next.ID), "future occurrence deleted")
+	assert.True(t, notFound(later.ID), "future occurrence deleted")
+	f.getJob(t, due.ID)
+
+	// Invalid arguments
+	_, err = f.db.AddScheduledJobs(ctx, name+"-missing", nil)
+	assert.Error(t, err, "schedule does not exist")
+	other, err := jobqueue.NewJob(uu.NewID(ctx), f.jobType, f.origin, `{}`, nullable.Time{})
+	require.NoError(t, err)
+	_, err = f.db.AddScheduledJobs(ctx, name, []*jobqueue.Job{other})
+	assert.Error(t, err, "not an occurrence of the schedule")
+	_, err = f.db.SaveJobSchedule(ctx, &jobqueue.JobSchedule{Name: name, CronExpr: "@daily", Timezone: "UTC", CatchUp: "latest"})
+	assert.Error(t, err, "invalid catch-up")
+
+	// Deleting the schedule deletes its waiting occurrences
+	// and keeps the started ones without the schedule name
+	f.claimJob(t, due.ID)
+	waiting := newJob(hour.Add(3 * time.Hour))
+	_, err = f.db.AddScheduledJobs(ctx, name, []*jobqueue.Job{waiting})
+	require.NoError(t, err)
+	require.NoError(t, f.db.DeleteJobSchedule(ctx, name))
+	assert.True(t, notFound(waiting.ID), "waiting occurrence deleted")
+	assert.True(t, f.getJob(t, due.ID).ScheduleName.IsNull(), "started occurrence kept")
+	_, err = f.db.AddScheduledJobs(ctx, name, nil)
+	assert.Error(t, err, "schedule deleted")
+}
+
+// testAddJobs checks that AddJobs adds all jobs or none of them,
+// with dependencies between the jobs of the batch,
+// the duplicate policies, and a single job available notification.
+func testAddJobs(t *testing.T, f *fixture) {
+	var called atomic.Int64
+	require.NoError(t, f.db.SetJobAvailableListener(t.Context(), func() { called.Add(1) }))
+	t.Cleanup(func() { _ = f.db.SetJobAvailableListener(context.Background(), nil) })
+
+	newJob := func(t *testing.T, startAt nullable.Time) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType, f.origin, `{}`, startAt)
+		require.NoError(t, err)
+		return job
+	}
+
+	require.NoError(t, f.db.AddJobs(t.Context(), nil), "empty batch")
+
+	first := newJob(t, nullable.Time{})
+	dependent := newJob(t, nullable.Time{})
+	dependent.DependsOn = uu.IDs{first.ID}
+	deferred := newJob(t, nullable.TimeFrom(time.Now().Add(time.Hour)))
+	unique := newJob(t, nullable.Time{})
+	unique.UniqueKey.Set("dbtest-add-jobs")
+	require.NoError(t, f.db.AddJobs(t.Context(), []*jobqueue.Job{first, dependent, deferred, unique}))
+
+	assert.Equal(t, uu.IDs{first.ID}, f.getJob(t, dependent.ID).DependsOn)
+	assert.True(t, f.getJob(t, deferred.ID).StartAt.IsNotNull())
+	assert.Equal(t, "dbtest-add-jobs", f.getJob(t, unique.ID).UniqueKey.Get())
+	eventually(t, func() bool { return called.Load() > 0 }, "job available callback")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(1), called.Load(), "one job available notification per batch")
+
+	// The dependent job is claimed after the job it depends on succeeded
+	f.claimJob(t, first.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), first.ID, nil))
+	claimed := f.claim(t)
+	require.NotNil(t, claimed)
+	assert.Contains(t, uu.IDs{dependent.ID, unique.ID}, claimed.ID)
+
+	useExisting := newJob(t, nullable.Time{})
+	useExisting.UniqueKey.Set("dbtest-add-jobs")
+	useExisting.OnDuplicate = jobqueue.DuplicateJobUseExisting
+	added := newJob(t, nullable.Time{})
+	require.NoError(t, f.db.AddJobs(t.Context(), []*jobqueue.Job{useExisting, added}))
+	assert.Equal(t, unique.ID, useExisting.ID, "ID of the existing job")
+	f.getJob(t, added.ID)
+
+	// A failing job fails the whole batch
+	notAdded := newJob(t, nullable.Time{})
+	duplicate := newJob(t, nullable.Time{})
+	duplicate.UniqueKey.Set("dbtest-add-jobs")
+	err := f.db.AddJobs(t.Context(), []*jobqueue.Job{notAdded, duplicate})
+	require.ErrorIs(t, err, jobqueue.ErrDuplicateJob)
+	_, err = f.db.GetJob(t.Context(), notAdded.ID)
+	assert.True(t, errs.IsErrNotFound(err), "batch rolled back: %v", err)
+
+	invalid := newJob(t, nullable.Time{})
+	invalid.Origin = ""
+	err = f.db.AddJobs(t.Context(), []*jobqueue.Job{notAdded, invalid})
+	require.Error(t, err)
+	_, err = f.db.GetJob(t.Context(), notAdded.ID)
+	assert.True(t, errs.IsErrNotFound(err), "batch rolled back: %v", err)
+}
+
+// testTraceContext checks that the TraceContext of jobs added with AddJob,
+// AddJobs, and AddJobBundle is stored and returned by GetJob and the claim.
+func testTraceContext(t *testing.T, f *fixture) {
+	tc := jobqueue.TraceContext{
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		TraceState:  "vendor=value",
+	}
+	newJob := func(t *testing.T, tc jobqueue.TraceContext) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType, f.origin, `{}`, nullable.Time{})
+		require.NoError(t, err)
+		job.TraceContext = tc
+		return job
+	}
+
+	single := newJob(t, tc)
+	require.NoError(t, f.db.AddJob(t.Context(), single))
+	batch := newJob(t, tc)
+	untraced := newJob(t, jobqueue.TraceContext{})
+	require.NoError(t, f.db.AddJobs(t.Context(), []*jobqueue.Job{batch, untraced}))
+	bundle, err := jobqueue.NewJobBundle(t.Context(), f.jobType, f.origin, []jobqueue.JobDesc{{Type: f.jobType, Payload: `{}`, Origin: f.origin}}, nullable.Time{})
+	require.NoError(t, err)
+	bundle.Jobs[0].TraceContext = tc
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+
+	for _, job := range []*jobqueue.Job{single, batch, bundle.Jobs[0]} {
+		assert.Equal(t, tc, f.getJob(t, job.ID).TraceContext)
+	}
+	assert.True(t, f.getJob(t, untraced.ID).TraceContext.IsZero(), "NULL trace_context")
+
+	claimed := f.claimJob(t, single.ID)
+	assert.Equal(t, tc, claimed.TraceContext, "claimed job has the trace context")
+}
+
+// testJobAvailableListener checks that the job available callback is called
+// for a job that can be started immediately.
+func testJobAvailableListener(t *testing.T, f *fixture) {
+	var called atomic.Int64
+	require.NoError(t, f.db.SetJobAvailableListener(t.Context(), func() { called.Add(1) }))
+	t.Cleanup(func() { _ = f.db.SetJobAvailableListener(context.Background(), nil) })
+
+	f.addJob(t, 0, nullable.Time{}, 0)
+	eventually(t, func() bool { return called.Load() > 0 }, "job available callback")
+}
+
+// testGetJobNotFound checks that loading missing rows returns
+// an error that errs.IsErrNotFound recognizes.
+func testGetJobNotFound(t *testing.T, f *fixture) {
+	_, err := f.db.GetJob(t.Context(), uu.NewID(t.Context()))
+	assert.True(t, errs.IsErrNotFound(err), "GetJob of a missing job: %v", err)
+
+	_, err = f.db.GetJobBundle(t.Context(), uu.NewID(t.Context()))
+	assert.True(t, errs.IsErrNotFound(err), "GetJobBundle of a missing bundle: %v", err)
+}
+
+// testQueryMethods checks the job selection of the GetAllJobs* methods.
+func testQueryMethods(t *testing.T, f *fixture) {
+	running := f.addJob(t, 0, nullable.Time{}, 0)
+	succeeded := f.addJob(t, 0, nullable.Time{}, 0)
+	failed := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, running.ID)
+	f.claimJob(t, succeeded.ID)
+	f.claimJob(t, failed.ID)
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeded.ID, nil))
+	require.NoError(t, f.db.SetJobError(t.Context(), failed.ID, "dbtest error", nil))
+	waiting := f.addJob(t, 0, nullable.Time{}, 0)
+
+	ids := func(jobs []*jobqueue.Job) (ids uu.IDs) {
+		for _, job := range jobs {
+			if job.Origin == f.origin {
+				ids = append(ids, job.ID)
+			}
+		}
+		return ids
+	}
+
+	toDo, err := f.db.GetAllJobsToDo(t.Context())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, uu.IDs{waiting.ID, running.ID}, ids(toDo), "GetAllJobsToDo returns jobs that are not stopped")
+
+	startedBefore, err := f.db.GetAllJobsStartedBefore(t.Context(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uu.IDs{running.ID}, ids(startedBefore), "GetAllJobsStartedBefore returns started and not stopped jobs")
+
+	startedBefore, err = f.db.GetAllJobsStartedBefore(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, ids(startedBefore), "GetAllJobsStartedBefore respects before")
+
+	withErrors, err := f.db.GetAllJobsWithErrors(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, uu.IDs{failed.ID}, ids(withErrors))
+
+	status, err := f.db.GetStatus(t.Context())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, status.NumJobs, 4)
+
+	// A cancelled job is stopped without error and result like a succeeded one
+	cancelled := f.addJob(t, 0, nullable.Time{}, 0)
+	blocked := f.addDependentJob(t, 0, jobqueue.DependencyFailureBlock, cancelled.ID)
+	require.NoError(t, f.db.CancelJob(t.Context(), cancelled.ID))
+	cancelledStandalone := f.addJob(t, 0, nullable.Time{}, 0)
+	require.NoError(t, f.db.CancelJob(t.Context(), cancelledStandalone.ID))
+
+	// DeleteFinishedJobs deletes only successfully finished standalone jobs
+	// and keeps cancelled jobs that block their dependents
+	require.NoError(t, f.db.DeleteFinishedJobs(t.Context()))
+	_, err = f.db.GetJob(t.Context(), succeeded.ID)
+	assert.True(t, errs.IsErrNotFound(err), "succeeded job deleted")
+	_, err = f.db.GetJob(t.Context(), cancelledStandalone.ID)
+	assert.True(t, errs.IsErrNotFound(err), "cancelled job without dependents deleted")
+	for _, id := range []uu.ID{waiting.ID, running.ID, failed.ID, cancelled.ID, blocked.ID} {
+		f.getJob(t, id)
+	}
+	assert.Equal(t, uu.IDs{cancelled.ID}, f.getJob(t, blocked.ID).DependsOn, "dependency on the cancelled job kept")
+}
+
+// testGetJobs checks the job selection of every JobFilter field of GetJobs.
+func testGetJobs(t *testing.T, f *fixture) {
+	failed := f.addJob(t, 0, nullable.Time{}, 0)
+	f.claimJob(t, failed.ID)
+	require.NoError(t, f.db.SetJobError(t.Context(), failed.ID, "dbtest error 42", nil))
+	pending := f.addJob(t, 0, nullable.Time{}, 0)
+	delayed := f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)
+	otherOrigin, err := jobqueue.NewJob(uu.NewID(t.Context()), f.jobType, f.origin+"-other", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJob(t.Context(), otherOrigin))
+	t.Cleanup(func() { _ = f.db.DeleteJobsFromOrigin(context.Background(), otherOrigin.Origin) })
+	desc := jobqueue.JobDesc{Type: f.jobType + "-bundled", Payload: `{}`, Origin: f.origin}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin, []jobqueue.JobDesc{desc}, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+
+	getJobIDs := func(filter *jobqueue.JobFilter) uu.IDs {
+		t.Helper()
+		jobs, err := f.db.GetJobs(t.Context(), filter)
+		require.NoError(t, err)
+		ids := make(uu.IDs, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, uu.IDs{failed.ID, pending.ID, delayed.ID, otherOrigin.ID}, getJobIDs(&jobqueue.JobFilter{Type: f.jobType}), "type, ordered by created_at")
+	assert.Equal(t, uu.IDs{failed.ID, pending.ID}, getJobIDs(&jobqueue.JobFilter{Type: f.jobType, Limit: 2}), "limit")
+	assert.Equal(t, uu.IDs{otherOrigin.ID, delayed.ID}, getJobIDs(&jobqueue.JobFilter{Type: f.jobType, NewestFirst: true, Limit: 2}), "newest first")
+	assert.Equal(t, uu.IDs{failed.ID, pending.ID, delayed.ID, bundle.Jobs[0].ID}, getJobIDs(&jobqueue.JobFilter{Origin: f.origin}), "origin")
+	assert.Equal(t, uu.IDs{otherOrigin.ID}, getJobIDs(&jobqueue.JobFilter{Type: f.jobType, Origin: otherOrigin.Origin}), "type and origin")
+	assert.Equal(t, uu.IDs{bundle.Jobs[0].ID}, getJobIDs(&jobqueue.JobFilter{BundleID: bundle.ID.Nullable()}), "bundle")
+	assert.Equal(t, uu.IDs{pending.ID, bundle.Jobs[0].ID}, getJobIDs(&jobqueue.JobFilter{Origin: f.origin, State: jobqueue.JobStatePending}), "pending")
+	assert.Equal(t, uu.IDs{delayed.ID}, getJobIDs(&jobqueue.JobFilter{Origin: f.origin, State: jobqueue.JobStateDelayed}), "delayed")
+	assert.Equal(t, uu.IDs{failed.ID}, getJobIDs(&jobqueue.JobFilter{Origin: f.origin, State: jobqueue.JobStateFailed}), "failed")
+	assert.Equal(t, uu.IDs{failed.ID}, getJobIDs(&jobqueue.JobFilter{Origin: f.origin, ErrorPattern: `error [0-9]+$`}), "error pattern")
+	assert.Empty(t, getJobIDs(&jobqueue.JobFilter{Origin: f.origin, ErrorPattern: `^error`}), "error pattern not matching")
+
+	all, err := f.db.GetJobs(t.Context(), nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(all), 5, "nil filter")
+
+	_, err = f.db.GetJobs(t.Context(), &jobqueue.JobFilter{State: "invalid"})
+	assert.Error(t, err, "invalid state")
+	_, err = f.db.GetJobs(t.Context(), &jobqueue.JobFilter{ErrorPattern: `(`})
+	assert.Error(t, err, "invalid error pattern")
+}
+
+// testStatus checks the lifecycle counts and ages of GetStatus
+// for all jobs, per job type, and per origin.
+func testStatus(t *testing.T, f *fixture) {
+	f.addJob(t, 0, nullable.Time{}, 0)                               // pending
+	f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(-time.Hour)), 0) // pending
+	f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)  // delayed
+	cancelled := f.addJob(t, 0, nullable.TimeFrom(time.Now().Add(time.Hour)), 0)
+	require.NoError(t, f.db.CancelJob(t.Context(), cancelled.ID))
+
+	running := f.addJob(t, 5, nullable.Time{}, 0)
+	retrying := f.addJob(t, 4, nullable.Time{}, 3)
+	failed := f.addJob(t, 3, nullable.Time{}, 0)
+	succeeded := f.addJob(t, 2, nullable.Time{}, 0)
+	f.claimJob(t, running.ID)
+	f.claimJob(t, retrying.ID)
+	f.claimJob(t, failed.ID)
+	f.claimJob(t, succeeded.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), retrying.ID, time.Now().Add(time.Hour), 1, "dbtest retry", nil))
+	require.NoError(t, f.db.SetJobError(t.Context(), failed.ID, "dbtest error", nil))
+	require.NoError(t, f.db.SetJobResult(t.Context(), succeeded.ID, nil))
+
+	status, err := f.db.GetStatus(t.Context())
+	require.NoError(t, err)
+
+	stats := status.ByType[f.jobType]
+	assert.Equal(t, 2, stats.Pending, "pending")
+	assert.Equal(t, 1, stats.Delayed, "delayed")
+	assert.Equal(t, 1, stats.Running, "running")
+	assert.Equal(t, 1, stats.Retrying, "retrying")
+	assert.Equal(t, 1, stats.Failed, "failed")
+	assert.Equal(t, 1, stats.Cancelled, "cancelled")
+	assert.Equal(t, 1, stats.Succeeded, "succeeded")
+	assert.GreaterOrEqual(t, stats.OldestPendingAge, time.Duration(0))
+	assert.Less(t, stats.OldestPendingAge, time.Minute, "pending since the job was added")
+	if f.pool.Claim().Heartbeat {
+		assert.GreaterOrEqual(t, stats.OldestHeartbeatAge, time.Duration(0))
+		assert.Less(t, stats.OldestHeartbeatAge, time.Minute)
+	}
+	assert.Equal(t, stats, status.ByOrigin[f.origin], "all jobs of the type have the same origin")
+
+	assert.Equal(t, status.NumJobs, status.Jobs.NumJobs(), "every job counted in one state")
+	assert.GreaterOrEqual(t, status.Jobs.Pending, stats.Pending)
+	assert.GreaterOrEqual(t, status.Jobs.OldestPendingAge, stats.OldestPendingAge)
+
+	// A retry whose time has passed waits to be claimed like a pending job
+	require.NoError(t, f.db.DeleteJobsFromOrigin(t.Context(), f.origin))
+	dueRetry := f.addJob(t, 0, nullable.Time{}, 3)
+	f.claimJob(t, dueRetry.ID)
+	require.NoError(t, f.db.ScheduleRetry(t.Context(), dueRetry.ID, time.Now().Add(-time.Minute), 1, "dbtest retry", nil))
+
+	status, err = f.db.GetStatus(t.Context())
+	require.NoError(t, err)
+	stats = status.ByOrigin[f.origin]
+	assert.Equal(t, 1, stats.Retrying, "retrying")
+	assert.Equal(t, 0, stats.Pending, "pending")
+	assert.Greater(t, stats.OldestPendingAge, time.Duration(0), "due retry can be started")
+	assert.Less(t, stats.OldestPendingAge, time.Minute, "available since the job was added")
+}
+
+// testDeleteMethods checks the delete methods by ID, type and origin.
+func testDeleteMethods(t *testing.T, f *fixture) {
+	byID := f.addJob(t, 0, nullable.Time{}, 0)
+	require.NoError(t, f.db.DeleteJob(t.Context(), byID.ID))
+	_, err := f.db.GetJob(t.Context(), byID.ID)
+	assert.True(t, errs.IsErrNotFound(err), "DeleteJob")
+
+	require.NoError(t, f.db.DeleteJob(t.Context(), uu.NewID(t.Context())), "deleting a missing job is no error")
+
+	byType := f.addJob(t, 0, nullable.Time{}, 0)
+	require.NoError(t, f.db.DeleteJobsOfType(t.Context(), f.jobType))
+	_, err = f.db.GetJob(t.Context(), byType.ID)
+	assert.True(t, errs.IsErrNotFound(err), "DeleteJobsOfType")
+
+	byOrigin := f.addJob(t, 0, nullable.Time{}, 0)
+	require.NoError(t, f.db.DeleteJobsFromOrigin(t.Context(), f.origin))
+	_, err = f.db.GetJob(t.Context(), byOrigin.ID)
+	assert.True(t, errs.IsErrNotFound(err), "DeleteJobsFromOrigin")
+
+	desc := jobqueue.JobDesc{Type: f.jobType, Payload: `{}`, Origin: f.origin}
+	bundleType := "dbtest-bundle-" + f.origin
+	for _, deleteBundles := range []func() error{
+		func() error { return f.db.DeleteJobBundlesFromOrigin(t.Context(), f.origin) },
+		func() error { return f.db.DeleteJobBundlesOfType(t.Context(), bundleType) },
+	} {
+		bundle, err := jobqueue.NewJobBundle(t.Context(), bundleType, f.origin, []jobqueue.JobDesc{desc}, nullable.Time{})
+		require.NoError(t, err)
+		require.NoError(t, f.db.AddJobBundle(t.Context(), bundle))
+
+		require.NoError(t, deleteBundles())
+		_, err = f.db.GetJobBundle(t.Context(), bundle.ID)
+		assert.True(t, errs.IsErrNotFound(err), "bundle deleted")
+		_, err = f.db.GetJob(t.Context(), bundle.Jobs[0].ID)
+		assert.True(t, errs.IsErrNotFound(err), "bundled job deleted with its bundle")
+	}
+}
+
+// testClose checks that Close can only be called once
+// and that all methods return jobqueue.ErrClosed afterwards.
+func testClose(t *testing.T, f *fixture) {
+	id := uu.NewID(t.Context())
+	job, err := jobqueue.NewJob(id, f.jobType, f.origin, `{}`, nullable.Time{})
+	require.NoError(t, err)
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "dbtest-bundle", f.origin,
+		[]jobqueue.JobDesc{{Type: f.jobType, Payload: `{}`, Origin: f.origin}}, nullable.Time{})
+	require.NoError(t, err)
+
+	require.NoError(t, f.db.Close(), "first Close")
+	require.ErrorIs(t, f.db.Close(), jobqueue.ErrClosed, "second Close")
+
+	ctx := t.Context()
+	calls := []struct {
+		name string
+		fn   func() error
+	}{
+		{"AddListener", func() error { return f.db.AddListener(ctx, new(listener)) }},
+		{"AddJob", func() error { return f.db.AddJob(ctx, job) }},
+		{"AddJobs", func() error { return f.db.AddJobs(ctx, []*jobqueue.Job{job}) }},
+		{"AddJobBundle", func() error { return f.db.AddJobBundle(ctx, bundle) }},
+		{"GetJob", func() error { _, e := f.db.GetJob(ctx, id); return e }},
+		{"GetJobAttempts", func() error { _, e := f.db.GetJobAttempts(ctx, id); return e }},
+		{"GetJobBundle", func() error { _, e := f.db.GetJobBundle(ctx, id); return e }},
+		{"GetStatus", func() error { _, e := f.db.GetStatus(ctx); return e }},
+		{"GetAllJobsToDo", func() error { _, e := f.db.GetAllJobsToDo(ctx); return e }},
+		{"GetAllJobsStartedBefore", func() error { _, e := f.db.GetAllJobsStartedBefore(ctx, time.Now()); return e }},
+		{"GetAllJobsWithErrors", func() error { _, e := f.db.GetAllJobsWithErrors(ctx); return e }},
+		{"GetJobs", func() error { _, e := f.db.GetJobs(ctx, nil); return e }},
+		{"StartNextJobOrNil", func() error { _, e := f.db.StartNextJobOrNil(ctx, f.pool.Claim()); return e }},
+		{"StartNextJobsOrNil", func() error { _, e := f.db.StartNextJobsOrNil(ctx, f.pool.Claim(), 2); return e }},
+		{"UnclaimJobs", func() error { return f.db.UnclaimJobs(ctx, uu.IDs{id}) }},
+		{"GetNextJobStartDelay", func() error { _, _, e := f.db.GetNextJobStartDelay(ctx, f.pool.Claim()); return e }},
+		{"SetJobError", func() error { return f.db.SetJobError(ctx, id, "dbtest error", nil) }},
+		{"SetJobResult", func() error { return f.db.SetJobResult(ctx, id, nil) }},
+		{"SetJobStart", func() error { return f.db.SetJobStart(ctx, id, time.Now()) }},
+		{"SetJobWorkerAlive", func() error { return f.db.SetJobWorkerAlive(ctx, id) }},
+		{"ScheduleRetry", func() error { return f.db.ScheduleRetry(ctx, id, time.Now(), 1, "error", nil) }},
+		{"SnoozeJob", func() error { return f.db.SnoozeJob(ctx, id, time.Now()) }},
+		{"ResetJob", func() error { return f.db.ResetJob(ctx, id) }},
+		{"ResetJobs", func() error { return f.db.ResetJobs(ctx, uu.IDs{id}) }},
+		{"CancelJob", func() error { return f.db.CancelJob(ctx, id) }},
+		{"SetJobCancelled", func() error { return f.db.SetJobCancelled(ctx, id) }},
+		{"SaveJobSchedule", func() error {
+			_, e := f.db.SaveJobSchedule(ctx, &jobqueue.JobSchedule{Name: f.origin, CronExpr: "@daily", Timezone: "UTC"})
+			return e
+		}},
+		{"AddScheduledJobs", func() error { _, e := f.db.AddScheduledJobs(ctx, f.origin, nil); return e }},
+		{"DeleteJobSchedule", func() error { return f.db.DeleteJobSchedule(ctx, f.origin) }},
+		{"DeleteJob", func() error { return f.db.DeleteJob(ctx, id) }},
+		{"DeleteFinishedJobs", func() error { return f.db.DeleteFinishedJobs(ctx) }},
+		{"DeleteJobsFromOrigin", func() error { return f.db.DeleteJobsFromOrigin(ctx, f.origin) }},
+		{"DeleteJobsOfType", func() error { return f.db.DeleteJobsOfType(ctx, f.jobType) }},
+		{"DeleteJobBundle", func() error { return f.db.DeleteJobBundle(ctx, id) }},
+		{"DeleteJobBundlesFromOrigin", func() error { return f.db.DeleteJobBundlesFromOrigin(ctx, f.origin) }},
+		{"DeleteJobBundlesOfType", func() error { return f.db.DeleteJobBundlesOfType(ctx, "dbtest-bundle") }},
+		{"DeleteAllJobsAndBundles", func() error { return f.db.DeleteAllJobsAndBundles(ctx) }},
+	}
+	for _, c := range calls {
+		t.Run(c.name, func(t *testing.T) {
+			require.ErrorIs(t, c.fn(), jobqueue.ErrClosed)
+		})
+	}
+}
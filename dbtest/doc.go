@@ -0,0 +1,46 @@
+/*
+Package dbtest provides a conformance test suite for implementations
+of the jobworker.DataBase interface.
+
+# Overview
+
+The jobworkerdb package is the reference implementation. An alternative
+backend proves that it behaves the same way by running [RunConformance]
+from a regular test function:
+
+	func TestConformance(t *testing.T) {
+		dbtest.RunConformance(t, func(t *testing.T) jobworker.DataBase {
+			db := mybackend.NewDataBase()
+			t.Cleanup(func() { _ = db.Close() })
+			return db
+		})
+	}
+
+The suite covers job claim ordering, exclusive claims under concurrent
+StartNextJobOrNil calls, batch claims with StartNextJobsOrNil and
+UnclaimJobs, start_at, ScheduleRetry, SnoozeJob, SetJobStart, SetJobResult,
+SetJobError retry count clamping, ResetJob, the worker heartbeat guard,
+the heartbeat of the worker threads of a Pool including while a retry
+is scheduled, CancelJob of waiting and running jobs,
+job bundle completion counting including ResetJobs and retries,
+job dependencies and their failure policies,
+the trace context of added jobs, ServiceListener notifications,
+the query methods including the JobFilter of GetJobs,
+the delete methods, and the behavior after Close.
+
+# Isolation
+
+Every sub-test creates its own DataBase with newDB and uses job types and
+origins that are unique to the test run, so the suite also works against a
+shared database that contains other jobs. Jobs and job bundles created by a
+sub-test are deleted by origin on cleanup.
+
+Every sub-test registers its job type with a jobworker.Pool of its own
+and claims jobs with the jobworker.Claim of that Pool, so the suite
+does not change the default Pool of the jobworker package.
+The heartbeat sub-tests start a worker thread of such a Pool.
+
+Notifications may be delivered asynchronously, for example by PostgreSQL
+LISTEN/NOTIFY, so the suite waits up to [NotificationTimeout] for them.
+*/
+package dbtest
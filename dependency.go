@@ -0,0 +1,55 @@
+package jobqueue
+
+import "fmt"
+
+// DependencyFailurePolicy defines what happens to a job
+// whose dependency (see Job.DependsOn) has finally failed,
+// meaning it stopped with an error and will not be retried,
+// or it was cancelled.
+type DependencyFailurePolicy string
+
+const (
+	// DependencyFailureBlock leaves the dependent job waiting
+	// until the failed dependency is reset and succeeds.
+	// Deleting a dependency that did not succeed cancels the dependent job.
+	// This is the default used for an empty DependencyFailurePolicy.
+	DependencyFailureBlock DependencyFailurePolicy = "block"
+
+	// DependencyFailureCancel cancels the dependent job
+	// like CancelJob would do for a job that was not started yet,
+	// also when a dependency that did not succeed is deleted.
+	// The cancellation cascades to jobs depending on the cancelled
+	// job that also use DependencyFailureCancel.
+	DependencyFailureCancel DependencyFailurePolicy = "cancel"
+
+	// DependencyFailureRun runs the dependent job anyway
+	// once all its dependencies have either succeeded or finally failed.
+	DependencyFailureRun DependencyFailurePolicy = "run"
+)
+
+// Valid returns true if the policy is one of the defined constants
+// or empty, which is interpreted as DependencyFailureBlock.
+func (p DependencyFailurePolicy) Valid() bool {
+	switch p {
+	case "", DependencyFailureBlock, DependencyFailureCancel, DependencyFailureRun:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the policy is not Valid.
+func (p DependencyFailurePolicy) Validate() error {
+	if !p.Valid() {
+		return fmt.Errorf("invalid DependencyFailurePolicy %q", string(p))
+	}
+	return nil
+}
+
+// OrDefault returns DependencyFailureBlock for an empty policy
+// and the policy itself otherwise.
+func (p DependencyFailurePolicy) OrDefault() DependencyFailurePolicy {
+	if p == "" {
+		return DependencyFailureBlock
+	}
+	return p
+}
@@ -0,0 +1,28 @@
+package jobqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestDependencyFailurePolicy(t *testing.T) {
+	for _, policy := range []jobqueue.DependencyFailurePolicy{
+		"",
+		jobqueue.DependencyFailureBlock,
+		jobqueue.DependencyFailureCancel,
+		jobqueue.DependencyFailureRun,
+	} {
+		assert.True(t, policy.Valid(), "policy %q", policy)
+		assert.NoError(t, policy.Validate(), "policy %q", policy)
+	}
+
+	invalid := jobqueue.DependencyFailurePolicy("ignore")
+	assert.False(t, invalid.Valid())
+	assert.Error(t, invalid.Validate())
+
+	assert.Equal(t, jobqueue.DependencyFailureBlock, jobqueue.DependencyFailurePolicy("").OrDefault())
+	assert.Equal(t, jobqueue.DependencyFailureRun, jobqueue.DependencyFailureRun.OrDefault())
+}
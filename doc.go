@@ -49,6 +49,15 @@ First, initialize the service and register workers:
 Jobs are defined by a type string and a JSON payload. Workers are registered
 for specific job types and process jobs with matching types.
 
+A JobKind is a typed handle for a job type with a payload type.
+Sharing it between the code adding jobs with JobKind.Add and the
+worker registered with jobworker.RegisterJobKind makes a mismatched
+payload type a compile error:
+
+	var SendEmail = jobqueue.NewJobKind[*EmailPayload]("send-email")
+
+	job, err := SendEmail.Add(ctx, payload, jobqueue.WithOrigin("signup"), jobqueue.WithPriority(10))
+
 # Job Lifecycle
 
 1. Created - Job is inserted into the database
@@ -73,11 +82,37 @@ Jobs can be configured with a maximum retry count. If a job fails and hasn't
 exceeded the retry count, a retry scheduler function determines when to retry.
 Register retry schedulers using jobworker.RegisterScheduleRetry.
 
+Every ended attempt of a job is recorded with its outcome, timing, error,
+and worker process. Use GetJobAttempts to read the history of a job.
+
 # Job Bundles
 
 Related jobs can be grouped into bundles. The bundle tracks completion of all
 jobs and provides a single notification when all jobs are finished.
 
+# Job Dependencies
+
+A job with Job.DependsOn is only started after all the jobs it depends on
+have succeeded. Job.OnDependencyFailure defines with a DependencyFailurePolicy
+if the job keeps waiting, is cancelled, or runs anyway when a dependency
+finally failed or was cancelled.
+
+# Job Deduplication
+
+A job with a Job.UniqueKey is not added while an unfinished job of the same
+type with the same key exists. Job.OnDuplicate defines with a
+DuplicateJobPolicy if adding it fails with a DuplicateJobError,
+uses the existing job, or replaces the payload and start time of the existing job.
+
+# Recurring Jobs
+
+jobworker.RegisterSchedule registers a JobSchedule that adds a job for every
+occurrence of a cron expression, see the cron package. The next occurrence is
+added in advance with its fire time as start time, and Job.ScheduleName and
+Job.ScheduleFireTime identify the occurrence so that it is only added once,
+even with multiple worker processes. ScheduleCatchUp defines which occurrences
+are run that were missed while no worker process was running.
+
 # Multiple Worker Processes
 
 Worker pools can run in multiple processes concurrently against the same
@@ -122,7 +157,8 @@ GetService.
 
 For testing, jobs can be executed synchronously without database persistence using
 jobworkerdb.ContextWithSynchronousJobs, or ignored entirely using
-jobworkerdb.ContextWithIgnoreJob.
+jobworkerdb.ContextWithIgnoreJob. The memqueue package provides an in-memory
+implementation of the service that needs no database at all.
 
 # Error Handling
 
@@ -27,6 +27,11 @@ func (doNothingService) AddJob(ctx context.Context, job *Job) error {
 	return nil
 }
 
+func (doNothingService) AddJobs(ctx context.Context, jobs []*Job) error {
+	log.Info("DoNothingService.AddJobs").Log()
+	return nil
+}
+
 func (doNothingService) GetJob(ctx context.Context, jobID uu.ID) (*Job, error) {
 	return nil, errors.New("DoNothingService.GetJob can't return jobs")
 }
@@ -36,6 +41,11 @@ func (doNothingService) DeleteJob(ctx context.Context, jobID uu.ID) error {
 	return nil
 }
 
+func (doNothingService) GetJobAttempts(ctx context.Context, jobID uu.ID) ([]*JobAttempt, error) {
+	log.Info("DoNothingService.GetJobAttempts").Log()
+	return nil, nil
+}
+
 func (doNothingService) ResetJob(ctx context.Context, jobID uu.ID) error {
 	log.Info("DoNothingService.ResetJob").Log()
 	return nil
@@ -46,6 +56,11 @@ func (doNothingService) ResetJobs(ctx context.Context, jobIDs uu.IDs) error {
 	return nil
 }
 
+func (doNothingService) CancelJob(ctx context.Context, jobID uu.ID) error {
+	log.Info("DoNothingService.CancelJob").Log()
+	return nil
+}
+
 func (doNothingService) AddJobBundle(ctx context.Context, jobBundle *JobBundle) error {
 	log.Info("DoNothingService.AddJobBundle").Log()
 	return nil
@@ -80,6 +95,11 @@ func (doNothingService) GetAllJobsWithErrors(context.Context) ([]*Job, error) {
 	return nil, nil
 }
 
+func (doNothingService) GetJobs(ctx context.Context, filter *JobFilter) ([]*Job, error) {
+	log.Info("DoNothingService.GetJobs").Log()
+	return nil, nil
+}
+
 func (doNothingService) DeleteFinishedJobs(ctx context.Context) error {
 	log.Info("DoNothingService.DeleteFinishedJobs").Log()
 	return nil
@@ -0,0 +1,84 @@
+package jobqueue
+
+import (
+	"fmt"
+
+	"github.com/domonda/go-types/uu"
+)
+
+// DuplicateJobPolicy defines what happens when a job with a UniqueKey is added
+// while an unfinished job of the same type with the same UniqueKey exists.
+// A job is unfinished until it has stopped, so waiting, running,
+// and jobs scheduled for a retry are unfinished.
+type DuplicateJobPolicy string
+
+const (
+	// DuplicateJobFail returns a DuplicateJobError wrapping ErrDuplicateJob
+	// and does not add the job.
+	// This is the default used for an empty DuplicateJobPolicy.
+	DuplicateJobFail DuplicateJobPolicy = "fail"
+
+	// DuplicateJobUseExisting does not add the job and sets the
+	// ID of the added Job to the ID of the existing job
+	// without returning an error.
+	DuplicateJobUseExisting DuplicateJobPolicy = "use-existing"
+
+	// DuplicateJobReplace replaces the payload and start_at of the
+	// existing job with the ones of the added job and sets the ID
+	// of the added Job to the ID of the existing job.
+	// Other fields of the existing job are not changed.
+	// If the existing job was already started then it is not changed
+	// and a DuplicateJobError is returned.
+	DuplicateJobReplace DuplicateJobPolicy = "replace"
+)
+
+// Valid returns true if the policy is one of the defined constants
+// or empty, which is interpreted as DuplicateJobFail.
+func (p DuplicateJobPolicy) Valid() bool {
+	switch p {
+	case "", DuplicateJobFail, DuplicateJobUseExisting, DuplicateJobReplace:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the policy is not Valid.
+func (p DuplicateJobPolicy) Validate() error {
+	if !p.Valid() {
+		return fmt.Errorf("invalid DuplicateJobPolicy %q", string(p))
+	}
+	return nil
+}
+
+// OrDefault returns DuplicateJobFail for an empty policy
+// and the policy itself otherwise.
+func (p DuplicateJobPolicy) OrDefault() DuplicateJobPolicy {
+	if p == "" {
+		return DuplicateJobFail
+	}
+	return p
+}
+
+// DuplicateJobError is returned when a job could not be added
+// because of an unfinished job with the same type and UniqueKey,
+// see DuplicateJobPolicy.
+// It wraps ErrDuplicateJob, so errors.Is(err, ErrDuplicateJob) can be used
+// and errors.As to get the ID of the existing job.
+type DuplicateJobError struct {
+	// JobType of the added and the existing job
+	JobType string
+	// UniqueKey of the added and the existing job
+	UniqueKey string
+	// ExistingJobID is the ID of the unfinished job with the same UniqueKey
+	ExistingJobID uu.ID
+}
+
+// Error implements the error interface.
+func (e *DuplicateJobError) Error() string {
+	return fmt.Sprintf("%s: job %s of type %s has the same unique key %q", ErrDuplicateJob, e.ExistingJobID, e.JobType, e.UniqueKey)
+}
+
+// Unwrap returns ErrDuplicateJob.
+func (e *DuplicateJobError) Unwrap() error {
+	return ErrDuplicateJob
+}
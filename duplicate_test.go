@@ -0,0 +1,47 @@
+package jobqueue_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestDuplicateJobPolicy(t *testing.T) {
+	for _, policy := range []jobqueue.DuplicateJobPolicy{
+		"",
+		jobqueue.DuplicateJobFail,
+		jobqueue.DuplicateJobUseExisting,
+		jobqueue.DuplicateJobReplace,
+	} {
+		assert.True(t, policy.Valid(), "policy %q", policy)
+		assert.NoError(t, policy.Validate(), "policy %q", policy)
+	}
+
+	invalid := jobqueue.DuplicateJobPolicy("ignore")
+	assert.False(t, invalid.Valid())
+	assert.Error(t, invalid.Validate())
+
+	assert.Equal(t, jobqueue.DuplicateJobFail, jobqueue.DuplicateJobPolicy("").OrDefault())
+	assert.Equal(t, jobqueue.DuplicateJobReplace, jobqueue.DuplicateJobReplace.OrDefault())
+}
+
+func TestDuplicateJobError(t *testing.T) {
+	existingID := uu.IDFrom("3d0c7f6e-2b1a-4c5d-8e9f-0a1b2c3d4e5f")
+	err := fmt.Errorf("wrapped: %w", &jobqueue.DuplicateJobError{
+		JobType:       "send-email",
+		UniqueKey:     "webhook-123",
+		ExistingJobID: existingID,
+	})
+
+	assert.ErrorIs(t, err, jobqueue.ErrDuplicateJob)
+	var duplicate *jobqueue.DuplicateJobError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, existingID, duplicate.ExistingJobID)
+	assert.Contains(t, err.Error(), "webhook-123")
+}
@@ -16,6 +16,15 @@ const (
 
 	// ErrClosed is returned by Service operations after the service has been closed.
 	ErrClosed errs.Sentinel = "jobqueue is closed"
+
+	// ErrJobCancelled is the cause of the context passed to a job worker
+	// function when the job was cancelled with CancelJob.
+	// Use context.Cause(ctx) to distinguish it from other cancellations.
+	ErrJobCancelled errs.Sentinel = "job cancelled"
+
+	// ErrDuplicateJob is wrapped by the DuplicateJobError returned when adding
+	// a job with a UniqueKey that is already used by an unfinished job.
+	ErrDuplicateJob errs.Sentinel = "duplicate job"
 )
 
 var _ Service = errService{}
@@ -34,14 +43,19 @@ func ServiceWithError(err error) Service {
 
 func (e errService) AddListener(context.Context, ServiceListener) error           { return e.err }
 func (e errService) AddJob(ctx context.Context, job *Job) error                   { return e.err }
+func (e errService) AddJobs(ctx context.Context, jobs []*Job) error               { return e.err }
 func (e errService) GetJob(ctx context.Context, jobID uu.ID) (*Job, error)        { return nil, e.err }
 func (e errService) DeleteJob(ctx context.Context, jobID uu.ID) error             { return e.err }
 func (e errService) ResetJob(ctx context.Context, jobID uu.ID) error              { return e.err }
 func (e errService) ResetJobs(ctx context.Context, jobIDs uu.IDs) error           { return e.err }
+func (e errService) CancelJob(ctx context.Context, jobID uu.ID) error             { return e.err }
 func (e errService) AddJobBundle(ctx context.Context, jobBundle *JobBundle) error { return e.err }
 func (e errService) GetJobBundle(ctx context.Context, jobBundleID uu.ID) (*JobBundle, error) {
 	return nil, e.err
 }
+func (e errService) GetJobAttempts(ctx context.Context, jobID uu.ID) ([]*JobAttempt, error) {
+	return nil, e.err
+}
 func (e errService) DeleteJobBundle(ctx context.Context, jobBundleID uu.ID) error { return e.err }
 func (e errService) GetStatus(context.Context) (*Status, error)                   { return nil, e.err }
 func (e errService) GetAllJobsToDo(context.Context) ([]*Job, error)               { return nil, e.err }
@@ -49,5 +63,8 @@ func (e errService) GetAllJobsWithErrors(context.Context) ([]*Job, error)
 func (e errService) GetAllJobsStartedBefore(ctx context.Context, since time.Time) ([]*Job, error) {
 	return nil, e.err
 }
+func (e errService) GetJobs(ctx context.Context, filter *JobFilter) ([]*Job, error) {
+	return nil, e.err
+}
 func (e errService) DeleteFinishedJobs(ctx context.Context) error { return e.err }
 func (e errService) Close() error                                 { return e.err }
@@ -14,7 +14,7 @@ import (
 
 // Job is an in-memory snapshot of a worker.job row as it was read from the
 // database. Its predicate methods (Started, Stopped, StartedAndNotStopped,
-// IsFinished, Succeeded, HasError, WorkerAlive) evaluate this snapshot and do
+// IsFinished, Succeeded, Cancelled, HasError, WorkerAlive) evaluate this snapshot and do
 // NOT re-query the database for the current state — a concurrently running
 // worker (in this or another process) may have moved the job on since it was
 // loaded.
@@ -39,9 +39,22 @@ type Job struct {
 	CurrentRetryCount int           `db:"current_retry_count"   json:"currentRetryCount"` // Number of retries already attempted
 	StartAt           nullable.Time `db:"start_at" json:"startAt"`                        // If not NULL, earliest time to start the job
 
-	StartedAt     nullable.Time `db:"started_at"      json:"startedAt"`     // Time when started working on the job, or NULL when not started
-	WorkerAliveAt nullable.Time `db:"worker_alive_at" json:"workerAliveAt"` // Heartbeat updated periodically while a worker processes the job, NULL when not being processed. A stale value while StoppedAt is NULL indicates the worker crashed.
-	StoppedAt     nullable.Time `db:"stopped_at"      json:"stoppedAt"`     // Time when working on job was stoped because of a decision question or an error, or NULL
+	OnDependencyFailure DependencyFailurePolicy `db:"on_dependency_failure" json:"onDependencyFailure"` // What happens to the job when one of DependsOn finally failed, empty means DependencyFailureBlock
+
+	UniqueKey nullable.NonEmptyString `db:"unique_key" json:"uniqueKey"` // If not NULL, no other unfinished job of the same type can have the same key
+
+	ScheduleName     nullable.NonEmptyString `db:"schedule_name"      json:"scheduleName"`     // Name of the JobSchedule that added the job, or NULL
+	ScheduleFireTime nullable.Time           `db:"schedule_fire_time" json:"scheduleFireTime"` // Fire time of the JobSchedule occurrence, unique per ScheduleName
+
+	StartedAt     nullable.Time           `db:"started_at"      json:"startedAt"`     // Time when started working on the job, or NULL when not started
+	Worker        nullable.NonEmptyString `db:"worker"          json:"worker"`        // jobworker.WorkerName of the process that claimed the job, NULL when not being processed
+	WorkerAliveAt nullable.Time           `db:"worker_alive_at" json:"workerAliveAt"` // Heartbeat updated periodically while a worker processes the job, NULL when not being processed. A stale value while StoppedAt is NULL indicates the worker crashed.
+	StoppedAt     nullable.Time           `db:"stopped_at"      json:"stoppedAt"`     // Time when working on job was stoped because of a decision question or an error, or NULL
+
+	CancelRequestedAt nullable.Time `db:"cancel_requested_at" json:"cancelRequestedAt"` // Time when cancellation of the job was requested with CancelJob, or NULL
+	CancelledAt       nullable.Time `db:"cancelled_at"        json:"cancelledAt"`       // Time when the job was stopped as cancelled, or NULL
+
+	TraceContext TraceContext `db:"trace_context" json:"traceContext,omitzero"` // W3C Trace Context of the span that added the job, or NULL
 
 	ErrorMsg  nullable.NonEmptyString `db:"error_msg"  json:"errorMsg"`  // If there was an error working off the job
 	ErrorData nullable.JSON           `db:"error_data" json:"errorData"` // Optional error metadata
@@ -49,6 +62,16 @@ type Job struct {
 
 	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"` // Time the row was last updated
 	CreatedAt time.Time `db:"created_at" json:"createdAt"` // Time the job was created
+
+	// DependsOn holds the IDs of jobs that have to succeed before this job is started.
+	// The dependencies must already exist or be added in the same JobBundle.
+	// Stored in the worker.job_dependency table and filled by GetJob.
+	DependsOn uu.IDs `db:"-" json:"dependsOn,omitempty"`
+
+	// OnDuplicate defines what happens when the job is added while
+	// an unfinished job of the same type with the same UniqueKey exists.
+	// Only used when adding the job, not stored in the database.
+	OnDuplicate DuplicateJobPolicy `db:"-" json:"-"`
 }
 
 // Started returns true if a worker has claimed the job and set its StartedAt
@@ -100,10 +123,21 @@ func (j *Job) IsFinished() bool {
 	return j.CurrentRetryCount >= j.MaxRetryCount || j.ErrorMsg.IsNull()
 }
 
-// Succeeded returns true if the job has finished without an error.
+// Succeeded returns true if the job has finished without an error
+// and was not cancelled.
 // May be stale after the snapshot was loaded.
 func (j *Job) Succeeded() bool {
-	return j.IsFinished() && !j.HasError()
+	return j.IsFinished() && !j.HasError() && !j.Cancelled()
+}
+
+// Cancelled returns true if the job was stopped because of a CancelJob call,
+// either before it was started or by cancelling the context of its worker,
+// which is recorded in CancelledAt.
+// A job that finished with a result or an error before the worker
+// noticed the cancellation is not considered cancelled.
+// May be stale after the snapshot was loaded.
+func (j *Job) Cancelled() bool {
+	return j.CancelledAt.IsNotNull()
 }
 
 // HasError returns true if the receiver is not nil
@@ -113,6 +147,30 @@ func (j *Job) HasError() bool {
 	return j.ErrorMsg.IsNotNull()
 }
 
+// State returns the JobState of the job at the time now
+// as counted by the JobStats of Status.
+// May be stale after the snapshot was loaded.
+func (j *Job) State(now time.Time) JobState {
+	switch {
+	case j.StartedAndNotStopped():
+		return JobStateRunning
+	case !j.Stopped() && j.CurrentRetryCount > 0:
+		return JobStateRetrying
+	case !j.Stopped() && j.StartAt.IsNotNull() && now.Before(j.StartAt.Get()):
+		return JobStateDelayed
+	case !j.Stopped():
+		return JobStatePending
+	case j.HasError() && j.CurrentRetryCount < j.MaxRetryCount:
+		return JobStateRetrying
+	case j.HasError():
+		return JobStateFailed
+	case j.Cancelled():
+		return JobStateCancelled
+	default:
+		return JobStateSucceeded
+	}
+}
+
 // String implements the fmt.Stringer interface.
 // Valid to call on a nil receiver.
 func (j *Job) String() string {
@@ -142,3 +142,50 @@ func TestNewJobReflectType(t *testing.T) {
 		"job type is derived from the payload type via reflection")
 	assert.JSONEq(t, `{"Name":"x"}`, string(job.Payload))
 }
+
+func TestJobCancelled(t *testing.T) {
+	now := time.Now()
+
+	running := jobqueue.Job{StartedAt: nullable.TimeFrom(now), CancelRequestedAt: nullable.TimeFrom(now)}
+	assert.False(t, running.Cancelled(), "cancel requested but not stopped yet")
+
+	cancelled := running
+	cancelled.StoppedAt = nullable.TimeFrom(now)
+	cancelled.CancelledAt = nullable.TimeFrom(now)
+	assert.True(t, cancelled.Cancelled())
+	assert.True(t, cancelled.IsFinished())
+	assert.False(t, cancelled.Succeeded(), "a cancelled job did not succeed")
+
+	finishedAnyway := running
+	finishedAnyway.StoppedAt = nullable.TimeFrom(now)
+	assert.False(t, finishedAnyway.Cancelled(), "stopped without a result before the cancellation was noticed")
+	assert.True(t, finishedAnyway.Succeeded())
+
+	failedAnyway := finishedAnyway
+	failedAnyway.ErrorMsg = "boom"
+	assert.False(t, failedAnyway.Cancelled(), "error written before the cancellation was noticed")
+}
+
+func TestJobState(t *testing.T) {
+	now := time.Now()
+	past, future := nullable.TimeFrom(now.Add(-time.Hour)), nullable.TimeFrom(now.Add(time.Hour))
+
+	for _, tc := range []struct {
+		name string
+		job  jobqueue.Job
+		want jobqueue.JobState
+	}{
+		{"not started", jobqueue.Job{}, jobqueue.JobStatePending},
+		{"start reached", jobqueue.Job{StartAt: past}, jobqueue.JobStatePending},
+		{"start in future", jobqueue.Job{StartAt: future}, jobqueue.JobStateDelayed},
+		{"retry scheduled", jobqueue.Job{StartAt: future, CurrentRetryCount: 1, MaxRetryCount: 3}, jobqueue.JobStateRetrying},
+		{"started", jobqueue.Job{StartedAt: past}, jobqueue.JobStateRunning},
+		{"error with retries left", jobqueue.Job{StartedAt: past, StoppedAt: past, ErrorMsg: "boom", MaxRetryCount: 1}, jobqueue.JobStateRetrying},
+		{"error", jobqueue.Job{StartedAt: past, StoppedAt: past, ErrorMsg: "boom"}, jobqueue.JobStateFailed},
+		{"cancelled", jobqueue.Job{StoppedAt: past, CancelRequestedAt: past, CancelledAt: past}, jobqueue.JobStateCancelled},
+		{"cancel requested", jobqueue.Job{StartedAt: past, StoppedAt: past, CancelRequestedAt: past}, jobqueue.JobStateSucceeded},
+		{"result", jobqueue.Job{StartedAt: past, StoppedAt: past, Result: nullable.JSON(`{}`)}, jobqueue.JobStateSucceeded},
+	} {
+		assert.Equal(t, tc.want, tc.job.State(now), tc.name)
+	}
+}
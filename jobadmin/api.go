@@ -0,0 +1,230 @@
+package jobadmin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// JobDetails is the response of GET /api/jobs/{id}.
+type JobDetails struct {
+	Job      *jobqueue.Job          `json:"job"`
+	Attempts []*jobqueue.JobAttempt `json:"attempts"`
+}
+
+// ErrorResponse is the response body of failed requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// badRequestError is an error caused by the request
+// that is returned with status 400.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string { return e.err.Error() }
+func (e badRequestError) Unwrap() error { return e.err }
+
+func writeJSON(writer http.ResponseWriter, status int, v any) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(v)
+}
+
+// writeError writes err with the status derived from it.
+// Internal errors are logged and not exposed to the client.
+func writeError(writer http.ResponseWriter, request *http.Request, err error) {
+	var badRequest badRequestError
+	switch {
+	case errors.As(err, &badRequest):
+		writeJSON(writer, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	case errs.IsErrNotFound(err):
+		writeJSON(writer, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	case errors.Is(err, jobqueue.ErrClosed), errors.Is(err, jobqueue.ErrNotInitialized):
+		writeJSON(writer, http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+	default:
+		log.ErrorCtx(request.Context(), "Error while serving job admin request").
+			Str("method", request.Method).
+			Str("path", request.URL.Path).
+			Err(err).
+			Log()
+		writeJSON(writer, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+	}
+}
+
+// pathID returns the ID of the {id} path wildcard of request.
+func pathID(request *http.Request) (uu.ID, error) {
+	id, err := uu.IDFromString(request.PathValue("id"))
+	if err != nil {
+		return uu.IDNil, badRequestError{fmt.Errorf("invalid ID %q: %w", request.PathValue("id"), err)}
+	}
+	return id, nil
+}
+
+// jobFilter returns the JobFilter of the query parameters of request.
+func jobFilter(request *http.Request) (*jobqueue.JobFilter, error) {
+	query := request.URL.Query()
+	filter := &jobqueue.JobFilter{
+		Type:         query.Get("type"),
+		Origin:       query.Get("origin"),
+		State:        jobqueue.JobState(query.Get("state")),
+		ErrorPattern: query.Get("error"),
+		Limit:        DefaultLimit,
+	}
+	if bundle := query.Get("bundle"); bundle != "" {
+		id, err := uu.IDFromString(bundle)
+		if err != nil {
+			return nil, badRequestError{fmt.Errorf("invalid bundle ID %q: %w", bundle, err)}
+		}
+		filter.BundleID = id.Nullable()
+	}
+	if newest := query.Get("newest"); newest != "" {
+		var err error
+		filter.NewestFirst, err = strconv.ParseBool(newest)
+		if err != nil {
+			return nil, badRequestError{fmt.Errorf("invalid newest %q: %w", newest, err)}
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return nil, badRequestError{fmt.Errorf("invalid limit %q: %w", limit, err)}
+		}
+		if filter.Limit > MaxLimit {
+			return nil, badRequestError{fmt.Errorf("limit %d is higher than %d", filter.Limit, MaxLimit)}
+		}
+		if filter.Limit == 0 {
+			// Zero means no limit for jobqueue.JobFilter
+			filter.Limit = DefaultLimit
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, badRequestError{err}
+	}
+	return filter, nil
+}
+
+func (h *Handler) getStatus(writer http.ResponseWriter, request *http.Request) {
+	status, err := h.getService(request).GetStatus(request.Context())
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, status)
+}
+
+func (h *Handler) getJobs(writer http.ResponseWriter, request *http.Request) {
+	filter, err := jobFilter(request)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	jobs, err := h.getService(request).GetJobs(request.Context(), filter)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	if jobs == nil {
+		jobs = []*jobqueue.Job{} // [] instead of null
+	}
+	writeJSON(writer, http.StatusOK, jobs)
+}
+
+func (h *Handler) getJob(writer http.ResponseWriter, request *http.Request) {
+	jobID, err := pathID(request)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	service := h.getService(request)
+	job, err := service.GetJob(request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	attempts, err := service.GetJobAttempts(request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	if attempts == nil {
+		attempts = []*jobqueue.JobAttempt{}
+	}
+	writeJSON(writer, http.StatusOK, JobDetails{Job: job, Attempts: attempts})
+}
+
+func (h *Handler) deleteJob(writer http.ResponseWriter, request *http.Request) {
+	jobID, err := pathID(request)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	service := h.getService(request)
+	// DeleteJob doesn't fail for a missing job
+	_, err = service.GetJob(request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	err = service.DeleteJob(request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) resetJob(writer http.ResponseWriter, request *http.Request) {
+	h.changeJob(writer, request, jobqueue.Service.ResetJob)
+}
+
+func (h *Handler) cancelJob(writer http.ResponseWriter, request *http.Request) {
+	h.changeJob(writer, request, jobqueue.Service.CancelJob)
+}
+
+// changeJob calls change with the job of the {id} path wildcard
+// and responds with the changed job.
+func (h *Handler) changeJob(writer http.ResponseWriter, request *http.Request, change func(jobqueue.Service, context.Context, uu.ID) error) {
+	jobID, err := pathID(request)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	service := h.getService(request)
+	err = change(service, request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	job, err := service.GetJob(request.Context(), jobID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, job)
+}
+
+func (h *Handler) getJobBundle(writer http.ResponseWriter, request *http.Request) {
+	jobBundleID, err := pathID(request)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	jobBundle, err := h.getService(request).GetJobBundle(request.Context(), jobBundleID)
+	if err != nil {
+		writeError(writer, request, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, jobBundle)
+}
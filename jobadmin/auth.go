@@ -0,0 +1,47 @@
+package jobadmin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuth returns a Middleware that requires HTTP basic authentication
+// with username and password for all requests.
+// Use it only behind TLS because basic authentication
+// sends the password in clear text.
+func BasicAuth(username, password string) Middleware {
+	// Comparing hashes of equal length doesn't leak
+	// the length of the credentials through timing
+	usernameHash := sha256.Sum256([]byte(username))
+	passwordHash := sha256.Sum256([]byte(password))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			u, p, ok := request.BasicAuth()
+			uHash := sha256.Sum256([]byte(u))
+			pHash := sha256.Sum256([]byte(p))
+			usernameOK := subtle.ConstantTimeCompare(uHash[:], usernameHash[:]) == 1
+			passwordOK := subtle.ConstantTimeCompare(pHash[:], passwordHash[:]) == 1
+			if !ok || !usernameOK || !passwordOK {
+				writer.Header().Set("WWW-Authenticate", `Basic realm="jobqueue", charset="UTF-8"`)
+				writeJSON(writer, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// ReadOnly is a Middleware that rejects all requests
+// changing jobs with status 403, so that the dashboard
+// and the API can only be used to inspect the queue.
+func ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(writer, request)
+		default:
+			writeJSON(writer, http.StatusForbidden, ErrorResponse{Error: "read-only access"})
+		}
+	})
+}
@@ -0,0 +1,194 @@
+"use strict";
+
+// All URLs are relative so the dashboard works
+// under any path prefix the Handler is mounted at.
+
+const REFRESH_MS = 5000;
+const FAILURES_LIMIT = 20;
+// Three times the default jobworker.HeartbeatInterval
+const STALE_HEARTBEAT_MS = 30000;
+
+// Offset of the server clock to the browser clock,
+// so heartbeat ages don't depend on the clock of the browser
+let serverClockOffset = 0;
+
+async function api(method, path) {
+	const response = await fetch(path, {method, headers: {"Accept": "application/json"}});
+	const date = response.headers.get("Date");
+	if (date) {
+		serverClockOffset = Date.parse(date) - Date.now();
+	}
+	if (!response.ok) {
+		let message = response.statusText;
+		try {
+			message = (await response.json()).error;
+		} catch {}
+		throw new Error(`${method} ${path}: ${response.status} ${message}`);
+	}
+	return response.status === 204 ? null : response.json();
+}
+
+function serverNow() {
+	return Date.now() + serverClockOffset;
+}
+
+function formatDuration(ms) {
+	if (ms <= 0) {
+		return "";
+	}
+	const s = Math.floor(ms / 1000);
+	if (s < 60) {
+		return `${s}s`;
+	}
+	if (s < 3600) {
+		return `${Math.floor(s / 60)}m ${s % 60}s`;
+	}
+	return `${Math.floor(s / 3600)}h ${Math.floor(s % 3600 / 60)}m`;
+}
+
+function formatTime(time) {
+	return time ? new Date(time).toLocaleString() : "";
+}
+
+function cell(row, text, className) {
+	const td = row.insertCell();
+	td.textContent = text;
+	if (className) {
+		td.className = className;
+	}
+	return td;
+}
+
+function idCell(row, job) {
+	const td = cell(row, job.id.slice(0, 8), "id");
+	td.title = job.id;
+	td.onclick = () => showJob(job.id);
+}
+
+function actionButton(td, label, method, path, confirmText) {
+	const button = document.createElement("button");
+	button.textContent = label;
+	button.onclick = async () => {
+		if (confirmText && !confirm(confirmText)) {
+			return;
+		}
+		button.disabled = true;
+		try {
+			await api(method, path);
+			await refresh();
+		} catch (err) {
+			showError(err);
+			button.disabled = false;
+		}
+	};
+	td.append(button);
+}
+
+function fillTable(tbody, items, columns, fillRow) {
+	tbody.replaceChildren();
+	if (items.length === 0) {
+		const td = tbody.insertRow().insertCell();
+		td.colSpan = columns;
+		td.className = "empty";
+		td.textContent = "None";
+		return;
+	}
+	for (const item of items) {
+		fillRow(tbody.insertRow(), item);
+	}
+}
+
+function renderStatus(status) {
+	const jobs = status.Jobs;
+	document.getElementById("summary").textContent =
+		`${status.NumJobs} jobs, ${status.NumJobBundles} bundles, ` +
+		`${jobs.Pending + jobs.Delayed + jobs.Retrying} queued, ${jobs.Running} running, ${jobs.Failed} failed`;
+
+	const types = Object.entries(status.ByType || {}).sort(([a], [b]) => a.localeCompare(b));
+	fillTable(document.getElementById("types"), types, 9, (row, [type, stats]) => {
+		cell(row, type);
+		cell(row, stats.Pending, "num");
+		cell(row, stats.Delayed, "num");
+		cell(row, stats.Retrying, "num");
+		cell(row, stats.Running, "num");
+		cell(row, stats.Failed, "num");
+		cell(row, stats.Cancelled, "num");
+		cell(row, stats.Succeeded, "num");
+		// Go time.Duration is encoded as nanoseconds
+		cell(row, formatDuration(stats.OldestPendingAge / 1e6), "num");
+	});
+}
+
+function renderRunning(jobs) {
+	fillTable(document.getElementById("running"), jobs, 6, (row, job) => {
+		idCell(row, job);
+		cell(row, job.type);
+		cell(row, job.origin);
+		cell(row, formatTime(job.startedAt));
+		if (job.workerAliveAt) {
+			const age = serverNow() - Date.parse(job.workerAliveAt);
+			cell(row, formatDuration(age) || "0s", age > STALE_HEARTBEAT_MS ? "num stale" : "num");
+		} else {
+			cell(row, "none", "num");
+		}
+		const td = row.insertCell();
+		if (job.cancelRequestedAt) {
+			td.textContent = "cancelling";
+		} else {
+			actionButton(td, "Cancel", "POST", `api/jobs/${job.id}/cancel`, `Cancel job ${job.id}?`);
+		}
+	});
+}
+
+function renderFailed(jobs) {
+	fillTable(document.getElementById("failed"), jobs, 6, (row, job) => {
+		idCell(row, job);
+		cell(row, job.type);
+		cell(row, job.origin);
+		cell(row, formatTime(job.stoppedAt));
+		cell(row, job.errorMsg, "error-msg");
+		const td = row.insertCell();
+		actionButton(td, "Reset", "POST", `api/jobs/${job.id}/reset`);
+		actionButton(td, "Delete", "DELETE", `api/jobs/${job.id}`, `Delete job ${job.id}?`);
+	});
+}
+
+async function showJob(id) {
+	try {
+		const details = await api("GET", `api/jobs/${id}`);
+		document.getElementById("details-body").textContent = JSON.stringify(details, null, 2);
+		document.getElementById("details").showModal();
+	} catch (err) {
+		showError(err);
+	}
+}
+
+function showError(err) {
+	const p = document.getElementById("error");
+	p.textContent = err ? err.message : "";
+	p.hidden = !err;
+}
+
+async function refresh() {
+	try {
+		const [status, running, failed] = await Promise.all([
+			api("GET", "api/status"),
+			api("GET", "api/jobs?state=running"),
+			api("GET", `api/jobs?state=failed&newest=true&limit=${FAILURES_LIMIT}`),
+		]);
+		renderStatus(status);
+		renderRunning(running);
+		renderFailed(failed);
+		showError(null);
+	} catch (err) {
+		showError(err);
+	}
+}
+
+document.getElementById("refresh").onclick = refresh;
+setInterval(() => {
+	if (document.getElementById("autorefresh").checked && !document.hidden) {
+		refresh();
+	}
+}, REFRESH_MS);
+refresh();
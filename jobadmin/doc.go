@@ -0,0 +1,45 @@
+/*
+Package jobadmin provides an http.Handler with a JSON API
+and an embedded web dashboard to inspect and repair the jobs of a queue.
+
+# Overview
+
+A Handler serves the API and the dashboard for a jobqueue.Service.
+It can be mounted under any path prefix:
+
+	admin := jobadmin.New(nil, jobadmin.WithMiddleware(jobadmin.BasicAuth("support", password)))
+	http.Handle("/admin/", http.StripPrefix("/admin", admin))
+
+The dashboard at the root path shows the queue depth per job type,
+the running jobs with the age of their heartbeat, and the recent failures.
+Failed jobs can be reset or deleted and running jobs cancelled.
+
+# API
+
+All responses are JSON, errors are returned as {"error": "message"}
+with status 400 for invalid requests, 404 for unknown jobs and bundles,
+and 503 if the service is closed or not initialized:
+
+	GET    /api/status            jobqueue.Status with JobStats per type and origin
+	GET    /api/jobs              jobs selected by a jobqueue.JobFilter
+	GET    /api/jobs/{id}         {"job": job, "attempts": [attempts]}
+	DELETE /api/jobs/{id}         delete the job, responds with status 204
+	POST   /api/jobs/{id}/reset   reset the job, responds with the job
+	POST   /api/jobs/{id}/cancel  cancel the job, responds with the job
+	GET    /api/bundles/{id}      jobqueue.JobBundle with its jobs
+
+GET /api/jobs accepts the JobFilter fields as query parameters:
+type, origin, bundle, state, error (a regular expression
+matched against the error message), newest (true to order
+by descending creation time), and limit, which defaults to DefaultLimit
+for a missing or zero value and must not be higher than MaxLimit.
+
+# Access Control
+
+Without middleware everybody who can reach the Handler can change jobs.
+Authentication and authorization are added with WithMiddleware,
+for example BasicAuth, ReadOnly, or the middleware of the application.
+Cross-origin requests changing jobs are always rejected
+with http.CrossOriginProtection.
+*/
+package jobadmin
@@ -0,0 +1,92 @@
+package jobadmin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"slices"
+
+	rootlog "github.com/domonda/golog/log"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+var log = rootlog.NewPackageLogger()
+
+// DefaultLimit is the maximum number of jobs returned by GET /api/jobs
+// without a limit parameter or with limit=0.
+const DefaultLimit = 100
+
+// MaxLimit is the highest limit parameter accepted by GET /api/jobs.
+const MaxLimit = 1000
+
+//go:embed dashboard
+var dashboardFS embed.FS
+
+// Middleware wraps the http.Handler of all requests to the Handler,
+// for example to authenticate and authorize them.
+type Middleware func(next http.Handler) http.Handler
+
+// Option configures the Handler created with New.
+type Option func(*Handler)
+
+// WithMiddleware wraps all requests of the Handler with middleware.
+// The first middleware is the outermost one, which is called first.
+// Without middleware the Handler is accessible for every client
+// that can reach it, see BasicAuth and ReadOnly.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(h *Handler) {
+		h.middleware = append(h.middleware, middleware...)
+	}
+}
+
+// Handler serves the JSON API and the dashboard, see the package documentation.
+type Handler struct {
+	service    jobqueue.Service
+	middleware []Middleware
+	handler    http.Handler
+}
+
+// New returns a Handler for service.
+// A nil service uses the service from the context
+// of the request or the default service.
+func New(service jobqueue.Service, opts ...Option) *Handler {
+	h := &Handler{service: service}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	dashboard, err := fs.Sub(dashboardFS, "dashboard")
+	if err != nil {
+		panic(err) // The embedded directory always exists
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", h.getStatus)
+	mux.HandleFunc("GET /api/jobs", h.getJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", h.getJob)
+	mux.HandleFunc("DELETE /api/jobs/{id}", h.deleteJob)
+	mux.HandleFunc("POST /api/jobs/{id}/reset", h.resetJob)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", h.cancelJob)
+	mux.HandleFunc("GET /api/bundles/{id}", h.getJobBundle)
+	mux.Handle("GET /", http.FileServerFS(dashboard))
+
+	// The dashboard sends the changing requests with fetch,
+	// so cross-origin requests can be rejected
+	h.handler = http.NewCrossOriginProtection().Handler(mux)
+	for _, middleware := range slices.Backward(h.middleware) {
+		h.handler = middleware(h.handler)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.handler.ServeHTTP(writer, request)
+}
+
+func (h *Handler) getService(request *http.Request) jobqueue.Service {
+	if h.service != nil {
+		return h.service
+	}
+	return jobqueue.GetService(request.Context())
+}
@@ -0,0 +1,368 @@
+package jobadmin_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobadmin"
+	"github.com/domonda/go-jobqueue/jobworker"
+	"github.com/domonda/go-jobqueue/memqueue"
+)
+
+func newTestDB(t *testing.T) jobworker.DataBase {
+	t.Helper()
+	db := memqueue.NewDataBase()
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// addTestJob adds a job and starts it if running or errorMsg is set.
+// Started jobs get a higher priority to be claimed before the others.
+func addTestJob(t *testing.T, db jobworker.DataBase, jobType string, running bool, errorMsg string) *jobqueue.Job {
+	t.Helper()
+	start := running || errorMsg != ""
+	var priority int64
+	if start {
+		priority = 1
+	}
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), jobType, "test", `{}`, priority, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, db.AddJob(t.Context(), job))
+	if start {
+		pool := jobworker.NewPool(db)
+		pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+		claimed, err := db.StartNextJobOrNil(t.Context(), pool.Claim())
+		require.NoError(t, err)
+		require.Equal(t, job.ID, claimed.ID)
+	}
+	if errorMsg != "" {
+		require.NoError(t, db.SetJobError(t.Context(), job.ID, errorMsg, nil))
+	}
+	return job
+}
+
+func serve(t *testing.T, handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func decode[T any](t *testing.T, recorder *httptest.ResponseRecorder) T {
+	t.Helper()
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	var v T
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &v), recorder.Body.String())
+	return v
+}
+
+func jobIDs(jobs []*jobqueue.Job) uu.IDs {
+	ids := make(uu.IDs, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func TestGetStatus(t *testing.T) {
+	db := newTestDB(t)
+	addTestJob(t, db, "send-mail", false, "")
+	addTestJob(t, db, "send-mail", false, "smtp timeout")
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	recorder := serve(t, handler, "GET", "/api/status")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	status := decode[jobqueue.Status](t, recorder)
+	assert.Equal(t, 2, status.NumJobs)
+	assert.Equal(t, 1, status.ByType["send-mail"].Pending)
+	assert.Equal(t, 1, status.ByType["send-mail"].Failed)
+}
+
+func TestGetJobs(t *testing.T) {
+	db := newTestDB(t)
+	pending := addTestJob(t, db, "send-mail", false, "")
+	failed1 := addTestJob(t, db, "send-mail", false, "smtp timeout")
+	failed2 := addTestJob(t, db, "render-pdf", false, "out of memory")
+	running := addTestJob(t, db, "render-pdf", true, "")
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	for _, tc := range []struct {
+		query string
+		want  uu.IDs
+	}{
+		{"", uu.IDs{pending.ID, failed1.ID, failed2.ID, running.ID}},
+		{"?type=send-mail", uu.IDs{pending.ID, failed1.ID}},
+		{"?state=running", uu.IDs{running.ID}},
+		{"?state=failed&newest=true", uu.IDs{failed2.ID, failed1.ID}},
+		{"?state=failed&newest=1&limit=1", uu.IDs{failed2.ID}},
+		{"?error=%5Esmtp", uu.IDs{failed1.ID}},
+		{"?origin=other", uu.IDs{}},
+		{"?bundle=" + uu.NewID(t.Context()).String(), uu.IDs{}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			recorder := serve(t, handler, "GET", "/api/jobs"+tc.query)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			// Empty results are encoded as [] instead of null
+			assert.NotEqual(t, "null\n", recorder.Body.String())
+			assert.Equal(t, tc.want, jobIDs(decode[[]*jobqueue.Job](t, recorder)))
+		})
+	}
+
+	for _, query := range []string{
+		"?state=unknown",
+		"?error=(",
+		"?limit=-1",
+		"?limit=1001",
+		"?limit=ten",
+		"?newest=maybe",
+		"?bundle=invalid",
+	} {
+		t.Run(query, func(t *testing.T) {
+			recorder := serve(t, handler, "GET", "/api/jobs"+query)
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.NotEmpty(t, decode[jobadmin.ErrorResponse](t, recorder).Error)
+		})
+	}
+}
+
+func TestGetJobsLimit(t *testing.T) {
+	db := newTestDB(t)
+	for range jobadmin.DefaultLimit + 1 {
+		addTestJob(t, db, "send-mail", false, "")
+	}
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", jobadmin.DefaultLimit},
+		{"?limit=0", jobadmin.DefaultLimit},
+		{"?limit=2", 2},
+		{"?limit=1000", jobadmin.DefaultLimit + 1},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			recorder := serve(t, handler, "GET", "/api/jobs"+tc.query)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Len(t, decode[[]*jobqueue.Job](t, recorder), tc.want)
+		})
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	db := newTestDB(t)
+	failed := addTestJob(t, db, "send-mail", false, "smtp timeout")
+	pending := addTestJob(t, db, "send-mail", false, "")
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	recorder := serve(t, handler, "GET", "/api/jobs/"+failed.ID.String())
+	require.Equal(t, http.StatusOK, recorder.Code)
+	details := decode[jobadmin.JobDetails](t, recorder)
+	assert.Equal(t, failed.ID, details.Job.ID)
+	assert.Equal(t, "smtp timeout", details.Job.ErrorMsg.Get())
+	require.Len(t, details.Attempts, 1)
+	assert.Equal(t, "smtp timeout", details.Attempts[0].ErrorMsg.Get())
+
+	recorder = serve(t, handler, "GET", "/api/jobs/"+pending.ID.String())
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"attempts":[]`)
+
+	recorder = serve(t, handler, "GET", "/api/jobs/"+uu.NewID(t.Context()).String())
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "not found", decode[jobadmin.ErrorResponse](t, recorder).Error)
+
+	recorder = serve(t, handler, "GET", "/api/jobs/invalid")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestChangeJobs(t *testing.T) {
+	db := newTestDB(t)
+	failed := addTestJob(t, db, "send-mail", false, "smtp timeout")
+	pending := addTestJob(t, db, "send-mail", false, "")
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	recorder := serve(t, handler, "POST", "/api/jobs/"+failed.ID.String()+"/reset")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	reset := decode[jobqueue.Job](t, recorder)
+	assert.Equal(t, failed.ID, reset.ID)
+	assert.True(t, reset.ErrorMsg.IsNull())
+	assert.True(t, reset.StoppedAt.IsNull())
+
+	recorder = serve(t, handler, "POST", "/api/jobs/"+pending.ID.String()+"/cancel")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cancelled := decode[jobqueue.Job](t, recorder)
+	assert.Equal(t, jobqueue.JobStateCancelled, cancelled.State(cancelled.UpdatedAt))
+
+	recorder = serve(t, handler, "DELETE", "/api/jobs/"+pending.ID.String())
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	_, err := db.GetJob(t.Context(), pending.ID)
+	assert.Error(t, err)
+
+	for _, target := range []string{
+		"/api/jobs/" + pending.ID.String(),
+		"/api/jobs/" + pending.ID.String() + "/reset",
+		"/api/jobs/" + pending.ID.String() + "/cancel",
+	} {
+		method := "POST"
+		if !strings.HasSuffix(target, "reset") && !strings.HasSuffix(target, "cancel") {
+			method = "DELETE"
+		}
+		assert.Equal(t, http.StatusNotFound, serve(t, handler, method, target).Code, "%s %s", method, target)
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(t, handler, "PUT", "/api/jobs/"+failed.ID.String()).Code)
+}
+
+func TestGetJobBundle(t *testing.T) {
+	db := newTestDB(t)
+	descs := []jobqueue.JobDesc{
+		{Type: "send-mail", Payload: `{}`, Origin: "test"},
+		{Type: "send-mail", Payload: `{}`, Origin: "test"},
+	}
+	bundle, err := jobqueue.NewJobBundle(t.Context(), "newsletter", "test", descs, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, db.AddJobBundle(t.Context(), bundle))
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	recorder := serve(t, handler, "GET", "/api/bundles/"+bundle.ID.String())
+	require.Equal(t, http.StatusOK, recorder.Code)
+	got := decode[jobqueue.JobBundle](t, recorder)
+	assert.Equal(t, bundle.ID, got.ID)
+	assert.Equal(t, "newsletter", got.Type)
+	assert.Len(t, got.Jobs, 2)
+
+	assert.Equal(t, http.StatusNotFound, serve(t, handler, "GET", "/api/bundles/"+uu.NewID(t.Context()).String()).Code)
+}
+
+func TestServiceFromContext(t *testing.T) {
+	db := newTestDB(t)
+	job := addTestJob(t, db, "send-mail", false, "")
+	handler := jobadmin.New(nil)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/api/jobs/"+job.ID.String(), nil)
+	request = request.WithContext(jobqueue.ContextWithService(request.Context(), db.(jobqueue.Service)))
+	handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, job.ID, decode[jobadmin.JobDetails](t, recorder).Job.ID)
+}
+
+func TestClosedService(t *testing.T) {
+	db := memqueue.NewDataBase()
+	require.NoError(t, db.Close())
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	recorder := serve(t, handler, "GET", "/api/status")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, decode[jobadmin.ErrorResponse](t, recorder).Error, jobqueue.ErrClosed.Error())
+}
+
+func TestDashboard(t *testing.T) {
+	handler := http.StripPrefix("/admin", jobadmin.New(newTestDB(t).(jobqueue.Service)))
+
+	recorder := serve(t, handler, "GET", "/admin/")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, recorder.Body.String(), "<title>Job Queue</title>")
+
+	for _, file := range []string{"dashboard.js", "dashboard.css"} {
+		recorder = serve(t, handler, "GET", "/admin/"+file)
+		assert.Equal(t, http.StatusOK, recorder.Code, file)
+	}
+
+	assert.Equal(t, http.StatusOK, serve(t, handler, "GET", "/admin/api/status").Code)
+	assert.Equal(t, http.StatusNotFound, serve(t, handler, "GET", "/admin/missing.html").Code)
+}
+
+func TestCrossOriginProtection(t *testing.T) {
+	db := newTestDB(t)
+	job := addTestJob(t, db, "send-mail", false, "")
+	handler := jobadmin.New(db.(jobqueue.Service))
+
+	request := httptest.NewRequest("POST", "/api/jobs/"+job.ID.String()+"/cancel", nil)
+	request.Header.Set("Sec-Fetch-Site", "cross-site")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	request = httptest.NewRequest("GET", "/api/jobs/"+job.ID.String(), nil)
+	request.Header.Set("Sec-Fetch-Site", "cross-site")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) jobadmin.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(writer, request)
+			})
+		}
+	}
+	handler := jobadmin.New(newTestDB(t).(jobqueue.Service),
+		jobadmin.WithMiddleware(middleware("first"), middleware("second")),
+		jobadmin.WithMiddleware(middleware("third")),
+	)
+
+	assert.Equal(t, http.StatusOK, serve(t, handler, "GET", "/api/status").Code)
+	assert.Equal(t, []string{"first", "second", "third"}, calls)
+}
+
+func TestBasicAuth(t *testing.T) {
+	handler := jobadmin.New(newTestDB(t).(jobqueue.Service),
+		jobadmin.WithMiddleware(jobadmin.BasicAuth("support", "secret")),
+	)
+
+	for _, tc := range []struct {
+		username, password string
+		want               int
+	}{
+		{"support", "secret", http.StatusOK},
+		{"support", "wrong", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+		{"support", "", http.StatusUnauthorized},
+	} {
+		request := httptest.NewRequest("GET", "/api/status", nil)
+		request.SetBasicAuth(tc.username, tc.password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(t, tc.want, recorder.Code, "%s:%s", tc.username, tc.password)
+	}
+
+	recorder := serve(t, handler, "GET", "/")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("WWW-Authenticate"), "Basic "))
+}
+
+func TestReadOnly(t *testing.T) {
+	db := newTestDB(t)
+	job := addTestJob(t, db, "send-mail", false, "")
+	handler := jobadmin.New(db.(jobqueue.Service), jobadmin.WithMiddleware(jobadmin.ReadOnly))
+
+	assert.Equal(t, http.StatusOK, serve(t, handler, "GET", "/api/jobs/"+job.ID.String()).Code)
+	assert.Equal(t, http.StatusOK, serve(t, handler, "GET", "/").Code)
+
+	recorder := serve(t, handler, "DELETE", "/api/jobs/"+job.ID.String())
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "read-only")
+	assert.Equal(t, http.StatusForbidden, serve(t, handler, "POST", "/api/jobs/"+job.ID.String()+"/reset").Code)
+
+	_, err = db.GetJob(t.Context(), job.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,97 @@
+package jobqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+)
+
+// JobAttemptOutcome defines how an attempt to work off a job ended.
+type JobAttemptOutcome string
+
+const (
+	// JobAttemptSucceeded means the worker returned without error.
+	JobAttemptSucceeded JobAttemptOutcome = "succeeded"
+
+	// JobAttemptFailed means the worker returned an error
+	// and the job was not retried.
+	JobAttemptFailed JobAttemptOutcome = "failed"
+
+	// JobAttemptRetried means the worker returned an error
+	// and the job was scheduled to be retried.
+	JobAttemptRetried JobAttemptOutcome = "retried"
+
+	// JobAttemptSnoozed means the worker snoozed the job
+	// without counting the attempt as a retry.
+	JobAttemptSnoozed JobAttemptOutcome = "snoozed"
+
+	// JobAttemptReset means the running job was reset with ResetJob or ResetJobs.
+	JobAttemptReset JobAttemptOutcome = "reset"
+
+	// JobAttemptCancelled means the running job was cancelled.
+	JobAttemptCancelled JobAttemptOutcome = "cancelled"
+
+	// JobAttemptInterrupted means the worker of the running job
+	// stopped sending heartbeats and the job was reset by
+	// jobworkerdb.InitJobQueueResetInterruptedJobs.
+	JobAttemptInterrupted JobAttemptOutcome = "interrupted"
+)
+
+// Valid returns true if the outcome is one of the defined constants.
+func (o JobAttemptOutcome) Valid() bool {
+	switch o {
+	case JobAttemptSucceeded, JobAttemptFailed, JobAttemptRetried,
+		JobAttemptSnoozed, JobAttemptReset, JobAttemptCancelled, JobAttemptInterrupted:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the outcome is not Valid.
+func (o JobAttemptOutcome) Validate() error {
+	if !o.Valid() {
+		return fmt.Errorf("invalid JobAttemptOutcome %q", string(o))
+	}
+	return nil
+}
+
+// JobAttempt is a worker.job_attempt row recording one attempt
+// to work off a job, from its start until the write that ended it.
+// Attempts are recorded for running jobs only,
+// and are deleted together with their job.
+//
+// The attempt of a job reclaimed from a crashed worker by
+// jobworkerdb.InitJobQueueResetInterruptedJobs is recorded
+// as JobAttemptInterrupted that stopped at the last heartbeat.
+type JobAttempt struct {
+	db.TableName `db:"worker.job_attempt"`
+
+	JobID      uu.ID             `db:"job_id,primarykey"  json:"jobId"`      // ID of the Job the attempt belongs to
+	Attempt    int               `db:"attempt,primarykey" json:"attempt"`    // 1-based number of the attempt of the job
+	RetryCount int               `db:"retry_count"        json:"retryCount"` // Job.CurrentRetryCount during the attempt
+	Outcome    JobAttemptOutcome `db:"outcome"            json:"outcome"`    // How the attempt ended
+	Worker     string            `db:"worker"             json:"worker"`     // Identity of the process that claimed the job and ran the attempt, see Job.Worker
+
+	StartedAt time.Time `db:"started_at" json:"startedAt"` // Job.StartedAt of the attempt
+	StoppedAt time.Time `db:"stopped_at" json:"stoppedAt"` // Time the attempt ended
+
+	ErrorMsg  nullable.NonEmptyString `db:"error_msg"  json:"errorMsg"`  // Error of the attempt, or NULL
+	ErrorData nullable.JSON           `db:"error_data" json:"errorData"` // Optional error metadata
+}
+
+// Duration returns how long the attempt took.
+func (a *JobAttempt) Duration() time.Duration {
+	return a.StoppedAt.Sub(a.StartedAt)
+}
+
+// String implements the fmt.Stringer interface.
+// Valid to call on a nil receiver.
+func (a *JobAttempt) String() string {
+	if a == nil {
+		return "nil JobAttempt"
+	}
+	return fmt.Sprintf("JobAttempt %d of job %s, %s after %s by worker '%s'", a.Attempt, a.JobID, a.Outcome, a.Duration(), a.Worker)
+}
@@ -69,6 +69,8 @@ func (b *JobBundle) String() string {
 // NewJobBundle creates a new job bundle with the specified type and origin.
 // A job will be created for every JobDesc in the jobDescriptions slice.
 // If a JobDesc.Type is an empty string, ReflectJobTypeOfPayload will be used to determine the type.
+// If a JobDesc.ID is nil a new ID will be generated, set it explicitly
+// to reference the job from the DependsOn of other jobs in the bundle.
 // If startAt is not null, none of the jobs in the bundle will start before that time.
 // Returns an error if jobDescriptions is empty or if any job creation fails.
 func NewJobBundle(ctx context.Context, jobBundleType, jobBundleOrigin string, jobDescriptions []JobDesc, startAt nullable.Time) (*JobBundle, error) {
@@ -83,10 +85,16 @@ func NewJobBundle(ctx context.Context, jobBundleType, jobBundleOrigin string, jo
 		if jobType == "" {
 			jobType = ReflectJobTypeOfPayload(desc.Payload)
 		}
-		job, err := NewJobWithPriority(uu.NewID(ctx), jobType, desc.Origin, desc.Payload, desc.Priority, startAt)
+		jobID := desc.ID
+		if jobID.IsNil() {
+			jobID = uu.NewID(ctx)
+		}
+		job, err := NewJobWithPriority(jobID, jobType, desc.Origin, desc.Payload, desc.Priority, startAt)
 		if err != nil {
 			return nil, err
 		}
+		job.DependsOn = desc.DependsOn
+		job.OnDependencyFailure = desc.OnDependencyFailure
 		jobs[i] = job
 	}
 
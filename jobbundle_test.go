@@ -48,6 +48,21 @@ func TestNewJobBundle(t *testing.T) {
 		assert.Equal(t, jobqueue.ReflectJobTypeOfPayload(payload), bundle.Jobs[0].Type)
 	})
 
+	t.Run("copies ID and dependencies", func(t *testing.T) {
+		firstID := uu.IDFrom("b2e3c1a4-7d6f-4e21-9a3b-5c8d0e1f2a3b")
+		descs := []jobqueue.JobDesc{
+			{Type: "type-a", Payload: "{}", Origin: "origin", ID: firstID},
+			{Type: "type-b", Payload: "{}", Origin: "origin", DependsOn: uu.IDs{firstID}, OnDependencyFailure: jobqueue.DependencyFailureCancel},
+		}
+		bundle, err := jobqueue.NewJobBundle(t.Context(), "bundleType", "bundleOrigin", descs, nullable.Time{})
+		require.NoError(t, err)
+
+		assert.Equal(t, firstID, bundle.Jobs[0].ID)
+		assert.Empty(t, bundle.Jobs[0].DependsOn)
+		assert.Equal(t, uu.IDs{firstID}, bundle.Jobs[1].DependsOn)
+		assert.Equal(t, jobqueue.DependencyFailureCancel, bundle.Jobs[1].OnDependencyFailure)
+	})
+
 	t.Run("propagates job creation error", func(t *testing.T) {
 		// A nil payload makes NewJobWithPriority fail, which must abort the bundle.
 		descs := []jobqueue.JobDesc{{Type: "type-a", Payload: nil, Origin: "origin"}}
@@ -1,6 +1,10 @@
 package jobqueue
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/domonda/go-types/uu"
+)
 
 // JobDesc describes a job to be created, typically used when creating job bundles.
 // It contains the essential information needed to create a Job instance.
@@ -13,6 +17,15 @@ type JobDesc struct {
 	Priority int64
 	// Origin identifies the source or context that created the job.
 	Origin string
+	// ID of the job to create. A new ID is generated if it is nil.
+	// Setting it allows other jobs of the same bundle to depend on this job.
+	ID uu.ID
+	// DependsOn holds the IDs of jobs that have to succeed before the job is started,
+	// see Job.DependsOn.
+	DependsOn uu.IDs
+	// OnDependencyFailure defines what happens to the job when
+	// one of its dependencies finally failed, see DependencyFailurePolicy.
+	OnDependencyFailure DependencyFailurePolicy
 }
 
 // String implements the fmt.Stringer interface.
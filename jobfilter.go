@@ -0,0 +1,59 @@
+package jobqueue
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/domonda/go-types/uu"
+)
+
+// JobFilter selects the jobs returned by Service.GetJobs.
+// Every non zero field narrows the selection,
+// a nil or zero JobFilter selects all jobs.
+type JobFilter struct {
+	// Type selects jobs of this job type.
+	Type string
+	// Origin selects jobs of this origin.
+	Origin string
+	// BundleID selects the jobs of this job bundle.
+	BundleID uu.NullableID
+	// State selects jobs in this JobState.
+	State JobState
+	// ErrorPattern selects jobs with an ErrorMsg matching
+	// this regular expression anywhere.
+	// jobworkerdb matches it with the PostgreSQL ~ operator
+	// and memqueue with the regexp package,
+	// so use syntax that both understand.
+	ErrorPattern string
+	// NewestFirst orders the jobs by descending instead of ascending CreatedAt,
+	// so that Limit selects the newest jobs.
+	NewestFirst bool
+	// Limit is the maximum number of returned jobs, zero means no limit.
+	Limit int
+}
+
+// Validate returns an error if State is not empty and not valid,
+// ErrorPattern is not a valid regular expression, or Limit is negative.
+// Valid to call on a nil receiver.
+func (f *JobFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.State != "" && !f.State.Valid() {
+		return fmt.Errorf("invalid JobFilter.State %q", f.State)
+	}
+	if _, err := regexp.Compile(f.ErrorPattern); err != nil {
+		return fmt.Errorf("invalid JobFilter.ErrorPattern: %w", err)
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("negative JobFilter.Limit %d", f.Limit)
+	}
+	return nil
+}
+
+// IsZero returns true if the receiver is nil
+// or dereferenced equal to its zero value.
+// Valid to call on a nil receiver.
+func (f *JobFilter) IsZero() bool {
+	return f == nil || *f == JobFilter{}
+}
@@ -0,0 +1,22 @@
+package jobqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestJobFilterValidate(t *testing.T) {
+	var nilFilter *jobqueue.JobFilter
+	assert.NoError(t, nilFilter.Validate(), "nil filter selects all jobs")
+	assert.True(t, nilFilter.IsZero())
+	assert.NoError(t, (&jobqueue.JobFilter{}).Validate())
+	assert.NoError(t, (&jobqueue.JobFilter{State: jobqueue.JobStateFailed, ErrorPattern: `^timeout`, Limit: 10}).Validate())
+	assert.False(t, (&jobqueue.JobFilter{Limit: 10}).IsZero())
+
+	assert.Error(t, (&jobqueue.JobFilter{State: "done"}).Validate())
+	assert.Error(t, (&jobqueue.JobFilter{ErrorPattern: `(`}).Validate())
+	assert.Error(t, (&jobqueue.JobFilter{Limit: -1}).Validate())
+}
@@ -0,0 +1,149 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+)
+
+// JobKind is a typed handle for the jobs of a job type with a payload of type P.
+//
+// Define a JobKind once and share it between the code adding the jobs
+// and the code registering the worker with jobworker.RegisterJobKind,
+// so that a mismatched payload type is a compile error:
+//
+//	var SendEmail = jobqueue.NewJobKind[*EmailPayload]("send-email")
+//
+//	job, err := SendEmail.Add(ctx, &EmailPayload{To: "user@example.com"}, jobqueue.WithOrigin("user-registration"))
+type JobKind[P any] struct {
+	jobType string
+}
+
+// NewJobKind returns a JobKind for jobType with a payload of type P.
+// NewJobKind panics if jobType is empty.
+func NewJobKind[P any](jobType string) JobKind[P] {
+	if jobType == "" {
+		panic(errors.New("jobqueue.NewJobKind: empty jobType"))
+	}
+	return JobKind[P]{jobType: jobType}
+}
+
+// NewJobKindReflectType returns a JobKind for the job type
+// JobTypeOfPayloadType(reflect.TypeFor[P]()), which is the job type
+// that jobworker.RegisterFunc uses for a worker function with payload type P.
+func NewJobKindReflectType[P any]() JobKind[P] {
+	return NewJobKind[P](JobTypeOfPayloadType(reflect.TypeFor[P]()))
+}
+
+// Type returns the job type.
+func (k JobKind[P]) Type() string {
+	return k.jobType
+}
+
+// String implements the fmt.Stringer interface.
+func (k JobKind[P]) String() string {
+	return k.jobType
+}
+
+// NewJob creates a Job of the JobKind with payload
+// but does not add it to the queue.
+// A new job ID is generated unless WithID is passed.
+// An origin has to be passed with WithOrigin.
+func (k JobKind[P]) NewJob(ctx context.Context, payload P, opts ...JobOption) (*Job, error) {
+	if k.jobType == "" {
+		return nil, errors.New("JobKind not created with NewJobKind")
+	}
+	job, err := NewJob(uu.NewID(ctx), k.jobType, "", payload, nullable.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if job.Origin == "" {
+		return nil, errors.New("empty job origin, use jobqueue.WithOrigin")
+	}
+	return job, nil
+}
+
+// Add creates a Job of the JobKind with payload like NewJob and adds it
+// to the queue using the service from the context or the default service.
+// The added Job is returned, with the ID of the existing job
+// if it was a duplicate handled by DuplicateJobUseExisting or DuplicateJobReplace.
+func (k JobKind[P]) Add(ctx context.Context, payload P, opts ...JobOption) (*Job, error) {
+	job, err := k.NewJob(ctx, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	err = Add(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// JobOption sets an optional field of a Job
+// created with JobKind.NewJob or JobKind.Add.
+type JobOption func(*Job)
+
+// WithID sets the ID of the job instead of generating a new one.
+func WithID(id uu.ID) JobOption {
+	return func(job *Job) {
+		job.ID = id
+	}
+}
+
+// WithOrigin sets the origin identifying the source
+// or context that created the job, see Job.Origin.
+func WithOrigin(origin string) JobOption {
+	return func(job *Job) {
+		job.Origin = origin
+	}
+}
+
+// WithPriority sets the priority of the job.
+// Jobs with a higher priority are started first.
+func WithPriority(priority int64) JobOption {
+	return func(job *Job) {
+		job.Priority = priority
+	}
+}
+
+// WithStartAt defers the start of the job until startAt.
+func WithStartAt(startAt time.Time) JobOption {
+	return func(job *Job) {
+		job.StartAt = nullable.TimeFrom(startAt)
+	}
+}
+
+// WithMaxRetryCount sets how often the job is retried
+// before it is considered finally failed.
+func WithMaxRetryCount(maxRetryCount int) JobOption {
+	return func(job *Job) {
+		job.MaxRetryCount = maxRetryCount
+	}
+}
+
+// WithUniqueKey sets the UniqueKey of the job and what happens
+// if an unfinished job of the same type with the same key exists,
+// see Job.UniqueKey and DuplicateJobPolicy.
+func WithUniqueKey(uniqueKey string, onDuplicate DuplicateJobPolicy) JobOption {
+	return func(job *Job) {
+		job.UniqueKey = nullable.NonEmptyString(uniqueKey)
+		job.OnDuplicate = onDuplicate
+	}
+}
+
+// WithDependsOn sets the jobs that have to succeed before the job is started
+// and what happens if one of them finally failed,
+// see Job.DependsOn and DependencyFailurePolicy.
+func WithDependsOn(onFailure DependencyFailurePolicy, jobIDs ...uu.ID) JobOption {
+	return func(job *Job) {
+		job.DependsOn = jobIDs
+		job.OnDependencyFailure = onFailure
+	}
+}
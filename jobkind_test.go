@@ -0,0 +1,73 @@
+package jobqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+type jobKindPayload struct {
+	Name string `json:"name"`
+}
+
+func TestJobKindNewJob(t *testing.T) {
+	kind := jobqueue.NewJobKind[*jobKindPayload]("job-kind-test")
+	assert.Equal(t, "job-kind-test", kind.Type())
+
+	t.Run("defaults", func(t *testing.T) {
+		job, err := kind.NewJob(t.Context(), &jobKindPayload{Name: "a"}, jobqueue.WithOrigin("test"))
+		require.NoError(t, err)
+		assert.False(t, job.ID.IsNil(), "ID generated")
+		assert.Equal(t, "job-kind-test", job.Type)
+		assert.Equal(t, "test", job.Origin)
+		assert.JSONEq(t, `{"name":"a"}`, job.Payload.String())
+		assert.Zero(t, job.Priority)
+		assert.True(t, job.StartAt.IsNull())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		id := uu.NewID(t.Context())
+		dependency := uu.NewID(t.Context())
+		startAt := time.Now().Add(time.Hour)
+		job, err := kind.NewJob(t.Context(), &jobKindPayload{Name: "b"},
+			jobqueue.WithID(id),
+			jobqueue.WithOrigin("test"),
+			jobqueue.WithPriority(7),
+			jobqueue.WithStartAt(startAt),
+			jobqueue.WithMaxRetryCount(3),
+			jobqueue.WithUniqueKey("key", jobqueue.DuplicateJobUseExisting),
+			jobqueue.WithDependsOn(jobqueue.DependencyFailureCancel, dependency),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, id, job.ID)
+		assert.Equal(t, int64(7), job.Priority)
+		assert.True(t, job.StartAt.Get().Equal(startAt))
+		assert.Equal(t, 3, job.MaxRetryCount)
+		assert.Equal(t, "key", job.UniqueKey.String())
+		assert.Equal(t, jobqueue.DuplicateJobUseExisting, job.OnDuplicate)
+		assert.Equal(t, uu.IDs{dependency}, job.DependsOn)
+		assert.Equal(t, jobqueue.DependencyFailureCancel, job.OnDependencyFailure)
+	})
+
+	t.Run("origin required", func(t *testing.T) {
+		_, err := kind.NewJob(t.Context(), &jobKindPayload{})
+		assert.Error(t, err)
+	})
+
+	t.Run("zero JobKind", func(t *testing.T) {
+		var zero jobqueue.JobKind[*jobKindPayload]
+		_, err := zero.NewJob(t.Context(), &jobKindPayload{}, jobqueue.WithOrigin("test"))
+		assert.Error(t, err)
+	})
+
+	assert.Panics(t, func() { jobqueue.NewJobKind[int]("") })
+}
+
+func TestNewJobKindReflectType(t *testing.T) {
+	assert.Equal(t, "time.Time", jobqueue.NewJobKindReflectType[*time.Time]().Type(), "pointer is dereferenced")
+}
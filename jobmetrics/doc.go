@@ -0,0 +1,53 @@
+/*
+Package jobmetrics exports metrics of the worker threads of a jobworker.Pool
+and of the job queue in the Prometheus text exposition format
+without depending on a Prometheus client library.
+
+# Overview
+
+A Metrics is a jobworker.Observer that counts the events of the worker
+threads of a Pool and an http.Handler that serves the metrics for scraping:
+
+	metrics := jobmetrics.New(jobworker.DefaultPool(), jobmetrics.WithQueueStatus(nil))
+	http.Handle("/metrics", metrics)
+
+# Worker Metrics
+
+The worker thread metrics are labelled with the job type:
+
+	jobqueue_jobs_started_total           counter    jobs started by the worker threads
+	jobqueue_jobs_succeeded_total         counter    jobs that succeeded
+	jobqueue_jobs_failed_total            counter    jobs that failed without a retry
+	jobqueue_jobs_retried_total           counter    jobs that failed and will be retried
+	jobqueue_jobs_snoozed_total           counter    jobs snoozed by their worker
+	jobqueue_jobs_cancelled_total         counter    jobs cancelled with jobqueue.CancelJob
+	jobqueue_jobs_interrupted_total       counter    jobs reset because the worker threads stopped
+	jobqueue_job_duration_seconds         histogram  time the worker took
+	jobqueue_job_queue_wait_seconds       histogram  started_at - created_at of the started jobs
+	jobqueue_heartbeat_errors_total       counter    failed worker_alive_at updates
+
+The claims and threads of the Pool are not labelled, except by state:
+
+	jobqueue_claim_duration_seconds       histogram  time of claiming jobs from the DataBase
+	jobqueue_claim_errors_total           counter    failed claims
+	jobqueue_worker_threads{state}        gauge      busy and idle worker threads
+
+A batch of a batch worker counts every job of the batch with the duration
+of the whole batch. The queue wait time of a retried job includes
+the time of its previous attempts.
+
+# Queue Metrics
+
+With WithQueueStatus every scrape also reads the jobqueue.Status
+of a Service and exports the JobStats per job type:
+
+	jobqueue_queue_jobs{type,state}                  gauge  jobs per lifecycle state
+	jobqueue_queue_oldest_pending_age_seconds{type}  gauge  wait time of the oldest pending job or due retry
+	jobqueue_queue_oldest_heartbeat_age_seconds{type} gauge age of the oldest heartbeat of a running job
+	jobqueue_queue_job_bundles                       gauge  job bundles in the queue
+
+Unlike the worker metrics, which only cover the Pool of the process,
+the queue metrics describe the whole queue, so it's enough
+to scrape them from one process.
+*/
+package jobmetrics
@@ -0,0 +1,115 @@
+package jobmetrics
+
+import (
+	"bytes"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// histogram counts observations in buckets with sorted upper bounds.
+type histogram struct {
+	buckets []float64
+	// counts has the non-cumulative count per bucket
+	// and one more for the +Inf bucket
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+func (h *histogram) observe(value float64) {
+	i, _ := slices.BinarySearch(h.buckets, value)
+	h.counts[i]++
+	h.count++
+	h.sum += value
+}
+
+// labelValueReplacer escapes a label value of the text exposition format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeHeader(buf *bytes.Buffer, name, metricType, help string) {
+	buf.WriteString("# HELP ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(help)
+	buf.WriteString("\n# TYPE ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(metricType)
+	buf.WriteByte('\n')
+}
+
+// writeSample writes a sample of name with labels
+// as pairs of label name and value.
+func writeSample(buf *bytes.Buffer, name string, labels []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(labels[i])
+			buf.WriteString(`="`)
+			_, _ = labelValueReplacer.WriteString(buf, labels[i+1])
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+func writeCountersByType(buf *bytes.Buffer, name string, counters map[string]uint64) {
+	for _, jobType := range sortedKeys(counters) {
+		writeSample(buf, name, []string{"type", jobType}, float64(counters[jobType]))
+	}
+}
+
+func writeHistogramsByType(buf *bytes.Buffer, name string, hists map[string]*histogram) {
+	for _, jobType := range sortedKeys(hists) {
+		writeHistogram(buf, name, []string{"type", jobType}, hists[jobType])
+	}
+}
+
+// writeHistogram writes the cumulative buckets, sum,
+// and count samples of h with labels.
+func writeHistogram(buf *bytes.Buffer, name string, labels []string, h *histogram) {
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(h.buckets) {
+			le = formatFloat(h.buckets[i])
+		}
+		writeSample(buf, name+"_bucket", append(slices.Clip(labels), "le", le), float64(cumulative))
+	}
+	writeSample(buf, name+"_sum", labels, h.sum)
+	writeSample(buf, name+"_count", labels, float64(h.count))
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,241 @@
+package jobmetrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	rootlog "github.com/domonda/golog/log"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+var log = rootlog.NewPackageLogger()
+
+// DefaultBuckets are the upper bounds in seconds of the histogram buckets
+// used if no WithBuckets option is passed to New.
+// They range from 5 milliseconds to one hour,
+// because jobs often run a lot longer than requests.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// outcomeCounters are the counters of the jobs per jobworker.JobOutcome.
+var outcomeCounters = []struct {
+	outcome jobworker.JobOutcome
+	name    string
+	help    string
+}{
+	{jobworker.JobSucceeded, "jobqueue_jobs_succeeded_total", "Number of jobs that succeeded."},
+	{jobworker.JobFailed, "jobqueue_jobs_failed_total", "Number of jobs that failed and will not be retried."},
+	{jobworker.JobRetried, "jobqueue_jobs_retried_total", "Number of jobs that failed and will be retried."},
+	{jobworker.JobSnoozed, "jobqueue_jobs_snoozed_total", "Number of jobs that were snoozed by their worker."},
+	{jobworker.JobCancelled, "jobqueue_jobs_cancelled_total", "Number of jobs that were cancelled."},
+	{jobworker.JobInterrupted, "jobqueue_jobs_interrupted_total", "Number of jobs that were reset because the worker threads stopped."},
+}
+
+// Option configures the Metrics created with New.
+type Option func(*Metrics)
+
+// WithBuckets sets the upper bounds in seconds of the buckets
+// of the duration histograms instead of DefaultBuckets.
+// The bounds are sorted and the +Inf bucket is always added.
+func WithBuckets(buckets ...float64) Option {
+	return func(m *Metrics) {
+		m.buckets = slices.Sorted(slices.Values(buckets))
+	}
+}
+
+// WithQueueStatus adds the JobStats of the jobqueue.Status of service
+// per job type to every scrape, see the package documentation.
+// A nil service uses the service from the context
+// of the request or the default service.
+func WithQueueStatus(service jobqueue.Service) Option {
+	return func(m *Metrics) {
+		m.queueStatus = true
+		m.service = service
+	}
+}
+
+// Metrics counts the events of the worker threads of a jobworker.Pool
+// as jobworker.Observer and serves them in the Prometheus text
+// exposition format as http.Handler.
+type Metrics struct {
+	pool        *jobworker.Pool
+	buckets     []float64
+	queueStatus bool
+	service     jobqueue.Service
+
+	// mtx guards the counters and histograms
+	mtx             sync.Mutex
+	started         map[string]uint64
+	stopped         map[jobworker.JobOutcome]map[string]uint64
+	jobDuration     map[string]*histogram
+	queueWait       map[string]*histogram
+	heartbeatErrors map[string]uint64
+	claimDuration   *histogram
+	claimErrors     uint64
+}
+
+// New returns Metrics for pool and adds them as Observer to pool.
+// Use jobworker.DefaultPool for the pool of the package level functions.
+func New(pool *jobworker.Pool, opts ...Option) *Metrics {
+	m := &Metrics{
+		pool:            pool,
+		buckets:         DefaultBuckets,
+		started:         map[string]uint64{},
+		stopped:         map[jobworker.JobOutcome]map[string]uint64{},
+		jobDuration:     map[string]*histogram{},
+		queueWait:       map[string]*histogram{},
+		heartbeatErrors: map[string]uint64{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.claimDuration = newHistogram(m.buckets)
+	pool.AddObserver(m)
+	return m
+}
+
+// OnClaim implements jobworker.Observer.
+func (m *Metrics) OnClaim(ctx context.Context, numJobs int, duration time.Duration, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.claimDuration.observe(duration.Seconds())
+	if err != nil {
+		m.claimErrors++
+	}
+}
+
+// OnJobStarted implements jobworker.Observer.
+func (m *Metrics) OnJobStarted(ctx context.Context, job *jobqueue.Job) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.started[job.Type]++
+	if job.StartedAt.IsNotNull() {
+		m.histogram(m.queueWait, job.Type).observe(job.StartedAt.Get().Sub(job.CreatedAt).Seconds())
+	}
+}
+
+// OnJobStopped implements jobworker.Observer.
+func (m *Metrics) OnJobStopped(ctx context.Context, job *jobqueue.Job, outcome jobworker.JobOutcome, duration time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.stopped[outcome] == nil {
+		m.stopped[outcome] = map[string]uint64{}
+	}
+	m.stopped[outcome][job.Type]++
+	if outcome != jobworker.JobCancelled || duration > 0 {
+		m.histogram(m.jobDuration, job.Type).observe(duration.Seconds())
+	}
+}
+
+// OnHeartbeatError implements jobworker.Observer.
+func (m *Metrics) OnHeartbeatError(ctx context.Context, job *jobqueue.Job, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.heartbeatErrors[job.Type]++
+}
+
+// histogram returns the histogram of jobType in hists
+// and adds it if it doesn't exist yet.
+// m.mtx must be locked.
+func (m *Metrics) histogram(hists map[string]*histogram, jobType string) *histogram {
+	h := hists[jobType]
+	if h == nil {
+		h = newHistogram(m.buckets)
+		hists[jobType] = h
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler by writing
+// the metrics in the Prometheus text exposition format.
+//
+// If WithQueueStatus was passed to New and the status
+// can't be read, the error is logged and the queue metrics
+// are left out, so the worker metrics are still scraped.
+func (m *Metrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+	m.writeWorkerMetrics(&buf)
+
+	if m.queueStatus {
+		var (
+			status *jobqueue.Status
+			err    error
+		)
+		if m.service != nil {
+			status, err = m.service.GetStatus(request.Context())
+		} else {
+			status, err = jobqueue.GetStatus(request.Context())
+		}
+		if err != nil {
+			log.ErrorCtx(request.Context(), "Error while reading the queue status for the metrics").
+				Err(err).
+				Log()
+		} else {
+			writeQueueMetrics(&buf, status)
+		}
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = writer.Write(buf.Bytes())
+}
+
+func (m *Metrics) writeWorkerMetrics(buf *bytes.Buffer) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	writeHeader(buf, "jobqueue_jobs_started_total", "counter", "Number of jobs started by the worker threads.")
+	writeCountersByType(buf, "jobqueue_jobs_started_total", m.started)
+	for _, c := range outcomeCounters {
+		writeHeader(buf, c.name, "counter", c.help)
+		writeCountersByType(buf, c.name, m.stopped[c.outcome])
+	}
+
+	writeHeader(buf, "jobqueue_job_duration_seconds", "histogram", "Time the worker of a job took.")
+	writeHistogramsByType(buf, "jobqueue_job_duration_seconds", m.jobDuration)
+	writeHeader(buf, "jobqueue_job_queue_wait_seconds", "histogram", "Time from the creation to the start of a job.")
+	writeHistogramsByType(buf, "jobqueue_job_queue_wait_seconds", m.queueWait)
+
+	writeHeader(buf, "jobqueue_heartbeat_errors_total", "counter", "Number of failed job heartbeat updates.")
+	writeCountersByType(buf, "jobqueue_heartbeat_errors_total", m.heartbeatErrors)
+
+	writeHeader(buf, "jobqueue_claim_duration_seconds", "histogram", "Time of claiming jobs from the database.")
+	writeHistogram(buf, "jobqueue_claim_duration_seconds", nil, m.claimDuration)
+	writeHeader(buf, "jobqueue_claim_errors_total", "counter", "Number of failed claims of jobs from the database.")
+	writeSample(buf, "jobqueue_claim_errors_total", nil, float64(m.claimErrors))
+
+	running, idle := m.pool.NumThreads()
+	writeHeader(buf, "jobqueue_worker_threads", "gauge", "Number of busy and idle worker threads.")
+	writeSample(buf, "jobqueue_worker_threads", []string{"state", "busy"}, float64(running-idle))
+	writeSample(buf, "jobqueue_worker_threads", []string{"state", "idle"}, float64(idle))
+}
+
+func writeQueueMetrics(buf *bytes.Buffer, status *jobqueue.Status) {
+	jobTypes := sortedKeys(status.ByType)
+
+	writeHeader(buf, "jobqueue_queue_jobs", "gauge", "Number of jobs in the queue per lifecycle state.")
+	for _, jobType := range jobTypes {
+		stats := status.ByType[jobType]
+		for _, state := range jobqueue.JobStates {
+			writeSample(buf, "jobqueue_queue_jobs", []string{"type", jobType, "state", string(state)}, float64(stats.Count(state)))
+		}
+	}
+	writeHeader(buf, "jobqueue_queue_oldest_pending_age_seconds", "gauge", "Time the oldest pending job has been waiting.")
+	for _, jobType := range jobTypes {
+		writeSample(buf, "jobqueue_queue_oldest_pending_age_seconds", []string{"type", jobType}, status.ByType[jobType].OldestPendingAge.Seconds())
+	}
+	writeHeader(buf, "jobqueue_queue_oldest_heartbeat_age_seconds", "gauge", "Age of the oldest heartbeat of a running job.")
+	for _, jobType := range jobTypes {
+		writeSample(buf, "jobqueue_queue_oldest_heartbeat_age_seconds", []string{"type", jobType}, status.ByType[jobType].OldestHeartbeatAge.Seconds())
+	}
+	writeHeader(buf, "jobqueue_queue_job_bundles", "gauge", "Number of job bundles in the queue.")
+	writeSample(buf, "jobqueue_queue_job_bundles", nil, float64(status.NumJobBundles))
+}
@@ -0,0 +1,92 @@
+package jobmetrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobmetrics"
+	"github.com/domonda/go-jobqueue/jobworker"
+	"github.com/domonda/go-jobqueue/memqueue"
+)
+
+func scrape(t *testing.T, metrics *jobmetrics.Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	return recorder.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	const (
+		okType   = "jobmetrics-test-ok"
+		failType = "jobmetrics-test-fail"
+	)
+	db := memqueue.NewDataBase()
+	t.Cleanup(func() { _ = db.Close() })
+	pool := jobworker.NewPool(db)
+	pool.Register(okType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, nil
+	})
+	pool.Register(failType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, jobworker.Permanent(errors.New("failed"))
+	})
+	metrics := jobmetrics.New(pool,
+		jobmetrics.WithBuckets(10, 0.5),
+		jobmetrics.WithQueueStatus(db.(jobqueue.Service)),
+	)
+
+	for _, jobType := range []string{okType, okType, failType} {
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), jobType, "test", `{}`, nullable.Time{})
+		require.NoError(t, err)
+		require.NoError(t, db.AddJob(t.Context(), job))
+	}
+	require.NoError(t, pool.StartThreads(t.Context(), 2))
+	require.Eventually(t, func() bool {
+		status, err := db.GetStatus(t.Context())
+		return err == nil && status.Jobs.Succeeded+status.Jobs.Failed == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	// Waits until the observers were notified
+	pool.FinishThreads(context.Background())
+
+	body := scrape(t, metrics)
+	for _, line := range []string{
+		"# TYPE jobqueue_jobs_started_total counter\n",
+		`jobqueue_jobs_started_total{type="jobmetrics-test-fail"} 1` + "\n",
+		`jobqueue_jobs_started_total{type="jobmetrics-test-ok"} 2` + "\n",
+		`jobqueue_jobs_succeeded_total{type="jobmetrics-test-ok"} 2` + "\n",
+		`jobqueue_jobs_failed_total{type="jobmetrics-test-fail"} 1` + "\n",
+		"# TYPE jobqueue_job_duration_seconds histogram\n",
+		`jobqueue_job_duration_seconds_bucket{type="jobmetrics-test-ok",le="0.5"} 2` + "\n",
+		`jobqueue_job_duration_seconds_bucket{type="jobmetrics-test-ok",le="10"} 2` + "\n",
+		`jobqueue_job_duration_seconds_bucket{type="jobmetrics-test-ok",le="+Inf"} 2` + "\n",
+		`jobqueue_job_duration_seconds_count{type="jobmetrics-test-ok"} 2` + "\n",
+		`jobqueue_job_queue_wait_seconds_count{type="jobmetrics-test-fail"} 1` + "\n",
+		"jobqueue_claim_errors_total 0\n",
+		`jobqueue_worker_threads{state="busy"} 0` + "\n",
+		`jobqueue_worker_threads{state="idle"} 0` + "\n",
+		`jobqueue_queue_jobs{type="jobmetrics-test-ok",state="succeeded"} 2` + "\n",
+		`jobqueue_queue_jobs{type="jobmetrics-test-fail",state="failed"} 1` + "\n",
+		`jobqueue_queue_jobs{type="jobmetrics-test-fail",state="pending"} 0` + "\n",
+		"jobqueue_queue_job_bundles 0\n",
+	} {
+		assert.Contains(t, body, line)
+	}
+	assert.NotContains(t, body, "jobqueue_jobs_retried_total{")
+	// At least one claim per job and one that found no job
+	assert.Regexp(t, `(?m)^jobqueue_claim_duration_seconds_count ([4-9]|\d{2,})$`, body)
+
+	// Label values are escaped
+	metrics.OnHeartbeatError(t.Context(), &jobqueue.Job{Type: "a\"b\\c\nd"}, errors.New("heartbeat failed"))
+	assert.Contains(t, scrape(t, metrics), `jobqueue_heartbeat_errors_total{type="a\"b\\c\nd"} 1`+"\n")
+}
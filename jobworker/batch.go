@@ -0,0 +1,428 @@
+package jobworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/notnull"
+	"github.com/domonda/go-types/uu"
+	"github.com/domonda/golog"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// BatchWorkerFunc processes several jobs of one type at once
+// and returns one error per job, or nil if all jobs succeeded.
+// Register a BatchWorkerFunc for a job type with RegisterBatch.
+//
+// The error at index i is the outcome of jobs[i]. The errors are handled
+// like the error of a WorkerFunc, so Permanent, Snooze, and RetryAt
+// apply to the single job. The function can set the Result
+// of a job that succeeded, it is stored as the job's result.
+type BatchWorkerFunc func(ctx context.Context, jobs []*jobqueue.Job) []error
+
+// BatchJobOrigin is the Origin of the job that the Middleware
+// is called with for a batch of a BatchWorkerFunc, see RegisterBatch.
+const BatchJobOrigin = "batch"
+
+// batchWorker is a BatchWorkerFunc registered with RegisterBatch.
+type batchWorker struct {
+	maxBatch int
+	maxWait  time.Duration
+	worker   BatchWorkerFunc
+}
+
+// RegisterBatch registers a BatchWorkerFunc for jobType
+// with the default Pool, see Pool.RegisterBatch.
+func RegisterBatch(jobType string, maxBatch int, maxWait time.Duration, worker BatchWorkerFunc) {
+	defaultPool.RegisterBatch(jobType, maxBatch, maxWait, worker)
+}
+
+// RegisterBatch registers a BatchWorkerFunc for jobType
+// for job types that are cheaper to process in bulk.
+//
+// A worker thread that claimed a job of jobType claims up to maxBatch-1
+// more available jobs of the type together with DataBase.StartNextJobsOrNil.
+// If fewer than maxBatch jobs were claimed, it waits maxWait once
+// for more jobs to become available and claims them too.
+// A job whose cancellation is requested while waiting
+// is stopped as cancelled right away.
+// Then it calls worker once with all jobs and records the outcome of every job
+// like for a WorkerFunc with SetJobResult, SetJobError, or ScheduleRetry.
+// The heartbeat of a job starts when it is claimed,
+// so keep maxWait short compared to the job timeout.
+//
+// Jobs whose cancellation was requested before worker is called
+// are stopped as cancelled without being passed to worker.
+// The context passed to worker is cancelled with jobqueue.ErrJobCancelled
+// as cause when the cancellation of all jobs of the batch was requested.
+// If worker returns an error for a job whose cancellation was requested
+// while the batch was running, the job is recorded as cancelled
+// instead of as errored and not retried.
+//
+// The Middleware added with Use and UseForJobType wraps a batch
+// run by the worker threads like a single job. It is called with
+// a job of jobType with a new ID, BatchJobOrigin as Origin,
+// and the JSON array of the payloads of the jobs of the batch as Payload.
+// Changing that job doesn't change the jobs passed to worker.
+// The errors returned by worker are the outcomes of the jobs,
+// an error returned by the Middleware is the outcome of every job
+// if it didn't call worker or worker returned no error.
+// DoJob calls worker with a batch of one job wrapped
+// by the Middleware for that job.
+// A batch run by the worker threads has one span of the Tracer
+// linked to the trace contexts of its jobs, see SetTracer,
+// and the context passed to worker doesn't hold a job for JobFromContext.
+// Every job of a batch counts for a SetMaxConcurrency limit of jobType,
+// so a batch has at most as many jobs as the limit.
+//
+// Like Register, RegisterBatch must be called before StartThreads
+// and panics if worker threads are running.
+func (p *Pool) RegisterBatch(jobType string, maxBatch int, maxWait time.Duration, worker BatchWorkerFunc) {
+	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType, maxBatch, maxWait)
+
+	if maxBatch < 1 {
+		panic(fmt.Errorf("maxBatch %d for jobType %#v must be at least 1", maxBatch, jobType))
+	}
+	if maxWait < 0 {
+		panic(fmt.Errorf("negative maxWait %s for jobType %#v", maxWait, jobType))
+	}
+	if worker == nil {
+		panic(fmt.Errorf("nil BatchWorkerFunc for jobType %#v", jobType))
+	}
+
+	single := func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
+		err = batchJobError(worker(ctx, []*jobqueue.Job{job}), 0, 1)
+		return job.Result, err
+	}
+	p.register(jobType, single, &batchWorker{maxBatch: maxBatch, maxWait: maxWait, worker: worker})
+}
+
+// batchWorkerOrNil returns the batch worker registered
+// for jobType with RegisterBatch or nil.
+func (p *Pool) batchWorkerOrNil(jobType string) *batchWorker {
+	p.workersMtx.RLock()
+	defer p.workersMtx.RUnlock()
+
+	return p.batchWorkers[jobType]
+}
+
+// batchJobError returns the error of the job at index i of the
+// numJobs jobs passed to a BatchWorkerFunc that returned jobErrs.
+func batchJobError(jobErrs []error, i, numJobs int) error {
+	if jobErrs == nil {
+		return nil
+	}
+	if len(jobErrs) != numJobs {
+		return fmt.Errorf("batch worker returned %d errors for %d jobs", len(jobErrs), numJobs)
+	}
+	return jobErrs[i]
+}
+
+// doBatchAndSaveResultsInDB claims more jobs of the type of the
+// previously claimed job first, runs the batch worker with all of them,
+// and persists the outcome of every job like doJobAndSaveResultInDB.
+// The duration of the batch is reported to the Observers for every job.
+func (p *Pool) doBatchAndSaveResultsInDB(ctx context.Context, batch *batchWorker, first *jobqueue.Job) (err error) {
+	defer errs.WrapWithFuncParams(&err, first)
+
+	b := p.newRunningBatch(ctx)
+	defer b.close()
+
+	var saveErrs []error
+	b.add(first)
+	b.add(p.claimBatchJobs(ctx, first.Type, batch.maxBatch-1)...)
+	if len(b.jobs) < batch.maxBatch && batch.maxWait > 0 && !p.stopping.Load() {
+		timer := time.NewTimer(batch.maxWait)
+		defer timer.Stop()
+	wait:
+		for {
+			select {
+			case <-timer.C:
+				if !p.stopping.Load() {
+					b.add(p.claimBatchJobs(ctx, first.Type, batch.maxBatch-len(b.jobs))...)
+				}
+				break wait
+			case <-b.cancelRequested:
+				// Don't keep a cancelled job waiting for the other jobs of the batch
+				saveErrs = append(saveErrs, b.stopCancelledJobs(ctx))
+				if len(b.jobs) == 0 {
+					return errors.Join(saveErrs...)
+				}
+			case <-ctx.Done():
+				break wait
+			}
+		}
+	}
+
+	// The cancellation was requested before the batch is run,
+	// for example while the job was waiting for a retry
+	saveErrs = append(saveErrs, b.stopCancelledJobs(ctx))
+	if len(b.jobs) == 0 {
+		return errors.Join(saveErrs...)
+	}
+
+	start := time.Now()
+	jobErrs := p.callBatchWorker(b.ctx, batch, b.jobs)
+	duration := time.Since(start)
+
+	for i, job := range b.jobs {
+		jobErr := batchJobError(jobErrs, i, len(b.jobs))
+		if jobErr == nil {
+			b.stopHeartbeats[i]()
+			saveErrs = append(saveErrs, p.db.SetJobResult(context.WithoutCancel(ctx), job.ID, job.Result))
+			p.notifyJobStopped(ctx, job, JobSucceeded, duration)
+			continue
+		}
+		// Cancelled with CancelJob while the batch was running:
+		// record the job as cancelled like doJobAndSaveResultInDB
+		if p.isJobCancelRequested(ctx, job) {
+			b.stopHeartbeats[i]()
+			saveErrs = append(saveErrs, p.db.SetJobCancelled(context.WithoutCancel(ctx), job.ID))
+			p.notifyJobStopped(ctx, job, JobCancelled, duration)
+			continue
+		}
+		if _, snoozed := snoozeDuration(jobErr); !snoozed {
+			p.onJobError(golog.ContextWithAttribs(ctx, golog.NewUUID("jobID", job.ID)), job, jobErr)
+		}
+		outcome, err := p.saveJobError(ctx, job, jobErr, b.stopHeartbeats[i])
+		saveErrs = append(saveErrs, err)
+		p.notifyJobStopped(ctx, job, outcome, duration)
+	}
+	return errors.Join(saveErrs...)
+}
+
+// runningBatch holds the claimed jobs of a batch
+// processed by doBatchAndSaveResultsInDB.
+type runningBatch struct {
+	pool *Pool
+	// threadCtx is the context of the worker thread
+	// that the heartbeats of the jobs use
+	threadCtx context.Context
+	// ctx is passed to the batch worker and cancelled with
+	// jobqueue.ErrJobCancelled as cause when the cancellation
+	// of all jobs of the batch was requested
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	// cancelRequested is signalled when the cancellation
+	// of a job of the batch was requested
+	cancelRequested chan struct{}
+
+	// jobs is only changed by the worker thread
+	// while holding Pool.claimedJobsMtx
+	jobs           []*jobqueue.Job
+	stopHeartbeats []func()
+}
+
+func (p *Pool) newRunningBatch(ctx context.Context) *runningBatch {
+	b := &runningBatch{pool: p, threadCtx: ctx, cancelRequested: make(chan struct{}, 1)}
+	b.ctx, b.cancel = context.WithCancelCause(ctx)
+	return b
+}
+
+// add starts the heartbeats of the claimed jobs, adds them to the batch,
+// and notifies the Observers that the jobs were started.
+// Registering the batch for the cancellation of the jobs before checking
+// for an earlier cancellation with stopCancelledJobs makes sure that
+// no cancellation request falls in between.
+func (b *runningBatch) add(jobs ...*jobqueue.Job) {
+	for _, job := range jobs {
+		b.stopHeartbeats = append(b.stopHeartbeats, b.pool.startJobHeartbeat(b.threadCtx, job))
+
+		b.pool.claimedJobsMtx.Lock()
+		claimed := b.pool.claimedJobs[job.ID]
+		if claimed == nil {
+			claimed = new(claimedJob)
+			b.pool.claimedJobs[job.ID] = claimed
+		}
+		claimed.cancel = b.onJobCancelRequested
+		b.jobs = append(b.jobs, job)
+		b.pool.claimedJobsMtx.Unlock()
+
+		b.pool.notifyJobStarted(b.threadCtx, job)
+	}
+}
+
+// onJobCancelRequested is called by Pool.onJobCancelRequested
+// for a job of the batch. It signals cancelRequested
+// and cancels the context of the batch
+// if the cancellation of all its jobs was requested.
+func (b *runningBatch) onJobCancelRequested(cause error) {
+	select {
+	case b.cancelRequested <- struct{}{}:
+	default:
+	}
+
+	b.pool.claimedJobsMtx.Lock()
+	all := len(b.jobs) > 0
+	for _, job := range b.jobs {
+		if claimed := b.pool.claimedJobs[job.ID]; claimed == nil || !claimed.cancelRequested {
+			all = false
+			break
+		}
+	}
+	b.pool.claimedJobsMtx.Unlock()
+
+	if all {
+		b.cancel(cause)
+	}
+}
+
+// stopCancelledJobs stops the jobs of the batch whose cancellation
+// was requested as cancelled and removes them from the batch.
+// The removed jobs are not counted for SetMaxConcurrency anymore.
+func (b *runningBatch) stopCancelledJobs(ctx context.Context) error {
+	p := b.pool
+	var saveErrs []error
+	for i := 0; i < len(b.jobs); {
+		job := b.jobs[i]
+		if !p.isJobCancelRequested(ctx, job) {
+			i++
+			continue
+		}
+		b.stopHeartbeats[i]()
+		saveErrs = append(saveErrs, p.db.SetJobCancelled(context.WithoutCancel(ctx), job.ID))
+		p.notifyJobStopped(ctx, job, JobCancelled, 0)
+
+		p.claimedJobsMtx.Lock()
+		b.jobs = slices.Delete(b.jobs, i, i+1)
+		p.claimedJobsMtx.Unlock()
+		b.stopHeartbeats = slices.Delete(b.stopHeartbeats, i, i+1)
+		p.releaseClaimedJob(job.ID)
+		p.onJobDone(job)
+	}
+	return errors.Join(saveErrs...)
+}
+
+// close stops the heartbeats of the jobs of the batch,
+// which is idempotent, releases their claims, counts them
+// as not running for SetMaxConcurrency anymore,
+// and cancels the context of the batch.
+func (b *runningBatch) close() {
+	for _, stop := range b.stopHeartbeats {
+		stop()
+	}
+	for _, job := range b.jobs {
+		b.pool.releaseClaimedJob(job.ID)
+		b.pool.onJobDone(job)
+	}
+	b.cancel(nil)
+}
+
+// claimBatchJobs claims up to n jobs of jobType
+// without exceeding its SetMaxConcurrency limit
+// and counts them as running for the limit.
+func (p *Pool) claimBatchJobs(ctx context.Context, jobType string, n int) []*jobqueue.Job {
+	// Reserve the jobs for the limit before the claim
+	// instead of holding the lock during the claim
+	p.concurrencyMtx.Lock()
+	limit, limited := p.maxConcurrency[jobType]
+	if limited {
+		n = min(n, limit-p.numRunningJobs[jobType])
+		if n > 0 {
+			p.numRunningJobs[jobType] += n
+		}
+	}
+	p.concurrencyMtx.Unlock()
+	if n <= 0 {
+		return nil
+	}
+
+	claim := p.Claim()
+	skipJobTypes := slices.DeleteFunc(slices.Clone(claim.JobTypes), func(t string) bool {
+		return t == jobType
+	})
+	jobs, err := p.claimJobs(ctx, claim, n, skipJobTypes...)
+	if err != nil {
+		p.onError(err)
+		log.ErrorCtx(ctx, "Error while claiming the jobs of a batch").
+			Str("jobType", jobType).
+			Err(err).
+			Log()
+	}
+	if limited && len(jobs) < n {
+		// Release the reservation of the jobs that were not claimed
+		p.concurrencyMtx.Lock()
+		p.numRunningJobs[jobType] -= n - len(jobs)
+		p.concurrencyMtx.Unlock()
+		// Other worker threads might have skipped
+		// available jobs of the type because of the reservation
+		p.onCheckJob()
+	}
+	return jobs
+}
+
+// callBatchWorker calls the worker of batch with jobs
+// wrapped with the Middleware for the type of the jobs
+// and returns one error per job, or nil if all jobs succeeded.
+// A panic of the worker or the Middleware is recovered
+// and returned as error of every job.
+//
+// The batch runs in a span of the Tracer of the Pool if one is set,
+// see SetTracer.
+//
+// The job timeout of the Pool is applied to the batch
+// if the passed context doesn't already have a deadline.
+func (p *Pool) callBatchWorker(ctx context.Context, batch *batchWorker, jobs []*jobqueue.Job) (jobErrs []error) {
+	ctx, endSpan := p.startBatchSpan(ctx, jobs)
+	defer func() {
+		if r := recover(); r != nil {
+			err := errs.Errorf("batch worker panic: %w", errs.AsErrorWithDebugStack(r))
+			jobErrs = make([]error, len(jobs))
+			for i := range jobErrs {
+				jobErrs[i] = err
+			}
+		}
+		if endSpan != nil {
+			endSpan(errors.Join(jobErrs...))
+		}
+	}()
+
+	if timeout := *p.jobTimeout; timeout > 0 {
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+
+	var workerErrs []error
+	worker := p.withMiddleware(jobs[0].Type, func(ctx context.Context, _ *jobqueue.Job) (any, error) {
+		workerErrs = batch.worker(ctx, jobs)
+		return nil, errors.Join(workerErrs...)
+	})
+	_, err := worker(ctx, newBatchJob(ctx, jobs))
+	if err == nil || errors.Join(workerErrs...) != nil {
+		return workerErrs
+	}
+	// The Middleware didn't call the worker
+	// or returned an error although the worker succeeded
+	jobErrs = make([]error, len(jobs))
+	for i := range jobErrs {
+		jobErrs[i] = err
+	}
+	return jobErrs
+}
+
+// newBatchJob returns the job that the Middleware
+// is called with for a batch of jobs, see RegisterBatch.
+func newBatchJob(ctx context.Context, jobs []*jobqueue.Job) *jobqueue.Job {
+	payloads := make([][]byte, len(jobs))
+	for i, job := range jobs {
+		payloads[i] = job.Payload
+	}
+	now := time.Now()
+	return &jobqueue.Job{
+		ID:        uu.NewID(ctx),
+		Type:      jobs[0].Type,
+		Payload:   notnull.JSON(slices.Concat([]byte("["), bytes.Join(payloads, []byte(",")), []byte("]"))),
+		Origin:    BatchJobOrigin,
+		UpdatedAt: now,
+		CreatedAt: now,
+	}
+}
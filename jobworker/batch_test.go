@@ -0,0 +1,264 @@
+package jobworker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestBatchWorker(t *testing.T) {
+	const jobType = "jobworker-test-batch"
+	pool, db := newTestPool(t)
+
+	jobs := make([]*jobqueue.Job, 8)
+	for i := range jobs {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+	}
+	failing := jobs[2].ID
+
+	var (
+		batchSizesMtx sync.Mutex
+		batchSizes    []int
+	)
+	pool.RegisterBatch(jobType, 5, 50*time.Millisecond, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		batchSizesMtx.Lock()
+		batchSizes = append(batchSizes, len(jobs))
+		batchSizesMtx.Unlock()
+
+		jobErrs := make([]error, len(jobs))
+		for i, job := range jobs {
+			if job.ID == failing {
+				jobErrs[i] = jobworker.Permanent(errors.New("batch job failed"))
+				continue
+			}
+			job.Result = nullable.JSON(`"done"`)
+		}
+		return jobErrs
+	})
+	assert.True(t, pool.IsRegistered(jobType))
+	assert.Panics(t, func() { pool.RegisterBatch(jobType+"-invalid", 0, 0, nil) }, "maxBatch 0")
+
+	// DoJob calls the batch worker with a single job
+	single := newTestJob(t, jobType, 0, nullable.Time{})
+	require.NoError(t, pool.DoJob(t.Context(), single))
+	assert.JSONEq(t, `"done"`, single.Result.String())
+	batchSizes = nil
+
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+	startThreads(t, pool, 1)
+
+	for _, job := range jobs {
+		loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Stopped)
+		if job.ID == failing {
+			assert.True(t, loaded.HasError())
+			assert.Equal(t, "batch job failed", loaded.ErrorMsg.String())
+		} else {
+			assert.True(t, loaded.Succeeded())
+			assert.JSONEq(t, `"done"`, loaded.Result.String())
+		}
+	}
+	batchSizesMtx.Lock()
+	defer batchSizesMtx.Unlock()
+	assert.Equal(t, []int{5, 3}, batchSizes, "batches of up to 5 jobs")
+}
+
+func TestCancelBatchJob(t *testing.T) {
+	const jobType = "jobworker-test-cancel-batch"
+	pool, db := newTestPool(t)
+
+	cancelled := newTestJob(t, jobType, 1, nullable.Time{})
+	cancelled.MaxRetryCount = 3
+	succeeded := newTestJob(t, jobType, 0, nullable.Time{})
+
+	var (
+		running = make(chan struct{})
+		release = make(chan struct{})
+	)
+	pool.RegisterBatch(jobType, 2, 0, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		close(running)
+		<-release
+		jobErrs := make([]error, len(jobs))
+		for i, job := range jobs {
+			if job.ID == cancelled.ID {
+				jobErrs[i] = errors.New("interrupted by cancellation")
+			}
+		}
+		return jobErrs
+	})
+	require.NoError(t, db.AddJobs(t.Context(), []*jobqueue.Job{cancelled, succeeded}))
+	startThreads(t, pool, 1)
+
+	select {
+	case <-running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not started")
+	}
+	require.NoError(t, db.CancelJob(t.Context(), cancelled.ID))
+	close(release)
+
+	loaded := waitForJob(t, db, succeeded.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Succeeded(), "other job of the batch not affected")
+
+	loaded = waitForJob(t, db, cancelled.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Cancelled(), "cancelled instead of errored")
+	assert.Zero(t, loaded.CurrentRetryCount, "cancelled job is not retried")
+	attempts, err := db.GetJobAttempts(t.Context(), cancelled.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1, "no retry attempt")
+	assert.Equal(t, jobqueue.JobAttemptCancelled, attempts[0].Outcome)
+}
+
+func TestBatchMaxConcurrency(t *testing.T) {
+	const jobType = "jobworker-test-batch-concurrency"
+	pool, db := newTestPool(t)
+
+	jobs := make([]*jobqueue.Job, 6)
+	for i := range jobs {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+	}
+	var (
+		batchSizesMtx sync.Mutex
+		batchSizes    []int
+	)
+	pool.RegisterBatch(jobType, 5, 0, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		batchSizesMtx.Lock()
+		batchSizes = append(batchSizes, len(jobs))
+		batchSizesMtx.Unlock()
+		return nil
+	})
+	require.NoError(t, pool.SetMaxConcurrency(jobType, 2))
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+	startThreads(t, pool, 1)
+
+	for _, job := range jobs {
+		waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	}
+	batchSizesMtx.Lock()
+	defer batchSizesMtx.Unlock()
+	assert.Equal(t, []int{2, 2, 2}, batchSizes, "every job of a batch counts for the limit")
+}
+
+func TestCancelBatchJobWhileWaiting(t *testing.T) {
+	const jobType = "jobworker-test-cancel-batch-waiting"
+	pool, db := newTestPool(t)
+
+	var called atomic.Bool
+	pool.RegisterBatch(jobType, 5, time.Minute, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		called.Store(true)
+		return nil
+	})
+	job := newTestJob(t, jobType, 0, nullable.Time{})
+	require.NoError(t, db.AddJob(t.Context(), job))
+	// Don't wait for the thread waiting maxWait
+	require.NoError(t, pool.StartThreads(t.Context(), 1))
+	t.Cleanup(func() { pool.StopThreads(context.Background()) })
+
+	waitForJob(t, db, job.ID, (*jobqueue.Job).Started)
+	require.NoError(t, db.CancelJob(t.Context(), job.ID))
+
+	// Stopped before maxWait ended
+	waitForJob(t, db, job.ID, (*jobqueue.Job).Cancelled)
+	assert.False(t, called.Load(), "batch worker not called for the cancelled job")
+}
+
+func TestBatchMiddleware(t *testing.T) {
+	const jobType = "jobworker-test-batch-middleware"
+	pool, db := newTestPool(t)
+
+	jobs := make([]*jobqueue.Job, 3)
+	for i := range jobs {
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), jobType, "jobworker-test", fmt.Sprintf(`{"i":%d}`, i), nullable.Time{})
+		require.NoError(t, err)
+		jobs[i] = job
+	}
+
+	batchJobs := make(chan *jobqueue.Job, 1)
+	pool.UseForJobType(jobType, func(next jobworker.WorkerFunc) jobworker.WorkerFunc {
+		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+			if job.Origin != jobworker.BatchJobOrigin {
+				return next(ctx, job)
+			}
+			batchJobs <- job
+			return nil, jobworker.Permanent(errors.New("rejected by middleware"))
+		}
+	})
+	var called atomic.Bool
+	pool.RegisterBatch(jobType, len(jobs), 0, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		called.Store(true)
+		return nil
+	})
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+	startThreads(t, pool, 1)
+
+	for _, job := range jobs {
+		loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Stopped)
+		assert.Equal(t, "rejected by middleware", loaded.ErrorMsg.String(), "error of the middleware is the outcome of every job")
+	}
+	assert.False(t, called.Load(), "batch worker not called by the middleware")
+
+	batchJob := <-batchJobs
+	assert.Equal(t, jobType, batchJob.Type)
+	assert.JSONEq(t, `[{"i":0},{"i":1},{"i":2}]`, batchJob.Payload.String())
+}
+
+func TestCancelAllBatchJobs(t *testing.T) {
+	const jobType = "jobworker-test-cancel-all-batch"
+	pool, db := newTestPool(t)
+
+	jobs := []*jobqueue.Job{
+		newTestJob(t, jobType, 1, nullable.Time{}),
+		newTestJob(t, jobType, 0, nullable.Time{}),
+	}
+	var (
+		running = make(chan struct{})
+		cause   = make(chan error, 1)
+	)
+	pool.RegisterBatch(jobType, len(jobs), 0, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		close(running)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		jobErrs := make([]error, len(jobs))
+		for i := range jobErrs {
+			jobErrs[i] = ctx.Err()
+		}
+		return jobErrs
+	})
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+	startThreads(t, pool, 1)
+
+	select {
+	case <-running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not started")
+	}
+	require.NoError(t, db.CancelJob(t.Context(), jobs[0].ID))
+	select {
+	case <-cause:
+		t.Fatal("batch context cancelled while a job of the batch is not cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, db.CancelJob(t.Context(), jobs[1].ID))
+	select {
+	case err := <-cause:
+		assert.ErrorIs(t, err, jobqueue.ErrJobCancelled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch context not cancelled")
+	}
+
+	for _, job := range jobs {
+		loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Stopped)
+		assert.True(t, loaded.Cancelled())
+	}
+}
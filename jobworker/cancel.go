@@ -0,0 +1,150 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// unclaimedCancelTTL is how long onJobCancelRequested remembers
+// the cancellation of a job that is not claimed by the Pool.
+// The notification of a cancellation requested right after a claim
+// can arrive before the claim returned, so the request is kept
+// for the registration of the claimed job with registerClaimedJob.
+const unclaimedCancelTTL = time.Minute
+
+// claimedJob is a job claimed by the worker threads of a Pool,
+// see Pool.claimedJobs.
+type claimedJob struct {
+	// cancel cancels the context of the job while it is processed
+	// by doJobAndSaveResultInDB, or notifies the runningBatch
+	// of doBatchAndSaveResultsInDB that holds the job, nil before
+	cancel context.CancelCauseFunc
+	// cancelRequested is set when the cancellation of the job
+	// was requested after the claim
+	cancelRequested bool
+	// cancelRequestedUnclaimed is set when the cancellation
+	// was requested before the claim was registered,
+	// which may be from before a ResetJob
+	cancelRequestedUnclaimed bool
+}
+
+// registerClaimedJob registers a job claimed by a worker thread,
+// so that a cancellation requested before the job is processed
+// is not lost. Every registered job must be released
+// with releaseClaimedJob.
+func (p *Pool) registerClaimedJob(jobID uu.ID) {
+	p.claimedJobsMtx.Lock()
+	defer p.claimedJobsMtx.Unlock()
+
+	claimed := new(claimedJob)
+	if _, ok := p.unclaimedCancels[jobID]; ok {
+		delete(p.unclaimedCancels, jobID)
+		claimed.cancelRequestedUnclaimed = true
+	}
+	p.claimedJobs[jobID] = claimed
+}
+
+// releaseClaimedJob removes a job registered with registerClaimedJob
+// after it was processed or returned to the queue.
+func (p *Pool) releaseClaimedJob(jobID uu.ID) {
+	p.claimedJobsMtx.Lock()
+	delete(p.claimedJobs, jobID)
+	p.claimedJobsMtx.Unlock()
+}
+
+// contextWithJobCancel returns a child of ctx that is cancelled with
+// jobqueue.ErrJobCancelled as cause when the cancellation of the job
+// with jobID is requested via onJobCancelRequested.
+// The returned done function must be called when the job was processed.
+func (p *Pool) contextWithJobCancel(ctx context.Context, jobID uu.ID) (jobCtx context.Context, done func()) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+
+	p.claimedJobsMtx.Lock()
+	claimed := p.claimedJobs[jobID]
+	if claimed == nil {
+		// Not claimed by a worker thread
+		claimed = new(claimedJob)
+		p.claimedJobs[jobID] = claimed
+	}
+	claimed.cancel = cancel
+	p.claimedJobsMtx.Unlock()
+
+	return jobCtx, func() {
+		p.claimedJobsMtx.Lock()
+		claimed.cancel = nil
+		p.claimedJobsMtx.Unlock()
+
+		cancel(nil)
+	}
+}
+
+// isJobCancelRequested reports whether the cancellation of the claimed job
+// was requested before it is processed: before the claim,
+// for example while the job was waiting for a retry,
+// or after the claim while it was not processed yet.
+// A request whose notification arrived before the claim was registered
+// is checked in the DataBase because it may be from before a ResetJob.
+func (p *Pool) isJobCancelRequested(ctx context.Context, job *jobqueue.Job) bool {
+	if job.CancelRequestedAt.IsNotNull() {
+		return true
+	}
+
+	p.claimedJobsMtx.Lock()
+	var requested, unclaimed bool
+	if claimed := p.claimedJobs[job.ID]; claimed != nil {
+		requested, unclaimed = claimed.cancelRequested, claimed.cancelRequestedUnclaimed
+	}
+	p.claimedJobsMtx.Unlock()
+
+	if requested || !unclaimed {
+		return requested
+	}
+	loaded, err := p.db.GetJob(context.WithoutCancel(ctx), job.ID)
+	if err != nil {
+		p.onError(err)
+		log.ErrorCtx(ctx, "Error while checking the cancellation of a claimed job").
+			UUID("jobID", job.ID).
+			Err(err).
+			Log()
+		return false
+	}
+	return loaded.CancelRequestedAt.IsNotNull()
+}
+
+// onJobCancelRequested is the DataBase.SetJobCancelRequestedListener callback.
+// It cancels the context of the job with jobID if it is processed by the Pool,
+// or marks it as cancelled if it is claimed but not processed yet.
+func (p *Pool) onJobCancelRequested(jobID uu.ID) {
+	p.claimedJobsMtx.Lock()
+	var cancel context.CancelCauseFunc
+	if claimed := p.claimedJobs[jobID]; claimed != nil {
+		claimed.cancelRequested = true
+		cancel = claimed.cancel
+	} else {
+		// Not claimed by the Pool, or the claim was not registered yet
+		now := time.Now()
+		maps.DeleteFunc(p.unclaimedCancels, func(_ uu.ID, requestedAt time.Time) bool {
+			return now.Sub(requestedAt) > unclaimedCancelTTL
+		})
+		p.unclaimedCancels[jobID] = now
+	}
+	p.claimedJobsMtx.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	log.Info("Cancelling job").UUID("jobID", jobID).Log()
+	cancel(jobqueue.ErrJobCancelled)
+}
+
+// isJobCancelled reports whether jobCtx
+// was cancelled by onJobCancelRequested.
+func isJobCancelled(jobCtx context.Context) bool {
+	return errors.Is(context.Cause(jobCtx), jobqueue.ErrJobCancelled)
+}
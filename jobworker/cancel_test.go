@@ -0,0 +1,128 @@
+package jobworker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestCancelRunningJob(t *testing.T) {
+	const jobType = "jobworker-test-cancel-running"
+	pool, db := newTestPool(t)
+	causes := make(chan error, 1)
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil, ctx.Err()
+	})
+	startThreads(t, pool, 1)
+
+	job := newTestJob(t, jobType, 0, nullable.Time{})
+	job.MaxRetryCount = 3
+	require.NoError(t, db.AddJob(t.Context(), job))
+
+	waitForJob(t, db, job.ID, (*jobqueue.Job).Started)
+	require.NoError(t, db.CancelJob(t.Context(), job.ID))
+
+	select {
+	case cause := <-causes:
+		assert.ErrorIs(t, cause, jobqueue.ErrJobCancelled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("job context not cancelled")
+	}
+
+	loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Cancelled)
+	assert.False(t, loaded.HasError(), "cancelled job is not recorded as errored")
+	assert.Zero(t, loaded.CurrentRetryCount, "cancelled job is not retried")
+}
+
+// claimHookDataBase calls afterClaim with every job it claimed
+// before returning it to the Pool.
+type claimHookDataBase struct {
+	jobworker.DataBase
+	afterClaim func(*jobqueue.Job)
+}
+
+func (db *claimHookDataBase) StartNextJobOrNil(ctx context.Context, claim *jobworker.Claim, skipJobTypes ...string) (*jobqueue.Job, error) {
+	job, err := db.DataBase.StartNextJobOrNil(ctx, claim, skipJobTypes...)
+	if job != nil {
+		db.afterClaim(job)
+	}
+	return job, err
+}
+
+func (db *claimHookDataBase) StartNextJobsOrNil(ctx context.Context, claim *jobworker.Claim, n int, skipJobTypes ...string) ([]*jobqueue.Job, error) {
+	jobs, err := db.DataBase.StartNextJobsOrNil(ctx, claim, n, skipJobTypes...)
+	for _, job := range jobs {
+		db.afterClaim(job)
+	}
+	return jobs, err
+}
+
+// startedObserver calls onJobStarted from Observer.OnJobStarted.
+type startedObserver struct {
+	onJobStarted func(*jobqueue.Job)
+}
+
+func (startedObserver) OnClaim(context.Context, int, time.Duration, error) {}
+
+func (o startedObserver) OnJobStarted(ctx context.Context, job *jobqueue.Job) { o.onJobStarted(job) }
+
+func (startedObserver) OnJobStopped(context.Context, *jobqueue.Job, jobworker.JobOutcome, time.Duration) {
+}
+
+func (startedObserver) OnHeartbeatError(context.Context, *jobqueue.Job, error) {}
+
+func TestCancelClaimedJob(t *testing.T) {
+	const jobType = "jobworker-test-cancel-claimed"
+	for _, tc := range []struct {
+		name string
+		// cancel is called with the cancel function of the job
+		// to set the hook that cancels it after the claim
+		setup func(db *claimHookDataBase, pool *jobworker.Pool, cancel func(*jobqueue.Job))
+	}{
+		{
+			// The notification arrives before the claim returned
+			name: "before claim returned",
+			setup: func(db *claimHookDataBase, pool *jobworker.Pool, cancel func(*jobqueue.Job)) {
+				db.afterClaim = cancel
+			},
+		},
+		{
+			// The notification arrives before the job is run
+			name: "before run",
+			setup: func(db *claimHookDataBase, pool *jobworker.Pool, cancel func(*jobqueue.Job)) {
+				pool.AddObserver(startedObserver{onJobStarted: cancel})
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &claimHookDataBase{DataBase: newTestDataBase(t), afterClaim: func(*jobqueue.Job) {}}
+			pool := jobworker.NewPool(db)
+			var ran atomic.Bool
+			pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+				ran.Store(true)
+				return nil, nil
+			})
+			tc.setup(db, pool, func(job *jobqueue.Job) {
+				assert.NoError(t, db.CancelJob(context.Background(), job.ID))
+			})
+			startThreads(t, pool, 1)
+
+			job := newTestJob(t, jobType, 0, nullable.Time{})
+			require.NoError(t, db.AddJob(t.Context(), job))
+
+			loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Stopped)
+			assert.True(t, loaded.Cancelled(), "cancelled instead of succeeded")
+			assert.False(t, ran.Load(), "worker not called")
+		})
+	}
+}
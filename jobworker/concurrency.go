@@ -0,0 +1,168 @@
+package jobworker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// SetMaxConcurrency limits the number of jobs of jobType that the worker
+// threads of the default Pool run at the same time to n,
+// see Pool.SetMaxConcurrency.
+func SetMaxConcurrency(jobType string, n int) error {
+	return defaultPool.SetMaxConcurrency(jobType, n)
+}
+
+// SetMaxConcurrency limits the number of jobs of jobType that the worker
+// threads of the Pool run at the same time to n,
+// so that slow jobs of one type can't occupy every thread
+// and starve jobs of other types.
+// Every job of a batch of a RegisterBatch worker counts for the limit.
+// Zero removes the limit.
+//
+// SetMaxConcurrency must be called before StartThreads,
+// it returns an error if worker threads are running.
+//
+// See SetClusterMaxConcurrency for a limit across all worker processes.
+func (p *Pool) SetMaxConcurrency(jobType string, n int) error {
+	if n < 0 {
+		return fmt.Errorf("negative max concurrency %d for job type %#v", n, jobType)
+	}
+
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.SetMaxConcurrency(%#v) must be called before StartThreads, but %d worker thread(s) are running", jobType, p.numRunningThreads)
+	}
+
+	p.concurrencyMtx.Lock()
+	defer p.concurrencyMtx.Unlock()
+
+	if n == 0 {
+		delete(p.maxConcurrency, jobType)
+	} else {
+		p.maxConcurrency[jobType] = n
+	}
+	return nil
+}
+
+// SetClusterMaxConcurrency limits the number of started and not stopped
+// jobs of jobType across all worker processes for the default Pool,
+// see Pool.SetClusterMaxConcurrency.
+func SetClusterMaxConcurrency(jobType string, n int) error {
+	return defaultPool.SetClusterMaxConcurrency(jobType, n)
+}
+
+// SetClusterMaxConcurrency limits the number of started and not stopped
+// jobs of jobType across all worker processes sharing the database to n.
+// Zero removes the limit.
+//
+// The limit is enforced by the DataBase when it claims a job
+// by counting the running jobs of the type, see Claim.ClusterMaxConcurrency.
+// Every Pool that has a worker registered for jobType
+// should set the same limit, a Pool only enforces its own limits.
+// A job that was skipped because the limit was reached by another Pool
+// is claimed when the worker threads of this Pool check
+// for available jobs the next time, for example because of a new job
+// or StartPollingAvailableJobs.
+//
+// SetClusterMaxConcurrency must be called before StartThreads,
+// it returns an error if worker threads are running.
+func (p *Pool) SetClusterMaxConcurrency(jobType string, n int) error {
+	if n < 0 {
+		return fmt.Errorf("negative cluster max concurrency %d for job type %#v", n, jobType)
+	}
+
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.SetClusterMaxConcurrency(%#v) must be called before StartThreads, but %d worker thread(s) are running", jobType, p.numRunningThreads)
+	}
+
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	limits := maps.Clone(p.clusterMaxConcurrency)
+	if n == 0 {
+		delete(limits, jobType)
+	} else {
+		limits[jobType] = n
+	}
+	p.clusterMaxConcurrency = limits
+	// The limits are part of the Claim
+	p.invalidateWorkerTypesCacheLocked()
+	return nil
+}
+
+// startNextJob claims the next job skipping the job types
+// that reached their SetMaxConcurrency limit in the Pool,
+// and prefetches jobs, see SetPrefetch.
+//
+// The limits are not locked during the claim, so that the claims
+// of the worker threads don't wait for each other. A claimed job
+// of a type that reached its limit in the meantime is returned
+// to the queue with DataBase.UnclaimJobs and the next job is claimed.
+func (p *Pool) startNextJob(ctx context.Context) (*jobqueue.Job, error) {
+	claim := p.Claim()
+	for {
+		job, err := p.startNextPrefetchedJob(ctx, claim, p.saturatedJobTypes()...)
+		if job == nil || p.countRunningJob(job) {
+			return job, err
+		}
+		p.unclaimJobs(ctx, []*jobqueue.Job{job})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// saturatedJobTypes returns the job types that reached
+// their SetMaxConcurrency limit in the Pool.
+func (p *Pool) saturatedJobTypes() (jobTypes []string) {
+	p.concurrencyMtx.Lock()
+	defer p.concurrencyMtx.Unlock()
+
+	for jobType, n := range p.maxConcurrency {
+		if p.numRunningJobs[jobType] >= n {
+			jobTypes = append(jobTypes, jobType)
+		}
+	}
+	return jobTypes
+}
+
+// countRunningJob counts the claimed job as running for
+// the SetMaxConcurrency limit of its type and returns true,
+// or returns false if the type has already reached its limit.
+func (p *Pool) countRunningJob(job *jobqueue.Job) bool {
+	p.concurrencyMtx.Lock()
+	defer p.concurrencyMtx.Unlock()
+
+	n, limited := p.maxConcurrency[job.Type]
+	if !limited {
+		return true
+	}
+	if p.numRunningJobs[job.Type] >= n {
+		return false
+	}
+	p.numRunningJobs[job.Type]++
+	return true
+}
+
+// onJobDone counts a job started by startNextJob as not running anymore.
+func (p *Pool) onJobDone(job *jobqueue.Job) {
+	p.concurrencyMtx.Lock()
+	n, limited := p.maxConcurrency[job.Type]
+	wasFull := limited && p.numRunningJobs[job.Type] >= n
+	if p.numRunningJobs[job.Type] > 0 {
+		p.numRunningJobs[job.Type]--
+	}
+	p.concurrencyMtx.Unlock()
+
+	if wasFull {
+		// Other worker threads might have skipped
+		// available jobs of the type and wait for a signal
+		p.onCheckJob()
+	}
+}
@@ -0,0 +1,123 @@
+package jobworker_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	const (
+		slowType  = "jobworker-test-max-concurrency-slow"
+		quickType = "jobworker-test-max-concurrency-quick"
+	)
+	pool, db := newTestPool(t)
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	pool.Register(slowType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		<-release
+		return nil, nil
+	})
+	pool.Register(quickType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	require.NoError(t, pool.SetMaxConcurrency(slowType, 1))
+
+	startThreads(t, pool, 3)
+	assert.Error(t, pool.SetMaxConcurrency(slowType, 2), "threads running")
+
+	slow := []*jobqueue.Job{
+		newTestJob(t, slowType, 10, nullable.Time{}),
+		newTestJob(t, slowType, 10, nullable.Time{}),
+	}
+	for _, job := range slow {
+		require.NoError(t, db.AddJob(t.Context(), job))
+	}
+	quick := newTestJob(t, quickType, 0, nullable.Time{})
+	require.NoError(t, db.AddJob(t.Context(), quick))
+
+	// The quick job runs while the slow job blocks
+	// its thread and the second slow job waits
+	waitForJob(t, db, quick.ID, (*jobqueue.Job).Succeeded)
+	// A thread can claim the second slow job before it is returned
+	// to the queue, so count the running jobs instead of the started ones
+	assert.Equal(t, int32(1), running.Load(), "second slow job waits for the first")
+
+	close(release)
+	for _, job := range slow {
+		waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	}
+	assert.Equal(t, int32(1), peak.Load(), "max concurrency")
+}
+
+func TestMaxConcurrencyWithPrefetch(t *testing.T) {
+	const (
+		limitedType = "jobworker-test-max-concurrency-prefetch-limited"
+		otherType   = "jobworker-test-max-concurrency-prefetch-other"
+		numThreads  = 4
+		numJobs     = 8
+	)
+	pool, db := newTestPool(t)
+
+	var (
+		mtx        sync.Mutex
+		running    int
+		maxRunning int
+		done       = make(map[uu.ID]int)
+	)
+	pool.Register(limitedType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		mtx.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mtx.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mtx.Lock()
+		running--
+		done[job.ID]++
+		mtx.Unlock()
+		return nil, nil
+	})
+	pool.Register(otherType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		mtx.Lock()
+		done[job.ID]++
+		mtx.Unlock()
+		return nil, nil
+	})
+	require.NoError(t, pool.SetMaxConcurrency(limitedType, 1))
+	require.NoError(t, pool.SetPrefetch(numThreads))
+	startThreads(t, pool, numThreads)
+
+	// Let all threads wait for a job, so that the first claim
+	// after AddJobs prefetches several jobs of the limited type
+	time.Sleep(50 * time.Millisecond)
+	var jobs []*jobqueue.Job
+	for _, jobType := range []string{limitedType, otherType} {
+		for range numJobs {
+			jobs = append(jobs, newTestJob(t, jobType, 0, nullable.Time{}))
+		}
+	}
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+
+	for _, job := range jobs {
+		waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, 1, maxRunning, "jobs of the limited type ran one at a time")
+	for _, job := range jobs {
+		assert.Equal(t, 1, done[job.ID], "job %s done exactly once", job.ID)
+	}
+}
@@ -2,6 +2,8 @@ package jobworker
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"reflect"
 	"time"
 
@@ -12,59 +14,58 @@ import (
 var (
 	log = rootlog.NewPackageLogger()
 
-	// OnError will be called for every error that
-	// would also be logged.
+	// OnError will be called for every error of the default Pool
+	// that would also be logged. See WithOnError for other pools.
 	OnError = func(error) {}
 
-	// JobTimeout is the timeout applied to the context passed to job worker functions.
-	// Default is 15 minutes. Set to 0 to disable the timeout.
+	// JobTimeout is the timeout applied to the context passed to job worker
+	// functions of the default Pool. Default is 15 minutes.
+	// Set to 0 to disable the timeout. See WithJobTimeout for other pools.
 	JobTimeout = 15 * time.Minute
 
-	// HeartbeatInterval is how often a worker updates the worker_alive_at
-	// timestamp of the job it is processing, so that observers can tell whether
-	// the worker is still alive or has crashed. Default is 10 seconds.
-	// Set to 0 to disable heartbeats.
+	// HeartbeatInterval is how often a worker of the default Pool updates the
+	// worker_alive_at timestamp of the job it is processing, so that observers
+	// can tell whether the worker is still alive or has crashed.
+	// Default is 10 seconds. Set to 0 to disable heartbeats.
+	// See WithHeartbeatInterval for other pools.
 	//
 	// HeartbeatInterval must be configured during startup, before StartThreads, and
 	// treated as immutable afterwards. Whether a claim stamps worker_alive_at is
-	// baked into the cached jobworkerdb claim statement the first time a job is
-	// claimed (it reads HeartbeatInterval > 0 at that point), and the cache is not
-	// re-prepared when this value changes. Toggling it across the 0 boundary at
-	// runtime would make the claim path and the heartbeat/reaper logic disagree.
+	// part of the Claim passed to the DataBase (see Claim.Heartbeat), which is
+	// only rebuilt when the next claim is made. Toggling it across the 0 boundary
+	// while threads are running would make the claim path and the
+	// heartbeat/reaper logic disagree.
 	HeartbeatInterval = 10 * time.Second
 
-	// Set by SetDataBase
-	db DataBase
+	// WorkerName identifies this process in the jobs it claimed
+	// and in their job attempts recorded by the DataBase,
+	// see jobqueue.Job.Worker and jobqueue.JobAttempt.Worker.
+	// Default is "<hostname>:<pid>".
+	//
+	// Like HeartbeatInterval it must be configured before StartThreads,
+	// it is passed to the DataBase as Claim.Worker.
+	WorkerName = defaultWorkerName()
 
 	typeOfError   = reflect.TypeFor[error]()
 	typeOfContext = reflect.TypeFor[context.Context]()
-
-	// workerTypes caches the sorted set of registered job types derived from the
-	// workers map and is guarded by workersMtx (declared in workerthreads.go). It is
-	// invalidated by setting it back to nil whenever workers changes (Register/
-	// Unregister, normally only at startup) and lazily rebuilt on the next
-	// RegisteredJobTypes read. A nil workerTypes means the cache is invalid and must
-	// be rebuilt; an empty-but-non-nil workerTypes means "rebuilt, no job types
-	// registered".
-	workerTypes []string
-
-	// workerTypesGeneration is incremented (under workersMtx, in Register/Unregister)
-	// every time the set of registered job types changes. RegisteredJobTypes returns
-	// it alongside the types so a consumer can cache values derived from the types
-	// and rebuild only when the generation changes — a single locked read yields a
-	// consistent (types, generation) pair, so there is no torn read and no need to
-	// compare the type sets element by element.
-	workerTypesGeneration uint64
 )
 
+func defaultWorkerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
 // OverrideLogger replaces the logger used by the jobworker package.
 func OverrideLogger(logger *golog.Logger) {
 	log = logger
 }
 
-// SetDataBase sets the DataBase implementation used by the jobworker package.
+// SetDataBase sets the DataBase implementation used by the default Pool.
 // It must be called during initialization before starting worker threads;
 // jobworkerdb.InitJobQueue does this automatically.
 func SetDataBase(newDB DataBase) {
-	db = newDB
+	defaultPool.db = newDB
 }
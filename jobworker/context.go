@@ -0,0 +1,78 @@
+package jobworker
+
+import (
+	"context"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+type jobCtxKey struct{}
+
+// ContextWithJob returns a context with job that is returned by JobFromContext.
+// DoJob passes such a context to the worker, so ContextWithJob
+// is only needed to call a worker function directly, for example in tests.
+func ContextWithJob(ctx context.Context, job *jobqueue.Job) context.Context {
+	return context.WithValue(ctx, jobCtxKey{}, job)
+}
+
+// JobFromContext returns the job that a worker called by DoJob is processing,
+// or nil if ctx is not the context of a job.
+//
+// It gives workers that only receive the payload, like the ones registered
+// with RegisterFunc or RegisterTyped, access to the job's ID, Origin, BundleID,
+// and retry counts, for example for idempotency keys or logging.
+// The returned job must not be modified.
+func JobFromContext(ctx context.Context) *jobqueue.Job {
+	job, _ := ctx.Value(jobCtxKey{}).(*jobqueue.Job)
+	return job
+}
+
+type poolCtxKey struct{}
+
+// ContextWithPool returns a context with pool that is returned by GetPool.
+// DoJob of a Pool passes such a context to the worker.
+func ContextWithPool(ctx context.Context, pool *Pool) context.Context {
+	return context.WithValue(ctx, poolCtxKey{}, pool)
+}
+
+// PoolFromContextOrNil returns the Pool added with ContextWithPool or nil.
+func PoolFromContextOrNil(ctx context.Context) *Pool {
+	pool, _ := ctx.Value(poolCtxKey{}).(*Pool)
+	return pool
+}
+
+// GetPool returns the Pool added to ctx with ContextWithPool
+// or the DefaultPool.
+//
+// It's used to run synchronous jobs with the workers of the Pool,
+// see jobworkerdb.ContextWithSynchronousJobs.
+func GetPool(ctx context.Context) *Pool {
+	if pool := PoolFromContextOrNil(ctx); pool != nil {
+		return pool
+	}
+	return defaultPool
+}
+
+type jobErrorCtxKey struct{}
+
+func contextWithJobError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, jobErrorCtxKey{}, err)
+}
+
+// JobErrorFromContext returns the error returned by the worker
+// for the context passed to a ScheduleRetryFunc, or nil otherwise.
+// Unlike Job.ErrorMsg it is the original error, so a ScheduleRetryFunc
+// can inspect it with errors.Is or errors.As, see HonorRetryAfter.
+func JobErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(jobErrorCtxKey{}).(error)
+	return err
+}
+
+// IsLastAttempt reports whether the job of ctx will not be retried
+// if the worker returns an error because its CurrentRetryCount
+// reached its MaxRetryCount.
+// It returns false if ctx is not the context of a job, see JobFromContext.
+func IsLastAttempt(ctx context.Context) bool {
+	job := JobFromContext(ctx)
+	return job != nil && job.CurrentRetryCount >= job.MaxRetryCount
+}
@@ -0,0 +1,59 @@
+package jobworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestJobFromContext(t *testing.T) {
+	assert.Nil(t, JobFromContext(t.Context()))
+	assert.False(t, IsLastAttempt(t.Context()))
+
+	type attempt struct {
+		job  *jobqueue.Job
+		last bool
+	}
+	attempts := make(chan attempt, 1)
+	p := newTestPool()
+	p.RegisterFuncForJobType("a", func(ctx context.Context, payload struct{}) error {
+		attempts <- attempt{JobFromContext(ctx), IsLastAttempt(ctx)}
+		return nil
+	})
+
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), "a", "test", `{}`, nullable.Time{}, 2)
+	require.NoError(t, err)
+
+	require.NoError(t, p.DoJob(t.Context(), job))
+	got := <-attempts
+	assert.Same(t, job, got.job)
+	assert.False(t, got.last, "first of 3 attempts")
+
+	job.CurrentRetryCount = 2
+	require.NoError(t, p.DoJob(t.Context(), job))
+	assert.True(t, (<-attempts).last, "retry count reached max")
+}
+
+func TestGetPool(t *testing.T) {
+	assert.Nil(t, PoolFromContextOrNil(t.Context()))
+	assert.Same(t, defaultPool, GetPool(t.Context()))
+
+	p := newTestPool()
+	assert.Same(t, p, GetPool(ContextWithPool(t.Context(), p)))
+
+	pools := make(chan *Pool, 1)
+	p.RegisterFuncForJobType("a", func(ctx context.Context, payload struct{}) error {
+		pools <- GetPool(ctx)
+		return nil
+	})
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), "a", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, p.DoJob(t.Context(), job))
+	assert.Same(t, p, <-pools, "DoJob adds its Pool to the context of the worker")
+}
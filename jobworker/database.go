@@ -14,6 +14,8 @@ import (
 // DataBase is the persistence backend the jobworker package needs in addition
 // to the jobqueue.Service interface. The jobworkerdb package provides the
 // PostgreSQL implementation and registers it with SetDataBase.
+//
+// A DataBase is used by a single Pool, see NewPool.
 type DataBase interface {
 	jobqueue.Service
 
@@ -21,19 +23,65 @@ type DataBase interface {
 	// job becomes available, or clears the callback when passed nil.
 	SetJobAvailableListener(context.Context, func()) error
 
-	// StartNextJobOrNil claims and starts the next available job, returning nil
-	// if no job is currently available.
-	StartNextJobOrNil(ctx context.Context) (*jobqueue.Job, error)
+	// SetJobCancelRequestedListener registers a callback that is invoked with
+	// the ID of a started job whenever CancelJob requests its cancellation,
+	// or clears the callback when passed nil.
+	SetJobCancelRequestedListener(context.Context, func(jobID uu.ID)) error
+
+	// StartNextJobOrNil claims and starts the next available job
+	// of the claim.JobTypes except skipJobTypes,
+	// returning nil if no job is currently available.
+	// Job types with a claim.ClusterMaxConcurrency limit are skipped
+	// while the limit of started and not stopped jobs is reached,
+	// and job types with a claim.RateLimits limit while the limit
+	// of started jobs in the current window is reached.
+	StartNextJobOrNil(ctx context.Context, claim *Claim, skipJobTypes ...string) (*jobqueue.Job, error)
+
+	// StartNextJobsOrNil claims and starts up to n of the next available jobs
+	// like StartNextJobOrNil, returning them in the order
+	// StartNextJobOrNil would have claimed them one after the other,
+	// or nil if no job is currently available.
+	// While the job types have claim.ClusterMaxConcurrency
+	// or claim.RateLimits limits, fewer than n jobs can be returned
+	// even if more are available, but never more than the limits allow.
+	StartNextJobsOrNil(ctx context.Context, claim *Claim, n int, skipJobTypes ...string) ([]*jobqueue.Job, error)
+
+	// UnclaimJobs returns jobs that were claimed with StartNextJobsOrNil
+	// but not processed to the queue, so that they can be claimed again.
+	// The start and worker_alive_at timestamps of the jobs are cleared,
+	// everything else is kept, and no attempt is recorded.
+	// Passed jobs that are not started or already stopped are ignored.
+	UnclaimJobs(ctx context.Context, jobIDs uu.IDs) error
+
+	// GetNextJobStartDelay returns how long it takes until the earliest
+	// start_at in the future of a not started job of the claim.JobTypes
+	// is reached, or the current window of a job type that reached
+	// its claim.RateLimits limit ends while it has an available job,
+	// measured with the clock of the DataBase.
+	// ok is false if there is no such job.
+	GetNextJobStartDelay(ctx context.Context, claim *Claim) (delay time.Duration, ok bool, err error)
 
 	// SetJobError stops the job with a terminal error described by errorMsg and
 	// optional errorData, marking it as not to be retried.
 	SetJobError(ctx context.Context, jobID uu.ID, errorMsg string, errorData nullable.JSON) error
 
+	// SetJobCancelled stops a job that has not stopped yet as cancelled,
+	// counting it in its bundle. It is called after the worker function
+	// of a job returned because of a CancelJob request.
+	SetJobCancelled(ctx context.Context, jobID uu.ID) error
+
 	// SetJobResult stops the job successfully and stores its result.
+	// A cancellation requested while the job was running is cleared,
+	// the job is not cancelled, see jobqueue.Job.Cancelled.
 	SetJobResult(ctx context.Context, jobID uu.ID, result nullable.JSON) error
 
 	// SetJobStart reschedules the job to start no earlier than startAt, clearing
 	// any previous start, stop, and error state.
+	// Like ResetJob it uncounts a stopped job in its bundle and ends
+	// the attempt of a running job as jobqueue.JobAttemptReset,
+	// but it keeps the retry count.
+	// The worker of a running job is not stopped,
+	// so the job can run twice at the same time.
 	SetJobStart(ctx context.Context, jobID uu.ID, startAt time.Time) error
 
 	// SetJobWorkerAlive updates the worker_alive_at heartbeat timestamp of a job
@@ -42,18 +90,50 @@ type DataBase interface {
 
 	// ScheduleRetry reschedules the job to run again at startAt with the given
 	// retryCount, clearing any previous start, stop, and error state.
-	ScheduleRetry(ctx context.Context, jobID uu.ID, startAt time.Time, retryCount int) error
+	// The ended attempt of the running job is recorded with errorMsg and errorData
+	// as jobqueue.JobAttemptRetried.
+	ScheduleRetry(ctx context.Context, jobID uu.ID, startAt time.Time, retryCount int, errorMsg string, errorData nullable.JSON) error
+
+	// SnoozeJob reschedules the job to run again at startAt like ScheduleRetry
+	// but keeps its current retry count. The ended attempt of the running job
+	// is recorded as jobqueue.JobAttemptSnoozed.
+	SnoozeJob(ctx context.Context, jobID uu.ID, startAt time.Time) error
+
+	// SaveJobSchedule inserts or updates the CronExpr, Timezone, and CatchUp
+	// of the schedule with the Name of the passed schedule and returns
+	// the stored schedule including its LastFireTime.
+	// If the CronExpr or Timezone changed, the not started occurrence
+	// that was added in advance is deleted and LastFireTime
+	// is limited to the current time.
+	SaveJobSchedule(ctx context.Context, schedule *jobqueue.JobSchedule) (*jobqueue.JobSchedule, error)
+
+	// AddScheduledJobs adds jobs as occurrences of the schedule with scheduleName.
+	// Every job must have ScheduleName and ScheduleFireTime set.
+	// Jobs with a ScheduleFireTime not after the LastFireTime of the schedule
+	// or with an already existing ScheduleFireTime are not added,
+	// so that several worker processes can add the same occurrences.
+	// The LastFireTime of the schedule is advanced to the latest added
+	// fire time and the stored schedule is returned.
+	AddScheduledJobs(ctx context.Context, scheduleName string, jobs []*jobqueue.Job) (*jobqueue.JobSchedule, error)
+
+	// DeleteJobSchedule deletes the schedule with scheduleName
+	// and its occurrences that were not started yet.
+	DeleteJobSchedule(ctx context.Context, scheduleName string) error
 
 	// DeleteJobsFromOrigin deletes all jobs created from the given origin.
+	// Dependent jobs are cancelled like for jobqueue.Service.DeleteJob.
 	DeleteJobsFromOrigin(ctx context.Context, origin string) error
 
 	// DeleteJobsOfType deletes all jobs of the given type.
+	// Dependent jobs are cancelled like for jobqueue.Service.DeleteJob.
 	DeleteJobsOfType(ctx context.Context, jobType string) error
 
 	// DeleteJobBundlesFromOrigin deletes all job bundles created from the given origin.
+	// Dependent jobs are cancelled like for jobqueue.Service.DeleteJob.
 	DeleteJobBundlesFromOrigin(ctx context.Context, origin string) error
 
 	// DeleteJobBundlesOfType deletes all job bundles of the given type.
+	// Dependent jobs are cancelled like for jobqueue.Service.DeleteJob.
 	DeleteJobBundlesOfType(ctx context.Context, bundleType string) error
 
 	// DeleteAllJobsAndBundles deletes all jobs and job bundles from the queue.
@@ -63,6 +143,7 @@ type DataBase interface {
 // DeleteAllJobsAndBundles deletes all jobs and job bundles from the queue using
 // the DataBase set with SetDataBase. It returns an error if no DataBase has been set.
 func DeleteAllJobsAndBundles(ctx context.Context) error {
+	db := defaultPool.db
 	if db == nil {
 		return errs.New("no DataBase defined")
 	}
@@ -12,7 +12,7 @@ The jobworker package handles the execution side of the job queue. It manages:
 
 # Worker Registration
 
-Workers can be registered in three ways:
+Workers can be registered in four ways:
 
 1. Automatic reflection-based registration:
 
@@ -33,6 +33,18 @@ Workers can be registered in three ways:
 		return result, nil
 	})
 
+4. Typed registration checked by the compiler, using a jobqueue.JobKind
+shared with the code adding the jobs:
+
+	var SendEmail = jobqueue.NewJobKind[*EmailPayload]("send-email")
+
+	jobworker.RegisterJobKind(SendEmail, func(ctx context.Context, payload *EmailPayload) (any, error) {
+		return nil, send(ctx, payload)
+	})
+
+RegisterTyped does the same for a job type string
+and TypedWorker returns the WorkerFunc for Pool.Register.
+
 # Worker Functions
 
 Worker functions can have various signatures:
@@ -48,6 +60,71 @@ Worker functions can have various signatures:
 
 The payload type is automatically unmarshalled from the job's JSON payload.
 
+# Batch Workers
+
+Job types that are cheaper to process in bulk can register a BatchWorkerFunc
+that is called with up to maxBatch jobs of the type at once.
+A worker thread waits up to maxWait for more jobs if fewer are available:
+
+	jobworker.RegisterBatch("index-document", 100, time.Second,
+		func(ctx context.Context, jobs []*jobqueue.Job) []error {
+			return searchIndex.AddDocuments(ctx, documentsOf(jobs))
+		},
+	)
+
+The returned errors are the outcomes of the jobs at the same index,
+recorded like the error of a WorkerFunc. A nil slice means all jobs succeeded.
+
+# Job Context
+
+Workers that only receive the payload can access the job they are processing
+from the context, for example for idempotency keys or to log the attempt:
+
+	func sendEmail(ctx context.Context, payload *EmailPayload) error {
+		job := jobworker.JobFromContext(ctx)
+		if jobworker.IsLastAttempt(ctx) {
+			// Notify someone before the job finally fails
+		}
+		return mailer.Send(ctx, payload, mailer.IdempotencyKey(job.ID.String()))
+	}
+
+# Middleware
+
+Cross-cutting concerns like tracing, metrics, or context setup
+can wrap the workers of all job types or of a single job type:
+
+	jobworker.Use(func(next jobworker.WorkerFunc) jobworker.WorkerFunc {
+		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+			ctx = tenant.ContextWithID(ctx, job.Origin)
+			return next(ctx, job)
+		}
+	})
+	jobworker.UseForJobType("send-email", decryptPayload)
+
+DoJob applies the middleware of Use around the middleware of UseForJobType
+around the registered worker, each in the order it was added.
+
+# Observers
+
+An Observer added with AddObserver is notified about the claims, the started
+and stopped jobs with their JobOutcome, and the heartbeat errors of the worker
+threads, for example to export metrics. Unlike Middleware it also sees
+the outcome that was saved in the DataBase, like a scheduled retry.
+The jobmetrics package implements an Observer
+serving Prometheus metrics.
+
+# Tracing
+
+DoJob adds the jobqueue.TraceContext of the job, captured when it was added,
+to the context of the worker. A Tracer set with SetTracer runs the worker
+in a span linked to it, so the trace of the code that added a job
+continues in the worker:
+
+	jobworker.SetTracer(myOpenTelemetryTracer)
+
+A batch of a RegisterBatch worker runs in one span
+linked to the trace contexts of all its jobs.
+
 # Thread Pool
 
 Start a worker thread pool to process jobs concurrently:
@@ -58,6 +135,52 @@ Start a worker thread pool to process jobs concurrently:
 	}
 	defer jobworker.FinishThreads(ctx) // Wait for jobs to complete
 
+Idle threads wait for a job_available notification. Because no notification
+is sent for jobs with a start_at in the future, like retries, the threads
+ask the DataBase for the earliest such start_at whenever they found no job
+and set a timer to wake up when it is reached.
+A job with an earlier start_at added while all threads are idle
+is picked up when they wake up the next time.
+
+# Prefetching
+
+By default every worker thread claims one job per database round trip.
+Under a large backlog of short jobs SetPrefetch lets a thread claim
+several jobs with DataBase.StartNextJobsOrNil and hand them
+to the other idle threads:
+
+	err := jobworker.SetPrefetch(8)
+
+A thread claims at most as many jobs as threads are waiting for one,
+so prefetched jobs start right away and their worker_alive_at
+doesn't become stale. Prefetched jobs that were not started
+when the threads stop are returned with DataBase.UnclaimJobs.
+
+# Concurrency Limits
+
+Limit the number of running jobs of a type in this process,
+so that slow jobs can't occupy every worker thread:
+
+	err := jobworker.SetMaxConcurrency("generate-pdf", 2)
+
+Limit the started and not stopped jobs of a type
+across all worker processes sharing the database:
+
+	err := jobworker.SetClusterMaxConcurrency("generate-pdf", 5)
+
+The cluster-wide limit is enforced by the DataBase when it claims a job,
+see ClusterMaxConcurrency. Both must be called before StartThreads.
+
+# Rate Limits
+
+Limit the started jobs of a type per time window
+across all worker processes sharing the database:
+
+	err := jobworker.SetRateLimit("call-api", 100, time.Minute)
+
+Jobs of a type that reached its limit stay unclaimed
+and idle worker threads wake up when the window ends.
+
 # Retry Scheduling
 
 Register retry schedulers to control retry timing:
@@ -68,6 +191,29 @@ Register retry schedulers to control retry timing:
 		return time.Now().Add(delay), nil
 	})
 
+ConstantBackoff, LinearBackoff, and ExponentialBackoff with optional Jitter
+return common retry schedulers. HonorRetryAfter uses the duration of a
+RetryAfterer error returned by the worker, see WithRetryAfter.
+Without a registered retry scheduler a failed job is not retried
+unless a default one is set:
+
+	jobworker.SetDefaultScheduleRetry(
+		jobworker.HonorRetryAfter(
+			jobworker.ExponentialBackoff(time.Second, time.Hour, jobworker.FullJitter),
+		),
+	)
+
+A worker controls the retry of a job with the error it returns:
+
+	// Fail without retrying, the payload will never be valid
+	return jobworker.Permanent(err)
+
+	// Start again in 5 minutes without counting an attempt
+	return jobworker.Snooze(5 * time.Minute)
+
+	// Retry at a known time instead of the one of the retry scheduler
+	return jobworker.RetryAt(quotaReset, err)
+
 # Job Execution
 
 Jobs are executed by calling DoJob, which:
@@ -89,18 +235,73 @@ This lets abandoned jobs be reclaimed safely even when multiple worker
 processes share one database; see
 jobworkerdb.InitJobQueueResetInterruptedJobs.
 
+# Job Cancellation
+
+jobqueue.CancelJob stops a job that was not started yet right away.
+For a running job the DataBase notifies every process running worker threads,
+and the process running the job cancels the context passed to the worker
+function with jobqueue.ErrJobCancelled as cause:
+
+	func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		...
+		if errors.Is(context.Cause(ctx), jobqueue.ErrJobCancelled) {
+			// Clean up
+		}
+		...
+	}
+
+After the worker function returned, the job is stopped as cancelled
+(see jobqueue.Job.Cancelled) instead of being recorded as errored,
+retried, or reset.
+
+# Recurring Jobs
+
+Register schedules before starting the worker threads:
+
+	err := jobworker.RegisterSchedule(
+		"nightly-cleanup",
+		"0 3 * * *",
+		"Europe/Vienna",
+		jobqueue.JobDesc{Type: "cleanup", Payload: CleanupPayload{Days: 30}},
+		jobqueue.ScheduleCatchUpSkip,
+	)
+
+StartThreads starts a scheduler goroutine that stores the registered schedules
+in the database and adds their next occurrence to the queue in advance.
+FinishThreads and StopThreads stop it. Errors of the scheduler are passed to
+OnError and retried after ScheduleErrorRetryInterval.
+
 # Polling
 
-For environments where PostgreSQL LISTEN/NOTIFY isn't reliable, use polling:
+For environments where PostgreSQL LISTEN/NOTIFY isn't reliable, use polling.
+Polling also picks up delayed jobs that were added while all threads were idle
+in time, see Thread Pool:
 
 	err := jobworker.StartPollingAvailableJobs(5 * time.Second)
 
+# Pools
+
+The package level functions use the Pool returned by DefaultPool,
+configured by the package variables OnError, JobTimeout, and HeartbeatInterval.
+NewPool creates an independent Pool with its own registered workers,
+retry schedulers, schedules, limits, threads, and configuration:
+
+	pool := jobworker.NewPool(memqueue.NewDataBase(), jobworker.WithJobTimeout(time.Minute))
+	pool.Register("send-email", sendEmailWorker)
+	err := pool.StartThreads(ctx, 4)
+
+Every Pool needs its own DataBase instance.
+Synchronous jobs run with the Pool added to their context
+with ContextWithPool or the default Pool, see GetPool.
+
 # Database Interface
 
-The worker package requires a DataBase implementation to be set:
+The default Pool requires a DataBase implementation to be set:
 
 	jobworker.SetDataBase(service)
 
 This interface handles job persistence and queue operations.
+A Pool passes the job types to claim and their limits
+to the DataBase as an immutable Claim.
 */
 package jobworker
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/domonda/go-errs"
 	"github.com/domonda/go-types/nullable"
@@ -12,6 +13,12 @@ import (
 	"github.com/domonda/go-jobqueue"
 )
 
+// DoJob runs the worker registered with the default Pool for job.Type
+// synchronously in the calling goroutine, see Pool.DoJob.
+func DoJob(ctx context.Context, job *jobqueue.Job) error {
+	return defaultPool.DoJob(ctx, job)
+}
+
 // DoJob runs the worker registered for job.Type synchronously in the calling
 // goroutine and sets job.Result on success, or job.ErrorMsg and job.ErrorData
 // on failure, in addition to returning any error.
@@ -22,21 +29,36 @@ import (
 // or in tests. Worker threads use doJobAndSaveResultInDB instead, which calls
 // DoJob and then persists the outcome and handles retries.
 //
+// The worker is wrapped with the Middleware added with Use and UseForJobType.
+//
+// A Snooze error returned by the worker is returned without
+// setting job.ErrorMsg or calling OnError.
+//
 // A panic in the job worker function is recovered and returned as an error,
 // so DoJob always returns normally and never panics out to its caller.
 //
 // The job.ID is added to the context that's passed to the
-// job worker function as golog attribute with the key "jobID".
+// job worker function as golog attribute with the key "jobID",
+// the job itself is returned by JobFromContext for that context,
+// and the Pool by GetPool, so that synchronous jobs added by the worker
+// run with the same Pool.
+//
+// The job.TraceContext is added to the context with jobqueue.ContextWithTraceContext
+// and the worker runs in a span of the Tracer of the Pool if one is set, see SetTracer.
 //
 // If the passed context already has a deadline, it will be respected.
-// Otherwise, JobTimeout is applied if configured.
+// Otherwise, the job timeout of the Pool is applied if configured.
 //
 // StartedAt, StoppedAt, and UpdatedAt are not modified.
-func DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
+func (p *Pool) DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
+	var endSpan func(error)
 	defer func() {
 		if p := recover(); p != nil {
 			err = errors.Join(err, errs.Errorf("job worker panic: %w", errs.AsErrorWithDebugStack(p)))
 		}
+		if endSpan != nil {
+			endSpan(err)
+		}
 		errs.WrapWithFuncParams(&err, job)
 	}()
 
@@ -44,49 +66,33 @@ func DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
 		return errs.New("can't do nil job")
 	}
 
-	workersMtx.RLock()
-	worker, hasWorker := workers[job.Type]
-	workersMtx.RUnlock()
-
-	if !hasWorker {
+	worker := p.workerWithMiddlewareOrNil(job.Type)
+	if worker == nil {
 		return errs.Errorf("no worker for job of type '%s'", job.Type)
 	}
 
 	jobCtx := golog.ContextWithAttribs(ctx, golog.NewUUID("jobID", job.ID))
+	jobCtx = ContextWithJob(jobCtx, job)
+	jobCtx = ContextWithPool(jobCtx, p)
+	jobCtx, endSpan = p.startJobSpan(jobCtx, job)
 
 	// Apply timeout if configured and context doesn't already have a deadline
-	if JobTimeout > 0 {
+	if timeout := *p.jobTimeout; timeout > 0 {
 		if _, hasDeadline := jobCtx.Deadline(); !hasDeadline {
 			var cancel context.CancelFunc
-			jobCtx, cancel = context.WithTimeout(jobCtx, JobTimeout)
+			jobCtx, cancel = context.WithTimeout(jobCtx, timeout)
 			defer cancel()
 		}
 	}
 
 	result, jobErr := worker(jobCtx, job)
+	if _, snoozed := snoozeDuration(jobErr); snoozed {
+		// Not a failure, so don't call OnError or set job.ErrorMsg
+		log.InfoCtx(jobCtx, "Job snoozed").Err(jobErr).Log()
+		return jobErr
+	}
 	if jobErr != nil {
-		errorTitle := errs.Root(jobErr).Error()
-		if nl := strings.IndexByte(errorTitle, '\n'); nl > 0 {
-			// Only use first line of error message as errorTitle
-			errorTitle = errorTitle[:nl]
-		}
-		errorTitle = strings.TrimSpace(errorTitle)
-
-		OnError(jobErr)
-
-		if job.CurrentRetryCount >= job.MaxRetryCount {
-			log.ErrorfCtx(jobCtx, "Job error: %s", errorTitle).
-				Any("job", job).
-				Err(jobErr).
-				Log()
-		} else {
-			log.WarnfCtx(jobCtx, "Job error: %s", errorTitle).
-				Any("job", job).
-				Err(jobErr).
-				Log()
-		}
-
-		job.ErrorMsg.Set(jobErr.Error())
+		p.onJobError(jobCtx, job, jobErr)
 		job.ErrorData, err = nullable.MarshalJSON(result)
 		return errors.Join(jobErr, err)
 	}
@@ -95,6 +101,33 @@ func DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
 	return err
 }
 
+// onJobError logs jobErr returned by the worker of job,
+// calls OnError, and sets job.ErrorMsg.
+func (p *Pool) onJobError(ctx context.Context, job *jobqueue.Job, jobErr error) {
+	errorTitle := errs.Root(jobErr).Error()
+	if nl := strings.IndexByte(errorTitle, '\n'); nl > 0 {
+		// Only use first line of error message as errorTitle
+		errorTitle = errorTitle[:nl]
+	}
+	errorTitle = strings.TrimSpace(errorTitle)
+
+	p.onError(jobErr)
+
+	if job.CurrentRetryCount >= job.MaxRetryCount || IsPermanent(jobErr) {
+		log.ErrorfCtx(ctx, "Job error: %s", errorTitle).
+			Any("job", job).
+			Err(jobErr).
+			Log()
+	} else {
+		log.WarnfCtx(ctx, "Job error: %s", errorTitle).
+			Any("job", job).
+			Err(jobErr).
+			Log()
+	}
+
+	job.ErrorMsg.Set(jobErr.Error())
+}
+
 // doJobAndSaveResultInDB runs a previously claimed job with DoJob and persists
 // the outcome in the database. It is the entry point used by the worker threads
 // (see worker in workerthreads.go), as opposed to the database-free DoJob.
@@ -103,12 +136,21 @@ func DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
 // this worker owns the job — both while DoJob runs and while the outcome is
 // finalized — so a crashed worker can be detected.
 //
+// The job is run with a context that is cancelled with jobqueue.ErrJobCancelled
+// as cause when jobqueue.CancelJob is called for it (see onJobCancelRequested).
+// A job whose cancellation was requested before it is run is not run at all.
+//
 // Depending on the outcome it:
 //   - stores the result via SetJobResult on success;
+//   - stops the job via SetJobCancelled if it was cancelled with CancelJob;
 //   - resets the job via ResetJob if the context was cancelled (e.g. shutdown),
 //     so it is retried without consuming a retry attempt;
-//   - schedules a retry via ScheduleRetry if retries remain; or
-//   - stores the error via SetJobError once all retries are exhausted.
+//   - reschedules the job via SnoozeJob without counting an attempt
+//     if the worker returned a Snooze error;
+//   - schedules a retry via ScheduleRetry if retries remain,
+//     at the time of a RetryAt error or else of the retry scheduler; or
+//   - stores the error via SetJobError once all retries are exhausted
+//     or if the error was wrapped with Permanent.
 //
 // The retry scheduler runs while the heartbeat is still alive, and the job is
 // marked stopped only by a single terminal write, so a slow scheduler cannot
@@ -118,7 +160,10 @@ func DoJob(ctx context.Context, job *jobqueue.Job) (err error) {
 // The database writes use context.WithoutCancel so that a cancelled context
 // (e.g. during shutdown) does not prevent the job's final state from being
 // persisted.
-func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error) {
+//
+// The Observers of the Pool are notified before the job is run
+// and after its outcome was persisted.
+func (p *Pool) doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error) {
 	defer errs.WrapWithFuncParams(&err, job)
 	defer errs.RecoverPanicAsError(&err)
 
@@ -139,16 +184,59 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 	// worker_alive_at update races the transition. It is idempotent and also
 	// deferred as a leak-safety net in case a future change adds a path that
 	// returns without the explicit call.
-	stopHeartbeat := startJobHeartbeat(ctx, job.ID)
+	stopHeartbeat := p.startJobHeartbeat(ctx, job)
 	defer stopHeartbeat()
+	defer p.releaseClaimedJob(job.ID)
+
+	p.notifyJobStarted(ctx, job)
+	var (
+		// A panic is recovered as error
+		outcome  = JobFailed
+		duration time.Duration
+	)
+	defer func() { p.notifyJobStopped(ctx, job, outcome, duration) }()
 
-	jobErr := DoJob(ctx, job)
+	// Registered before checking for an earlier cancellation,
+	// so that no cancellation request falls in between
+	jobCtx, jobDone := p.contextWithJobCancel(ctx, job.ID)
+	defer jobDone()
+
+	// The cancellation was requested before the job is run,
+	// for example while it was waiting for a retry
+	// or right after it was claimed.
+	if isJobCancelled(jobCtx) || p.isJobCancelRequested(ctx, job) {
+		stopHeartbeat()
+		outcome = JobCancelled
+		return p.db.SetJobCancelled(context.WithoutCancel(ctx), job.ID)
+	}
+
+	start := time.Now()
+	jobErr := p.DoJob(jobCtx, job)
+	duration = time.Since(start)
 
 	if jobErr == nil {
 		stopHeartbeat()
-		return db.SetJobResult(context.WithoutCancel(ctx), job.ID, job.Result)
+		outcome = JobSucceeded
+		return p.db.SetJobResult(context.WithoutCancel(ctx), job.ID, job.Result)
+	}
+
+	// Cancelled with CancelJob: record the job as cancelled
+	// instead of as errored or reset, and don't retry it.
+	if isJobCancelled(jobCtx) {
+		stopHeartbeat()
+		outcome = JobCancelled
+		return p.db.SetJobCancelled(context.WithoutCancel(ctx), job.ID)
 	}
 
+	outcome, err = p.saveJobError(ctx, job, jobErr, stopHeartbeat)
+	return err
+}
+
+// saveJobError persists the outcome of a job that returned jobErr
+// as the terminal write of doJobAndSaveResultInDB
+// or doBatchAndSaveResultsInDB, see doJobAndSaveResultInDB.
+// The returned outcome is the one that was attempted to persist.
+func (p *Pool) saveJobError(ctx context.Context, job *jobqueue.Job, jobErr error, stopHeartbeat func()) (outcome JobOutcome, err error) {
 	// Reset the job without consuming a retry attempt when it was interrupted
 	// rather than having genuinely failed, so it can be picked up again later.
 	//
@@ -160,7 +248,7 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 	//
 	// A context.DeadlineExceeded carried by jobErr while ctx is still alive is
 	// deliberately NOT treated as an interruption: it originates from the
-	// per-job JobTimeout applied inside DoJob, which means the job genuinely ran
+	// per-job timeout applied inside DoJob, which means the job genuinely ran
 	// too long. Such a job falls through to the normal error/retry handling
 	// below so it consumes a retry instead of being reset (and retried) forever.
 	// Adding `|| errors.Is(jobErr, context.DeadlineExceeded)` here would also
@@ -169,15 +257,31 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 	// without ever consuming a retry attempt or failing permanently.
 	if ctx.Err() != nil || errors.Is(jobErr, context.Canceled) {
 		stopHeartbeat()
-		resetErr := db.ResetJob(context.WithoutCancel(ctx), job.ID)
+		resetErr := p.db.ResetJob(context.WithoutCancel(ctx), job.ID)
 		if resetErr != nil {
-			OnError(resetErr)
+			p.onError(resetErr)
 			log.ErrorCtx(ctx, "Error resetting job after context cancellation").
 				UUID("jobID", job.ID).
 				Err(resetErr).
 				Log()
 		}
-		return ctx.Err()
+		return JobInterrupted, ctx.Err()
+	}
+
+	// Snoozed by the worker with Snooze: start the job again after the
+	// snooze duration without counting an attempt, even if no retries remain.
+	if snooze, ok := snoozeDuration(jobErr); ok {
+		stopHeartbeat()
+		err = p.db.SnoozeJob(context.WithoutCancel(ctx), job.ID, time.Now().Add(snooze))
+		if err != nil {
+			p.onError(err)
+			log.ErrorCtx(ctx, "Could not snooze job").
+				UUID("jobID", job.ID).
+				Any("job", job).
+				Err(err).
+				Log()
+		}
+		return JobSnoozed, err
 	}
 
 	// job.ErrorMsg might be null if DoJob returns an error
@@ -185,21 +289,22 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 	// some other job-queue logic error.
 	errorMsg := job.ErrorMsg.StringOr(jobErr.Error())
 
-	// Genuine final failure: no retries remain. Mark the job errored as the
+	// Genuine final failure: no retries remain or the worker returned
+	// an error wrapped with Permanent. Mark the job errored as the
 	// single terminal write (SetJobError also counts the job in its bundle).
-	if job.CurrentRetryCount >= job.MaxRetryCount {
+	if job.CurrentRetryCount >= job.MaxRetryCount || IsPermanent(jobErr) {
 		stopHeartbeat()
-		err = db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData)
+		err = p.db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData)
 		if err != nil {
-			OnError(err)
+			p.onError(err)
 			log.ErrorCtx(ctx, "Error while updating job error in the database").
 				UUID("jobID", job.ID).
 				Any("job", job).
 				Err(err).
 				Log()
-			return err
+			return JobFailed, err
 		}
-		return nil
+		return JobFailed, nil
 	}
 
 	// Retryable: run the (possibly slow) retry scheduler while the heartbeat is
@@ -212,13 +317,17 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 	// error) instead record a TERMINAL failure via SetJobError, which clamps the
 	// retry count so the job is counted in its bundle and is not resurrected by
 	// the reaper's retries-remaining branch.
-	retrySchedulersMtx.RLock()
-	scheduleRetry, ok := retrySchedulers[job.Type]
-	retrySchedulersMtx.RUnlock()
-	if !ok {
+	//
+	// The worker can request the start time of the retry with RetryAt
+	// instead, then the retry scheduler is not called.
+	if nextStart, ok := retryAtOf(jobErr); ok {
+		return JobRetried, p.scheduleRetry(ctx, job, nextStart, job.CurrentRetryCount+1, errorMsg, job.ErrorData, stopHeartbeat)
+	}
+	scheduleRetry := p.retrySchedulerOrNil(job.Type)
+	if scheduleRetry == nil {
 		stopHeartbeat()
 		err = errs.New("Retry scheduler doesn't exist for job")
-		OnError(err)
+		p.onError(err)
 		log.ErrorCtx(ctx, "Retry scheduler doesn't exist for job").
 			UUID("jobID", job.ID).
 			Any("job", job).
@@ -228,16 +337,16 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 		// visible and the job's bundle can still complete. SetJobError clamps the
 		// retry count, so the reaper will not resurrect this job. Re-register a
 		// scheduler and ResetJob the job to retry it.
-		if setErr := db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData); setErr != nil {
-			OnError(setErr)
+		if setErr := p.db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData); setErr != nil {
+			p.onError(setErr)
 		}
-		return err
+		return JobFailed, err
 	}
 
-	nextStart, err := scheduleRetry(ctx, job)
+	nextStart, err := scheduleRetry(contextWithJobError(ctx, jobErr), job)
 	if err != nil {
 		stopHeartbeat()
-		OnError(err)
+		p.onError(err)
 		log.ErrorCtx(ctx, "Retry scheduler returned an error").
 			UUID("jobID", job.ID).
 			Any("job", job).
@@ -247,19 +356,25 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 		// is visible and the job's bundle can still complete, rather than leaving
 		// it silently in-progress. SetJobError clamps the retry count, so the
 		// reaper will not resurrect this job; ResetJob it to retry once fixed.
-		if setErr := db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData); setErr != nil {
-			OnError(setErr)
+		if setErr := p.db.SetJobError(context.WithoutCancel(ctx), job.ID, errorMsg, job.ErrorData); setErr != nil {
+			p.onError(setErr)
 		}
-		return err
+		return JobFailed, err
 	}
 
+	return JobRetried, p.scheduleRetry(ctx, job, nextStart, job.CurrentRetryCount+1, errorMsg, job.ErrorData, stopHeartbeat)
+}
+
+// scheduleRetry stops the heartbeat and reschedules the job
+// as the terminal write of doJobAndSaveResultInDB.
+func (p *Pool) scheduleRetry(ctx context.Context, job *jobqueue.Job, nextStart time.Time, retryCount int, errorMsg string, errorData nullable.JSON, stopHeartbeat func()) error {
 	// Stop the heartbeat before the terminal write so no worker_alive_at update
 	// races the transition. Use WithoutCancel so context cancellation between the
-	// checks above and this call cannot leave the job stuck without a retry.
+	// checks of the caller and this call cannot leave the job stuck without a retry.
 	stopHeartbeat()
-	err = db.ScheduleRetry(context.WithoutCancel(ctx), job.ID, nextStart, job.CurrentRetryCount+1)
+	err := p.db.ScheduleRetry(context.WithoutCancel(ctx), job.ID, nextStart, retryCount, errorMsg, errorData)
 	if err != nil {
-		OnError(err)
+		p.onError(err)
 		log.ErrorCtx(ctx, "Could not schedule retry for job").
 			UUID("jobID", job.ID).
 			Any("job", job).
@@ -267,6 +382,5 @@ func doJobAndSaveResultInDB(ctx context.Context, job *jobqueue.Job) (err error)
 			Log()
 		return err
 	}
-
 	return nil
 }
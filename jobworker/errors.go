@@ -0,0 +1,96 @@
+package jobworker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Permanent wraps err so that a job whose worker returned it
+// is stopped with the error without being retried,
+// for example because its payload is malformed and can never succeed.
+// It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err or an error in its chain
+// was returned by Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Snooze returns an error that makes the job whose worker returned it start
+// again after duration without counting as a retry attempt,
+// even if the job has no retries left.
+// The job is not recorded as errored and OnError is not called.
+//
+// Use it for a job that can't be done yet, for example because
+// a resource it depends on is not ready:
+//
+//	if !report.Ready() {
+//		return jobworker.Snooze(time.Minute)
+//	}
+func Snooze(duration time.Duration) error {
+	return &snoozeError{duration: max(duration, 0)}
+}
+
+type snoozeError struct {
+	duration time.Duration
+}
+
+func (e *snoozeError) Error() string {
+	return fmt.Sprintf("job snoozed for %s", e.duration)
+}
+
+// snoozeDuration returns the duration passed to Snooze
+// if err or an error in its chain was returned by Snooze.
+func snoozeDuration(err error) (duration time.Duration, ok bool) {
+	var snooze *snoozeError
+	if !errors.As(err, &snooze) {
+		return 0, false
+	}
+	return snooze.duration, true
+}
+
+// RetryAt wraps err so that the job whose worker returned it
+// is retried at nextStart instead of the time returned
+// by the registered ScheduleRetryFunc.
+// The retry counts as an attempt, so a job without retries left
+// is stopped with the error.
+// A nil err is replaced with an error describing the requested retry.
+func RetryAt(nextStart time.Time, err error) error {
+	if err == nil {
+		err = fmt.Errorf("job retry requested at %s", nextStart.Format(time.RFC3339))
+	}
+	return &retryAtError{err: err, nextStart: nextStart}
+}
+
+type retryAtError struct {
+	err       error
+	nextStart time.Time
+}
+
+func (e *retryAtError) Error() string { return e.err.Error() }
+func (e *retryAtError) Unwrap() error { return e.err }
+
+// retryAtOf returns the time passed to RetryAt
+// if err or an error in its chain was returned by RetryAt.
+func retryAtOf(err error) (nextStart time.Time, ok bool) {
+	var retryAt *retryAtError
+	if !errors.As(err, &retryAt) {
+		return time.Time{}, false
+	}
+	return retryAt.nextStart, true
+}
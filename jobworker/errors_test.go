@@ -0,0 +1,108 @@
+package jobworker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestPermanentSnoozeRetryAt(t *testing.T) {
+	const (
+		permanentType = "jobworker-test-permanent"
+		snoozeType    = "jobworker-test-snooze"
+		retryAtType   = "jobworker-test-retry-at"
+	)
+	var onErrorCalls atomic.Int32
+	pool, db := newTestPool(t, jobworker.WithOnError(func(error) { onErrorCalls.Add(1) }))
+	pool.Register(permanentType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, jobworker.Permanent(errors.New("malformed payload"))
+	})
+	pool.Register(snoozeType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		if job.StartAt.IsNull() {
+			return nil, jobworker.Snooze(50 * time.Millisecond)
+		}
+		return "done", nil
+	})
+	// No retry scheduler is registered, RetryAt is enough
+	pool.Register(retryAtType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		if job.CurrentRetryCount == 0 {
+			return nil, jobworker.RetryAt(time.Now().Add(50*time.Millisecond), errors.New("not yet"))
+		}
+		return "done", nil
+	})
+	startThreads(t, pool, 2)
+
+	permanentJob := newTestJob(t, permanentType, 0, nullable.Time{})
+	permanentJob.MaxRetryCount = 3
+	snoozeJob := newTestJob(t, snoozeType, 0, nullable.Time{})
+	retryAtJob := newTestJob(t, retryAtType, 0, nullable.Time{})
+	retryAtJob.MaxRetryCount = 1
+	for _, job := range []*jobqueue.Job{permanentJob, snoozeJob, retryAtJob} {
+		require.NoError(t, db.AddJob(t.Context(), job))
+	}
+
+	loaded := waitForJob(t, db, permanentJob.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.HasError())
+	assert.Equal(t, "malformed payload", loaded.ErrorMsg.String())
+	assert.True(t, loaded.IsFinished(), "not retried")
+
+	loaded = waitForJob(t, db, snoozeJob.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Succeeded())
+	assert.Zero(t, loaded.CurrentRetryCount, "snooze is no attempt")
+
+	loaded = waitForJob(t, db, retryAtJob.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Succeeded())
+	assert.Equal(t, 1, loaded.CurrentRetryCount)
+
+	assert.Equal(t, int32(2), onErrorCalls.Load(), "permanent and RetryAt errors, no snooze")
+}
+
+func TestJobAttempts(t *testing.T) {
+	const jobType = "jobworker-test-attempts"
+	pool, db := newTestPool(t)
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		switch {
+		case job.StartAt.IsNull():
+			return nil, jobworker.Snooze(10 * time.Millisecond)
+		case job.CurrentRetryCount == 0:
+			return nil, jobworker.RetryAt(time.Now().Add(10*time.Millisecond), errors.New("not yet"))
+		}
+		return "done", nil
+	})
+	startThreads(t, pool, 1)
+
+	job := newTestJob(t, jobType, 0, nullable.Time{})
+	job.MaxRetryCount = 1
+	require.NoError(t, db.AddJob(t.Context(), job))
+
+	waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+
+	attempts, err := db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	assert.Equal(t, jobqueue.JobAttemptSnoozed, attempts[0].Outcome)
+	assert.True(t, attempts[0].ErrorMsg.IsNull())
+	assert.Equal(t, jobqueue.JobAttemptRetried, attempts[1].Outcome)
+	assert.Equal(t, "not yet", attempts[1].ErrorMsg.String())
+	assert.Equal(t, jobqueue.JobAttemptSucceeded, attempts[2].Outcome)
+	assert.Equal(t, 1, attempts[2].RetryCount)
+	for i, a := range attempts {
+		assert.Equal(t, i+1, a.Attempt)
+		assert.Equal(t, jobworker.WorkerName, a.Worker)
+		assert.False(t, a.StoppedAt.Before(a.StartedAt))
+	}
+
+	require.NoError(t, db.DeleteJob(t.Context(), job.ID))
+	attempts, err = db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, attempts)
+}
@@ -6,16 +6,17 @@ import (
 	"time"
 
 	"github.com/domonda/go-errs"
-	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
 )
 
 // startJobHeartbeat starts a goroutine that periodically updates the
-// worker_alive_at timestamp of the job with jobID in the database every
-// HeartbeatInterval. This lets observers tell whether the worker processing the
-// job is still alive: while the worker runs, worker_alive_at keeps advancing; if
-// the worker process crashes, the goroutine dies with it and worker_alive_at
-// stops advancing, so a stale worker_alive_at indicates a job abandoned by a
-// crashed worker.
+// worker_alive_at timestamp of job in the database every
+// heartbeat interval of the Pool. This lets observers tell whether the worker
+// processing the job is still alive: while the worker runs, worker_alive_at
+// keeps advancing; if the worker process crashes, the goroutine dies with it
+// and worker_alive_at stops advancing, so a stale worker_alive_at indicates a
+// job abandoned by a crashed worker.
 //
 // The returned stop function stops the heartbeat and blocks until the
 // goroutine has terminated, guaranteeing that no further worker_alive_at update
@@ -25,9 +26,10 @@ import (
 // more than once, so callers can both call it explicitly and defer it as a
 // leak-safety net.
 //
-// If HeartbeatInterval is <= 0 heartbeating is disabled and stop is a no-op.
-func startJobHeartbeat(ctx context.Context, jobID uu.ID) (stop func()) {
-	if HeartbeatInterval <= 0 {
+// If the heartbeat interval is <= 0 heartbeating is disabled and stop is a no-op.
+func (p *Pool) startJobHeartbeat(ctx context.Context, job *jobqueue.Job) (stop func()) {
+	interval := *p.heartbeatInterval
+	if interval <= 0 {
 		return func() {}
 	}
 
@@ -47,9 +49,9 @@ func startJobHeartbeat(ctx context.Context, jobID uu.ID) (stop func()) {
 
 	go func() {
 		defer close(doneChan)
-		defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), jobID)
+		defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), job.ID)
 
-		ticker := time.NewTicker(HeartbeatInterval)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
@@ -61,15 +63,16 @@ func startJobHeartbeat(ctx context.Context, jobID uu.ID) (stop func()) {
 				// heartbeat (and, via stop(), the worker thread) indefinitely; the
 				// next tick retries. ctx is also cancelled by stop(), so an
 				// in-flight write is aborted promptly on shutdown.
-				writeCtx, cancelWrite := context.WithTimeout(ctx, HeartbeatInterval)
-				err := db.SetJobWorkerAlive(writeCtx, jobID)
+				writeCtx, cancelWrite := context.WithTimeout(ctx, interval)
+				err := p.db.SetJobWorkerAlive(writeCtx, job.ID)
 				cancelWrite()
 				// Skip logging when ctx was cancelled by stop(): that error is from
 				// our own shutdown, not a real heartbeat failure.
 				if err != nil && ctx.Err() == nil {
-					OnError(err)
+					p.onError(err)
+					p.notifyHeartbeatError(ctx, job, err)
 					log.ErrorCtx(ctx, "Error while updating the job heartbeat").
-						UUID("jobID", jobID).
+						UUID("jobID", job.ID).
 						Err(err).
 						Log()
 				}
@@ -0,0 +1,92 @@
+package jobworker
+
+import (
+	"slices"
+)
+
+// Middleware wraps the WorkerFunc next, for example to add tracing, metrics,
+// or context values, or to transform the job payload or the result and error.
+// The returned WorkerFunc is called with the job and has to call next
+// to run the wrapped worker.
+//
+// Example:
+//
+//	jobworker.Use(func(next jobworker.WorkerFunc) jobworker.WorkerFunc {
+//		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+//			start := time.Now()
+//			result, err := next(ctx, job)
+//			metrics.ObserveJob(job.Type, time.Since(start), err)
+//			return result, err
+//		}
+//	})
+type Middleware func(next WorkerFunc) WorkerFunc
+
+// Use adds middleware for the workers of all job types
+// of the default Pool, see Pool.Use.
+func Use(middleware ...Middleware) {
+	defaultPool.Use(middleware...)
+}
+
+// Use adds middleware for the workers of all job types.
+//
+// DoJob wraps the registered worker with the middleware of UseForJobType
+// and then with the middleware of Use, so the middleware of Use runs first
+// and the first added middleware is the outermost.
+// The middleware is called with the context passed to the worker,
+// and a panic in it is recovered like a panic in the worker.
+//
+// Middleware is applied to the jobs started after Use returned.
+// It also wraps the batches of RegisterBatch workers, see RegisterBatch.
+func (p *Pool) Use(middleware ...Middleware) {
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	// Replace instead of append so a DoJob call
+	// iterating the previous slice is not affected
+	p.middleware = slices.Concat(p.middleware, middleware)
+}
+
+// UseForJobType adds middleware for the worker of jobType
+// of the default Pool, see Pool.UseForJobType.
+func UseForJobType(jobType string, middleware ...Middleware) {
+	defaultPool.UseForJobType(jobType, middleware...)
+}
+
+// UseForJobType adds middleware for the worker of jobType.
+// It runs after the middleware added with Use, see Pool.Use.
+// The middleware is kept if the worker is unregistered.
+func (p *Pool) UseForJobType(jobType string, middleware ...Middleware) {
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	p.jobTypeMiddleware[jobType] = slices.Concat(p.jobTypeMiddleware[jobType], middleware)
+}
+
+// workerWithMiddlewareOrNil returns the worker registered for jobType
+// wrapped with the middleware for it, or nil if no worker is registered.
+func (p *Pool) workerWithMiddlewareOrNil(jobType string) WorkerFunc {
+	p.workersMtx.RLock()
+	worker := p.workers[jobType]
+	p.workersMtx.RUnlock()
+
+	if worker == nil {
+		return nil
+	}
+	return p.withMiddleware(jobType, worker)
+}
+
+// withMiddleware wraps worker with the middleware for jobType.
+func (p *Pool) withMiddleware(jobType string, worker WorkerFunc) WorkerFunc {
+	p.workersMtx.RLock()
+	middleware := p.middleware
+	jobTypeMiddleware := p.jobTypeMiddleware[jobType]
+	p.workersMtx.RUnlock()
+
+	for _, m := range slices.Backward(jobTypeMiddleware) {
+		worker = m(worker)
+	}
+	for _, m := range slices.Backward(middleware) {
+		worker = m(worker)
+	}
+	return worker
+}
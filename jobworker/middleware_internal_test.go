@@ -0,0 +1,82 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func newTestPool() *Pool {
+	onError := func(error) {}
+	jobTimeout := time.Minute
+	heartbeatInterval := time.Duration(0)
+	return newPool(nil, &onError, &jobTimeout, &heartbeatInterval)
+}
+
+// recordingMiddleware appends name to calls before and after calling next.
+func recordingMiddleware(calls *[]string, name string) Middleware {
+	return func(next WorkerFunc) WorkerFunc {
+		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+			*calls = append(*calls, name)
+			result, err := next(ctx, job)
+			*calls = append(*calls, "/"+name)
+			return result, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	p := newTestPool()
+	p.Register("a", func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		calls = append(calls, "worker")
+		return "result", nil
+	})
+	p.Register("b", noopWorker)
+	p.UseForJobType("a", recordingMiddleware(&calls, "typeA1"), recordingMiddleware(&calls, "typeA2"))
+	p.UseForJobType("b", recordingMiddleware(&calls, "typeB"))
+	p.Use(recordingMiddleware(&calls, "all1"))
+	p.Use(recordingMiddleware(&calls, "all2"))
+
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), "a", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, p.DoJob(t.Context(), job))
+
+	assert.Equal(t, []string{"all1", "all2", "typeA1", "typeA2", "worker", "/typeA2", "/typeA1", "/all2", "/all1"}, calls)
+	assert.JSONEq(t, `"result"`, job.Result.String())
+}
+
+func TestMiddlewareResultAndError(t *testing.T) {
+	p := newTestPool()
+	p.Register("a", func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, errors.New("worker error")
+	})
+	p.Use(func(next WorkerFunc) WorkerFunc {
+		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+			_, err := next(ctx, job)
+			return map[string]string{"wrapped": err.Error()}, errors.New("middleware error")
+		}
+	})
+
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), "a", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	err = p.DoJob(t.Context(), job)
+	require.Error(t, err)
+	assert.Equal(t, "middleware error", job.ErrorMsg.String())
+	assert.JSONEq(t, `{"wrapped":"worker error"}`, job.ErrorData.String())
+
+	p.UseForJobType("a", func(next WorkerFunc) WorkerFunc {
+		return func(ctx context.Context, job *jobqueue.Job) (any, error) {
+			panic("middleware panic")
+		}
+	})
+	assert.ErrorContains(t, p.DoJob(t.Context(), job), "middleware panic", "panic recovered")
+}
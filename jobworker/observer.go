@@ -0,0 +1,150 @@
+package jobworker
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// JobOutcome is how a job run by the worker threads of a Pool ended,
+// reported to Observer.OnJobStopped.
+type JobOutcome string
+
+const (
+	// JobSucceeded means the worker returned without an error.
+	JobSucceeded JobOutcome = "succeeded"
+	// JobFailed means the worker returned an error
+	// and the job will not be retried.
+	JobFailed JobOutcome = "failed"
+	// JobRetried means the worker returned an error
+	// and a retry of the job was scheduled.
+	JobRetried JobOutcome = "retried"
+	// JobSnoozed means the worker returned a Snooze error.
+	JobSnoozed JobOutcome = "snoozed"
+	// JobCancelled means the job was cancelled with jobqueue.CancelJob.
+	JobCancelled JobOutcome = "cancelled"
+	// JobInterrupted means the context of the worker threads was cancelled,
+	// for example at shutdown, and the job was reset to be started again.
+	JobInterrupted JobOutcome = "interrupted"
+)
+
+// Observer is notified about the work of the worker threads of a Pool,
+// for example to export metrics like the jobmetrics package does.
+//
+// The methods are called synchronously by the worker threads
+// and the heartbeat goroutines, so they must return quickly
+// and be safe for concurrent use.
+// DoJob called outside of the worker threads is not observed.
+type Observer interface {
+	// OnClaim is called after the Pool claimed numJobs jobs
+	// from its DataBase in duration, or failed with err.
+	// An empty queue is a claim with numJobs zero.
+	OnClaim(ctx context.Context, numJobs int, duration time.Duration, err error)
+
+	// OnJobStarted is called after a worker thread claimed job
+	// and before its worker is called.
+	// The job.StartedAt was set by the claim.
+	OnJobStarted(ctx context.Context, job *jobqueue.Job)
+
+	// OnJobStopped is called after the outcome of a job
+	// that was passed to OnJobStarted was saved in the DataBase.
+	// duration is the time the worker took,
+	// zero if the job was cancelled before it was started.
+	OnJobStopped(ctx context.Context, job *jobqueue.Job, outcome JobOutcome, duration time.Duration)
+
+	// OnHeartbeatError is called when updating the
+	// worker_alive_at timestamp of job failed with err.
+	OnHeartbeatError(ctx context.Context, job *jobqueue.Job, err error)
+}
+
+// AddObserver adds observers of the worker threads
+// of the default Pool, see Pool.AddObserver.
+func AddObserver(observers ...Observer) {
+	defaultPool.AddObserver(observers...)
+}
+
+// AddObserver adds observers of the worker threads of the Pool.
+// They are notified about the events after AddObserver returned.
+func (p *Pool) AddObserver(observers ...Observer) {
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	// Replace instead of append so a notification
+	// iterating the previous slice is not affected
+	p.observers = slices.Concat(p.observers, observers)
+}
+
+// NumThreads returns the number of running worker threads of the Pool
+// and how many of them are idle because they wait for a job.
+// The worker threads stopped with StopThreads are counted
+// until they finished their current job.
+func (p *Pool) NumThreads() (running, idle int) {
+	// Load idle first, so it can't be more than running
+	// because of a thread starting between the loads
+	idle = int(p.numWaitingThreads.Load())
+	running = int(p.numLiveThreads.Load())
+	return running, min(idle, running)
+}
+
+func (p *Pool) getObservers() []Observer {
+	p.workersMtx.RLock()
+	defer p.workersMtx.RUnlock()
+
+	return p.observers
+}
+
+// claimJob calls DataBase.StartNextJobOrNil and notifies the observers.
+func (p *Pool) claimJob(ctx context.Context, claim *Claim, skipJobTypes ...string) (*jobqueue.Job, error) {
+	start := time.Now()
+	job, err := p.db.StartNextJobOrNil(ctx, claim, skipJobTypes...)
+	if job != nil {
+		p.registerClaimedJob(job.ID)
+	}
+	if observers := p.getObservers(); len(observers) > 0 {
+		numJobs := 0
+		if job != nil {
+			numJobs = 1
+		}
+		duration := time.Since(start)
+		for _, o := range observers {
+			o.OnClaim(ctx, numJobs, duration, err)
+		}
+	}
+	return job, err
+}
+
+// claimJobs calls DataBase.StartNextJobsOrNil and notifies the observers.
+func (p *Pool) claimJobs(ctx context.Context, claim *Claim, n int, skipJobTypes ...string) ([]*jobqueue.Job, error) {
+	start := time.Now()
+	jobs, err := p.db.StartNextJobsOrNil(ctx, claim, n, skipJobTypes...)
+	for _, job := range jobs {
+		p.registerClaimedJob(job.ID)
+	}
+	if observers := p.getObservers(); len(observers) > 0 {
+		duration := time.Since(start)
+		for _, o := range observers {
+			o.OnClaim(ctx, len(jobs), duration, err)
+		}
+	}
+	return jobs, err
+}
+
+func (p *Pool) notifyJobStarted(ctx context.Context, job *jobqueue.Job) {
+	for _, o := range p.getObservers() {
+		o.OnJobStarted(ctx, job)
+	}
+}
+
+func (p *Pool) notifyJobStopped(ctx context.Context, job *jobqueue.Job, outcome JobOutcome, duration time.Duration) {
+	for _, o := range p.getObservers() {
+		o.OnJobStopped(ctx, job, outcome, duration)
+	}
+}
+
+func (p *Pool) notifyHeartbeatError(ctx context.Context, job *jobqueue.Job, err error) {
+	for _, o := range p.getObservers() {
+		o.OnHeartbeatError(ctx, job, err)
+	}
+}
@@ -0,0 +1,299 @@
+package jobworker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// Pool runs worker threads that claim jobs from its DataBase.
+// A Pool owns its registered workers, middleware, retry schedulers, schedules,
+// concurrency and rate limits, threads, and configuration,
+// so independent pools can run in the same process,
+// for example against different databases or in parallel tests.
+//
+// Every Pool needs its own DataBase instance,
+// because a DataBase calls a single job available
+// and job cancel requested listener.
+//
+// The package level functions like Register and StartThreads
+// use the default Pool returned by DefaultPool.
+type Pool struct {
+	db DataBase
+
+	// Configuration, pointing to the package variables
+	// OnError, JobTimeout, and HeartbeatInterval for the default Pool
+	onErrorFunc       *func(error)
+	jobTimeout        *time.Duration
+	heartbeatInterval *time.Duration
+
+	// setupMtx guards numRunningThreads, workerWaitGroup, checkJobSignal, stopPolling, and workerCtx
+	setupMtx sync.RWMutex
+
+	numRunningThreads int
+	workerWaitGroup   *sync.WaitGroup
+	// checkJobSignal is a dummy signal notifying the thread workers that there is a new job available
+	checkJobSignal chan struct{}
+	// stopPolling is closed to signal the polling goroutines and the scheduler to stop,
+	// then reassigned to a new channel for the next polling cycle
+	stopPolling chan struct{}
+	// workerCtx is the context passed to StartThreads,
+	// used to cancel worker threads when the context is cancelled
+	workerCtx context.Context
+	// stopping is set to true by FinishThreads/StopThreads
+	// so that nextJob won't pick up new jobs from the database.
+	// Using atomic.Bool so nextJob can check it without holding setupMtx.
+	stopping atomic.Bool
+
+	// workersMtx guards workers, batchWorkers, middleware, jobTypeMiddleware, workerTypes,
+	// workerTypesGeneration, clusterMaxConcurrency, rateLimits, claim, observers, and tracer
+	workersMtx sync.RWMutex
+	workers    map[JobType]WorkerFunc
+	// batchWorkers holds the workers registered with RegisterBatch,
+	// which are also in workers for DoJob
+	batchWorkers map[JobType]*batchWorker
+	// middleware holds the Middleware added with Use
+	// and jobTypeMiddleware the Middleware added with UseForJobType.
+	// The slices are replaced instead of appended to.
+	middleware        []Middleware
+	jobTypeMiddleware map[JobType][]Middleware
+	// workerTypes caches the sorted set of registered job types derived from the
+	// workers map. It is invalidated by setting it back to nil whenever workers
+	// changes (Register/Unregister, normally only at startup) and lazily rebuilt
+	// on the next RegisteredJobTypes read. A nil workerTypes means the cache is
+	// invalid and must be rebuilt; an empty-but-non-nil workerTypes means
+	// "rebuilt, no job types registered".
+	workerTypes []string
+	// workerTypesGeneration is incremented every time the set of registered
+	// job types or their limits change. RegisteredJobTypes returns it alongside
+	// the types so a consumer can cache values derived from the types and
+	// rebuild only when the generation changes — a single locked read yields a
+	// consistent (types, generation) pair, so there is no torn read and no need
+	// to compare the type sets element by element.
+	workerTypesGeneration uint64
+	// clusterMaxConcurrency holds the limits set with SetClusterMaxConcurrency
+	// and rateLimits the limits set with SetRateLimit.
+	// Both are replaced instead of mutated so that a Claim can share them.
+	clusterMaxConcurrency map[JobType]int
+	rateLimits            map[JobType]RateLimit
+	// claim caches the Claim returned by Claim,
+	// nil if it has to be rebuilt
+	claim *Claim
+	// observers holds the Observers added with AddObserver.
+	// The slice is replaced instead of appended to.
+	observers []Observer
+	// tracer is the Tracer set with SetTracer or nil
+	tracer Tracer
+
+	// retrySchedulersMtx guards retrySchedulers and defaultRetryScheduler
+	retrySchedulers       map[JobType]ScheduleRetryFunc
+	defaultRetryScheduler ScheduleRetryFunc
+	retrySchedulersMtx    sync.RWMutex
+
+	// maxConcurrency holds the limits set with SetMaxConcurrency
+	// and numRunningJobs the number of running jobs of those job types
+	// started by the worker threads of the Pool
+	maxConcurrency map[JobType]int
+	numRunningJobs map[JobType]int
+	// concurrencyMtx guards maxConcurrency and numRunningJobs
+	// and serializes the claims of the worker threads
+	// while any limit is set
+	concurrencyMtx sync.Mutex
+
+	// prefetch is the number of jobs set with SetPrefetch
+	// that a worker thread claims at most at once,
+	// and prefetched holds the claimed jobs that are
+	// not processed yet by a worker thread
+	prefetch    int
+	prefetched  []*jobqueue.Job
+	prefetchMtx sync.Mutex
+	// numWaitingThreads is the number of worker threads
+	// that are in nextJob waiting for a job
+	// and numLiveThreads the number of worker threads
+	// that have not ended yet
+	numWaitingThreads atomic.Int64
+	numLiveThreads    atomic.Int64
+
+	// claimedJobs holds the jobs claimed by the worker threads
+	// until they were processed, and unclaimedCancels
+	// the recent cancellation requests of jobs that were not claimed,
+	// see onJobCancelRequested.
+	claimedJobs      map[uu.ID]*claimedJob
+	unclaimedCancels map[uu.ID]time.Time
+	claimedJobsMtx   sync.Mutex
+
+	schedules    map[string]*schedule
+	schedulesMtx sync.RWMutex
+
+	// startDelayTimer wakes up the worker threads when the earliest
+	// start_at in the future of a job is reached at startDelayDeadline
+	startDelayTimer    *time.Timer
+	startDelayDeadline time.Time
+	startDelayMtx      sync.Mutex
+}
+
+// PoolOption configures a Pool created with NewPool.
+type PoolOption func(*Pool)
+
+// WithOnError sets a function that will be called
+// for every error of the Pool that would also be logged.
+func WithOnError(onError func(error)) PoolOption {
+	return func(p *Pool) {
+		*p.onErrorFunc = onError
+	}
+}
+
+// WithJobTimeout sets the timeout applied to the context passed
+// to the job worker functions of the Pool, see JobTimeout.
+// Default is 15 minutes. Zero disables the timeout.
+func WithJobTimeout(timeout time.Duration) PoolOption {
+	return func(p *Pool) {
+		*p.jobTimeout = timeout
+	}
+}
+
+// WithHeartbeatInterval sets how often the Pool updates the
+// worker_alive_at timestamp of the jobs it is processing,
+// see HeartbeatInterval. Default is 10 seconds. Zero disables heartbeats.
+func WithHeartbeatInterval(interval time.Duration) PoolOption {
+	return func(p *Pool) {
+		*p.heartbeatInterval = interval
+	}
+}
+
+// NewPool returns a new Pool claiming jobs from db
+// with an empty registry and no running threads.
+//
+// Pass a new DataBase instance that is not used by another Pool,
+// like one returned by memqueue.NewDataBase or jobworkerdb.NewDataBase.
+// NewPool panics if db is nil.
+//
+// A separate DataBase instance does not separate the queue:
+// jobworkerdb always uses the tables of the worker schema and the
+// job_available and job_cancel_requested channels, so Pools working
+// with the same PostgreSQL database share its jobs and notifications.
+// Such Pools have to register different job types,
+// otherwise use a database per Pool.
+func NewPool(db DataBase, opts ...PoolOption) *Pool {
+	if db == nil {
+		panic(errs.New("jobworker.NewPool: nil DataBase"))
+	}
+	var (
+		onError           = func(error) {}
+		jobTimeout        = 15 * time.Minute
+		heartbeatInterval = 10 * time.Second
+	)
+	p := newPool(db, &onError, &jobTimeout, &heartbeatInterval)
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func newPool(db DataBase, onError *func(error), jobTimeout, heartbeatInterval *time.Duration) *Pool {
+	return &Pool{
+		db:                    db,
+		onErrorFunc:           onError,
+		jobTimeout:            jobTimeout,
+		heartbeatInterval:     heartbeatInterval,
+		stopPolling:           make(chan struct{}),
+		workers:               map[JobType]WorkerFunc{},
+		batchWorkers:          map[JobType]*batchWorker{},
+		jobTypeMiddleware:     map[JobType][]Middleware{},
+		clusterMaxConcurrency: map[JobType]int{},
+		rateLimits:            map[JobType]RateLimit{},
+		retrySchedulers:       map[JobType]ScheduleRetryFunc{},
+		maxConcurrency:        map[JobType]int{},
+		numRunningJobs:        map[JobType]int{},
+		claimedJobs:           map[uu.ID]*claimedJob{},
+		unclaimedCancels:      map[uu.ID]time.Time{},
+		schedules:             map[string]*schedule{},
+	}
+}
+
+// defaultPool is used by the package level functions.
+// Its configuration are the package variables
+// and its DataBase is set with SetDataBase.
+var defaultPool = newPool(nil, &OnError, &JobTimeout, &HeartbeatInterval)
+
+// DefaultPool returns the Pool used by the package level functions.
+// It is configured by the package variables OnError, JobTimeout,
+// and HeartbeatInterval and uses the DataBase set with SetDataBase.
+func DefaultPool() *Pool {
+	return defaultPool
+}
+
+// DataBase returns the DataBase of the Pool.
+func (p *Pool) DataBase() DataBase {
+	return p.db
+}
+
+// onError calls the OnError function of the Pool.
+func (p *Pool) onError(err error) {
+	(*p.onErrorFunc)(err)
+}
+
+// Claim describes the jobs a Pool claims from its DataBase
+// with DataBase.StartNextJobOrNil and waits for
+// with DataBase.GetNextJobStartDelay.
+//
+// A Claim is not changed after it was returned by Pool.Claim,
+// so a DataBase can cache values derived from it,
+// like a prepared statement, for the same Claim pointer.
+// Its slice and maps are shared and MUST NOT be mutated.
+type Claim struct {
+	// JobTypes are the sorted job types with a registered worker.
+	JobTypes []string
+	// ClusterMaxConcurrency holds the limits per job type set with
+	// SetClusterMaxConcurrency. A DataBase skips the job types
+	// that reached their limit of started and not stopped jobs.
+	ClusterMaxConcurrency map[JobType]int
+	// RateLimits holds the limits per job type set with SetRateLimit.
+	// A DataBase skips the job types that reached their limit
+	// of started jobs in the current window.
+	RateLimits map[JobType]RateLimit
+	// Heartbeat is true if the heartbeat interval of the Pool is positive,
+	// in which case the worker_alive_at timestamp of a claimed job
+	// is set when it is claimed, else it is left null.
+	Heartbeat bool
+	// Worker is the WorkerName of the process, stored as Job.Worker
+	// of a claimed job and recorded as JobAttempt.Worker of its attempt.
+	Worker string
+}
+
+// Claim returns the Claim of the currently registered workers and limits
+// of the Pool. The same pointer is returned until one of them,
+// the heartbeat interval, or WorkerName changes.
+func (p *Pool) Claim() *Claim {
+	heartbeat := *p.heartbeatInterval > 0
+	worker := WorkerName
+
+	p.workersMtx.RLock()
+	claim := p.claim
+	p.workersMtx.RUnlock()
+	if claim != nil && claim.Heartbeat == heartbeat && claim.Worker == worker {
+		return claim
+	}
+
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	// Re-check under the write lock: another goroutine
+	// may have rebuilt the Claim in the meantime
+	if p.claim == nil || p.claim.Heartbeat != heartbeat || p.claim.Worker != worker {
+		p.claim = &Claim{
+			JobTypes:              p.workerTypesLocked(),
+			ClusterMaxConcurrency: p.clusterMaxConcurrency,
+			RateLimits:            p.rateLimits,
+			Heartbeat:             heartbeat,
+			Worker:                worker,
+		}
+	}
+	return p.claim
+}
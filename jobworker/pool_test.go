@@ -0,0 +1,102 @@
+package jobworker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+	"github.com/domonda/go-jobqueue/memqueue"
+)
+
+// newTestDataBase returns a memqueue.DataBase
+// that is closed at the end of the test.
+func newTestDataBase(t *testing.T) jobworker.DataBase {
+	t.Helper()
+	db := memqueue.NewDataBase()
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// newTestPool returns a Pool with a DataBase from newTestDataBase.
+func newTestPool(t *testing.T, opts ...jobworker.PoolOption) (*jobworker.Pool, jobworker.DataBase) {
+	t.Helper()
+	db := newTestDataBase(t)
+	return jobworker.NewPool(db, opts...), db
+}
+
+// startThreads starts numThreads worker threads of pool
+// and finishes them at the end of the test.
+func startThreads(t *testing.T, pool *jobworker.Pool, numThreads int) {
+	t.Helper()
+	require.NoError(t, pool.StartThreads(t.Context(), numThreads))
+	t.Cleanup(func() { pool.FinishThreads(context.Background()) })
+}
+
+func newTestJob(t *testing.T, jobType string, priority int64, startAt nullable.Time) *jobqueue.Job {
+	t.Helper()
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), jobType, "jobworker-test", "{}", priority, startAt)
+	require.NoError(t, err)
+	return job
+}
+
+// waitForJob waits until the job with jobID
+// loaded from db satisfies cond and returns it.
+func waitForJob(t *testing.T, db jobworker.DataBase, jobID uu.ID, cond func(*jobqueue.Job) bool) *jobqueue.Job {
+	t.Helper()
+	var loaded *jobqueue.Job
+	require.Eventually(t, func() bool {
+		job, err := db.GetJob(t.Context(), jobID)
+		if err != nil || !cond(job) {
+			return false
+		}
+		loaded = job
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return loaded
+}
+
+func TestPools(t *testing.T) {
+	const jobType = "jobworker-test-pools"
+
+	newPool := func(t *testing.T, result string, opts ...jobworker.PoolOption) (*jobworker.Pool, jobworker.DataBase) {
+		t.Helper()
+		pool, db := newTestPool(t, opts...)
+		pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) {
+			return result, nil
+		})
+		startThreads(t, pool, 1)
+		return pool, db
+	}
+
+	poolA, dbA := newPool(t, "a")
+	poolB, dbB := newPool(t, "b", jobworker.WithHeartbeatInterval(0))
+
+	assert.True(t, poolA.IsRegistered(jobType))
+	assert.False(t, jobworker.IsRegistered(jobType), "default Pool not affected")
+	assert.True(t, poolA.Claim().Heartbeat)
+	assert.False(t, poolB.Claim().Heartbeat, "WithHeartbeatInterval(0)")
+
+	jobA := newTestJob(t, jobType, 0, nullable.Time{})
+	jobB := newTestJob(t, jobType, 0, nullable.Time{})
+	require.NoError(t, dbA.AddJob(t.Context(), jobA))
+	require.NoError(t, dbB.AddJob(t.Context(), jobB))
+
+	for _, tc := range []struct {
+		db     jobworker.DataBase
+		job    *jobqueue.Job
+		result string
+	}{
+		{dbA, jobA, `"a"`},
+		{dbB, jobB, `"b"`},
+	} {
+		loaded := waitForJob(t, tc.db, tc.job.ID, (*jobqueue.Job).Succeeded)
+		assert.JSONEq(t, tc.result, loaded.Result.String(), "job done by the worker of its own Pool")
+	}
+}
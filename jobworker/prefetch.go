@@ -0,0 +1,124 @@
+package jobworker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// SetPrefetch lets a worker thread of the default Pool
+// claim up to n jobs at once, see Pool.SetPrefetch.
+func SetPrefetch(n int) error {
+	return defaultPool.SetPrefetch(n)
+}
+
+// SetPrefetch lets a worker thread of the Pool claim up to n jobs
+// with a single DataBase.StartNextJobsOrNil call instead of one job per call,
+// so that under a large backlog of short jobs the claims
+// don't become the bottleneck.
+// One or zero claims every job separately, which is the default.
+//
+// The jobs beyond the first are buffered in the Pool and processed
+// by the other worker threads that are waiting for a job.
+// A worker thread claims at most as many jobs as threads are waiting,
+// so a buffered job is processed right away and its worker_alive_at
+// timestamp set by the claim doesn't become stale before
+// the heartbeat of the processing thread takes over.
+// Buffered jobs that were not processed when the worker threads stop
+// are returned to the queue with DataBase.UnclaimJobs.
+// A buffered job whose cancellation was requested
+// is stopped as cancelled without being run.
+//
+// A buffered job of a type that reached its SetMaxConcurrency limit
+// when a worker thread takes it is returned to the queue
+// with DataBase.UnclaimJobs.
+//
+// SetPrefetch must be called before StartThreads,
+// it returns an error if worker threads are running.
+func (p *Pool) SetPrefetch(n int) error {
+	if n < 0 {
+		return fmt.Errorf("negative prefetch %d", n)
+	}
+
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.SetPrefetch(%d) must be called before StartThreads, but %d worker thread(s) are running", n, p.numRunningThreads)
+	}
+
+	p.prefetchMtx.Lock()
+	p.prefetch = n
+	p.prefetchMtx.Unlock()
+	return nil
+}
+
+// startNextPrefetchedJob returns a buffered job or else claims
+// the next jobs that are not of one of skipJobTypes,
+// buffering the ones beyond the first
+// and signalling the waiting worker threads for them.
+func (p *Pool) startNextPrefetchedJob(ctx context.Context, claim *Claim, skipJobTypes ...string) (*jobqueue.Job, error) {
+	p.prefetchMtx.Lock()
+	if len(p.prefetched) > 0 {
+		job := p.prefetched[0]
+		p.prefetched = p.prefetched[1:]
+		p.prefetchMtx.Unlock()
+		return job, nil
+	}
+	// The waiting threads include the calling one
+	n := min(p.prefetch, int(p.numWaitingThreads.Load()))
+	p.prefetchMtx.Unlock()
+
+	if n <= 1 {
+		return p.claimJob(ctx, claim, skipJobTypes...)
+	}
+	jobs, err := p.claimJobs(ctx, claim, n, skipJobTypes...)
+	if len(jobs) == 0 {
+		return nil, err
+	}
+	if len(jobs) > 1 {
+		p.prefetchMtx.Lock()
+		p.prefetched = append(p.prefetched, jobs[1:]...)
+		p.prefetchMtx.Unlock()
+		for range len(jobs) - 1 {
+			p.onCheckJob()
+		}
+	}
+	return jobs[0], err
+}
+
+// unclaimPrefetchedJobs returns the buffered jobs to the queue.
+// It is called by every worker thread when it ends,
+// including the ones that buffered jobs,
+// so no buffered job is left behind.
+func (p *Pool) unclaimPrefetchedJobs(ctx context.Context) {
+	p.prefetchMtx.Lock()
+	jobs := p.prefetched
+	p.prefetched = nil
+	p.prefetchMtx.Unlock()
+
+	p.unclaimJobs(ctx, jobs)
+}
+
+// unclaimJobs returns claimed jobs that were not started
+// by a worker thread to the queue.
+func (p *Pool) unclaimJobs(ctx context.Context, jobs []*jobqueue.Job) {
+	if len(jobs) == 0 {
+		return
+	}
+	jobIDs := make(uu.IDs, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = job.ID
+		p.releaseClaimedJob(job.ID)
+	}
+	err := p.db.UnclaimJobs(context.WithoutCancel(ctx), jobIDs)
+	if err != nil {
+		p.onError(err)
+		log.ErrorCtx(ctx, "Error while unclaiming jobs").
+			Err(err).
+			Any("jobIDs", jobIDs).
+			Log()
+	}
+}
@@ -0,0 +1,111 @@
+package jobworker_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// batchClaimDataBase counts the jobs claimed with StartNextJobsOrNil.
+type batchClaimDataBase struct {
+	jobworker.DataBase
+	batchClaimed atomic.Int64
+}
+
+func (db *batchClaimDataBase) StartNextJobsOrNil(ctx context.Context, claim *jobworker.Claim, n int, skipJobTypes ...string) ([]*jobqueue.Job, error) {
+	jobs, err := db.DataBase.StartNextJobsOrNil(ctx, claim, n, skipJobTypes...)
+	db.batchClaimed.Add(int64(len(jobs)))
+	return jobs, err
+}
+
+func TestPrefetch(t *testing.T) {
+	const (
+		jobType    = "jobworker-test-prefetch"
+		numThreads = 4
+		numJobs    = 40
+	)
+	db := &batchClaimDataBase{DataBase: newTestDataBase(t)}
+	pool := jobworker.NewPool(db)
+	var (
+		doneMtx sync.Mutex
+		done    = make(map[uu.ID]int)
+	)
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		time.Sleep(time.Millisecond)
+		doneMtx.Lock()
+		done[job.ID]++
+		doneMtx.Unlock()
+		return nil, nil
+	})
+	assert.Error(t, pool.SetPrefetch(-1))
+	require.NoError(t, pool.SetPrefetch(numThreads))
+	startThreads(t, pool, numThreads)
+	assert.Error(t, pool.SetPrefetch(1), "threads running")
+
+	// Let all threads wait for a job, so that the
+	// first claim after AddJobs fills several of them
+	time.Sleep(50 * time.Millisecond)
+	jobs := make([]*jobqueue.Job, numJobs)
+	for i := range jobs {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+	}
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+
+	for _, job := range jobs {
+		waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	}
+	doneMtx.Lock()
+	defer doneMtx.Unlock()
+	assert.Len(t, done, numJobs)
+	for _, job := range jobs {
+		assert.Equal(t, 1, done[job.ID], "job %s done exactly once", job.ID)
+	}
+	assert.Positive(t, db.batchClaimed.Load(), "jobs claimed with StartNextJobsOrNil")
+}
+
+func TestCancelPrefetchedJob(t *testing.T) {
+	const (
+		jobType    = "jobworker-test-cancel-prefetched"
+		numThreads = 2
+	)
+	first := newTestJob(t, jobType, 1, nullable.Time{})
+	buffered := newTestJob(t, jobType, 0, nullable.Time{})
+	db := &claimHookDataBase{DataBase: newTestDataBase(t)}
+	db.afterClaim = func(job *jobqueue.Job) {
+		if job.ID == buffered.ID {
+			assert.NoError(t, db.CancelJob(context.Background(), job.ID))
+		}
+	}
+	batchDB := &batchClaimDataBase{DataBase: db}
+	pool := jobworker.NewPool(batchDB)
+	var ran sync.Map
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		ran.Store(job.ID, true)
+		return nil, nil
+	})
+	require.NoError(t, pool.SetPrefetch(numThreads))
+	startThreads(t, pool, numThreads)
+
+	// Let all threads wait for a job, so that
+	// the first claim after AddJobs prefetches
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, db.AddJobs(t.Context(), []*jobqueue.Job{first, buffered}))
+
+	loaded := waitForJob(t, db, first.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Succeeded())
+	loaded = waitForJob(t, db, buffered.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.Cancelled(), "cancelled instead of succeeded")
+	_, bufferedRan := ran.Load(buffered.ID)
+	assert.False(t, bufferedRan, "worker not called for the cancelled job")
+	assert.Equal(t, int64(2), batchDB.batchClaimed.Load(), "both jobs claimed with StartNextJobsOrNil")
+}
@@ -0,0 +1,75 @@
+package jobworker
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// RateLimit allows N jobs of a type to be started per window of the duration Per.
+type RateLimit struct {
+	N   int
+	Per time.Duration
+}
+
+// String implements the fmt.Stringer interface.
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%d per %s", r.N, r.Per)
+}
+
+// SetRateLimit limits the jobs of jobType that are started
+// across all worker processes sharing the database to n
+// per time window of the duration per for the default Pool,
+// see Pool.SetRateLimit.
+func SetRateLimit(jobType string, n int, per time.Duration) error {
+	return defaultPool.SetRateLimit(jobType, n, per)
+}
+
+// SetRateLimit limits the jobs of jobType that are started
+// across all worker processes sharing the database to n
+// per time window of the duration per.
+// Zero for n removes the limit.
+//
+// A window starts with the first job started after the previous window ended.
+// The windows are stored in the worker.rate_limit table which the DataBase
+// consults when it claims a job, so jobs of a type that reached its limit
+// stay unclaimed until the window ends. The worker threads wake up
+// at the end of the window if jobs of the type are available,
+// see DataBase.GetNextJobStartDelay.
+// Every Pool that has a worker registered for jobType
+// should set the same limit, a Pool only enforces its own limits.
+//
+// Rate limited job types are claimed like job types with a
+// SetClusterMaxConcurrency limit, one claim after the other
+// across all worker processes, see Claim.RateLimits.
+//
+// SetRateLimit must be called before StartThreads,
+// it returns an error if worker threads are running.
+func (p *Pool) SetRateLimit(jobType string, n int, per time.Duration) error {
+	if n < 0 {
+		return fmt.Errorf("negative rate limit %d for job type %#v", n, jobType)
+	}
+	if n > 0 && per < time.Millisecond {
+		return fmt.Errorf("rate limit window %s for job type %#v is shorter than a millisecond", per, jobType)
+	}
+
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.SetRateLimit(%#v) must be called before StartThreads, but %d worker thread(s) are running", jobType, p.numRunningThreads)
+	}
+
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	limits := maps.Clone(p.rateLimits)
+	if n == 0 {
+		delete(limits, jobType)
+	} else {
+		limits[jobType] = RateLimit{N: n, Per: per}
+	}
+	p.rateLimits = limits
+	// The limits are part of the Claim
+	p.invalidateWorkerTypesCacheLocked()
+	return nil
+}
@@ -0,0 +1,44 @@
+package jobworker_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestRateLimit(t *testing.T) {
+	const (
+		jobType = "jobworker-test-rate-limit"
+		window  = 300 * time.Millisecond
+	)
+	pool, db := newTestPool(t)
+	pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	require.NoError(t, pool.SetRateLimit(jobType, 1, window))
+	assert.Error(t, pool.SetRateLimit(jobType, 1, 0), "window too short")
+
+	jobs := make([]*jobqueue.Job, 3)
+	for i := range jobs {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+		require.NoError(t, db.AddJob(t.Context(), jobs[i]))
+	}
+
+	// No polling is started, the threads have to wake up when the window ends
+	startThreads(t, pool, 2)
+
+	var startedAt []time.Time
+	for _, job := range jobs {
+		loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+		startedAt = append(startedAt, loaded.StartedAt.Get())
+	}
+	slices.SortFunc(startedAt, time.Time.Compare)
+	for i := 1; i < len(startedAt); i++ {
+		assert.GreaterOrEqual(t, startedAt[i].Sub(startedAt[i-1]), window, "one job per window")
+	}
+}
@@ -28,9 +28,9 @@ type rfResult struct {
 // exercised directly without a database or worker threads.
 func getRegisteredWorker(t *testing.T, jobType string) WorkerFunc {
 	t.Helper()
-	workersMtx.RLock()
-	defer workersMtx.RUnlock()
-	w := workers[jobType]
+	defaultPool.workersMtx.RLock()
+	defer defaultPool.workersMtx.RUnlock()
+	w := defaultPool.workers[jobType]
 	require.NotNil(t, w, "a worker for %q must be registered", jobType)
 	return w
 }
@@ -151,11 +151,12 @@ func TestRegisterFuncRuntime(t *testing.T) {
 		assert.Equal(t, rfResult{Greeting: "z"}, result)
 	})
 
-	t.Run("payload that does not unmarshal returns an error", func(t *testing.T) {
+	t.Run("payload that does not unmarshal returns a permanent error", func(t *testing.T) {
 		resetWorkerRegistryState(t)
 		RegisterFuncForJobType("t", func(rfPayload) {})
 		// A JSON array cannot be unmarshalled into the struct argument.
 		_, err := getRegisteredWorker(t, "t")(t.Context(), &jobqueue.Job{Payload: notnull.JSON(`[1,2,3]`)})
 		require.Error(t, err)
+		assert.True(t, IsPermanent(err), "malformed payload is not retried")
 	})
 }
@@ -0,0 +1,137 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// Jitter randomizes the delays of ExponentialBackoff
+// so that jobs failing at the same time are not all retried at the same time.
+type Jitter int
+
+const (
+	// NoJitter uses the computed delay.
+	NoJitter Jitter = iota
+	// FullJitter uses a random delay between zero and the computed delay.
+	FullJitter
+	// EqualJitter uses half of the computed delay
+	// plus a random delay between zero and the other half.
+	EqualJitter
+)
+
+// String implements the fmt.Stringer interface.
+func (j Jitter) String() string {
+	switch j {
+	case NoJitter:
+		return "NoJitter"
+	case FullJitter:
+		return "FullJitter"
+	case EqualJitter:
+		return "EqualJitter"
+	}
+	return fmt.Sprintf("Jitter(%d)", int(j))
+}
+
+func (j Jitter) apply(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+	switch j {
+	case FullJitter:
+		return rand.N(delay + 1)
+	case EqualJitter:
+		half := delay / 2
+		return half + rand.N(delay-half+1)
+	}
+	return delay
+}
+
+// ConstantBackoff returns a ScheduleRetryFunc
+// that retries a failed job after delay.
+func ConstantBackoff(delay time.Duration) ScheduleRetryFunc {
+	return func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
+		return time.Now().Add(delay), nil
+	}
+}
+
+// LinearBackoff returns a ScheduleRetryFunc that retries a failed job
+// after initial plus step for every previous retry, at most after maxDelay.
+// A zero maxDelay means no maximum.
+func LinearBackoff(initial, step, maxDelay time.Duration) ScheduleRetryFunc {
+	return func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
+		delay := initial + time.Duration(job.CurrentRetryCount)*step
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+		return time.Now().Add(delay), nil
+	}
+}
+
+// ExponentialBackoff returns a ScheduleRetryFunc that retries a failed job
+// after initial doubled for every previous retry, at most after maxDelay,
+// randomized by jitter. A zero maxDelay means no maximum.
+func ExponentialBackoff(initial, maxDelay time.Duration, jitter Jitter) ScheduleRetryFunc {
+	return func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
+		delay := initial
+		for range job.CurrentRetryCount {
+			if maxDelay > 0 && delay >= maxDelay {
+				break
+			}
+			if delay > math.MaxInt64/2 {
+				// Doubling would overflow
+				break
+			}
+			delay *= 2
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+		return time.Now().Add(jitter.apply(delay)), nil
+	}
+}
+
+// RetryAfterer is implemented by errors that know how long to wait
+// before the failed operation can be retried,
+// for example because of the Retry-After header of an HTTP response.
+// See WithRetryAfter and HonorRetryAfter.
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// WithRetryAfter wraps err with a RetryAfterer returning after.
+// It returns nil if err is nil.
+func WithRetryAfter(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, after: after}
+}
+
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string             { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error             { return e.err }
+func (e *retryAfterError) RetryAfter() time.Duration { return e.after }
+
+// HonorRetryAfter returns a ScheduleRetryFunc that retries a failed job
+// after the duration of the RetryAfterer in the chain of the error
+// returned by the worker, see JobErrorFromContext,
+// or uses fallback if there is none.
+func HonorRetryAfter(fallback ScheduleRetryFunc) ScheduleRetryFunc {
+	return func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
+		var retryAfter RetryAfterer
+		if errors.As(JobErrorFromContext(ctx), &retryAfter) {
+			return time.Now().Add(max(retryAfter.RetryAfter(), 0)), nil
+		}
+		return fallback(ctx, job)
+	}
+}
@@ -0,0 +1,102 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// retryDelay returns the delay until the next start computed by scheduleFunc
+// for a job with currentRetryCount, rounded to milliseconds.
+func retryDelay(t *testing.T, ctx context.Context, scheduleFunc ScheduleRetryFunc, currentRetryCount int) time.Duration {
+	t.Helper()
+	before := time.Now()
+	nextStart, err := scheduleFunc(ctx, &jobqueue.Job{CurrentRetryCount: currentRetryCount})
+	require.NoError(t, err)
+	return nextStart.Sub(before).Round(time.Millisecond)
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		scheduleFunc ScheduleRetryFunc
+		want         []time.Duration // delay per CurrentRetryCount
+	}{
+		{
+			name:         "constant",
+			scheduleFunc: ConstantBackoff(time.Minute),
+			want:         []time.Duration{time.Minute, time.Minute, time.Minute},
+		},
+		{
+			name:         "linear",
+			scheduleFunc: LinearBackoff(time.Minute, 2*time.Minute, 4*time.Minute),
+			want:         []time.Duration{time.Minute, 3 * time.Minute, 4 * time.Minute, 4 * time.Minute},
+		},
+		{
+			name:         "exponential",
+			scheduleFunc: ExponentialBackoff(time.Second, 5*time.Second, NoJitter),
+			want:         []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:         "exponential without maximum",
+			scheduleFunc: ExponentialBackoff(time.Second, 0, NoJitter),
+			want:         []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for retryCount, want := range tc.want {
+				assert.Equal(t, want, retryDelay(t, t.Context(), tc.scheduleFunc, retryCount), "retry count %d", retryCount)
+			}
+		})
+	}
+
+	t.Run("exponential does not overflow", func(t *testing.T) {
+		assert.Positive(t, retryDelay(t, t.Context(), ExponentialBackoff(time.Second, 0, NoJitter), 1000))
+	})
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		delay := retryDelay(t, t.Context(), ExponentialBackoff(time.Second, time.Minute, FullJitter), 2)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, 4*time.Second)
+
+		delay = retryDelay(t, t.Context(), ExponentialBackoff(time.Second, time.Minute, EqualJitter), 2)
+		assert.GreaterOrEqual(t, delay, 2*time.Second)
+		assert.LessOrEqual(t, delay, 4*time.Second)
+	}
+}
+
+func TestHonorRetryAfter(t *testing.T) {
+	scheduleFunc := HonorRetryAfter(ConstantBackoff(time.Minute))
+
+	err := fmt.Errorf("wrapped: %w", WithRetryAfter(errors.New("429 Too Many Requests"), 30*time.Second))
+	assert.Equal(t, 30*time.Second, retryDelay(t, contextWithJobError(t.Context(), err), scheduleFunc, 0))
+
+	err = errors.New("no Retry-After")
+	assert.Equal(t, time.Minute, retryDelay(t, contextWithJobError(t.Context(), err), scheduleFunc, 0), "fallback")
+	assert.Equal(t, time.Minute, retryDelay(t, t.Context(), scheduleFunc, 0), "fallback without error")
+
+	assert.NoError(t, WithRetryAfter(nil, time.Second))
+}
+
+func TestDefaultScheduleRetry(t *testing.T) {
+	p := newTestPool()
+	registered := ConstantBackoff(time.Second)
+	p.RegisterScheduleRetry("registered", registered)
+	assert.Nil(t, p.retrySchedulerOrNil("other"))
+
+	p.SetDefaultScheduleRetry(ConstantBackoff(time.Hour))
+	assert.Equal(t, time.Second, retryDelay(t, t.Context(), p.retrySchedulerOrNil("registered"), 0))
+	assert.Equal(t, time.Hour, retryDelay(t, t.Context(), p.retrySchedulerOrNil("other"), 0))
+
+	p.SetDefaultScheduleRetry(nil)
+	assert.Nil(t, p.retrySchedulerOrNil("other"))
+}
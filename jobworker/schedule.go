@@ -0,0 +1,321 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/notnull"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/cron"
+)
+
+// ScheduleErrorRetryInterval is how long the scheduler waits
+// before it tries again to add the occurrences of a schedule
+// after an error. Default is one minute.
+var ScheduleErrorRetryInterval = time.Minute
+
+// scheduleLateTolerance is how late an occurrence can be added
+// to the queue before it counts as missed and the
+// jobqueue.ScheduleCatchUp policy of its schedule applies.
+const scheduleLateTolerance = time.Minute
+
+// schedule is a schedule registered with RegisterSchedule.
+// It is not changed after registration.
+type schedule struct {
+	jobqueue.JobSchedule
+
+	expr     *cron.Expression
+	location *time.Location
+
+	jobType  string
+	origin   string
+	payload  notnull.JSON
+	priority int64
+}
+
+// RegisterSchedule registers a recurring job with the default Pool,
+// see Pool.RegisterSchedule.
+func RegisterSchedule(name, cronExpr, timezone string, desc jobqueue.JobDesc, catchUp ...jobqueue.ScheduleCatchUp) error {
+	return defaultPool.RegisterSchedule(name, cronExpr, timezone, desc, catchUp...)
+}
+
+// RegisterSchedule registers a recurring job described by desc that is added
+// to the queue for every occurrence of cronExpr interpreted in the IANA
+// time zone timezone, see the cron package for the syntax of cronExpr.
+// An empty timezone means UTC.
+//
+// If desc.Type is empty, ReflectJobTypeOfPayload(desc.Payload) will be used,
+// and if desc.Origin is empty, the name of the schedule will be used.
+// desc.ID and desc.DependsOn must not be set
+// because every occurrence is a new job without dependencies.
+//
+// The optional catchUp policy defines which occurrences are run that were
+// missed because no worker process was running, the default is
+// jobqueue.ScheduleCatchUpOnce. The occurrence that was added to the queue
+// before the downtime always runs, see jobqueue.ScheduleCatchUp.
+//
+// The schedule is stored in the worker.job_schedule table under its name
+// when worker threads are started with StartThreads. The started threads
+// add the next occurrence of every registered schedule as a normal job
+// with a start_at of its fire time in advance. Several worker processes
+// can register the same schedule, an occurrence is only added once.
+// Changing the cron expression or time zone of an already stored schedule
+// replaces its next occurrence if it was already added.
+//
+// RegisterSchedule must be called before StartThreads,
+// it returns an error if worker threads are running.
+func (p *Pool) RegisterSchedule(name, cronExpr, timezone string, desc jobqueue.JobDesc, catchUp ...jobqueue.ScheduleCatchUp) (err error) {
+	defer errs.WrapWithFuncParams(&err, name, cronExpr, timezone, desc, catchUp)
+
+	if l := len(name); l == 0 || l > 100 {
+		return fmt.Errorf("schedule name length %d not in range [1, 100]", l)
+	}
+	expr, err := cron.Parse(cronExpr)
+	if err != nil {
+		return err
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+	if desc.ID.IsNotNil() {
+		return errors.New("JobDesc.ID must not be set for a schedule")
+	}
+	if len(desc.DependsOn) > 0 {
+		return errors.New("JobDesc.DependsOn must not be set for a schedule")
+	}
+	var c jobqueue.ScheduleCatchUp
+	if len(catchUp) > 0 {
+		c = catchUp[0]
+	}
+	err = c.Validate()
+	if err != nil {
+		return err
+	}
+
+	jobType := desc.Type
+	if jobType == "" {
+		jobType = jobqueue.ReflectJobTypeOfPayload(desc.Payload)
+	}
+	origin := desc.Origin
+	if origin == "" {
+		origin = name
+	}
+	// Validates and marshals the payload once for all occurrences
+	job, err := jobqueue.NewJobWithPriority(uu.IDNil, jobType, origin, desc.Payload, desc.Priority, nullable.Time{})
+	if err != nil {
+		return err
+	}
+
+	s := &schedule{
+		JobSchedule: jobqueue.JobSchedule{
+			Name:     name,
+			CronExpr: cronExpr,
+			Timezone: timezone,
+			CatchUp:  c.OrDefault(),
+		},
+		expr:     expr,
+		location: location,
+		jobType:  jobType,
+		origin:   origin,
+		payload:  job.Payload,
+		priority: desc.Priority,
+	}
+
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.RegisterSchedule(%#v) must be called before StartThreads, but %d worker thread(s) are running", name, p.numRunningThreads)
+	}
+
+	p.schedulesMtx.Lock()
+	defer p.schedulesMtx.Unlock()
+
+	if _, exists := p.schedules[name]; exists {
+		return fmt.Errorf("a schedule with the name %#v has already been registered", name)
+	}
+	p.schedules[name] = s
+	return nil
+}
+
+// UnregisterSchedule removes a schedule registered with RegisterSchedule
+// from the default Pool, see Pool.UnregisterSchedule.
+func UnregisterSchedule(name string) error {
+	return defaultPool.UnregisterSchedule(name)
+}
+
+// UnregisterSchedule removes a schedule registered with RegisterSchedule
+// so that worker threads started afterwards don't add its occurrences.
+// The stored schedule and its already added occurrences are not deleted,
+// use DataBase.DeleteJobSchedule for that.
+//
+// UnregisterSchedule must be called while no worker threads are running,
+// it returns an error otherwise.
+func (p *Pool) UnregisterSchedule(name string) error {
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		return fmt.Errorf("jobworker.UnregisterSchedule(%#v) must not be called while %d worker thread(s) are running", name, p.numRunningThreads)
+	}
+
+	p.schedulesMtx.Lock()
+	defer p.schedulesMtx.Unlock()
+
+	delete(p.schedules, name)
+	return nil
+}
+
+func (p *Pool) registeredSchedules() []*schedule {
+	p.schedulesMtx.RLock()
+	defer p.schedulesMtx.RUnlock()
+
+	registered := make([]*schedule, 0, len(p.schedules))
+	for _, s := range p.schedules {
+		registered = append(registered, s)
+	}
+	return registered
+}
+
+// newJob returns the job for the occurrence of s at fireTime.
+func (s *schedule) newJob(ctx context.Context, fireTime time.Time) *jobqueue.Job {
+	now := time.Now()
+	return &jobqueue.Job{
+		ID:               uu.NewID(ctx),
+		Type:             s.jobType,
+		Payload:          s.payload,
+		Priority:         s.priority,
+		Origin:           s.origin,
+		StartAt:          nullable.TimeFrom(fireTime),
+		ScheduleName:     nullable.NonEmptyString(s.Name),
+		ScheduleFireTime: nullable.TimeFrom(fireTime),
+		UpdatedAt:        now,
+		CreatedAt:        now,
+	}
+}
+
+// scheduleFireTimes returns the fire times of expr that have to be added
+// to the queue at now, given lastFireTime as the latest fire time
+// that was already added.
+//
+// If the fire time that was added last is still in the future, nothing has
+// to be added. Else the missed occurrences are selected by catchUp,
+// occurrences that are less than scheduleLateTolerance late are not missed
+// but always added, and the next occurrence after now is added in advance.
+func scheduleFireTimes(expr *cron.Expression, lastFireTime nullable.Time, now time.Time, catchUp jobqueue.ScheduleCatchUp) []time.Time {
+	if lastFireTime.IsNotNull() && lastFireTime.Get().After(now) {
+		return nil
+	}
+
+	var fireTimes []time.Time
+	if lastFireTime.IsNotNull() {
+		from := lastFireTime.Get().In(now.Location())
+		missedUntil := now.Add(-scheduleLateTolerance)
+		lateFrom := missedUntil
+		if from.After(lateFrom) {
+			lateFrom = from
+		}
+		late := expr.Between(lateFrom, now, jobqueue.MaxScheduleCatchUpRuns)
+		// Only calculate the missed occurrences that are added
+		switch catchUp.OrDefault() {
+		case jobqueue.ScheduleCatchUpOnce:
+			if len(late) == 0 {
+				fireTimes = expr.Between(from, missedUntil, 1)
+			}
+		case jobqueue.ScheduleCatchUpAll:
+			fireTimes = expr.Between(from, missedUntil, jobqueue.MaxScheduleCatchUpRuns-len(late))
+		}
+		fireTimes = append(fireTimes, late...)
+	}
+	if next := expr.Next(now); !next.IsZero() {
+		fireTimes = append(fireTimes, next)
+	}
+	return fireTimes
+}
+
+// addScheduledJobs adds the occurrences of s that are due at now
+// to the queue. stored is the worker.job_schedule row of s
+// or nil if it has to be saved first.
+// Returns the updated row.
+func (p *Pool) addScheduledJobs(ctx context.Context, s *schedule, stored *jobqueue.JobSchedule, now time.Time) (*jobqueue.JobSchedule, error) {
+	if stored == nil {
+		var err error
+		stored, err = p.db.SaveJobSchedule(ctx, &s.JobSchedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fireTimes := scheduleFireTimes(s.expr, stored.LastFireTime, now.In(s.location), s.CatchUp)
+	if len(fireTimes) == 0 {
+		return stored, nil
+	}
+	jobs := make([]*jobqueue.Job, len(fireTimes))
+	for i, fireTime := range fireTimes {
+		jobs[i] = s.newJob(ctx, fireTime)
+	}
+	log.DebugCtx(ctx, "Adding scheduled jobs").
+		Str("schedule", s.Name).
+		Int("numJobs", len(jobs)).
+		Log()
+	return p.db.AddScheduledJobs(ctx, s.Name, jobs)
+}
+
+// runScheduler adds the occurrences of the registered schedules to the queue
+// until stop is closed or ctx is cancelled. It sleeps until the earliest
+// fire time that was added in advance, because the next occurrence
+// of that schedule has to be added when it fires.
+func (p *Pool) runScheduler(ctx context.Context, registered []*schedule, stop <-chan struct{}) {
+	defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter())
+
+	stored := make(map[*schedule]*jobqueue.JobSchedule, len(registered))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		now := time.Now()
+		var wakeUp time.Time
+		for _, s := range registered {
+			row, err := p.addScheduledJobs(ctx, s, stored[s], now)
+			if err != nil {
+				p.onError(err)
+				log.ErrorCtx(ctx, "Error while adding scheduled jobs").
+					Str("schedule", s.Name).
+					Err(err).
+					Log()
+				// Save the schedule again in case it was deleted
+				delete(stored, s)
+				if retry := now.Add(ScheduleErrorRetryInterval); wakeUp.IsZero() || retry.Before(wakeUp) {
+					wakeUp = retry
+				}
+				continue
+			}
+			stored[s] = row
+			if row.LastFireTime.IsNotNull() && row.LastFireTime.Get().After(now) {
+				if next := row.LastFireTime.Get(); wakeUp.IsZero() || next.Before(wakeUp) {
+					wakeUp = next
+				}
+			}
+		}
+		if !wakeUp.IsZero() {
+			timer.Reset(time.Until(wakeUp))
+		}
+	}
+}
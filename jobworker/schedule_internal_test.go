@@ -0,0 +1,132 @@
+package jobworker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/cron"
+)
+
+func TestScheduleFireTimes(t *testing.T) {
+	hourly := cron.MustParse("0 * * * *")
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	last := func(hour, minute int) nullable.Time {
+		return nullable.TimeFrom(at(hour, minute))
+	}
+
+	tests := []struct {
+		name         string
+		lastFireTime nullable.Time
+		now          time.Time
+		catchUp      jobqueue.ScheduleCatchUp
+		want         []time.Time
+	}{
+		{"first occurrence", nullable.Time{}, at(10, 30), "", []time.Time{at(11, 0)}},
+		{"next already added", last(11, 0), at(10, 30), "", nil},
+		{"added occurrence fired", last(11, 0), at(11, 0), "", []time.Time{at(12, 0)}},
+		{"late occurrence", last(11, 0), at(12, 0).Add(30 * time.Second), jobqueue.ScheduleCatchUpSkip, []time.Time{at(12, 0), at(13, 0)}},
+		{"skip missed", last(11, 0), at(14, 30), jobqueue.ScheduleCatchUpSkip, []time.Time{at(15, 0)}},
+		{"once missed", last(11, 0), at(14, 30), jobqueue.ScheduleCatchUpOnce, []time.Time{at(14, 0), at(15, 0)}},
+		{"once is default", last(11, 0), at(14, 30), "", []time.Time{at(14, 0), at(15, 0)}},
+		{"all missed", last(11, 0), at(14, 30), jobqueue.ScheduleCatchUpAll, []time.Time{at(12, 0), at(13, 0), at(14, 0), at(15, 0)}},
+		{"once with late", last(11, 0), at(14, 0).Add(time.Second), jobqueue.ScheduleCatchUpOnce, []time.Time{at(14, 0), at(15, 0)}},
+		{"all with late", last(11, 0), at(14, 0).Add(time.Second), jobqueue.ScheduleCatchUpAll, []time.Time{at(12, 0), at(13, 0), at(14, 0), at(15, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scheduleFireTimes(hourly, tt.lastFireTime, tt.now, tt.catchUp))
+		})
+	}
+
+	t.Run("all is limited", func(t *testing.T) {
+		everyMinute := cron.MustParse("* * * * *")
+		now := at(0, 0).AddDate(0, 0, 7)
+		fireTimes := scheduleFireTimes(everyMinute, last(0, 0), now, jobqueue.ScheduleCatchUpAll)
+		require.Len(t, fireTimes, jobqueue.MaxScheduleCatchUpRuns+1)
+		assert.Equal(t, now, fireTimes[len(fireTimes)-2], "latest missed occurrences")
+		assert.Equal(t, now.Add(time.Minute), fireTimes[len(fireTimes)-1])
+	})
+
+	t.Run("once after years", func(t *testing.T) {
+		everyMinute := cron.MustParse("* * * * *")
+		now := at(0, 0).AddDate(50, 0, 0).Add(30 * time.Second)
+		fireTimes := scheduleFireTimes(everyMinute, last(0, 0), now, jobqueue.ScheduleCatchUpOnce)
+		assert.Equal(t, []time.Time{now.Add(-30 * time.Second), now.Add(30 * time.Second)}, fireTimes, "late occurrence and next one")
+	})
+}
+
+// resetScheduleRegistryState clears the schedule registry of the default Pool
+// so each test starts from a known state, and restores it on cleanup.
+func resetScheduleRegistryState(t *testing.T) {
+	t.Helper()
+	clearRegistry := func() {
+		defaultPool.schedulesMtx.Lock()
+		clear(defaultPool.schedules)
+		defaultPool.schedulesMtx.Unlock()
+	}
+	clearRegistry()
+	t.Cleanup(clearRegistry)
+}
+
+func TestRegisterSchedule(t *testing.T) {
+	resetScheduleRegistryState(t)
+
+	desc := jobqueue.JobDesc{Type: "nightly-report", Payload: map[string]int{"days": 1}}
+	require.NoError(t, RegisterSchedule("nightly", "0 3 * * *", "Europe/Vienna", desc, jobqueue.ScheduleCatchUpSkip))
+	assert.Error(t, RegisterSchedule("nightly", "0 3 * * *", "Europe/Vienna", desc), "duplicate name")
+
+	registered := defaultPool.registeredSchedules()
+	require.Len(t, registered, 1)
+	s := registered[0]
+	assert.Equal(t, "nightly", s.Name)
+	assert.Equal(t, "Europe/Vienna", s.Timezone)
+	assert.Equal(t, jobqueue.ScheduleCatchUpSkip, s.CatchUp)
+	assert.Equal(t, "nightly", s.origin, "origin defaults to the name")
+
+	fireTime := time.Date(2024, 1, 1, 3, 0, 0, 0, s.location)
+	job := s.newJob(t.Context(), fireTime)
+	assert.Equal(t, "nightly-report", job.Type)
+	assert.JSONEq(t, `{"days":1}`, job.Payload.String())
+	assert.Equal(t, fireTime, job.StartAt.Get())
+	assert.Equal(t, fireTime, job.ScheduleFireTime.Get())
+	assert.Equal(t, "nightly", job.ScheduleName.Get())
+
+	require.NoError(t, RegisterSchedule("utc", "@hourly", "", desc))
+	assert.Equal(t, "UTC", registeredScheduleByName(t, "utc").Timezone, "empty timezone means UTC")
+
+	require.NoError(t, UnregisterSchedule("nightly"))
+	require.NoError(t, RegisterSchedule("nightly", "0 4 * * *", "Europe/Vienna", desc), "name free again")
+
+	for name, register := range map[string]func() error{
+		"empty name":       func() error { return RegisterSchedule("", "@daily", "", desc) },
+		"invalid cron":     func() error { return RegisterSchedule("invalid", "0 25 * * *", "", desc) },
+		"invalid timezone": func() error { return RegisterSchedule("invalid", "@daily", "Mars/Olympus", desc) },
+		"invalid catch-up": func() error { return RegisterSchedule("invalid", "@daily", "", desc, "latest") },
+		"nil payload":      func() error { return RegisterSchedule("invalid", "@daily", "", jobqueue.JobDesc{Type: "x"}) },
+		"ID set": func() error {
+			return RegisterSchedule("invalid", "@daily", "", jobqueue.JobDesc{Type: "x", Payload: 1, ID: uu.IDFrom("0a5b5d0f-7c6e-4d44-8b5f-3b0f6c1b2a11")})
+		},
+		"DependsOn set": func() error {
+			return RegisterSchedule("invalid", "@daily", "", jobqueue.JobDesc{Type: "x", Payload: 1, DependsOn: uu.IDs{uu.IDFrom("0a5b5d0f-7c6e-4d44-8b5f-3b0f6c1b2a11")}})
+		},
+	} {
+		assert.Error(t, register(), name)
+	}
+}
+
+func registeredScheduleByName(t *testing.T, name string) *schedule {
+	t.Helper()
+	defaultPool.schedulesMtx.RLock()
+	defer defaultPool.schedulesMtx.RUnlock()
+	s := defaultPool.schedules[name]
+	require.NotNil(t, s, "schedule %q", name)
+	return s
+}
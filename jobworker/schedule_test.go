@@ -0,0 +1,36 @@
+package jobworker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestScheduledJobs(t *testing.T) {
+	const jobType = "jobworker-test-schedule"
+	pool, db := newTestPool(t)
+	pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	require.NoError(t, pool.RegisterSchedule(jobType, "* * * * *", "", jobqueue.JobDesc{Type: jobType, Payload: "{}"}))
+	startThreads(t, pool, 1)
+
+	// The next occurrence is added in advance
+	var jobs []*jobqueue.Job
+	require.Eventually(t, func() bool {
+		var err error
+		jobs, err = db.GetAllJobsToDo(t.Context())
+		return err == nil && len(jobs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	job := jobs[0]
+	assert.Equal(t, jobType, job.Type)
+	assert.Equal(t, jobType, job.Origin)
+	assert.Equal(t, jobType, job.ScheduleName.Get())
+	assert.True(t, job.ScheduleFireTime.Get().After(time.Now()))
+	assert.True(t, job.ScheduleFireTime.Get().Equal(job.StartAt.Get()))
+	assert.Zero(t, job.ScheduleFireTime.Get().Second(), "fire time is a full minute")
+}
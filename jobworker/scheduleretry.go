@@ -9,18 +9,61 @@ import (
 )
 
 // ScheduleRetryFunc computes the next start time for a failed job that is going
-// to be retried. It is registered per job type with RegisterScheduleRetry.
+// to be retried. It is registered per job type with RegisterScheduleRetry
+// or for all other job types with SetDefaultScheduleRetry.
+// The error returned by the worker is available with JobErrorFromContext.
+//
+// See ConstantBackoff, LinearBackoff, ExponentialBackoff, and HonorRetryAfter.
 type ScheduleRetryFunc func(ctx context.Context, job *jobqueue.Job) (nextStart time.Time, err error)
 
+// RegisterScheduleRetry registers a ScheduleRetryFunc for the given job type
+// with the default Pool, see Pool.RegisterScheduleRetry.
+func RegisterScheduleRetry(jobType JobType, scheduleFunc ScheduleRetryFunc) {
+	defaultPool.RegisterScheduleRetry(jobType, scheduleFunc)
+}
+
 // RegisterScheduleRetry registers a ScheduleRetryFunc for the given job type.
 // It panics if a retry scheduler has already been registered for that type.
-func RegisterScheduleRetry(jobType JobType, scheduleFunc ScheduleRetryFunc) {
-	retrySchedulersMtx.Lock()
-	defer retrySchedulersMtx.Unlock()
+func (p *Pool) RegisterScheduleRetry(jobType JobType, scheduleFunc ScheduleRetryFunc) {
+	p.retrySchedulersMtx.Lock()
+	defer p.retrySchedulersMtx.Unlock()
 
-	if _, exists := retrySchedulers[jobType]; exists {
+	if _, exists := p.retrySchedulers[jobType]; exists {
 		panic(fmt.Errorf("retry scheduler for job type '%s' already exists", jobType))
 	}
 
-	retrySchedulers[jobType] = scheduleFunc
+	p.retrySchedulers[jobType] = scheduleFunc
+}
+
+// SetDefaultScheduleRetry sets the ScheduleRetryFunc of the default Pool
+// for job types without a registered one, see Pool.SetDefaultScheduleRetry.
+func SetDefaultScheduleRetry(scheduleFunc ScheduleRetryFunc) {
+	defaultPool.SetDefaultScheduleRetry(scheduleFunc)
+}
+
+// SetDefaultScheduleRetry sets the ScheduleRetryFunc used for the retries
+// of job types without one registered with RegisterScheduleRetry.
+// Passing nil removes it, then a failed job of such a type
+// is not retried and stopped with its error.
+//
+// Example:
+//
+//	pool.SetDefaultScheduleRetry(jobworker.ExponentialBackoff(time.Second, time.Hour, jobworker.FullJitter))
+func (p *Pool) SetDefaultScheduleRetry(scheduleFunc ScheduleRetryFunc) {
+	p.retrySchedulersMtx.Lock()
+	defer p.retrySchedulersMtx.Unlock()
+
+	p.defaultRetryScheduler = scheduleFunc
+}
+
+// retrySchedulerOrNil returns the ScheduleRetryFunc registered for jobType,
+// the default one, or nil.
+func (p *Pool) retrySchedulerOrNil(jobType JobType) ScheduleRetryFunc {
+	p.retrySchedulersMtx.RLock()
+	defer p.retrySchedulersMtx.RUnlock()
+
+	if scheduleFunc, ok := p.retrySchedulers[jobType]; ok {
+		return scheduleFunc
+	}
+	return p.defaultRetryScheduler
 }
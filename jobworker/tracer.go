@@ -0,0 +1,128 @@
+package jobworker
+
+import (
+	"context"
+	"slices"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// Tracer starts the spans covering the execution of jobs by DoJob
+// and of the batches of RegisterBatch workers,
+// so that the trace of a job continues the trace of the code that added it.
+// Implement it with a tracing library like OpenTelemetry
+// and set it with SetTracer.
+type Tracer interface {
+	// StartJobSpan starts a span with name and attributes as child of ctx
+	// and returns a context with the span and a function that ends it.
+	// links are the jobqueue.TraceContexts of the spans that added the jobs,
+	// which should be added as links of the new span: one for a traced job,
+	// none for a job that was added without trace context,
+	// and one per distinct trace context of the jobs of a batch.
+	// end is called with the error of the job or batch or nil if it succeeded.
+	StartJobSpan(ctx context.Context, name string, links []jobqueue.TraceContext, attributes []SpanAttribute) (spanCtx context.Context, end func(err error))
+}
+
+// SpanAttribute is an attribute of a span started by a Tracer.
+type SpanAttribute struct {
+	Key   string
+	Value any
+}
+
+// Keys of the SpanAttributes of the span of a job.
+const (
+	// SpanAttributeJobID is the key of the job ID as string.
+	SpanAttributeJobID = "job.id"
+	// SpanAttributeJobType is the key of the job type.
+	SpanAttributeJobType = "job.type"
+	// SpanAttributeJobAttempt is the key of the number of the attempt
+	// as int, starting at 1 and counting the retries.
+	SpanAttributeJobAttempt = "job.attempt"
+	// SpanAttributeJobOrigin is the key of the job origin.
+	SpanAttributeJobOrigin = "job.origin"
+	// SpanAttributeJobBatchSize is the key of the number of jobs
+	// of a batch as int.
+	SpanAttributeJobBatchSize = "job.batch.size"
+)
+
+// SetTracer sets the Tracer of the default Pool, see Pool.SetTracer.
+func SetTracer(tracer Tracer) {
+	defaultPool.SetTracer(tracer)
+}
+
+// SetTracer sets the Tracer that DoJob uses to start a span for every job
+// named "job " plus the job type with the jobqueue.TraceContext
+// of the job as link and the attributes SpanAttributeJobID,
+// SpanAttributeJobType, SpanAttributeJobAttempt, and SpanAttributeJobOrigin.
+// The span covers the Middleware and the worker.
+// A nil tracer disables the spans, which is the default.
+//
+// A batch of a RegisterBatch worker run by the worker threads
+// is covered by one span named "job batch " plus the job type
+// with the distinct trace contexts of its jobs as links
+// and the attributes SpanAttributeJobType and SpanAttributeJobBatchSize.
+//
+// Independent of the Tracer, DoJob adds the jobqueue.TraceContext of the job
+// to the context of the worker with jobqueue.ContextWithTraceContext,
+// so the jobs added by the worker continue the trace
+// if jobqueue.ExtractTraceContext doesn't return the span of the Tracer.
+// The context of a batch worker gets the trace context
+// if all traced jobs of the batch have the same.
+func (p *Pool) SetTracer(tracer Tracer) {
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	p.tracer = tracer
+}
+
+func (p *Pool) getTracer() Tracer {
+	p.workersMtx.RLock()
+	defer p.workersMtx.RUnlock()
+
+	return p.tracer
+}
+
+// startJobSpan adds the TraceContext of job to ctx and starts
+// its span if the Pool has a Tracer, else end is nil.
+func (p *Pool) startJobSpan(ctx context.Context, job *jobqueue.Job) (jobCtx context.Context, end func(err error)) {
+	if !job.TraceContext.IsZero() {
+		ctx = jobqueue.ContextWithTraceContext(ctx, job.TraceContext)
+	}
+	tracer := p.getTracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	var links []jobqueue.TraceContext
+	if !job.TraceContext.IsZero() {
+		links = []jobqueue.TraceContext{job.TraceContext}
+	}
+	return tracer.StartJobSpan(ctx, "job "+job.Type, links, []SpanAttribute{
+		{Key: SpanAttributeJobID, Value: job.ID.String()},
+		{Key: SpanAttributeJobType, Value: job.Type},
+		{Key: SpanAttributeJobAttempt, Value: job.CurrentRetryCount + 1},
+		{Key: SpanAttributeJobOrigin, Value: job.Origin},
+	})
+}
+
+// startBatchSpan adds the TraceContext shared by the traced jobs
+// of a batch to ctx and starts the span of the batch linked
+// to the TraceContexts of all jobs if the Pool has a Tracer, else end is nil.
+func (p *Pool) startBatchSpan(ctx context.Context, jobs []*jobqueue.Job) (batchCtx context.Context, end func(err error)) {
+	var links []jobqueue.TraceContext
+	for _, job := range jobs {
+		if !job.TraceContext.IsZero() && !slices.Contains(links, job.TraceContext) {
+			links = append(links, job.TraceContext)
+		}
+	}
+	if len(links) == 1 {
+		ctx = jobqueue.ContextWithTraceContext(ctx, links[0])
+	}
+	tracer := p.getTracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.StartJobSpan(ctx, "job batch "+jobs[0].Type, links, []SpanAttribute{
+		{Key: SpanAttributeJobType, Value: jobs[0].Type},
+		{Key: SpanAttributeJobBatchSize, Value: len(jobs)},
+	})
+}
@@ -0,0 +1,170 @@
+package jobworker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// recordingTracer is an in-process jobworker.Tracer
+// exporting the ended spans to a slice.
+type recordingTracer struct {
+	mtx      sync.Mutex
+	lastSpan uint64
+	exported []*recordedSpan
+}
+
+type recordedSpan struct {
+	name       string
+	links      []jobqueue.TraceContext
+	attributes []jobworker.SpanAttribute
+	traceCtx   jobqueue.TraceContext
+	err        error
+}
+
+func (tr *recordingTracer) StartJobSpan(ctx context.Context, name string, links []jobqueue.TraceContext, attributes []jobworker.SpanAttribute) (context.Context, func(error)) {
+	tr.mtx.Lock()
+	tr.lastSpan++
+	spanID := tr.lastSpan
+	tr.mtx.Unlock()
+
+	span := &recordedSpan{
+		name:       name,
+		links:      links,
+		attributes: attributes,
+		traceCtx:   jobqueue.TraceContext{TraceParent: fmt.Sprintf("00-0af7651916cd43dd8448eb211c80319c-%016x-01", spanID)},
+	}
+	return jobqueue.ContextWithTraceContext(ctx, span.traceCtx), func(err error) {
+		span.err = err
+		tr.mtx.Lock()
+		defer tr.mtx.Unlock()
+		tr.exported = append(tr.exported, span)
+	}
+}
+
+func (tr *recordingTracer) spans() []*recordedSpan {
+	tr.mtx.Lock()
+	defer tr.mtx.Unlock()
+	return slices.Clone(tr.exported)
+}
+
+func TestTraceContext(t *testing.T) {
+	const (
+		parentType = "jobworker-test-trace-parent"
+		childType  = "jobworker-test-trace-child"
+	)
+	tracer := new(recordingTracer)
+	pool, db := newTestPool(t)
+	pool.SetTracer(tracer)
+	pool.Register(parentType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		// Continues the trace of the span of the job
+		return nil, jobqueue.Add(ctx, newTestJob(t, childType, 0, nullable.Time{}))
+	})
+	pool.Register(childType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return nil, jobworker.Permanent(errors.New("child failed"))
+	})
+	var workerTraceCtx jobqueue.TraceContext
+	pool.Register("jobworker-test-trace-do-job", func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		workerTraceCtx = jobqueue.TraceContextFromContext(ctx)
+		return nil, nil
+	})
+	// The parent job adds its child with the Service of the context
+	ctx := jobqueue.ContextWithService(t.Context(), db)
+	require.NoError(t, pool.StartThreads(ctx, 1))
+	t.Cleanup(func() { pool.FinishThreads(context.Background()) })
+
+	producer := jobqueue.TraceContext{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	parent := newTestJob(t, parentType, 0, nullable.Time{})
+	require.NoError(t, jobqueue.Add(jobqueue.ContextWithTraceContext(ctx, producer), parent))
+	assert.Equal(t, producer, parent.TraceContext, "captured from the context")
+
+	require.Eventually(t, func() bool { return len(tracer.spans()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	spans := tracer.spans()
+
+	assert.Equal(t, "job "+parentType, spans[0].name)
+	assert.Equal(t, []jobqueue.TraceContext{producer}, spans[0].links, "linked to the producer")
+	assert.Equal(t, []jobworker.SpanAttribute{
+		{Key: jobworker.SpanAttributeJobID, Value: parent.ID.String()},
+		{Key: jobworker.SpanAttributeJobType, Value: parentType},
+		{Key: jobworker.SpanAttributeJobAttempt, Value: 1},
+		{Key: jobworker.SpanAttributeJobOrigin, Value: "jobworker-test"},
+	}, spans[0].attributes)
+	assert.NoError(t, spans[0].err)
+
+	assert.Equal(t, "job "+childType, spans[1].name)
+	assert.Equal(t, []jobqueue.TraceContext{spans[0].traceCtx}, spans[1].links, "linked to the span of the job that added it")
+	assert.ErrorContains(t, spans[1].err, "child failed")
+
+	// DoJob without Tracer still passes the TraceContext to the worker
+	pool.SetTracer(nil)
+	job := newTestJob(t, "jobworker-test-trace-do-job", 0, nullable.Time{})
+	job.TraceContext = producer
+	require.NoError(t, pool.DoJob(t.Context(), job))
+	assert.Equal(t, producer, workerTraceCtx)
+	assert.Len(t, tracer.spans(), 2, "no span without Tracer")
+}
+
+func TestBatchTraceContext(t *testing.T) {
+	const jobType = "jobworker-test-trace-batch"
+	tracer := new(recordingTracer)
+	pool, db := newTestPool(t)
+	pool.SetTracer(tracer)
+	workerTraceCtxs := make(chan jobqueue.TraceContext, 2)
+	pool.RegisterBatch(jobType, 4, 0, func(ctx context.Context, jobs []*jobqueue.Job) []error {
+		workerTraceCtxs <- jobqueue.TraceContextFromContext(ctx)
+		jobErrs := make([]error, len(jobs))
+		for i, job := range jobs {
+			if job.TraceContext.IsZero() {
+				jobErrs[i] = jobworker.Permanent(errors.New("untraced job failed"))
+			}
+		}
+		return jobErrs
+	})
+
+	producerA := jobqueue.TraceContext{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	producerB := jobqueue.TraceContext{TraceParent: "00-5bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	jobs := make([]*jobqueue.Job, 4)
+	for i, traceCtx := range []jobqueue.TraceContext{producerA, producerB, producerA, {}} {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+		jobs[i].TraceContext = traceCtx
+	}
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+	startThreads(t, pool, 1)
+
+	require.Eventually(t, func() bool { return len(tracer.spans()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	span := tracer.spans()[0]
+	assert.Equal(t, "job batch "+jobType, span.name)
+	assert.ElementsMatch(t, []jobqueue.TraceContext{producerA, producerB}, span.links, "linked to the distinct producers")
+	assert.Equal(t, []jobworker.SpanAttribute{
+		{Key: jobworker.SpanAttributeJobType, Value: jobType},
+		{Key: jobworker.SpanAttributeJobBatchSize, Value: 4},
+	}, span.attributes)
+	assert.ErrorContains(t, span.err, "untraced job failed")
+	assert.Equal(t, span.traceCtx, <-workerTraceCtxs, "worker runs in the span")
+
+	// Without Tracer the worker gets the trace context shared by the traced jobs
+	pool.SetTracer(nil)
+	for i, traceCtx := range []jobqueue.TraceContext{producerA, producerA} {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+		jobs[i].TraceContext = traceCtx
+	}
+	require.NoError(t, db.AddJobs(t.Context(), jobs[:2]))
+	select {
+	case workerTraceCtx := <-workerTraceCtxs:
+		assert.Equal(t, producerA, workerTraceCtx)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch worker not called")
+	}
+	assert.Len(t, tracer.spans(), 1, "no span without Tracer")
+}
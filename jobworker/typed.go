@@ -0,0 +1,57 @@
+package jobworker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/domonda/go-errs"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// TypedWorker returns a WorkerFunc that unmarshals the JSON payload
+// of a job to P and calls worker with it.
+// The result of worker is stored as the job's result.
+// A payload that can't be unmarshalled to P is a Permanent error,
+// so the job is not retried.
+//
+// Use it to register a typed worker with a Pool
+// because methods can't have type parameters:
+//
+//	pool.Register(SendEmail.Type(), jobworker.TypedWorker(sendEmail))
+func TypedWorker[P, R any](worker func(ctx context.Context, payload P) (R, error)) WorkerFunc {
+	return func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
+		var payload P
+		err = job.Payload.UnmarshalTo(&payload)
+		if err != nil {
+			return nil, Permanent(fmt.Errorf("error while unmarshalling job payload '%s': %w", job.Payload, err))
+		}
+		return worker(ctx, payload)
+	}
+}
+
+// RegisterTyped registers worker for jobs of jobType with the default Pool.
+// The JSON payload of a job is unmarshalled to P without reflection
+// at call time and the result of worker is stored as the job's result.
+//
+// Unlike RegisterFunc the signature of worker is checked by the compiler.
+// Use RegisterJobKind to also share the payload type with the code adding the jobs.
+//
+// Like Register (which it calls), RegisterTyped must be called before StartThreads
+// and panics if worker threads are running.
+func RegisterTyped[P, R any](jobType string, worker func(ctx context.Context, payload P) (R, error)) {
+	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType)
+
+	if jobType == "" {
+		panic(errs.New("jobType must not be empty"))
+	}
+
+	defaultPool.Register(jobType, TypedWorker(worker))
+}
+
+// RegisterJobKind registers worker for the jobs of kind with the default Pool,
+// see RegisterTyped. The payload type of kind and worker must match,
+// so jobs added with kind.Add are always unmarshalled to the payload type of worker.
+func RegisterJobKind[P, R any](kind jobqueue.JobKind[P], worker func(ctx context.Context, payload P) (R, error)) {
+	RegisterTyped(kind.Type(), worker)
+}
@@ -0,0 +1,48 @@
+package jobworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/domonda/go-types/notnull"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+type typedPayload struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func TestTypedWorker(t *testing.T) {
+	worker := TypedWorker(func(ctx context.Context, payload *typedPayload) (int, error) {
+		return payload.A + payload.B, nil
+	})
+
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), "typed", "test", `{"a":1,"b":2}`, nullable.Time{})
+	require.NoError(t, err)
+	result, err := worker(t.Context(), job)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result)
+
+	job.Payload = notnull.JSON(`"not an object"`)
+	_, err = worker(t.Context(), job)
+	assert.Error(t, err, "payload not unmarshallable to P")
+	assert.True(t, IsPermanent(err), "malformed payload is not retried")
+}
+
+func TestRegisterJobKind(t *testing.T) {
+	resetWorkerRegistryState(t)
+
+	kind := jobqueue.NewJobKind[typedPayload]("typed-kind")
+	RegisterJobKind(kind, func(ctx context.Context, payload typedPayload) (any, error) { return nil, nil })
+	assert.True(t, IsRegistered("typed-kind"))
+
+	assert.Panics(t, func() {
+		RegisterTyped("", func(ctx context.Context, payload typedPayload) (any, error) { return nil, nil })
+	})
+}
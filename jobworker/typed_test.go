@@ -0,0 +1,60 @@
+package jobworker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestJobKind(t *testing.T) {
+	type sumPayload struct {
+		A, B int
+	}
+	kind := jobqueue.NewJobKind[sumPayload]("jobworker-test-job-kind")
+
+	pool, db := newTestPool(t)
+	pool.Register(kind.Type(), jobworker.TypedWorker(func(ctx context.Context, payload sumPayload) (int, error) {
+		return payload.A + payload.B, nil
+	}))
+	startThreads(t, pool, 1)
+
+	ctx := jobqueue.ContextWithService(t.Context(), db)
+	job, err := kind.Add(ctx, sumPayload{A: 1, B: 2}, jobqueue.WithOrigin("jobworker-test"))
+	require.NoError(t, err)
+
+	loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	assert.JSONEq(t, `3`, loaded.Result.String())
+}
+
+func TestMalformedPayloadNotRetried(t *testing.T) {
+	const jobType = "jobworker-test-malformed-payload"
+	pool, db := newTestPool(t)
+
+	var called atomic.Bool
+	pool.Register(jobType, jobworker.TypedWorker(func(ctx context.Context, payload struct{ N int }) (any, error) {
+		called.Store(true)
+		return nil, nil
+	}))
+	pool.SetDefaultScheduleRetry(jobworker.ConstantBackoff(0))
+	startThreads(t, pool, 1)
+
+	job, err := jobqueue.NewJob(uu.NewID(t.Context()), jobType, "jobworker-test", `{"N":"not a number"}`, nullable.Time{}, 3)
+	require.NoError(t, err)
+	require.NoError(t, db.AddJob(t.Context(), job))
+
+	loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Stopped)
+	assert.True(t, loaded.HasError())
+	assert.False(t, called.Load(), "worker not called")
+	attempts, err := db.GetJobAttempts(t.Context(), job.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1, "not rescheduled")
+	assert.Equal(t, jobqueue.JobAttemptFailed, attempts[0].Outcome)
+}
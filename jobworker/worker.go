@@ -18,18 +18,31 @@ import (
 // a job type with Register.
 type WorkerFunc func(ctx context.Context, job *jobqueue.Job) (result any, err error)
 
+// Register a Worker implementation for a jobType
+// with the default Pool, see Pool.Register.
+//
+// See also RegisterFunc
+func Register(jobType string, worker WorkerFunc) {
+	defaultPool.Register(jobType, worker)
+}
+
 // Register a Worker implementation for a jobType.
 //
-// Register must be called during startup, before StartThreads. It mutates the set
-// of job types that jobworkerdb caches a prepared claim statement against (keyed
-// on a generation bumped here), and changing that set while worker threads claim
-// jobs would race that cache. Register panics if called while worker threads are
-// running.
+// Register must be called during startup, before StartThreads. It changes the
+// Claim that the DataBase may cache a prepared claim statement for, and changing
+// it while worker threads claim jobs would race that cache. Register panics if
+// called while worker threads are running.
 //
 // See also RegisterFunc
-func Register(jobType string, worker WorkerFunc) {
+func (p *Pool) Register(jobType string, worker WorkerFunc) {
 	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType)
 
+	p.register(jobType, worker, nil)
+}
+
+// register registers worker for jobType,
+// and batch if it is registered with RegisterBatch.
+func (p *Pool) register(jobType string, worker WorkerFunc, batch *batchWorker) {
 	if sqlInject, info := sqldb.IsSQLInjection(jobType); sqlInject {
 		panic(fmt.Errorf("jobType %#v contains probably SQL injection: %s", jobType, info))
 	}
@@ -37,34 +50,49 @@ func Register(jobType string, worker WorkerFunc) {
 	// Hold setupMtx for the whole call so StartThreads (which takes setupMtx for
 	// writing) cannot begin between this guard and the workers mutation below.
 	// Registration is a startup-only step; see the doc comment above.
-	setupMtx.RLock()
-	defer setupMtx.RUnlock()
-	if numRunningThreads > 0 {
-		panic(fmt.Errorf("jobworker.Register(%#v) must be called before StartThreads, but %d worker thread(s) are running", jobType, numRunningThreads))
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		panic(fmt.Errorf("jobworker.Register(%#v) must be called before StartThreads, but %d worker thread(s) are running", jobType, p.numRunningThreads))
 	}
 
-	workersMtx.Lock()
-	defer workersMtx.Unlock()
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
 
-	if _, exists := workers[jobType]; exists {
+	if _, exists := p.workers[jobType]; exists {
 		panic(fmt.Errorf("a worker for jobType %#v has already been registered", jobType))
 	}
 
-	workers[jobType] = worker
-	invalidateWorkerTypesCacheLocked()
+	p.workers[jobType] = worker
+	if batch != nil {
+		p.batchWorkers[jobType] = batch
+	}
+	p.invalidateWorkerTypesCacheLocked()
 }
 
-// IsRegistered checks if a worker is registered for the given job type.
+// IsRegistered checks if a worker is registered
+// for the given job type with the default Pool.
 func IsRegistered(jobType string) bool {
-	workersMtx.RLock()
-	defer workersMtx.RUnlock()
+	return defaultPool.IsRegistered(jobType)
+}
+
+// IsRegistered checks if a worker is registered for the given job type.
+func (p *Pool) IsRegistered(jobType string) bool {
+	p.workersMtx.RLock()
+	defer p.workersMtx.RUnlock()
+
+	return p.workers[jobType] != nil
+}
 
-	return workers[jobType] != nil
+// RegisteredJobTypes returns the sorted job types that currently have a worker
+// registered with the default Pool, see Pool.RegisteredJobTypes.
+func RegisteredJobTypes() (jobTypes []string, generation uint64) {
+	return defaultPool.RegisteredJobTypes()
 }
 
 // RegisteredJobTypes returns the sorted job types that currently have a worker
 // registered, together with a generation that changes whenever that set changes
-// (via Register/Unregister). The pair comes from a single locked read, so it is
+// (via Register/Unregister) or a limit of them changes. The pair comes from a single locked read, so it is
 // always consistent: a consumer can cache a value derived from the types and
 // rebuild only when the generation differs from the one it last built against,
 // without comparing the type sets themselves.
@@ -72,71 +100,99 @@ func IsRegistered(jobType string) bool {
 // The result is read from a cache rebuilt only when the set of registered workers
 // changes, so the common case is a single read lock with no allocation. The
 // returned slice is shared and MUST NOT be mutated by the caller.
-func RegisteredJobTypes() (jobTypes []string, generation uint64) {
-	workersMtx.RLock()
-	cached, generation := workerTypes, workerTypesGeneration
-	workersMtx.RUnlock()
+func (p *Pool) RegisteredJobTypes() (jobTypes []string, generation uint64) {
+	p.workersMtx.RLock()
+	cached, generation := p.workerTypes, p.workerTypesGeneration
+	p.workersMtx.RUnlock()
 	if cached != nil {
 		return cached, generation
 	}
 
-	workersMtx.Lock()
-	defer workersMtx.Unlock()
-
-	// Re-check under the write lock: another goroutine may have rebuilt the cache
-	// between the RUnlock above and acquiring the write lock. The rebuilt slice is
-	// sorted for a canonical order downstream consumers can cache against;
-	// workerTypesGeneration is left untouched (it is bumped on invalidation, so it
-	// already reflects the current set).
-	if workerTypes == nil {
-		types := make([]string, 0, len(workers))
-		for jobType := range workers {
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
+
+	return p.workerTypesLocked(), p.workerTypesGeneration
+}
+
+// workerTypesLocked returns the cached sorted registered job types,
+// rebuilding the cache if it was invalidated.
+// The caller must hold workersMtx for writing.
+func (p *Pool) workerTypesLocked() []string {
+	// Another goroutine may have rebuilt the cache between a RUnlock and
+	// acquiring the write lock. The rebuilt slice is sorted for a canonical
+	// order downstream consumers can cache against; workerTypesGeneration is
+	// left untouched (it is bumped on invalidation, so it already reflects
+	// the current set).
+	if p.workerTypes == nil {
+		types := make([]string, 0, len(p.workers))
+		for jobType := range p.workers {
 			types = append(types, jobType)
 		}
 		slices.Sort(types)
-		workerTypes = types
+		p.workerTypes = types
 	}
-	return workerTypes, workerTypesGeneration
+	return p.workerTypes
 }
 
-// invalidateWorkerTypesCacheLocked marks the registered-job-types cache stale and
-// bumps the generation so downstream consumers (e.g. the jobworkerdb claim query)
-// know to rebuild. The caller must hold workersMtx for writing.
-func invalidateWorkerTypesCacheLocked() {
-	workerTypes = nil
-	workerTypesGeneration++
+// invalidateWorkerTypesCacheLocked marks the registered-job-types cache and
+// the Claim stale and bumps the generation so downstream consumers know to
+// rebuild. The caller must hold workersMtx for writing.
+func (p *Pool) invalidateWorkerTypesCacheLocked() {
+	p.workerTypes = nil
+	p.claim = nil
+	p.workerTypesGeneration++
+}
+
+// RegisterFunc uses reflection to register a function with a custom
+// payload argument type as Worker with the default Pool,
+// see Pool.RegisterFunc.
+func RegisterFunc(workerFunc any) {
+	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), workerFunc)
+
+	defaultPool.registerFunc("REFLECT_PAYLOAD_TYPE", workerFunc)
 }
 
 // RegisterFunc uses reflection to register a function with a custom
 // payload argument type as Worker for jobs of type ReflectJobType(arg).
-// The playload JSON of the job will be unmarshalled to the type of the argument.
+// The playload JSON of the job will be unmarshalled to the type of the argument,
+// a payload that can't be unmarshalled is a Permanent error.
 //
 // Like Register (which it calls), RegisterFunc must be called before StartThreads
 // and panics if worker threads are running.
-func RegisterFunc(workerFunc any) {
+func (p *Pool) RegisterFunc(workerFunc any) {
 	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), workerFunc)
 
-	registerFunc("REFLECT_PAYLOAD_TYPE", workerFunc)
+	p.registerFunc("REFLECT_PAYLOAD_TYPE", workerFunc)
+}
+
+// RegisterFuncForJobType uses reflection to register a function with a custom
+// payload argument type as Worker for jobs of jobType with the default Pool,
+// see Pool.RegisterFuncForJobType.
+func RegisterFuncForJobType(jobType string, workerFunc any) {
+	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType, workerFunc)
+
+	defaultPool.RegisterFuncForJobType(jobType, workerFunc)
 }
 
 // RegisterFuncForJobType uses reflection to register a function with a custom
 // payload argument type as Worker for jobs of jobType.
-// The playload JSON of the job will be unmarshalled to the type of the argument.
+// The playload JSON of the job will be unmarshalled to the type of the argument,
+// a payload that can't be unmarshalled is a Permanent error.
 //
 // Like Register (which it calls), RegisterFuncForJobType must be called before
 // StartThreads and panics if worker threads are running.
-func RegisterFuncForJobType(jobType string, workerFunc any) {
+func (p *Pool) RegisterFuncForJobType(jobType string, workerFunc any) {
 	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType, workerFunc)
 
 	if jobType == "" {
 		panic(errs.New("jobType must not be empty"))
 	}
 
-	registerFunc(jobType, workerFunc)
+	p.registerFunc(jobType, workerFunc)
 }
 
 // registerFunc uses jobType = reflectJobType(payloadType) if jobType is "REFLECT_PAYLOAD_TYPE"
-func registerFunc(jobType string, workerFunc any) {
+func (p *Pool) registerFunc(jobType string, workerFunc any) {
 	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobType, workerFunc)
 
 	workerFuncVal := reflect.ValueOf(workerFunc)
@@ -197,11 +253,12 @@ func registerFunc(jobType string, workerFunc any) {
 		panic(fmt.Errorf("workerFunc must have 1 or 2 results, but has %d", workerFuncType.NumOut()))
 	}
 
-	Register(jobType, WorkerFunc(func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
+	p.Register(jobType, WorkerFunc(func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
 		payloadVal := reflect.New(payloadType) // JSON unmarshalling always needs a pointer
 		err = job.Payload.UnmarshalTo(payloadVal.Interface())
 		if err != nil {
-			return nil, fmt.Errorf("error while unmarshalling job payload '%s': %w", job.Payload, err)
+			// Retrying can't fix a malformed payload
+			return nil, Permanent(fmt.Errorf("error while unmarshalling job payload '%s': %w", job.Payload, err))
 		}
 		if argType.Kind() != reflect.Pointer {
 			payloadVal = payloadVal.Elem()
@@ -244,42 +301,49 @@ func registerFunc(jobType string, workerFunc any) {
 // 		panic(err)
 // 	}
 
-// 	Register(jobType, WorkerFunc(func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
+// 	p.Register(jobType, WorkerFunc(func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
 // 		return nil, f(ctx, job.Payload)
 // 	}))
 // }
 
+// Unregister removes the workers for the given job types,
+// or all registered workers if no job type is passed,
+// from the default Pool, see Pool.Unregister.
+func Unregister(jobTypes ...string) {
+	defaultPool.Unregister(jobTypes...)
+}
+
 // Unregister removes the workers for the given job types,
 // or all registered workers if no job type is passed.
 //
-// Like Register, Unregister mutates the cached claim statement's job-type set, so
-// it must be called while no worker threads are running: stop them with
+// Like Register, Unregister changes the Claim of the Pool, so it must be
+// called while no worker threads are running: stop them with
 // FinishThreads (or StopThreads) first. Unregister panics if worker threads are
 // running.
-func Unregister(jobTypes ...string) {
+func (p *Pool) Unregister(jobTypes ...string) {
 	defer errs.LogPanicWithFuncParams(log.ErrorWriter(), jobTypes)
 
 	// Hold setupMtx for the whole call so StartThreads cannot begin between this
 	// guard and the workers mutation below (mirrors Register).
-	setupMtx.RLock()
-	defer setupMtx.RUnlock()
-	if numRunningThreads > 0 {
-		panic(fmt.Errorf("jobworker.Unregister must be called after FinishThreads, but %d worker thread(s) are running", numRunningThreads))
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+	if p.numRunningThreads > 0 {
+		panic(fmt.Errorf("jobworker.Unregister must be called after FinishThreads, but %d worker thread(s) are running", p.numRunningThreads))
 	}
 
-	workersMtx.Lock()
-	defer workersMtx.Unlock()
+	p.workersMtx.Lock()
+	defer p.workersMtx.Unlock()
 
 	if len(jobTypes) > 0 {
 		log.Debug("Unregister workers for job types").Strs("jobTypes", jobTypes).Log()
 		for _, jobType := range jobTypes {
-			delete(workers, jobType)
+			delete(p.workers, jobType)
+			delete(p.batchWorkers, jobType)
 		}
 	} else {
 		log.Debug("Unregister all workers").Log()
-		for jobType := range workers {
-			delete(workers, jobType)
-		}
+		clear(p.workers)
+		clear(p.batchWorkers)
 	}
-	invalidateWorkerTypesCacheLocked()
+	p.invalidateWorkerTypesCacheLocked()
 }
@@ -5,6 +5,7 @@ import (
 	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,22 +15,23 @@ import (
 
 func noopWorker(context.Context, *jobqueue.Job) (any, error) { return nil, nil }
 
-// resetWorkerRegistryState clears the package-global worker registry and the
+// resetWorkerRegistryState clears the worker registry of the default Pool and the
 // RegisteredJobTypes cache so each test starts from a known state, and restores
-// it on cleanup. The jobworker package keeps registrations in process-global maps,
+// it on cleanup. The package level functions keep registrations in the default Pool,
 // so tests that register must isolate themselves explicitly. It mutates the maps
 // directly (under workersMtx) rather than via Unregister so it works regardless of
 // the running-threads guard.
 func resetWorkerRegistryState(t *testing.T) {
 	t.Helper()
 	clearRegistry := func() {
-		workersMtx.Lock()
-		for k := range workers {
-			delete(workers, k)
+		defaultPool.workersMtx.Lock()
+		for k := range defaultPool.workers {
+			delete(defaultPool.workers, k)
 		}
-		workerTypes = nil
-		workerTypesGeneration = 0
-		workersMtx.Unlock()
+		defaultPool.workerTypes = nil
+		defaultPool.workerTypesGeneration = 0
+		defaultPool.claim = nil
+		defaultPool.workersMtx.Unlock()
 	}
 	clearRegistry()
 	t.Cleanup(clearRegistry)
@@ -77,9 +79,9 @@ func TestRegisteredJobTypesEmptyRebuildIsCached(t *testing.T) {
 	types1, gen1 := RegisteredJobTypes()
 	assert.Empty(t, types1)
 
-	workersMtx.RLock()
-	cacheIsNonNil := workerTypes != nil
-	workersMtx.RUnlock()
+	defaultPool.workersMtx.RLock()
+	cacheIsNonNil := defaultPool.workerTypes != nil
+	defaultPool.workersMtx.RUnlock()
 	assert.True(t, cacheIsNonNil, "empty registry still produces a non-nil cache")
 
 	types2, gen2 := RegisteredJobTypes()
@@ -137,13 +139,13 @@ func TestRegisteredJobTypesConcurrentAccess(t *testing.T) {
 func TestRegisterUnregisterPanicWhileThreadsRunning(t *testing.T) {
 	resetWorkerRegistryState(t)
 
-	setupMtx.Lock()
-	numRunningThreads = 1
-	setupMtx.Unlock()
+	defaultPool.setupMtx.Lock()
+	defaultPool.numRunningThreads = 1
+	defaultPool.setupMtx.Unlock()
 	t.Cleanup(func() {
-		setupMtx.Lock()
-		numRunningThreads = 0
-		setupMtx.Unlock()
+		defaultPool.setupMtx.Lock()
+		defaultPool.numRunningThreads = 0
+		defaultPool.setupMtx.Unlock()
 	})
 
 	assert.Panics(t, func() { Register("late", noopWorker) },
@@ -151,3 +153,33 @@ func TestRegisterUnregisterPanicWhileThreadsRunning(t *testing.T) {
 	assert.Panics(t, func() { Unregister("late") },
 		"Unregister must panic while worker threads are running")
 }
+
+// TestPoolClaimCache covers that Pool.Claim returns the same pointer
+// until the registered workers, the limits, or the heartbeat change.
+func TestPoolClaimCache(t *testing.T) {
+	heartbeatInterval := 10 * time.Second
+	onError := func(error) {}
+	jobTimeout := time.Minute
+	p := newPool(nil, &onError, &jobTimeout, &heartbeatInterval)
+	p.Register("b", noopWorker)
+	p.Register("a", noopWorker)
+
+	claim := p.Claim()
+	assert.Equal(t, []string{"a", "b"}, claim.JobTypes)
+	assert.True(t, claim.Heartbeat)
+	assert.Same(t, claim, p.Claim(), "cache hit")
+
+	require.NoError(t, p.SetClusterMaxConcurrency("a", 1))
+	limited := p.Claim()
+	require.NotSame(t, claim, limited, "rebuilt after SetClusterMaxConcurrency")
+	assert.Equal(t, map[JobType]int{"a": 1}, limited.ClusterMaxConcurrency)
+	assert.Empty(t, claim.ClusterMaxConcurrency, "previous Claim not mutated")
+
+	heartbeatInterval = 0
+	noHeartbeat := p.Claim()
+	require.NotSame(t, limited, noHeartbeat, "rebuilt after the heartbeat interval changed")
+	assert.False(t, noHeartbeat.Heartbeat)
+
+	p.Unregister("b")
+	assert.Equal(t, []string{"a"}, p.Claim().JobTypes)
+}
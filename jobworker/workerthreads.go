@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/domonda/go-errs"
@@ -15,51 +14,103 @@ import (
 // string literals can be used directly as map keys and function arguments.
 type JobType = string
 
-var (
-	// setupMtx guards numRunningThreads, workerWaitGroup, checkJobSignal, stopPolling, and workerCtx
-	setupMtx sync.RWMutex
-
-	numRunningThreads int
-	workerWaitGroup   *sync.WaitGroup
-	// checkJobSignal is a dummy signal notifying the thread workers that there is a new job available
-	checkJobSignal chan struct{}
-	// stopPolling is closed to signal the polling goroutine to stop,
-	// then reassigned to a new channel for the next polling cycle
-	stopPolling = make(chan struct{})
-	// workerCtx is the context passed to StartThreads,
-	// used to cancel worker threads when the context is cancelled
-	workerCtx context.Context
-	// stopping is set to true by FinishThreads/StopThreads
-	// so that nextJob won't pick up new jobs from the database.
-	// Using atomic.Bool so nextJob can check it without holding setupMtx.
-	stopping atomic.Bool
-
-	workers    = map[JobType]WorkerFunc{}
-	workersMtx sync.RWMutex
-
-	retrySchedulers    = map[JobType]ScheduleRetryFunc{}
-	retrySchedulersMtx sync.RWMutex
-)
-
-func onCheckJob() {
-	setupMtx.RLock()
-	defer setupMtx.RUnlock()
+func (p *Pool) onCheckJob() {
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
 
-	if numRunningThreads == 0 || checkJobSignal == nil {
+	if p.numRunningThreads == 0 || p.checkJobSignal == nil {
 		return
 	}
 
 	// Non-blocking send to avoid blocking while holding the lock.
 	// If the buffer is full, there's already a pending signal.
 	select {
-	case checkJobSignal <- struct{}{}:
+	case p.checkJobSignal <- struct{}{}:
 	default:
 	}
 }
 
+// wakeUpAtNextJobStart arms startDelayTimer to wake up the worker threads
+// when the next job with a start_at in the future becomes available,
+// because no job_available notification is sent for it.
+// An already armed timer is kept if it fires earlier.
+func (p *Pool) wakeUpAtNextJobStart(ctx context.Context) {
+	delay, ok, err := p.db.GetNextJobStartDelay(ctx, p.Claim())
+	if err != nil {
+		p.onError(err)
+		log.ErrorCtx(ctx, "Error while retrieving the next job start delay").Err(err).Log()
+		return
+	}
+	if !ok {
+		return
+	}
+	deadline := time.Now().Add(delay)
+
+	p.startDelayMtx.Lock()
+	defer p.startDelayMtx.Unlock()
+
+	if p.startDelayTimer != nil {
+		if !deadline.Before(p.startDelayDeadline) {
+			return
+		}
+		p.startDelayTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		p.startDelayMtx.Lock()
+		// Don't clear a timer that replaced this one
+		if p.startDelayTimer == timer {
+			p.startDelayTimer = nil
+		}
+		p.startDelayMtx.Unlock()
+
+		p.onJobStartReached()
+	})
+	p.startDelayTimer = timer
+	p.startDelayDeadline = deadline
+}
+
+// onJobStartReached signals every worker thread because more than one
+// job can have become available at the same start_at.
+func (p *Pool) onJobStartReached() {
+	p.setupMtx.RLock()
+	defer p.setupMtx.RUnlock()
+
+	if p.numRunningThreads == 0 || p.checkJobSignal == nil {
+		return
+	}
+	for range p.numRunningThreads {
+		select {
+		case p.checkJobSignal <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+func (p *Pool) stopStartDelayTimer() {
+	p.startDelayMtx.Lock()
+	defer p.startDelayMtx.Unlock()
+
+	if p.startDelayTimer != nil {
+		p.startDelayTimer.Stop()
+		p.startDelayTimer = nil
+	}
+}
+
+// StartPollingAvailableJobs polls the database for available jobs
+// every `interval` duration for the default Pool,
+// see Pool.StartPollingAvailableJobs.
+func StartPollingAvailableJobs(interval time.Duration) error {
+	return defaultPool.StartPollingAvailableJobs(interval)
+}
+
 // StartPollingAvailableJobs polls the database for available jobs every `interval` duration.
 // Can be called before or after StartThreads.
-func StartPollingAvailableJobs(interval time.Duration) error {
+//
+// Polling is not needed for jobs with a start_at in the future
+// or retries, the worker threads wake up when their start_at is reached.
+func (p *Pool) StartPollingAvailableJobs(interval time.Duration) error {
 	if interval < 0 {
 		return errors.New("polling interval cannot be negative")
 	}
@@ -67,12 +118,12 @@ func StartPollingAvailableJobs(interval time.Duration) error {
 		return errors.New("polling interval cannot be zero")
 	}
 
-	setupMtx.RLock()
+	p.setupMtx.RLock()
 	// Capture channel reference in local variable.
 	// This ensures the goroutine below will receive the close signal
 	// even if stopPolling is reassigned to a new channel later.
-	stop := stopPolling
-	setupMtx.RUnlock()
+	stop := p.stopPolling
+	p.setupMtx.RUnlock()
 
 	ticker := time.NewTicker(interval)
 
@@ -82,7 +133,7 @@ func StartPollingAvailableJobs(interval time.Duration) error {
 		for {
 			select {
 			case <-ticker.C:
-				onCheckJob()
+				p.onCheckJob()
 			case <-stop:
 				// Receives immediately when channel is closed.
 				// No value is ever sent; closing is the only signal.
@@ -95,62 +146,98 @@ func StartPollingAvailableJobs(interval time.Duration) error {
 	return nil
 }
 
+// StartThreads starts numThreads new threads of the default Pool,
+// see Pool.StartThreads.
+func StartThreads(ctx context.Context, numThreads int) error {
+	return defaultPool.StartThreads(ctx, numThreads)
+}
+
 // StartThreads starts numThreads new threads that are
-// polling postgresdb for jobs to work on.
+// polling the DataBase of the Pool for jobs to work on.
+//
+// If schedules were registered with RegisterSchedule,
+// it also starts a goroutine adding their occurrences to the queue.
 //
 // The passed context is forwarded to the job worker functions
 // and can be used to cancel them.
-func StartThreads(ctx context.Context, numThreads int) error {
+func (p *Pool) StartThreads(ctx context.Context, numThreads int) error {
 	if numThreads <= 0 {
 		return errors.New("need at least 1 worker thread")
 	}
 
-	setupMtx.Lock()
-	defer setupMtx.Unlock()
+	p.setupMtx.Lock()
+	defer p.setupMtx.Unlock()
 
-	if numRunningThreads > 0 {
+	if p.numRunningThreads > 0 {
 		return errors.New("worker threads already running")
 	}
 
 	// Wait for old workers from a previous StopThreads call to finish
 	// before starting new ones, so they don't read the new checkJobSignal.
-	if workerWaitGroup != nil {
-		workerWaitGroup.Wait()
-		workerWaitGroup = nil
-		checkJobSignal = nil
+	if p.workerWaitGroup != nil {
+		p.workerWaitGroup.Wait()
+		p.workerWaitGroup = nil
+		p.checkJobSignal = nil
 	}
 
-	err := db.SetJobAvailableListener(ctx, onCheckJob)
+	err := p.db.SetJobAvailableListener(ctx, p.onCheckJob)
 	if err != nil {
 		return err
 	}
+	err = p.db.SetJobCancelRequestedListener(ctx, p.onJobCancelRequested)
+	if err != nil {
+		// Don't leave the Pool listening without running threads
+		if e := p.db.SetJobAvailableListener(context.WithoutCancel(ctx), nil); e != nil {
+			p.onError(e)
+			log.ErrorCtx(ctx, "Error while setting the job available listener to nil").Err(e).Log()
+		}
+		return err
+	}
 
-	workerCtx = ctx
-	stopping.Store(false)
-	numRunningThreads = numThreads
-	workerWaitGroup = new(sync.WaitGroup)
-	workerWaitGroup.Add(numThreads)
-	checkJobSignal = make(chan struct{}, 1024)
+	p.workerCtx = ctx
+	p.stopping.Store(false)
+	p.numRunningThreads = numThreads
+	p.workerWaitGroup = new(sync.WaitGroup)
+	p.workerWaitGroup.Add(numThreads)
+	p.checkJobSignal = make(chan struct{}, 1024)
 
 	for i := range numThreads {
-		go worker(i)
+		go p.worker(i)
+	}
+
+	// The scheduler stops together with the polling goroutines
+	// when stopPolling is closed by FinishThreads or StopThreads
+	if registered := p.registeredSchedules(); len(registered) > 0 {
+		go p.runScheduler(ctx, registered, p.stopPolling)
 	}
 
 	return nil
 }
 
-func nextJob(ctx context.Context) *jobqueue.Job {
-	for ctx.Err() == nil && !stopping.Load() {
-		job, err := db.StartNextJobOrNil(ctx)
+func (p *Pool) nextJob(ctx context.Context) *jobqueue.Job {
+	p.numWaitingThreads.Add(1)
+	defer p.numWaitingThreads.Add(-1)
+
+	for ctx.Err() == nil && !p.stopping.Load() {
+		job, err := p.startNextJob(ctx)
 		if err != nil {
-			OnError(err)
+			p.onError(err)
 			log.ErrorCtx(ctx, "Error while retrieving the next job").Err(err).Log()
 		}
 		if job != nil {
+			// A job_available notification can stand for several jobs,
+			// like the one of AddJobs, but wakes up only one thread,
+			// so wake up the next waiting thread in case more are available
+			if p.numWaitingThreads.Load() > 1 {
+				p.onCheckJob()
+			}
 			return job
 		}
+		if err == nil && !p.stopping.Load() {
+			p.wakeUpAtNextJobStart(ctx)
+		}
 
-		_, isOpen := <-checkJobSignal
+		_, isOpen := <-p.checkJobSignal
 		if !isOpen {
 			return nil
 		}
@@ -158,12 +245,15 @@ func nextJob(ctx context.Context) *jobqueue.Job {
 	return nil
 }
 
-func worker(threadIndex int) {
-	defer workerWaitGroup.Done()
+func (p *Pool) worker(threadIndex int) {
+	defer p.workerWaitGroup.Done()
 
-	setupMtx.RLock()
-	ctx := workerCtx
-	setupMtx.RUnlock()
+	p.numLiveThreads.Add(1)
+	defer p.numLiveThreads.Add(-1)
+
+	p.setupMtx.RLock()
+	ctx := p.workerCtx
+	p.setupMtx.RUnlock()
 
 	log, ctx := log.With().
 		Int("threadIndex", threadIndex).
@@ -172,11 +262,19 @@ func worker(threadIndex int) {
 	log.Debug("Starting the worker thread").Log()
 
 	defer log.Debug("Worker thread ended").Log()
-
-	for job := nextJob(ctx); job != nil; job = nextJob(ctx) {
-		err := doJobAndSaveResultInDB(ctx, job)
+	defer p.unclaimPrefetchedJobs(ctx)
+
+	for job := p.nextJob(ctx); job != nil; job = p.nextJob(ctx) {
+		var err error
+		if batch := p.batchWorkerOrNil(job.Type); batch != nil {
+			// Counts every job of the batch as done for SetMaxConcurrency
+			err = p.doBatchAndSaveResultsInDB(ctx, batch, job)
+		} else {
+			err = p.doJobAndSaveResultInDB(ctx, job)
+			p.onJobDone(job)
+		}
 		if err != nil {
-			OnError(err)
+			p.onError(err)
 			log.ErrorCtx(ctx, "Error while dispatching the job").
 				Err(err).
 				Any("job", job).
@@ -188,82 +286,106 @@ func worker(threadIndex int) {
 	}
 }
 
+// FinishThreads waits until all worker threads of the default Pool
+// have finished their current jobs and stops them,
+// see Pool.FinishThreads.
+func FinishThreads(ctx context.Context) {
+	defaultPool.FinishThreads(ctx)
+}
+
 // FinishThreads waits until all worker threads have
 // finished their current jobs and stops them before they start
 // working on new jobs.
 //
 // The passed context can be used to pass in an optional
 // database connection ignoring any cancellation.
-func FinishThreads(ctx context.Context) {
+func (p *Pool) FinishThreads(ctx context.Context) {
 	log.Debug("Finishing threads").Log()
 
-	setupMtx.Lock()
-	defer setupMtx.Unlock()
+	p.setupMtx.Lock()
+	defer p.setupMtx.Unlock()
 
-	if numRunningThreads == 0 {
+	if p.numRunningThreads == 0 {
 		return
 	}
 
 	// Signal workers to stop picking up new jobs before closing the channel.
-	// nextJob checks this flag before calling db.StartNextJobOrNil
+	// nextJob checks this flag before claiming a job
 	// so workers won't start new jobs after this point.
-	stopping.Store(true)
-	numRunningThreads = 0
-	workerCtx = nil
+	p.stopping.Store(true)
+	p.numRunningThreads = 0
+	p.workerCtx = nil
 
-	err := db.SetJobAvailableListener(context.WithoutCancel(ctx), nil)
+	err := p.db.SetJobAvailableListener(context.WithoutCancel(ctx), nil)
 	if err != nil {
-		OnError(err)
+		p.onError(err)
 		log.Error("Error while setting the job available listener to nil").Err(err).Log()
 	}
 
-	close(checkJobSignal)
+	close(p.checkJobSignal)
+	p.stopStartDelayTimer()
 	// Closing stopPolling unblocks any goroutine receiving from it.
 	// Reassigning to a new channel is safe because running goroutines
 	// have captured the old channel reference in a local variable.
-	close(stopPolling)
-	stopPolling = make(chan struct{})
+	close(p.stopPolling)
+	p.stopPolling = make(chan struct{})
 
 	// Wait for workers to finish while holding the lock.
 	// This is safe because workers don't acquire setupMtx.
 	// Don't nil checkJobSignal before Wait completes because
 	// workers read it without holding the lock in nextJob,
 	// and receiving from a nil channel blocks forever.
-	workerWaitGroup.Wait()
-	workerWaitGroup = nil
-	checkJobSignal = nil
+	p.workerWaitGroup.Wait()
+	p.workerWaitGroup = nil
+	p.checkJobSignal = nil
+
+	// Only clear the cancel listener after the workers finished their jobs,
+	// so the jobs can still be cancelled until then.
+	// StopThreads doesn't wait for the workers and keeps the listener.
+	err = p.db.SetJobCancelRequestedListener(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		p.onError(err)
+		log.Error("Error while setting the job cancel requested listener to nil").Err(err).Log()
+	}
 
 	log.Info("Threads have finished").Log()
 }
 
+// StopThreads stops the threads of the default Pool
+// listening for new jobs, see Pool.StopThreads.
+func StopThreads(ctx context.Context) {
+	defaultPool.StopThreads(ctx)
+}
+
 // StopThreads stops the threads listening for new jobs.
 // Use FinishThreads to also wait for workers to complete.
 //
 // The passed context can be used to pass in an optional
 // database connection ignoring any cancellation.
-func StopThreads(ctx context.Context) {
+func (p *Pool) StopThreads(ctx context.Context) {
 	log.Debug("Stopping threads").Log()
 
-	setupMtx.Lock()
-	defer setupMtx.Unlock()
+	p.setupMtx.Lock()
+	defer p.setupMtx.Unlock()
 
-	if numRunningThreads == 0 {
+	if p.numRunningThreads == 0 {
 		return
 	}
 	// Signal workers to stop picking up new jobs before closing the channel.
-	// nextJob checks this flag before calling db.StartNextJobOrNil
+	// nextJob checks this flag before claiming a job
 	// so workers won't start new jobs after this point.
-	stopping.Store(true)
-	numRunningThreads = 0
-	workerCtx = nil
+	p.stopping.Store(true)
+	p.numRunningThreads = 0
+	p.workerCtx = nil
 
-	err := db.SetJobAvailableListener(context.WithoutCancel(ctx), nil)
+	err := p.db.SetJobAvailableListener(context.WithoutCancel(ctx), nil)
 	if err != nil {
-		OnError(err)
+		p.onError(err)
 		log.ErrorCtx(ctx, "Error while setting the job available listener to nil").Err(err).Log()
 	}
 
-	close(checkJobSignal)
+	close(p.checkJobSignal)
+	p.stopStartDelayTimer()
 	// Don't nil checkJobSignal here because StopThreads doesn't wait
 	// for workers to finish, and receiving from a nil channel blocks forever.
 	// StartThreads will overwrite it with a new channel.
@@ -271,6 +393,6 @@ func StopThreads(ctx context.Context) {
 	// Closing stopPolling unblocks any goroutine receiving from it.
 	// Reassigning to a new channel is safe because running goroutines
 	// have captured the old channel reference in a local variable.
-	close(stopPolling)
-	stopPolling = make(chan struct{})
+	close(p.stopPolling)
+	p.stopPolling = make(chan struct{})
 }
@@ -0,0 +1,107 @@
+package jobworker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestAddJobsWakesAllThreads(t *testing.T) {
+	const (
+		jobType    = "jobworker-test-add-jobs-parallel"
+		numThreads = 4
+	)
+	pool, db := newTestPool(t)
+
+	var (
+		mtx        sync.Mutex
+		cond       = sync.NewCond(&mtx)
+		running    int
+		maxRunning int
+	)
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		running++
+		maxRunning = max(maxRunning, running)
+		cond.Broadcast()
+		// Wait until all jobs run in parallel or the test gives up
+		deadline := time.Now().Add(5 * time.Second)
+		for maxRunning < numThreads && time.Now().Before(deadline) {
+			timer := time.AfterFunc(10*time.Millisecond, cond.Broadcast)
+			cond.Wait()
+			timer.Stop()
+		}
+		running--
+		return nil, nil
+	})
+	startThreads(t, pool, numThreads)
+
+	// Let all threads wait for the one job_available notification of AddJobs
+	time.Sleep(50 * time.Millisecond)
+	jobs := make([]*jobqueue.Job, numThreads)
+	for i := range jobs {
+		jobs[i] = newTestJob(t, jobType, 0, nullable.Time{})
+	}
+	require.NoError(t, db.AddJobs(t.Context(), jobs))
+
+	for _, job := range jobs {
+		waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, numThreads, maxRunning, "all threads woke up for the jobs of one AddJobs call")
+}
+
+func TestDelayedJobWakesWorkerThreads(t *testing.T) {
+	const jobType = "jobworker-test-delayed"
+	pool, db := newTestPool(t)
+	pool.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+
+	job := newTestJob(t, jobType, 0, nullable.TimeFrom(time.Now().Add(200*time.Millisecond)))
+	require.NoError(t, db.AddJob(t.Context(), job))
+
+	// No polling is started, the idle threads have to wake up at start_at
+	startThreads(t, pool, 2)
+
+	loaded := waitForJob(t, db, job.ID, (*jobqueue.Job).Succeeded)
+	assert.False(t, loaded.StartedAt.Get().Before(job.StartAt.Get()), "not started before start_at")
+}
+
+// failingCancelListenerDataBase fails to set
+// the job cancel requested listener.
+type failingCancelListenerDataBase struct {
+	jobworker.DataBase
+	availableListenerSet atomic.Bool
+}
+
+func (db *failingCancelListenerDataBase) SetJobAvailableListener(ctx context.Context, callback func()) error {
+	db.availableListenerSet.Store(callback != nil)
+	return db.DataBase.SetJobAvailableListener(ctx, callback)
+}
+
+func (db *failingCancelListenerDataBase) SetJobCancelRequestedListener(ctx context.Context, callback func(uu.ID)) error {
+	if callback == nil {
+		return db.DataBase.SetJobCancelRequestedListener(ctx, nil)
+	}
+	return errors.New("listen failed")
+}
+
+func TestStartThreadsListenerError(t *testing.T) {
+	db := &failingCancelListenerDataBase{DataBase: newTestDataBase(t)}
+	pool := jobworker.NewPool(db)
+
+	require.Error(t, pool.StartThreads(t.Context(), 1))
+	assert.False(t, db.availableListenerSet.Load(), "job available listener removed again")
+}
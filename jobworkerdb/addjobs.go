@@ -0,0 +1,184 @@
+package jobworkerdb
+
+import (
+	"context"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/notnull"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// jobInsertRow has the worker.job columns of a job inserted by insertJobs
+// with a multi-row insert, all other columns get their default values.
+type jobInsertRow struct {
+	db.TableName `db:"worker.job"`
+
+	ID                  uu.ID                            `db:"id,primarykey"`
+	BundleID            uu.NullableID                    `db:"bundle_id"`
+	Type                string                           `db:"type"`
+	Payload             notnull.JSON                     `db:"payload"`
+	Priority            int64                            `db:"priority"`
+	Origin              string                           `db:"origin"`
+	MaxRetryCount       int                              `db:"max_retry_count"`
+	StartAt             nullable.Time                    `db:"start_at"`
+	OnDependencyFailure jobqueue.DependencyFailurePolicy `db:"on_dependency_failure"`
+	TraceContext        jobqueue.TraceContext            `db:"trace_context"`
+}
+
+// insertJobs inserts jobs with as few statements as possible
+// and sends a single job_available notification for all of them
+// instead of one per job from the job_available_insert_trigger.
+//
+// Jobs without a UniqueKey or ScheduleName are inserted with multi-row inserts,
+// the others one by one with insertJob to apply their conflict clause.
+// With failOnDuplicate a duplicate UniqueKey always fails with a
+// jobqueue.DuplicateJobError instead of applying job.OnDuplicate.
+// The dependencies are inserted after all jobs,
+// so jobs can depend on other jobs of the batch.
+//
+// Has to be called within a transaction.
+func insertJobs(ctx context.Context, jobs []*jobqueue.Job, failOnDuplicate bool) (err error) {
+	// Don't format all jobs of a big batch into the error
+	defer errs.WrapWithFuncParams(&err, ctx, len(jobs), failOnDuplicate)
+
+	// The job_available function of the trigger skips the notification
+	// while worker.job_available_batch is on. set_config with is_local
+	// limits the setting to the transaction, and it is reset
+	// after the insert for a caller that continues its transaction.
+	err = db.Exec(ctx, `select set_config('worker.job_available_batch', 'on', true)`)
+	if err != nil {
+		return err
+	}
+	insertedIDs, err := insertJobsWithoutNotification(ctx, jobs, failOnDuplicate)
+	errReset := db.Exec(ctx, `select set_config('worker.job_available_batch', '', true)`)
+	if err != nil {
+		return err
+	}
+	if errReset != nil {
+		return errReset
+	}
+
+	// Same condition as the job_available_insert_trigger
+	return db.Exec(ctx,
+		/*sql*/ `
+			select pg_notify('job_available', json_build_object('count', count(*))::text)
+			from worker.job
+			where id = any($1)
+				and stopped_at is null
+				and (start_at is null or now() >= start_at)
+			having count(*) > 0
+		`,
+		insertedIDs, // $1
+	)
+}
+
+func insertJobsWithoutNotification(ctx context.Context, jobs []*jobqueue.Job, failOnDuplicate bool) (insertedIDs uu.IDs, err error) {
+	inserted := make(map[uu.ID]bool, len(jobs))
+	var rows []*jobInsertRow
+	for _, job := range jobs {
+		if job.UniqueKey.IsNotNull() || job.ScheduleName.IsNotNull() {
+			continue
+		}
+		rows = append(rows, &jobInsertRow{
+			ID:                  job.ID,
+			BundleID:            job.BundleID,
+			Type:                job.Type,
+			Payload:             job.Payload,
+			Priority:            job.Priority,
+			Origin:              job.Origin,
+			MaxRetryCount:       job.MaxRetryCount,
+			StartAt:             job.StartAt,
+			OnDependencyFailure: job.OnDependencyFailure.OrDefault(),
+			TraceContext:        job.TraceContext,
+		})
+		inserted[job.ID] = true
+	}
+	// Inserts as many rows per statement as the
+	// maximum number of query arguments allows
+	err = db.InsertRowStructs(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if job.UniqueKey.IsNull() && job.ScheduleName.IsNull() {
+			continue
+		}
+		onDuplicate := job.OnDuplicate
+		if failOnDuplicate {
+			onDuplicate = jobqueue.DuplicateJobFail
+		}
+		ok, err := insertJob(ctx, job, onDuplicate)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			inserted[job.ID] = true
+		}
+	}
+
+	insertedIDs = make(uu.IDs, 0, len(inserted))
+	for _, job := range jobs {
+		if !inserted[job.ID] {
+			// The dependencies of an existing job are not changed
+			continue
+		}
+		insertedIDs = append(insertedIDs, job.ID)
+		err = insertJobDependencies(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return insertedIDs, nil
+}
+
+func (j *jobworkerDB) AddJobs(ctx context.Context, jobs []*jobqueue.Job) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, len(jobs))
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var notIgnored []*jobqueue.Job
+	for _, job := range jobs {
+		if IgnoreJob(ctx, job) {
+			log.Debug("Ignoring job").
+				UUID("jobID", job.ID).
+				Log()
+			continue
+		}
+		if job.UniqueKey.IsNotNull() {
+			err = job.OnDuplicate.Validate()
+			if err != nil {
+				return err
+			}
+		}
+		notIgnored = append(notIgnored, job)
+	}
+	if len(notIgnored) == 0 {
+		return nil
+	}
+
+	if SynchronousJobs(ctx) {
+		pool := jobworker.GetPool(ctx)
+		for _, job := range notIgnored {
+			log.Debug("Synchronous job").
+				UUID("jobID", job.ID).
+				Log()
+			err = pool.DoJob(ctx, job)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		return insertJobs(ctx, notIgnored, false)
+	})
+}
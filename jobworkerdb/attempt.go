@@ -0,0 +1,82 @@
+package jobworkerdb
+
+import (
+	"context"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// insertJobAttempts records the ended attempt of the passed jobs
+// that are running, meaning started and not stopped.
+// Passed jobs that are not running are ignored.
+//
+// Has to be called within a transaction before the update that ends
+// the attempts, so that started_at and current_retry_count of the
+// attempt are still set and stopped_at is the now() of the update.
+// `for update` serializes concurrent writes ending the same attempt,
+// the one waiting re-checks the running condition and records nothing.
+//
+// The worker of the attempt is the worker that claimed the job,
+// not the process ending the attempt, which can be another one
+// for example with ResetJob.
+func insertJobAttempts(ctx context.Context, jobIDs uu.IDs, outcome jobqueue.JobAttemptOutcome, errorMsg nullable.NonEmptyString, errorData nullable.JSON) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobIDs, outcome, errorMsg, errorData)
+
+	return db.Exec(ctx,
+		/*sql*/ `
+			insert into worker.job_attempt (
+				job_id,
+				attempt,
+				retry_count,
+				outcome,
+				worker,
+				started_at,
+				stopped_at,
+				error_msg,
+				error_data
+			)
+			select
+				j.id,
+				(select count(*) from worker.job_attempt as a where a.job_id = j.id) + 1,
+				j.current_retry_count,
+				$2,
+				coalesce(j.worker, ''), -- '' for jobs claimed before the worker column existed
+				j.started_at,
+				now(),
+				$3,
+				$4
+			from worker.job as j
+			where j.id = any($1)
+				and j.started_at is not null
+				and j.stopped_at is null
+			for update of j
+		`,
+		jobIDs,    // $1
+		outcome,   // $2
+		errorMsg,  // $3
+		errorData, // $4
+	)
+}
+
+func (j *jobworkerDB) GetJobAttempts(ctx context.Context, jobID uu.ID) (attempts []*jobqueue.JobAttempt, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if j.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	return db.QueryRowsAsSlice[*jobqueue.JobAttempt](ctx,
+		/*sql*/ `
+			select *
+			from worker.job_attempt
+			where job_id = $1
+			order by attempt
+		`,
+		jobID, // $1
+	)
+}
@@ -26,12 +26,9 @@ var log = rootlog.NewPackageLogger()
 // stale worker_alive_at was abandoned by a crashed worker
 // (see [jobqueue.Job.WorkerAlive]).
 //
-// A process is expected to run a single active job-queue service at a time. The
-// claim and heartbeat prepared statements are cached in package-level state (not
-// per service instance) and are released by [jobworkerDB.Close], so to swap the
-// underlying database connection, Close the current service before calling
-// InitJobQueue again; do not run two services against different connections
-// concurrently, as the second init would reuse the first's cached statements.
+// The claim and heartbeat prepared statements are cached per service instance
+// and are released by its Close method, so to swap the underlying database
+// connection, Close the current service before calling InitJobQueue again.
 func InitJobQueue(ctx context.Context) (err error) {
 	defer errs.WrapWithFuncParams(&err, ctx)
 
@@ -49,6 +46,24 @@ func InitJobQueue(ctx context.Context) (err error) {
 	return nil
 }
 
+// NewDataBase returns a new PostgreSQL implementation of
+// jobworker.DataBase (and therefore jobqueue.Service)
+// for a jobworker.Pool created with jobworker.NewPool.
+//
+// Unlike InitJobQueue it does not register the returned value anywhere and
+// adds no ServiceListener. Like every function of the package it uses the
+// connection of the context passed to its methods, see db.Conn, so a Pool
+// working with another database than the global connection has to start its
+// threads with a context that has the connection of that database, see
+// db.ContextWithConn. Notifications are received with LISTEN on that
+// connection, so Pools that run side by side need connections of their own.
+//
+// The schema name worker and the notification channels are fixed,
+// so all instances using the same database work on the same queue.
+func NewDataBase() jobworker.DataBase {
+	return &jobworkerDB{}
+}
+
 // minDeadForHeartbeatFactor is the minimum multiple of
 // jobworker.HeartbeatInterval that deadFor must reach (when heartbeats are
 // enabled) before a job is treated as abandoned.
@@ -155,6 +170,11 @@ func InitJobQueueResetInterruptedJobs(ctx context.Context, deadFor time.Duration
 //     deadFor ago the worker is gone. Genuine final failures
 //     (current_retry_count >= max_retry_count) are left untouched.
 //
+// The attempt of a job that crashed mid-execution is recorded in the same
+// statement as jobqueue.JobAttemptInterrupted that stopped at its last
+// heartbeat. The errored job of a pre-heartbeat worker was already stopped,
+// so no attempt is recorded for it like for every stopped job.
+//
 // The deadFor cutoff is evaluated entirely with the database clock
 // (now() - interval) rather than the app-server clock, so the comparison against
 // the DB-written worker_alive_at / stopped_at columns is not affected by clock
@@ -164,7 +184,37 @@ func resetInterruptedRetryableJobs(ctx context.Context, deadFor time.Duration) (
 
 	return db.QueryRowAs[int](ctx,
 		/*sql*/ `
-			with resets as (
+			with interrupted as (
+				-- Crashed mid-execution: heartbeat went stale.
+				select id, current_retry_count, worker, started_at, worker_alive_at
+				from worker.job
+				where started_at is not null
+					and stopped_at is null
+					and worker_alive_at is not null
+					and worker_alive_at < now() - make_interval(secs => $1)
+				for update
+			),
+			attempts as (
+				insert into worker.job_attempt (
+					job_id,
+					attempt,
+					retry_count,
+					outcome,
+					worker,
+					started_at,
+					stopped_at
+				)
+				select
+					i.id,
+					(select count(*) from worker.job_attempt as a where a.job_id = i.id) + 1,
+					i.current_retry_count,
+					'interrupted',
+					coalesce(i.worker, ''),
+					i.started_at,
+					i.worker_alive_at
+				from interrupted as i
+			),
+			resets as (
 				update worker.job
 				set
 					started_at     =null,
@@ -172,26 +222,21 @@ func resetInterruptedRetryableJobs(ctx context.Context, deadFor time.Duration) (
 					error_msg      =null,
 					error_data     =null,
 					result         =null,
+					worker         =null,
 					worker_alive_at=null,
 					updated_at     =now()
 				where
-					started_at is not null
-					and (
-						(
-							-- Crashed mid-execution: heartbeat went stale.
-							stopped_at is null
-							and worker_alive_at is not null
-							and worker_alive_at < now() - make_interval(secs => $1)
-						) or (
-							-- Pre-heartbeat worker (rolling upgrade) that crashed
-							-- between SetJobError and ScheduleRetry. Current-version
-							-- SetJobError clamps current_retry_count to
-							-- max_retry_count, so it never lands here.
-							stopped_at is not null
-							and error_msg is not null
-							and current_retry_count < max_retry_count
-							and stopped_at < now() - make_interval(secs => $1)
-						)
+					id in (select id from interrupted)
+					or (
+						-- Pre-heartbeat worker (rolling upgrade) that crashed
+						-- between SetJobError and ScheduleRetry. Current-version
+						-- SetJobError clamps current_retry_count to
+						-- max_retry_count, so it never lands here.
+						started_at is not null
+						and stopped_at is not null
+						and error_msg is not null
+						and current_retry_count < max_retry_count
+						and stopped_at < now() - make_interval(secs => $1)
 					)
 				returning id
 			)
@@ -11,6 +11,10 @@ type synchronousJobsCtxKey struct{}
 // ContextWithSynchronousJobs returns a context that makes added jobs run inline
 // (synchronously) instead of being persisted to the database for a worker to
 // pick up. Use SynchronousJobs to test a context for this flag.
+//
+// The jobs are run with the workers of the jobworker.Pool returned by
+// jobworker.GetPool, so add a Pool created with jobworker.NewPool
+// to the context with jobworker.ContextWithPool.
 func ContextWithSynchronousJobs(ctx context.Context) context.Context {
 	return context.WithValue(ctx, synchronousJobsCtxKey{}, struct{}{})
 }
@@ -1,11 +1,16 @@
 package jobworkerdb_test
 
 import (
+	"context"
 	"testing"
 
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
 	"github.com/domonda/go-jobqueue/jobworkerdb"
 )
 
@@ -14,6 +19,35 @@ func TestSynchronousJobs(t *testing.T) {
 	assert.True(t, jobworkerdb.SynchronousJobs(jobworkerdb.ContextWithSynchronousJobs(t.Context())))
 }
 
+func TestSynchronousJobsWithPool(t *testing.T) {
+	const jobType = "jobworkerdb-test-synchronous"
+	db := jobworkerdb.NewDataBase()
+	pool := jobworker.NewPool(db)
+	var ran uu.IDs
+	pool.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		ran = append(ran, job.ID)
+		return "done", nil
+	})
+	require.False(t, jobworker.DefaultPool().IsRegistered(jobType), "worker only registered with the Pool")
+
+	newJob := func() *jobqueue.Job {
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), jobType, "jobworkerdb-test", `{}`, nullable.Time{})
+		require.NoError(t, err)
+		return job
+	}
+	single, batch, bundled := newJob(), newJob(), newJob()
+	bundle := &jobqueue.JobBundle{ID: uu.NewID(t.Context()), Type: "jobworkerdb-test-bundle", Jobs: []*jobqueue.Job{bundled}}
+
+	ctx := jobworker.ContextWithPool(jobworkerdb.ContextWithSynchronousJobs(t.Context()), pool)
+	require.NoError(t, db.AddJob(ctx, single))
+	require.NoError(t, db.AddJobs(ctx, []*jobqueue.Job{batch}))
+	require.NoError(t, db.AddJobBundle(ctx, bundle))
+	assert.Equal(t, uu.IDs{single.ID, batch.ID, bundled.ID}, ran)
+	assert.JSONEq(t, `"done"`, single.Result.String())
+
+	assert.Error(t, db.AddJob(jobworkerdb.ContextWithSynchronousJobs(t.Context()), newJob()), "no worker registered with the default Pool")
+}
+
 func TestIgnoreJob(t *testing.T) {
 	job := &jobqueue.Job{Type: "type-a"}
 
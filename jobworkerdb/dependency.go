@@ -0,0 +1,176 @@
+package jobworkerdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// insertJobDependencies inserts the worker.job_dependency rows for job.DependsOn.
+// The job and all its dependencies must already be inserted,
+// so for a job bundle it has to be called after all jobs of the bundle were inserted.
+//
+// A job with the jobqueue.DependencyFailureCancel policy that depends
+// on an already failed job is cancelled right away.
+func insertJobDependencies(ctx context.Context, job *jobqueue.Job) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, job)
+
+	if len(job.DependsOn) == 0 {
+		return nil
+	}
+
+	err = db.Exec(ctx,
+		/*sql*/ `
+			insert into worker.job_dependency (job_id, depends_on_id)
+			select $1, unnest($2::uuid[])
+			on conflict do nothing
+		`,
+		job.ID,        // $1
+		job.DependsOn, // $2
+	)
+	if err != nil {
+		return err
+	}
+
+	if job.OnDependencyFailure != jobqueue.DependencyFailureCancel {
+		return nil
+	}
+	return cancelDependentsOfFailedJobs(ctx, job.DependsOn)
+}
+
+// cancelDependentsOfFailedJobs cancels all not started jobs with the
+// jobqueue.DependencyFailureCancel policy that depend on one of the passed jobs
+// that has finally failed, meaning it stopped with an error and exhausted
+// its retries, or it was cancelled. Passed jobs that did not fail are ignored,
+// so it's safe to call it after every stop of a job.
+//
+// The cancellation cascades to the dependents of cancelled jobs
+// that also use the jobqueue.DependencyFailureCancel policy,
+// and every cancelled job is counted in its bundle.
+// Has to be called within a transaction together with stopping the failed jobs.
+func cancelDependentsOfFailedJobs(ctx context.Context, jobIDs uu.IDs) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobIDs)
+
+	return cancelDependents(ctx,
+		/*sql*/ `
+			select j.id, j.bundle_id
+			from worker.job_dependency as d
+				inner join worker.job as j on j.id = d.job_id
+			where d.depends_on_id in (
+					select f.id
+					from worker.job as f
+					where f.id = any($1)
+						and f.stopped_at is not null
+						and (
+							-- failed with no retries left
+							(f.error_msg is not null and f.current_retry_count >= f.max_retry_count)
+							or
+							-- cancelled, see jobqueue.Job.Cancelled
+							f.cancelled_at is not null
+						)
+				)
+				and j.on_dependency_failure = 'cancel'
+				and j.started_at is null
+				and j.stopped_at is null
+		`,
+		jobIDs, // $1
+	)
+}
+
+// cancelDependentsOfDeletedJobs cancels all not started jobs with the
+// jobqueue.DependencyFailureBlock or jobqueue.DependencyFailureCancel policy
+// that depend on one of the jobs matching the SQL condition jobsWhere
+// on the alias f with args, if that job did not succeed.
+// A deleted job can never succeed, and the ON DELETE CASCADE
+// of worker.job_dependency would unblock its dependents.
+//
+// The cancellation cascades like the one of cancelDependentsOfFailedJobs.
+// Has to be called within a transaction together with deleting the jobs.
+func cancelDependentsOfDeletedJobs(ctx context.Context, jobsWhere string, args ...any) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobsWhere, args)
+
+	return cancelDependents(ctx,
+		fmt.Sprintf(
+			/*sql*/ `
+				select j.id, j.bundle_id
+				from worker.job_dependency as d
+					inner join worker.job as j on j.id = d.job_id
+				where d.depends_on_id in (
+						select f.id
+						from worker.job as f
+						where %s
+							-- not succeeded, see jobqueue.Job.Succeeded
+							and not (
+								f.stopped_at is not null
+								and f.error_msg is null
+								and f.cancelled_at is null
+							)
+					)
+					and j.on_dependency_failure in ('block', 'cancel')
+					and j.started_at is null
+					and j.stopped_at is null
+			`,
+			jobsWhere, // for where %s
+		),
+		args...,
+	)
+}
+
+// cancelDependents stops the not started jobs selected by dependentsSQL
+// with args as cancelled together with the not started jobs
+// with the jobqueue.DependencyFailureCancel policy that depend on them,
+// directly or via other cancelled jobs, and counts them in their bundles.
+// dependentsSQL has to select the id and bundle_id of the jobs.
+func cancelDependents(ctx context.Context, dependentsSQL string, args ...any) error {
+	// `union` instead of `union all` drops rows that were already visited,
+	// so the recursion terminates even for cyclic dependencies.
+	// The started_at/stopped_at guard of the update is re-checked
+	// for rows that were claimed concurrently, so a job that a worker
+	// has started in the meantime is not stopped here.
+	return db.Exec(ctx,
+		fmt.Sprintf(
+			/*sql*/ `
+				with recursive cancelled as (
+					%s
+					union
+					select j.id, j.bundle_id
+					from cancelled as c
+						inner join worker.job_dependency as d on d.depends_on_id = c.id
+						inner join worker.job as j on j.id = d.job_id
+					where j.on_dependency_failure = 'cancel'
+						and j.started_at is null
+						and j.stopped_at is null
+				),
+				stopped as (
+					update worker.job
+					set
+						cancel_requested_at=coalesce(worker.job.cancel_requested_at, now()),
+						stopped_at=now(),
+						cancelled_at=now(),
+						updated_at=now()
+					from cancelled
+					where worker.job.id = cancelled.id
+						and worker.job.started_at is null
+						and worker.job.stopped_at is null
+					returning worker.job.bundle_id
+				)
+				update worker.job_bundle as b
+				set num_jobs_stopped = b.num_jobs_stopped + counted.cnt, updated_at = now()
+				from (
+					select bundle_id, count(*) as cnt
+					from stopped
+					where bundle_id is not null
+					group by bundle_id
+				) as counted
+				where b.id = counted.bundle_id
+			`,
+			dependentsSQL, // for with recursive cancelled as (%s
+		),
+		args...,
+	)
+}
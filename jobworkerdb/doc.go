@@ -28,6 +28,16 @@ worker processes share the same database:
 
 	err := jobworkerdb.InitJobQueueResetInterruptedJobs(ctx, deadFor)
 
+Use [NewDataBase] for a jobworker.Pool created with jobworker.NewPool.
+It is not registered anywhere and uses the connection of the context
+passed to its methods, see db.ContextWithConn:
+
+	pool := jobworker.NewPool(jobworkerdb.NewDataBase())
+	err := pool.StartThreads(db.ContextWithConn(ctx, otherConn), 4)
+
+The schema name worker and the LISTEN/NOTIFY channels are not configurable,
+so every Pool working with the same database shares one queue.
+
 # Database Schema
 
 The package requires the worker schema in PostgreSQL with:
@@ -71,12 +81,67 @@ other abandoned job, subject to its deadFor grace period:
 	set worker_alive_at=started_at, updated_at=now()
 	where started_at is not null and stopped_at is null and worker_alive_at is null;
 
+Job cancellation needs the worker.job.cancel_requested_at and cancelled_at columns
+and the job_cancel_requested trigger from schema/worker/job_triggers.sql:
+
+	alter table worker.job add column if not exists cancel_requested_at timestamptz;
+	alter table worker.job add column if not exists cancelled_at timestamptz;
+
+Job dependencies need the worker.job.on_dependency_failure column,
+the worker.job_dependency table from schema/worker/job_dependency.sql,
+and the job_dependents_available trigger from schema/worker/job_triggers.sql:
+
+	alter table worker.job add column if not exists on_dependency_failure text not null default 'block'
+		check(on_dependency_failure in ('block', 'cancel', 'run'));
+
+Job deduplication needs the worker.job.unique_key column
+and the worker_job_unique_key_idx index from schema/worker/job.sql:
+
+	alter table worker.job add column if not exists unique_key text check(length(unique_key) > 0);
+	create unique index worker_job_unique_key_idx on worker.job("type", unique_key)
+		where unique_key is not null and stopped_at is null;
+
+Recurring jobs need the worker.job_schedule table from schema/worker/job_schedule.sql,
+the worker.job.schedule_name and schedule_fire_time columns,
+and the worker_job_schedule_fire_time_idx index from schema/worker/job.sql:
+
+	alter table worker.job add column if not exists schedule_name text references worker.job_schedule(name) on delete set null;
+	alter table worker.job add column if not exists schedule_fire_time timestamptz;
+	create unique index worker_job_schedule_fire_time_idx on worker.job(schedule_name, schedule_fire_time)
+		where schedule_name is not null;
+
+The cluster-wide limits of jobworker.SetClusterMaxConcurrency count the running
+jobs of a type with the worker_job_running_type_idx index from schema/worker/job.sql.
+Until it exists the limits still work, only slower:
+
+	create index concurrently if not exists worker_job_running_type_idx
+		on worker.job("type") where started_at is not null and stopped_at is null;
+
+The rate limits of jobworker.SetRateLimit need the worker.rate_limit table
+from schema/worker/rate_limit.sql.
+
+The attempt history of jobqueue.GetJobAttempts needs the worker.job_attempt table
+from schema/worker/job_attempt.sql and the worker.job.worker column
+with the worker that claimed the job. Until they exist claiming jobs
+and every write that ends a running job fail:
+
+	alter table worker.job add column if not exists worker text;
+
+The jobqueue.TraceContext of jobs needs the worker.job.trace_context column.
+Until it exists inserting jobs fails:
+
+	alter table worker.job add column if not exists trace_context jsonb;
+
 # LISTEN/NOTIFY
 
 The service uses PostgreSQL LISTEN/NOTIFY for real-time job notifications:
-  - job_available: Fired when a new job is ready to process
+  - job_available: Fired when a new job is ready to process,
+    or when a job stopped that other waiting jobs depend on.
+    AddJobs and AddJobBundle fire it once per call
+    with the number of available jobs as payload
   - job_stopped: Fired when a job completes
   - job_bundle_stopped: Fired when all jobs in a bundle complete
+  - job_cancel_requested: Fired when CancelJob is called for a running job
 
 # Testing Utilities
 
@@ -87,6 +152,11 @@ Synchronous job execution (no database persistence):
 	ctx = jobworkerdb.ContextWithSynchronousJobs(ctx)
 	jobqueue.Add(ctx, job) // Executes immediately
 
+The jobs are run with the default jobworker.Pool
+or the Pool added with jobworker.ContextWithPool:
+
+	ctx = jobworker.ContextWithPool(ctx, pool)
+
 Ignore all jobs using the [IgnoreAllJobs] predicate:
 
 	ctx = jobworkerdb.ContextWithIgnoreJob(ctx, jobworkerdb.IgnoreAllJobs)
@@ -111,6 +181,23 @@ Custom filtering with [IgnoreJobFunc] and [IgnoreJobBundleFunc]:
 
 The implementation uses database transactions to ensure consistency:
   - Job bundles are inserted atomically with all their jobs
+  - AddJobs inserts all jobs or none with multi-row inserts
   - Job completion updates job_bundle.num_jobs_stopped in a transaction
+  - Ending a running job records its worker.job_attempt row in the same transaction
+
+All methods use the connection of the context passed to them, so AddJob and
+AddJobBundle join a transaction started by the caller with db.Transaction
+instead of starting their own. The job is only added if the caller's transaction
+commits, and PostgreSQL sends the job_available notification only on commit,
+so workers don't see a job before it exists for them.
+Use jobqueue.AddInTx to make sure the job is added within a transaction:
+
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		err := db.InsertRowStruct(ctx, invoice)
+		if err != nil {
+			return err
+		}
+		return jobqueue.AddInTx(ctx, job)
+	})
 */
 package jobworkerdb
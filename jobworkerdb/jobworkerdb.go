@@ -1,10 +1,13 @@
 package jobworkerdb
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,10 +24,22 @@ import (
 )
 
 type jobworkerDB struct {
-	serviceListeners        []jobqueue.ServiceListener
-	hasJobAvailableListener bool
-	listenersMtx            sync.Mutex
-	closed                  atomic.Bool
+	serviceListeners              []jobqueue.ServiceListener
+	hasJobAvailableListener       bool
+	hasJobCancelRequestedListener bool
+	listenersMtx                  sync.Mutex
+	closed                        atomic.Bool
+
+	// claimJobStmt cache, guarded by claimJobStmtMtx
+	claimJobStmtMtx   sync.Mutex
+	claimJobStmtClaim *jobworker.Claim
+	claimJobStmtQuery func(ctx context.Context, args ...any) (*jobqueue.Job, error)
+	claimJobStmtClose func() error
+
+	// setJobWorkerAliveStmt cache, guarded by setJobWorkerAliveMtx
+	setJobWorkerAliveMtx   sync.Mutex
+	setJobWorkerAliveExec  func(ctx context.Context, args ...any) error
+	setJobWorkerAliveClose func() error
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -131,41 +146,174 @@ func (*jobworkerDB) unlisten(ctx context.Context) (err error) {
 	return errors.Join(err1, err2)
 }
 
-func insertJob(ctx context.Context, job *jobqueue.Job) (err error) {
-	defer errs.WrapWithFuncParams(&err, ctx, job)
+// insertJob inserts job and returns if it was inserted.
+//
+// For a job with a UniqueKey, onDuplicate defines what happens when an
+// unfinished job with the same type and UniqueKey already exists,
+// see jobqueue.DuplicateJobPolicy. In that case the job is not inserted,
+// and job.ID is set to the ID of the existing job if no error is returned.
+//
+// A job with a ScheduleName is not inserted without an error if a job
+// for the same schedule and ScheduleFireTime already exists.
+// Its UniqueKey is not checked for duplicates before the insert,
+// so a conflicting UniqueKey results in a unique violation error.
+func insertJob(ctx context.Context, job *jobqueue.Job, onDuplicate jobqueue.DuplicateJobPolicy) (inserted bool, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, job, onDuplicate)
+
+	// The conflict targets match the partial unique indexes
+	// worker_job_schedule_fire_time_idx and worker_job_unique_key_idx,
+	// rows with a NULL schedule_name or unique_key never conflict.
+	var onConflict string
+	switch {
+	case job.ScheduleName.IsNotNull():
+		onConflict = `on conflict (schedule_name, schedule_fire_time) where schedule_name is not null do nothing`
+
+	case job.UniqueKey.IsNotNull():
+		switch onDuplicate.OrDefault() {
+		case jobqueue.DuplicateJobFail:
+			onConflict = `do nothing`
+		case jobqueue.DuplicateJobUseExisting:
+			// No-op update so that the id of the existing row is returned
+			onConflict = `do update set unique_key=excluded.unique_key`
+		case jobqueue.DuplicateJobReplace:
+			onConflict = `do update set payload=excluded.payload, start_at=excluded.start_at, updated_at=now() where worker.job.started_at is null`
+		default:
+			return false, onDuplicate.Validate()
+		}
+		onConflict = `on conflict ("type", unique_key) where unique_key is not null and stopped_at is null ` + onConflict
+	}
 
-	return db.Exec(ctx,
+	jobID, err := db.QueryRowAsOr(ctx,
+		uu.IDNil,
+		fmt.Sprintf(
+			/*sql*/ `
+				INSERT INTO worker.job
+				(
+					id,
+					bundle_id,
+					type,
+					payload,
+					priority,
+					origin,
+					max_retry_count,
+					start_at,
+					on_dependency_failure,
+					unique_key,
+					schedule_name,
+					schedule_fire_time,
+					trace_context
+				) VALUES (
+					$1,
+					$2,
+					$3,
+					$4,
+					$5,
+					$6,
+					$7,
+					$8,
+					$9,
+					$10,
+					$11,
+					$12,
+					$13
+				)
+				%s
+				RETURNING id
+			`,
+			onConflict, // for %s
+		),
+		job.ID,                              // $1
+		job.BundleID,                        // $2
+		job.Type,                            // $3
+		job.Payload,                         // $4
+		job.Priority,                        // $5
+		job.Origin,                          // $6
+		job.MaxRetryCount,                   // $7
+		job.StartAt,                         // $8
+		job.OnDependencyFailure.OrDefault(), // $9
+		job.UniqueKey,                       // $10
+		job.ScheduleName,                    // $11
+		job.ScheduleFireTime,                // $12
+		job.TraceContext,                    // $13
+	)
+	switch {
+	case err != nil:
+		return false, err
+	case jobID == job.ID:
+		return true, nil
+	case jobID.IsNotNil():
+		// Existing job returned by the update of the conflict clause
+		job.ID = jobID
+		return false, nil
+	case job.ScheduleName.IsNotNull():
+		// The occurrence of the schedule was already added
+		return false, nil
+	}
+
+	// Neither inserted nor updated because of DuplicateJobFail
+	// or because DuplicateJobReplace found a started job
+	existingJobID, err := db.QueryRowAsOr(ctx,
+		uu.IDNil,
 		/*sql*/ `
-			INSERT INTO worker.job
-			(
-				id,
-				bundle_id,
-				type,
-				payload,
-				priority,
-				origin,
-				max_retry_count,
-				start_at
-			) VALUES (
-				$1,
-				$2,
-				$3,
-				$4,
-				$5,
-				$6,
-				$7,
-				$8
-			)
+			select id
+			from worker.job
+			where "type" = $1
+				and unique_key = $2
+				and stopped_at is null
+		`,
+		job.Type,      // $1
+		job.UniqueKey, // $2
+	)
+	if err != nil {
+		return false, err
+	}
+	if existingJobID.IsNil() {
+		// The existing job stopped in the meantime,
+		// so the unique key is free again
+		return insertJob(ctx, job, onDuplicate)
+	}
+	return false, &jobqueue.DuplicateJobError{
+		JobType:       job.Type,
+		UniqueKey:     job.UniqueKey.Get(),
+		ExistingJobID: existingJobID,
+	}
+}
+
+// restartedJobsDuplicateError returns a jobqueue.DuplicateJobError
+// instead of err if err is a violation of the worker_job_unique_key_idx index
+// caused by making one of the stopped jobs with jobIDs unfinished again
+// while an unfinished job with the same type and UniqueKey exists.
+// Other errors are returned unchanged.
+// Has to be called after the failed transaction was rolled back.
+func restartedJobsDuplicateError(ctx context.Context, err error, jobIDs uu.IDs) error {
+	var uniqueViolation sqldb.ErrUniqueViolation
+	if !errors.As(err, &uniqueViolation) || uniqueViolation.Constraint != "worker_job_unique_key_idx" {
+		return err
+	}
+	// The other job may also be one of the restarted jobs
+	jobType, uniqueKey, existingJobID, e := db.QueryRowAs3[string, string, uu.ID](ctx,
+		/*sql*/ `
+			select r."type", r.unique_key, e.id
+			from worker.job as r
+				inner join worker.job as e
+					on e."type" = r."type"
+					and e.unique_key = r.unique_key
+					and e.id <> r.id
+			where r.id = any($1)
+				and (e.stopped_at is null or e.id = any($1))
+			order by r.created_at, e.created_at
+			limit 1
 		`,
-		job.ID,            // $1
-		job.BundleID,      // $2
-		job.Type,          // $3
-		job.Payload,       // $4
-		job.Priority,      // $5
-		job.Origin,        // $6
-		job.MaxRetryCount, // $7
-		job.StartAt,       // $8
+		jobIDs, // $1
 	)
+	if e != nil {
+		return err
+	}
+	return &jobqueue.DuplicateJobError{
+		JobType:       jobType,
+		UniqueKey:     uniqueKey,
+		ExistingJobID: existingJobID,
+	}
 }
 
 func (j *jobworkerDB) AddJob(ctx context.Context, job *jobqueue.Job) (err error) {
@@ -186,10 +334,28 @@ func (j *jobworkerDB) AddJob(ctx context.Context, job *jobqueue.Job) (err error)
 		log.Debug("Synchronous job").
 			UUID("jobID", job.ID).
 			Log()
-		return jobworker.DoJob(ctx, job)
+		return jobworker.GetPool(ctx).DoJob(ctx, job)
+	}
+
+	if job.UniqueKey.IsNotNull() {
+		err = job.OnDuplicate.Validate()
+		if err != nil {
+			return err
+		}
 	}
 
-	return insertJob(ctx, job)
+	if len(job.DependsOn) == 0 {
+		_, err = insertJob(ctx, job, job.OnDuplicate)
+		return err
+	}
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		inserted, err := insertJob(ctx, job, job.OnDuplicate)
+		if err != nil || !inserted {
+			// The dependencies of an existing job are not changed
+			return err
+		}
+		return insertJobDependencies(ctx, job)
+	})
 }
 
 func (j *jobworkerDB) AddJobBundle(ctx context.Context, jobBundle *jobqueue.JobBundle) (err error) {
@@ -215,8 +381,9 @@ func (j *jobworkerDB) AddJobBundle(ctx context.Context, jobBundle *jobqueue.JobB
 		log.Debug("Synchronous job-bundle").
 			UUID("jobBundleID", jobBundle.ID).
 			Log()
+		pool := jobworker.GetPool(ctx)
 		for _, job := range jobBundle.Jobs {
-			err = jobworker.DoJob(ctx, job)
+			err = pool.DoJob(ctx, job)
 			if err != nil {
 				return err
 			}
@@ -245,16 +412,25 @@ func (j *jobworkerDB) AddJobBundle(ctx context.Context, jobBundle *jobqueue.JobB
 			return err
 		}
 
-		for _, job := range jobBundle.Jobs {
-			err = insertJob(ctx, job)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+		// A duplicate can't be left out of the bundle without
+		// breaking its num_jobs, so it always fails the whole bundle
+		return insertJobs(ctx, jobBundle.Jobs, true)
 	})
 }
 
+// jobStateSQL is the SQL expression of the jobqueue.JobState of a worker.job row.
+const jobStateSQL = /*sql*/ `
+	case
+		when stopped_at is null and started_at is not null then 'running'
+		when stopped_at is null and current_retry_count > 0 then 'retrying'
+		when stopped_at is null and start_at > now()        then 'delayed'
+		when stopped_at is null                             then 'pending'
+		when error_msg is not null and current_retry_count < max_retry_count then 'retrying'
+		when error_msg is not null                          then 'failed'
+		when cancelled_at is not null                       then 'cancelled'
+		else 'succeeded'
+	end`
+
 func (j *jobworkerDB) GetStatus(ctx context.Context) (status *jobqueue.Status, err error) {
 	defer errs.WrapWithFuncParams(&err, ctx)
 
@@ -262,20 +438,103 @@ func (j *jobworkerDB) GetStatus(ctx context.Context) (status *jobqueue.Status, e
 		return nil, jobqueue.ErrClosed
 	}
 
-	status = new(jobqueue.Status)
-	status.NumJobs, status.NumJobBundles, err = db.QueryRowAs2[int, int](ctx,
+	// One aggregate over all jobs with the grouping sets
+	// of all jobs, the job types, and the origins.
+	// The states partition the jobs like the docs of jobqueue.JobStats,
+	// the ages are returned as microseconds.
+	// The oldest pending age covers all startable jobs,
+	// which are the pending jobs and the due retries.
+	rows, err := db.QueryRowsAsSlice[*jobStatsRow](ctx,
 		/*sql*/ `
+			with job_state as (
+				select
+					"type",
+					origin,
+					`+jobStateSQL+` as state,
+					greatest(start_at, created_at) as available_at,
+					stopped_at is null and started_at is null and coalesce(start_at <= now(), true) as startable,
+					worker_alive_at
+				from worker.job
+			)
 			select
-			(select count(*) from worker.job)        as num_jobs,
-			(select count(*) from worker.job_bundle) as num_job_bundles
+				case
+					when grouping("type") = 0 then 'type'
+					when grouping(origin) = 0 then 'origin'
+					else ''
+				end as grouped_by,
+				coalesce(
+					case when grouping("type") = 0 then "type" end,
+					case when grouping(origin) = 0 then origin end,
+					''
+				) as key,
+				count(*) filter (where state = 'pending')   as pending,
+				count(*) filter (where state = 'delayed')   as delayed,
+				count(*) filter (where state = 'running')   as running,
+				count(*) filter (where state = 'retrying')  as retrying,
+				count(*) filter (where state = 'failed')    as failed,
+				count(*) filter (where state = 'cancelled') as cancelled,
+				count(*) filter (where state = 'succeeded') as succeeded,
+				coalesce((extract(epoch from now() - min(available_at) filter (where startable)) * 1000000)::bigint, 0) as oldest_pending_us,
+				coalesce((extract(epoch from now() - min(worker_alive_at) filter (where state = 'running')) * 1000000)::bigint, 0) as oldest_heartbeat_us,
+				(select count(*) from worker.job_bundle) as num_job_bundles
+			from job_state
+			group by grouping sets ((), ("type"), (origin))
 		`,
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	status = &jobqueue.Status{
+		ByType:   make(map[string]jobqueue.JobStats),
+		ByOrigin: make(map[string]jobqueue.JobStats),
+	}
+	for _, row := range rows {
+		switch row.GroupedBy {
+		case "type":
+			status.ByType[row.Key] = row.jobStats()
+		case "origin":
+			status.ByOrigin[row.Key] = row.jobStats()
+		default:
+			status.Jobs = row.jobStats()
+			status.NumJobs = status.Jobs.NumJobs()
+			status.NumJobBundles = row.NumJobBundles
+		}
+	}
 	return status, nil
 }
 
+// jobStatsRow is a row of the GetStatus query with the jobqueue.JobStats
+// of all jobs, or of the jobs of the job type or origin in Key.
+type jobStatsRow struct {
+	GroupedBy         string `db:"grouped_by"`
+	Key               string `db:"key"`
+	Pending           int    `db:"pending"`
+	Delayed           int    `db:"delayed"`
+	Running           int    `db:"running"`
+	Retrying          int    `db:"retrying"`
+	Failed            int    `db:"failed"`
+	Cancelled         int    `db:"cancelled"`
+	Succeeded         int    `db:"succeeded"`
+	OldestPendingUS   int64  `db:"oldest_pending_us"`
+	OldestHeartbeatUS int64  `db:"oldest_heartbeat_us"`
+	NumJobBundles     int    `db:"num_job_bundles"`
+}
+
+func (r *jobStatsRow) jobStats() jobqueue.JobStats {
+	return jobqueue.JobStats{
+		Pending:            r.Pending,
+		Delayed:            r.Delayed,
+		Running:            r.Running,
+		Retrying:           r.Retrying,
+		Failed:             r.Failed,
+		Cancelled:          r.Cancelled,
+		Succeeded:          r.Succeeded,
+		OldestPendingAge:   time.Duration(r.OldestPendingUS) * time.Microsecond,
+		OldestHeartbeatAge: time.Duration(r.OldestHeartbeatUS) * time.Microsecond,
+	}
+}
+
 func (j *jobworkerDB) GetAllJobsToDo(ctx context.Context) (jobs []*jobqueue.Job, err error) {
 	defer errs.WrapWithFuncParams(&err, ctx)
 
@@ -330,6 +589,47 @@ func (j *jobworkerDB) GetAllJobsWithErrors(ctx context.Context) (jobs []*jobqueu
 	)
 }
 
+func (j *jobworkerDB) GetJobs(ctx context.Context, filter *jobqueue.JobFilter) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, filter)
+
+	if j.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+	if err = filter.Validate(); err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = new(jobqueue.JobFilter)
+	}
+
+	// Empty filter values select all jobs, a zero limit is null,
+	// which means no limit. Without NewestFirst the first order
+	// expression is null for all rows, so created_at orders ascending.
+	return db.QueryRowsAsSlice[*jobqueue.Job](ctx,
+		/*sql*/ `
+			select *
+			from worker.job
+			where ($1 = '' or "type" = $1)
+				and ($2 = '' or origin = $2)
+				and ($3::uuid is null or bundle_id = $3)
+				and ($4 = '' or `+jobStateSQL+` = $4)
+				and ($5 = '' or error_msg ~ $5)
+			order by
+				case when $7 then created_at end desc,
+				created_at,
+				id
+			limit nullif($6, 0)
+		`,
+		filter.Type,          // $1
+		filter.Origin,        // $2
+		filter.BundleID,      // $3
+		string(filter.State), // $4
+		filter.ErrorPattern,  // $5
+		filter.Limit,         // $6
+		filter.NewestFirst,   // $7
+	)
+}
+
 func (j *jobworkerDB) Close() (err error) {
 	defer errs.WrapWithFuncParams(&err)
 
@@ -347,6 +647,11 @@ func (j *jobworkerDB) Close() (err error) {
 		j.hasJobAvailableListener = false
 	}
 
+	if j.hasJobCancelRequestedListener {
+		err = errors.Join(err, db.UnlistenChannel(ctx, "job_cancel_requested"))
+		j.hasJobCancelRequestedListener = false
+	}
+
 	if len(j.serviceListeners) > 0 {
 		e := j.unlisten(ctx)
 		if e != nil {
@@ -360,7 +665,7 @@ func (j *jobworkerDB) Close() (err error) {
 
 	// Release the cached prepared statements (claim + heartbeat). The closed flag
 	// is already set above, so no new claim or heartbeat will start using them.
-	err = errors.Join(err, closeCachedStmts())
+	err = errors.Join(err, j.closeCachedStmts())
 
 	return err
 }
@@ -396,6 +701,44 @@ func (j *jobworkerDB) SetJobAvailableListener(ctx context.Context, callback func
 	)
 }
 
+func (j *jobworkerDB) SetJobCancelRequestedListener(ctx context.Context, callback func(jobID uu.ID)) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, callback)
+
+	j.listenersMtx.Lock()
+	defer j.listenersMtx.Unlock()
+
+	if j.hasJobCancelRequestedListener {
+		err = db.UnlistenChannel(ctx, "job_cancel_requested")
+		if err != nil {
+			return err
+		}
+	}
+
+	if callback == nil {
+		j.hasJobCancelRequestedListener = false
+		return nil
+	}
+
+	j.hasJobCancelRequestedListener = true
+	return db.ListenOnChannel(ctx,
+		"job_cancel_requested",
+		func(channel, payload string) {
+			defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), channel, payload)
+
+			var notification struct {
+				ID uu.ID `json:"id"`
+			}
+			err := json.Unmarshal([]byte(payload), &notification)
+			if err != nil {
+				log.ErrorCtx(ctx, "onJobCancelRequested").Err(err).Log()
+				return
+			}
+			callback(notification.ID)
+		},
+		nil,
+	)
+}
+
 func (j *jobworkerDB) GetJob(ctx context.Context, jobID uu.ID) (job *jobqueue.Job, err error) {
 	defer errs.WrapWithFuncParams(&err, ctx, jobID)
 
@@ -403,9 +746,30 @@ func (j *jobworkerDB) GetJob(ctx context.Context, jobID uu.ID) (job *jobqueue.Jo
 		return nil, jobqueue.ErrClosed
 	}
 
-	return db.QueryRowAs[*jobqueue.Job](ctx,
-		/*sql*/ `select * from worker.job where id = $1`, jobID,
-	)
+	err = db.TransactionReadOnly(ctx, func(ctx context.Context) error {
+		job, err = db.QueryRowAs[*jobqueue.Job](ctx,
+			/*sql*/ `select * from worker.job where id = $1`, jobID,
+		)
+		if err != nil {
+			return err
+		}
+
+		job.DependsOn, err = db.QueryRowsAsSlice[uu.ID](ctx,
+			/*sql*/ `
+				select depends_on_id
+				from worker.job_dependency
+				where job_id = $1
+				order by depends_on_id
+			`,
+			jobID, // $1
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
 }
 
 // buildClaimJobQuery assembles the StartNextJobOrNil claim statement: a single
@@ -414,148 +778,425 @@ func (j *jobworkerDB) GetJob(ctx context.Context, jobID uu.ID) (job *jobqueue.Jo
 // row. Being parameterless lets it be a cached prepared statement.
 //
 // The registered job types are inlined as a `"type" in ('a','b')` predicate; the
-// set is constant per process (it only changes via jobworker.Register/Unregister,
-// normally at startup) and is SQL-injection checked at registration, so inlining
-// the types as literals lets PostgreSQL plan against the actual values instead of
-// an opaque `= any($n)` array parameter.
+// set is constant per jobworker.Claim (it only changes via jobworker.Register/
+// Unregister, normally at startup) and is SQL-injection checked at registration,
+// so inlining the types as literals lets PostgreSQL plan against the actual values
+// instead of an opaque `= any($n)` array parameter.
 //
 // All timestamps use the database clock (now()), so started_at / worker_alive_at /
 // updated_at are immune to clock skew between worker processes and the database.
 // worker_alive_at is stamped with now() only when heartbeats are enabled; with
 // heartbeats disabled it is left NULL so the reaper's heartbeat-staleness branch
 // (which requires worker_alive_at IS NOT NULL) stays inert and never resets a
-// still-running job that has no liveness signal. HeartbeatInterval is process
-// startup config, so that choice is inlined here too — no query parameter.
+// still-running job that has no liveness signal. heartbeat comes from
+// jobworker.Claim.Heartbeat, so that choice is inlined here too — no query parameter.
+// Likewise worker from jobworker.Claim.Worker is inlined as the string literal
+// of the worker column, which insertJobAttempts copies into the attempts.
+//
+// If jobTypes have limits, a CTE `saturated` with the claimLimits.saturatedQuery
+// is evaluated once per statement and excludes the types that reached a limit.
+// Without limits the CTE and its predicate are left out, so the prepared claim
+// statement is not affected.
+//
+// The jobs of deferJobTypes are never claimed by the statement, see buildClaimQuery.
 //
 // jobTypes must be non-empty; StartNextJobOrNil returns early for the empty case
 // (nothing to claim) so this never builds an invalid empty `in ()`.
-func buildClaimJobQuery(jobTypes []string, conn sqldb.QueryFormatter) string {
+func buildClaimJobQuery(jobTypes []string, limits claimLimits, deferJobTypes []string, heartbeat bool, worker string, conn sqldb.QueryFormatter) string {
+	return buildClaimQuery(jobTypes, limits, deferJobTypes, heartbeat, worker, "1", conn)
+}
+
+// buildClaimJobsQuery assembles the StartNextJobsOrNil claim statement
+// that claims up to as many jobs as the query parameter $1
+// instead of a single one. The started jobs are returned in no particular order.
+//
+// There are no limits, because a limit that is not reached yet
+// could be exceeded by the jobs claimed together.
+// The jobs of the limited job types are passed as deferJobTypes instead,
+// see buildClaimQuery.
+func buildClaimJobsQuery(jobTypes, deferJobTypes []string, heartbeat bool, worker string, conn sqldb.QueryFormatter) string {
+	return buildClaimQuery(jobTypes, claimLimits{}, deferJobTypes, heartbeat, worker, "$1", conn)
+}
+
+// buildClaimQuery assembles the claim statement of buildClaimJobQuery
+// and buildClaimJobsQuery with limit as SQL of the limit clause.
+//
+// Jobs of deferJobTypes are not claimed, and neither are the jobs after
+// the first of them in claim order, so that they don't overtake it.
+// If a job of deferJobTypes is the next job to claim, the statement
+// returns it unchanged instead, with a null started_at,
+// so that the caller can claim it with claimLimitedJob.
+func buildClaimQuery(jobTypes []string, limits claimLimits, deferJobTypes []string, heartbeat bool, worker, limit string, conn sqldb.QueryFormatter) string {
 	workerAliveAt := "null"
-	if jobworker.HeartbeatInterval > 0 {
+	if heartbeat {
 		workerAliveAt = "now()"
 	}
 
-	// FormatStringLiteral returns a complete, properly quoted PostgreSQL string
-	// literal (single quotes, '' escaping, E'' for backslashes). Job types are
-	// also SQL-injection checked in jobworker.Register.
-	typeLiterals := make([]string, len(jobTypes))
-	for i, jobType := range jobTypes {
-		typeLiterals[i] = conn.FormatStringLiteral(jobType)
+	var saturatedCTE, saturatedPredicate string
+	if saturated := limits.saturatedQuery(conn); saturated != "" {
+		saturatedCTE = "saturated as (" + saturated + "),\n"
+		saturatedPredicate = /*sql*/ `and "type" not in (select "type" from saturated) -- no limit of the type reached`
+	}
+
+	// Without deferred job types the updated rows are the result
+	var deferredCTE, deferredPredicate, deferredResult string
+	if len(deferJobTypes) > 0 {
+		deferredCTE = fmt.Sprintf(
+			/*sql*/ `,
+			deferred as (
+				select id, priority, created_at
+				from claimed
+				where "type" in (%s)
+				order by priority desc, created_at asc
+				limit 1
+			),
+			started as (`,
+			jobTypeLiterals(deferJobTypes, conn), // for "type" in (%s)
+		)
+		deferredPredicate = /*sql*/ `
+				and not exists (                      -- not the first deferred job or a job after it
+					select from deferred as f
+					where (f.priority, claimed.created_at) >= (claimed.priority, f.created_at)
+				)`
+		deferredResult = /*sql*/ `
+			)
+			select * from started
+			union all
+			select j.*                                -- the first deferred job if it's the next job
+			from worker.job as j
+				inner join deferred as f on f.id = j.id
+			where not exists (select from started)`
 	}
 
-	// The CTE `claimed` finds and row-locks the single next job to run; the outer
-	// UPDATE marks that same row as started. Both run as one statement, so the
+	// The CTE `claimed` finds and row-locks the next jobs to run; the outer
+	// UPDATE marks those same rows as started. Both run as one statement, so the
 	// claim is atomic without a surrounding transaction.
 	return fmt.Sprintf(
 		/*sql*/ `
-			with claimed as (
-				select id
-				from worker.job
+			with %sclaimed as (
+				select id, "type", priority, created_at
+				from worker.job as j
 				where started_at is null                          -- not started yet
+					and stopped_at is null                        -- not cancelled before it was started
 					and (start_at is null or start_at <= now())   -- scheduled start reached (or unscheduled)
 					and "type" in (%s)                            -- only job types this process has workers for
+					%s
+					and not exists (                              -- no dependency blocking the job:
+						select 1
+						from worker.job_dependency as d
+							inner join worker.job as dep on dep.id = d.depends_on_id
+						where d.job_id = j.id
+							and not (
+								-- dependency succeeded (stopped without error and not cancelled)
+								(dep.stopped_at is not null and dep.error_msg is null and dep.cancelled_at is null)
+								or
+								-- dependency finished either way and the job runs anyway
+								(j.on_dependency_failure = 'run' and dep.stopped_at is not null and (dep.error_msg is null or dep.current_retry_count >= dep.max_retry_count))
+							)
+					)
 				order by
 					priority desc,    -- highest priority first,
 					created_at asc    -- then oldest first (FIFO within a priority)
-				limit 1
+				limit %s
 				-- skip locked: take the next row not already locked by another worker
 				-- (rather than blocking on it), so concurrent workers each claim a
 				-- different job.
 				for update skip locked
-			)
+			)%s
 			update worker.job
 			set started_at      = now(),
+				worker          = %s,  -- jobworker.WorkerName of the claiming process
 				worker_alive_at = %s,  -- liveness anchor: now() if heartbeats enabled, else null
 				updated_at      = now()
 			from claimed
-			where worker.job.id = claimed.id      -- the row the CTE locked
-			returning worker.job.*                -- full updated row, scanned into the job struct
+			where worker.job.id = claimed.id      -- the rows the CTE locked%s
+			returning worker.job.*                -- full updated row, scanned into the job struct%s
 		`,
-		strings.Join(typeLiterals, ","), // for "type" in (%s)
-		workerAliveAt,                   // for worker_alive_at = %s
+		saturatedCTE,                     // for with %s
+		jobTypeLiterals(jobTypes, conn),  // for "type" in (%s)
+		saturatedPredicate,               // for the line after "type" in (%s)
+		limit,                            // for limit %s
+		deferredCTE,                      // for the CTEs after claimed
+		conn.FormatStringLiteral(worker), // for worker = %s
+		workerAliveAt,                    // for worker_alive_at = %s
+		deferredPredicate,                // for the line after where
+		deferredResult,                   // for the lines after returning
 	)
 }
 
-// claimJobStmt cache, guarded by claimJobStmtMtx. The claim statement takes no
-// parameters, so it is prepared once and reused; it is re-prepared only when the
-// registered job types change (the jobworker generation), which is startup-only.
-//
-// This cache is package-level, not per jobworkerDB instance: a process runs a
-// single active service (see InitJobQueue), and closeCachedStmts releases it on
-// Close. Running two services against different connections concurrently is not
-// supported, as they would share this one cached statement.
-var (
-	claimJobStmtMtx   sync.Mutex
-	claimJobStmtGen   uint64
-	claimJobStmtQuery func(ctx context.Context, args ...any) (*jobqueue.Job, error)
-	claimJobStmtClose func() error
-)
+// jobTypeLiterals returns jobTypes as comma separated SQL string literals
+// for a `"type" in (...)` predicate.
+func jobTypeLiterals(jobTypes []string, conn sqldb.QueryFormatter) string {
+	// FormatStringLiteral returns a complete, properly quoted PostgreSQL string
+	// literal (single quotes, '' escaping, E'' for backslashes). Job types are
+	// also SQL-injection checked in jobworker.Register.
+	typeLiterals := make([]string, len(jobTypes))
+	for i, jobType := range jobTypes {
+		typeLiterals[i] = conn.FormatStringLiteral(jobType)
+	}
+	return strings.Join(typeLiterals, ",")
+}
 
-// claimJobStmt returns the cached prepared claim statement for the given
-// registered job types, (re)preparing it when the generation changes. jobTypes
-// and gen come from a single jobworker.RegisteredJobTypes call, so they are a
-// consistent pair. The returned query func wraps a pool-safe *sql.Stmt
-// (database/sql re-prepares it per pooled connection) and is safe to call
-// concurrently, so callers execute it outside the lock. The generation changes
-// only on Register/Unregister (startup-only), so the re-prepare — and the Close of
-// the previously prepared statement — does not race a concurrent claim.
-func claimJobStmt(ctx context.Context, jobTypes []string, gen uint64) (func(context.Context, ...any) (*jobqueue.Job, error), error) {
-	claimJobStmtMtx.Lock()
-	defer claimJobStmtMtx.Unlock()
-
-	if claimJobStmtQuery == nil || gen != claimJobStmtGen {
-		if claimJobStmtClose != nil {
+// claimJobStmt returns the cached prepared claim statement for claim,
+// (re)preparing it when it is called with another claim. The claim statement
+// takes no parameters, so it is prepared once and reused; a jobworker.Pool only
+// passes another claim when its registered job types or limits change, which
+// is startup-only. The cache is per jobworkerDB instance and released by
+// closeCachedStmts on Close.
+//
+// The returned query func wraps a pool-safe *sql.Stmt (database/sql re-prepares
+// it per pooled connection) and is safe to call concurrently, so callers execute
+// it outside the lock. The claim changes only while no worker threads are
+// running, so the re-prepare — and the Close of the previously prepared
+// statement — does not race a concurrent claim.
+func (j *jobworkerDB) claimJobStmt(ctx context.Context, claim *jobworker.Claim) (func(context.Context, ...any) (*jobqueue.Job, error), error) {
+	j.claimJobStmtMtx.Lock()
+	defer j.claimJobStmtMtx.Unlock()
+
+	if j.claimJobStmtQuery == nil || claim != j.claimJobStmtClaim {
+		if j.claimJobStmtClose != nil {
 			// Clear the cache before closing the old statement so that, even if
 			// Close fails, we never leave a stale (closed-or-close-failed)
-			// statement cached at the old generation. The next call then rebuilds
+			// statement cached for the old claim. The next call then rebuilds
 			// from scratch instead of closing the same statement again.
-			closeOld := claimJobStmtClose
-			claimJobStmtQuery, claimJobStmtClose = nil, nil
+			closeOld := j.claimJobStmtClose
+			j.claimJobStmtQuery, j.claimJobStmtClose, j.claimJobStmtClaim = nil, nil, nil
 			if err := closeOld(); err != nil {
 				return nil, err
 			}
 		}
-		query := buildClaimJobQuery(jobTypes, db.Conn(ctx))
+		limitedJobTypes := claimLimitsOf(claim.JobTypes, claim).jobTypes()
+		query := buildClaimJobQuery(claim.JobTypes, claimLimits{}, limitedJobTypes, claim.Heartbeat, claim.Worker, db.Conn(ctx))
 		queryFunc, closeStmt, err := db.QueryRowAsStmt[*jobqueue.Job](ctx, query)
 		if err != nil {
-			claimJobStmtQuery, claimJobStmtClose = nil, nil
 			return nil, err
 		}
-		claimJobStmtQuery, claimJobStmtClose, claimJobStmtGen = queryFunc, closeStmt, gen
+		j.claimJobStmtQuery, j.claimJobStmtClose, j.claimJobStmtClaim = queryFunc, closeStmt, claim
 	}
-	return claimJobStmtQuery, nil
+	return j.claimJobStmtQuery, nil
 }
 
-func (j *jobworkerDB) StartNextJobOrNil(ctx context.Context) (job *jobqueue.Job, err error) {
-	defer errs.WrapWithFuncParams(&err, ctx)
+func (j *jobworkerDB) StartNextJobOrNil(ctx context.Context, claim *jobworker.Claim, skipJobTypes ...string) (job *jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim, skipJobTypes)
 
 	if j.closed.Load() {
 		return nil, jobqueue.ErrClosed
 	}
 
-	jobTypes, gen := jobworker.RegisteredJobTypes()
-	if len(jobTypes) == 0 {
-		// No registered worker types: nothing this process can claim.
-		return nil, nil
+	jobs, err := j.startNextJobs(ctx, claim, 1, skipJobTypes)
+	if len(jobs) == 0 || err != nil {
+		return nil, err
 	}
+	return jobs[0], nil
+}
 
-	claimJob, err := claimJobStmt(ctx, jobTypes, gen)
-	if err != nil {
+func (j *jobworkerDB) StartNextJobsOrNil(ctx context.Context, claim *jobworker.Claim, n int, skipJobTypes ...string) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim, n, skipJobTypes)
+
+	if j.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	if n <= 0 {
+		return nil, nil
+	}
+	jobs, err = j.startNextJobs(ctx, claim, n, skipJobTypes)
+	if err != nil || len(jobs) == 0 {
 		return nil, err
 	}
+	// The update returns the claimed rows in no particular order
+	slices.SortFunc(jobs, func(a, b *jobqueue.Job) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return jobs, nil
+}
+
+// startNextJobs claims up to n of the next jobs of the claim.JobTypes
+// except skipJobTypes.
+//
+// The claim statement defers the jobs of job types with limits,
+// see buildClaimQuery, so claims of other job types take no locks
+// and a single job is claimed with the prepared statement of claimJobStmt
+// as long as no job types are skipped. Only if a job of a limited job type
+// is the next job to claim, it is claimed alone with claimLimitedJob.
+// If a limit of its job type is reached, the claim is repeated
+// with the job type skipped.
+func (j *jobworkerDB) startNextJobs(ctx context.Context, claim *jobworker.Claim, n int, skipJobTypes []string) ([]*jobqueue.Job, error) {
+	for {
+		jobTypes := claim.JobTypes
+		if len(skipJobTypes) > 0 {
+			jobTypes = slices.DeleteFunc(slices.Clone(jobTypes), func(jobType string) bool {
+				return slices.Contains(skipJobTypes, jobType)
+			})
+		}
+		if len(jobTypes) == 0 {
+			// No registered worker types: nothing this process can claim.
+			return nil, nil
+		}
+		limitedJobTypes := claimLimitsOf(jobTypes, claim).jobTypes()
+
+		var (
+			jobs []*jobqueue.Job
+			err  error
+		)
+		switch {
+		case n > 1:
+			jobs, err = db.QueryRowsAsSlice[*jobqueue.Job](ctx,
+				buildClaimJobsQuery(jobTypes, limitedJobTypes, claim.Heartbeat, claim.Worker, db.Conn(ctx)),
+				n, // $1
+			)
+		case len(skipJobTypes) > 0:
+			// The claimable job types change with the running jobs
+			// of the process, so the claim is not prepared
+			jobs, err = jobsOfRow(db.QueryRowAs[*jobqueue.Job](ctx,
+				buildClaimJobQuery(jobTypes, claimLimits{}, limitedJobTypes, claim.Heartbeat, claim.Worker, db.Conn(ctx)),
+			))
+		default:
+			var claimJob func(context.Context, ...any) (*jobqueue.Job, error)
+			claimJob, err = j.claimJobStmt(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			// The claim statement is a single CTE that selects the next job
+			// (FOR UPDATE SKIP LOCKED) and marks it started atomically, so no explicit
+			// transaction is needed. `skip locked` lets workers compete: a row already
+			// locked by another worker is skipped rather than waited on. It takes no
+			// arguments (now(), inlined job types and worker), so it runs as a prepared statement.
+			jobs, err = jobsOfRow(claimJob(ctx))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(jobs) != 1 || jobs[0].Started() {
+			return jobs, nil
+		}
+
+		// The next job is of a limited job type and was not claimed
+		deferred := jobs[0]
+		job, err := claimLimitedJob(ctx, claim, deferred.Type)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return []*jobqueue.Job{job}, nil
+		}
+		skipJobTypes = append(slices.Clone(skipJobTypes), deferred.Type)
+	}
+}
 
-	// The claim statement is a single CTE that selects the next job
-	// (FOR UPDATE SKIP LOCKED) and marks it started atomically, so no explicit
-	// transaction is needed. `skip locked` lets workers compete: a row already
-	// locked by another worker is skipped rather than waited on. It takes no
-	// arguments (now() and inlined job types), so it runs as a prepared statement.
-	job, err = claimJob(ctx)
+// jobsOfRow returns the job of a single row query as slice,
+// or nil if the query found no row.
+func jobsOfRow(job *jobqueue.Job, err error) ([]*jobqueue.Job, error) {
 	if err != nil {
 		return nil, sqldb.ReplaceErrNoRows(err, nil)
 	}
+	return []*jobqueue.Job{job}, nil
+}
+
+// claimLimitedJob claims the next job of jobType, which has limits in claim,
+// or returns nil if a limit of the job type is reached
+// or no job of the type is available anymore.
+//
+// The claim runs in a transaction after lockJobTypeLimits of jobType.
+// The advisory lock is held until the transaction commits the claim,
+// so a concurrent claim of the same type waits and its statement
+// starts with a snapshot that counts this claim.
+func claimLimitedJob(ctx context.Context, claim *jobworker.Claim, jobType string) (job *jobqueue.Job, err error) {
+	jobTypes := []string{jobType}
+	limits := claimLimitsOf(jobTypes, claim)
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		err := db.Exec(ctx, lockJobTypeLimits(jobTypes, db.Conn(ctx)))
+		if err != nil {
+			return err
+		}
+		job, err = db.QueryRowAs[*jobqueue.Job](ctx, buildClaimJobQuery(jobTypes, limits, nil, claim.Heartbeat, claim.Worker, db.Conn(ctx)))
+		if err != nil || job == nil {
+			return sqldb.ReplaceErrNoRows(err, nil)
+		}
+		if rateLimit, ok := limits.rateLimits[jobType]; ok {
+			return countRateLimitedJob(ctx, jobType, rateLimit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return job, nil
 }
 
+func (j *jobworkerDB) UnclaimJobs(ctx context.Context, jobIDs uu.IDs) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobIDs)
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	// Clearing started_at fires the job_available_update_trigger
+	return db.Exec(ctx,
+		/*sql*/ `
+			update worker.job
+			set
+				started_at=null,
+				worker=null,
+				worker_alive_at=null,
+				updated_at=now()
+			where id = any($1)
+				and started_at is not null
+				and stopped_at is null
+		`,
+		jobIDs, // $1
+	)
+}
+
+func (j *jobworkerDB) GetNextJobStartDelay(ctx context.Context, claim *jobworker.Claim) (delay time.Duration, ok bool, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim)
+
+	if j.closed.Load() {
+		return 0, false, jobqueue.ErrClosed
+	}
+
+	jobTypes := claim.JobTypes
+	if len(jobTypes) == 0 {
+		return 0, false, nil
+	}
+
+	// Rate limited job types that reached their limit
+	// become claimable again when their window ends
+	var rateWindowEnds string
+	if query := claimLimitsOf(jobTypes, claim).rateWindowEndsQuery(db.Conn(ctx)); query != "" {
+		rateWindowEnds = "union all" + query
+	}
+
+	// The delay is calculated with the database clock like the claim,
+	// so a skewed clock of the worker process doesn't wake it too early.
+	// Only runs when a claim found no job, so it is not prepared like the claim.
+	micros, err := db.QueryRowAs[sql.NullInt64](ctx,
+		fmt.Sprintf(
+			/*sql*/ `
+				select ceil(extract(epoch from min(next_at) - now()) * 1000000)::bigint
+				from (
+					select start_at as next_at
+					from worker.job
+					where started_at is null
+						and stopped_at is null
+						and start_at > now()
+						and "type" in (%s)
+					%s
+				) as n
+			`,
+			jobTypeLiterals(jobTypes, db.Conn(ctx)), // for "type" in (%s)
+			rateWindowEnds,                          // for union all %s
+		),
+	)
+	if err != nil || !micros.Valid {
+		return 0, false, err
+	}
+	return time.Duration(micros.Int64) * time.Microsecond, true, nil
+}
+
 func (j *jobworkerDB) SetJobError(ctx context.Context, jobID uu.ID, errorMsg string, errorData nullable.JSON) (err error) {
 	defer errs.WrapWithFuncParams(&err, ctx, jobID, errorMsg, errorData)
 
@@ -579,6 +1220,10 @@ func (j *jobworkerDB) SetJobError(ctx context.Context, jobID uu.ID, errorMsg str
 		// genuine rolling-upgrade leftovers. Without it, a job with a missing
 		// retry scheduler would be reset and re-run on every startup forever and
 		// its bundle would never complete (it would never be counted below).
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptFailed, nullable.NonEmptyString(errorMsg), errorData)
+		if err != nil {
+			return err
+		}
 		err = db.Exec(ctx,
 			/*sql*/ `
 				update worker.job
@@ -586,6 +1231,7 @@ func (j *jobworkerDB) SetJobError(ctx context.Context, jobID uu.ID, errorMsg str
 					error_msg=$1,
 					error_data=$2,
 					current_retry_count=max_retry_count,
+					worker=null,
 					worker_alive_at=null,
 					updated_at=now()
 				where id = $3
@@ -617,18 +1263,21 @@ func (j *jobworkerDB) SetJobError(ctx context.Context, jobID uu.ID, errorMsg str
 		if err != nil {
 			return err
 		}
-		if jobBundleID.IsNull() {
-			return nil
-		}
-
-		return db.Exec(ctx,
-			/*sql*/ `
-				update worker.job_bundle
-				set num_jobs_stopped=num_jobs_stopped+1, updated_at=now()
-				where id = $1
-			`,
-			jobBundleID.Get(), // $1
-		)
+		if jobBundleID.IsNotNull() {
+			err = db.Exec(ctx,
+				/*sql*/ `
+					update worker.job_bundle
+					set num_jobs_stopped=num_jobs_stopped+1, updated_at=now()
+					where id = $1
+				`,
+				jobBundleID.Get(), // $1
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return cancelDependentsOfFailedJobs(ctx, uu.IDs{jobID})
 	})
 }
 
@@ -639,7 +1288,7 @@ func (j *jobworkerDB) ResetJob(ctx context.Context, jobID uu.ID) (err error) {
 		return jobqueue.ErrClosed
 	}
 
-	return db.Transaction(ctx, func(ctx context.Context) error {
+	err = db.Transaction(ctx, func(ctx context.Context) error {
 		// Decrement the bundle counter if this job was already counted
 		// as stopped. A job is counted when SetJobResult or SetJobError
 		// incremented num_jobs_stopped, which happens when:
@@ -667,6 +1316,11 @@ func (j *jobworkerDB) ResetJob(ctx context.Context, jobID uu.ID) (err error) {
 			return err
 		}
 
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptReset, "", nil)
+		if err != nil {
+			return err
+		}
+
 		return db.Exec(ctx,
 			/*sql*/ `
 				update worker.job
@@ -676,7 +1330,10 @@ func (j *jobworkerDB) ResetJob(ctx context.Context, jobID uu.ID) (err error) {
 					error_msg=null,
 					error_data=null,
 					result=null,
+					worker=null,
 					worker_alive_at=null,
+					cancel_requested_at=null,
+					cancelled_at=null,
 					current_retry_count=0,
 					updated_at=now()
 				where id = $1
@@ -684,6 +1341,7 @@ func (j *jobworkerDB) ResetJob(ctx context.Context, jobID uu.ID) (err error) {
 			jobID, // $1
 		)
 	})
+	return restartedJobsDuplicateError(ctx, err, uu.IDs{jobID})
 }
 
 func (j *jobworkerDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error) {
@@ -693,7 +1351,7 @@ func (j *jobworkerDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error)
 		return jobqueue.ErrClosed
 	}
 
-	return db.Transaction(ctx, func(ctx context.Context) error {
+	err = db.Transaction(ctx, func(ctx context.Context) error {
 		// Decrement bundle counters for jobs that were already counted
 		// as stopped. See ResetJob for the detailed explanation.
 		// Jobs may belong to different bundles, so group by bundle_id
@@ -720,6 +1378,11 @@ func (j *jobworkerDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error)
 			return err
 		}
 
+		err = insertJobAttempts(ctx, jobIDs, jobqueue.JobAttemptReset, "", nil)
+		if err != nil {
+			return err
+		}
+
 		return db.Exec(ctx,
 			/*sql*/ `
 				update worker.job
@@ -729,7 +1392,10 @@ func (j *jobworkerDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error)
 					error_msg=null,
 					error_data=null,
 					result=null,
+					worker=null,
 					worker_alive_at=null,
+					cancel_requested_at=null,
+					cancelled_at=null,
 					current_retry_count=0,
 					updated_at=now()
 				where id = any($1)
@@ -737,6 +1403,115 @@ func (j *jobworkerDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error)
 			jobIDs, // $1
 		)
 	})
+	return restartedJobsDuplicateError(ctx, err, jobIDs)
+}
+
+func (j *jobworkerDB) CancelJob(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		// Only jobs that have not stopped yet can be cancelled.
+		// A job that was not started yet is stopped right away,
+		// a started job keeps running until its worker has seen
+		// the job_cancel_requested notification and called SetJobCancelled.
+		// Returns the bundle_id only if the job was stopped here
+		// and has to be counted in its bundle.
+		jobBundleID, err := db.QueryRowAsOr(ctx,
+			uu.IDNull,
+			/*sql*/ `
+				update worker.job
+				set
+					cancel_requested_at=coalesce(cancel_requested_at, now()),
+					stopped_at=case when started_at is null then now() end,
+					cancelled_at=case when started_at is null then now() end,
+					updated_at=now()
+				where id = $1
+					and stopped_at is null
+				returning case when started_at is null then bundle_id end
+			`,
+			jobID, // $1
+		)
+		if err != nil {
+			return err
+		}
+		if jobBundleID.IsNotNull() {
+			err = db.Exec(ctx,
+				/*sql*/ `
+					update worker.job_bundle
+					set num_jobs_stopped=num_jobs_stopped+1, updated_at=now()
+					where id = $1
+				`,
+				jobBundleID.Get(), // $1
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Only has an effect if the job was stopped here
+		return cancelDependentsOfFailedJobs(ctx, uu.IDs{jobID})
+	})
+}
+
+func (j *jobworkerDB) SetJobCancelled(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		// The stopped_at guard makes sure the job is counted
+		// in its bundle only once. A cancelled job has neither
+		// an error nor a result, but a cancelled_at time,
+		// see jobqueue.Job.Cancelled.
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptCancelled, "", nil)
+		if err != nil {
+			return err
+		}
+		jobBundleID, err := db.QueryRowAsOr(ctx,
+			uu.IDNull,
+			/*sql*/ `
+				update worker.job
+				set
+					cancel_requested_at=coalesce(cancel_requested_at, now()),
+					stopped_at=now(),
+					cancelled_at=now(),
+					error_msg=null,
+					error_data=null,
+					result=null,
+					worker=null,
+					worker_alive_at=null,
+					updated_at=now()
+				where id = $1
+					and stopped_at is null
+				returning bundle_id
+			`,
+			jobID, // $1
+		)
+		if err != nil {
+			return err
+		}
+		if jobBundleID.IsNotNull() {
+			err = db.Exec(ctx,
+				/*sql*/ `
+					update worker.job_bundle
+					set num_jobs_stopped=num_jobs_stopped+1, updated_at=now()
+					where id = $1
+				`,
+				jobBundleID.Get(), // $1
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return cancelDependentsOfFailedJobs(ctx, uu.IDs{jobID})
+	})
 }
 
 func (j *jobworkerDB) SetJobResult(ctx context.Context, jobID uu.ID, result nullable.JSON) (err error) {
@@ -752,11 +1527,19 @@ func (j *jobworkerDB) SetJobResult(ctx context.Context, jobID uu.ID, result null
 	}
 
 	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptSucceeded, "", nil)
+		if err != nil {
+			return err
+		}
+		// A cancellation requested while the job was running
+		// is obsolete once the job has succeeded
 		err = db.Exec(ctx,
 			/*sql*/ `
 				update worker.job
 				set result=$1,
 					stopped_at=now(),
+					cancel_requested_at=null,
+					worker=null,
 					worker_alive_at=null,
 					updated_at=now(),
 					error_msg=null,
@@ -817,44 +1600,69 @@ func (j *jobworkerDB) SetJobStart(ctx context.Context, jobID uu.ID, startAt time
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `
-			update worker.job
-			set
-				start_at=$1,
-				started_at=null,
-				stopped_at=null,
-				error_msg=null,
-				error_data=null,
-				worker_alive_at=null,
-				updated_at=now()
-			where id = $2
-		`,
-		startAt, // $1
-		jobID,   // $2
-	)
-}
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		// Decrement the bundle counter of a job that was already
+		// counted as stopped, see ResetJob
+		err = db.Exec(ctx,
+			/*sql*/ `
+				update worker.job_bundle
+				set num_jobs_stopped = num_jobs_stopped - 1, updated_at = now()
+				where id = (
+					select j.bundle_id
+					from worker.job as j
+					where j.id = $1
+						and j.bundle_id is not null
+						and j.stopped_at is not null
+						and (j.error_msg is null or j.current_retry_count >= j.max_retry_count)
+				)
+			`,
+			jobID, // $1
+		)
+		if err != nil {
+			return err
+		}
 
-// setJobWorkerAliveStmt cache, guarded by setJobWorkerAliveMtx. The heartbeat
-// query is constant — only the job id varies, as a bind parameter — so it is
-// prepared once and reused for the process lifetime.
-var (
-	setJobWorkerAliveMtx   sync.Mutex
-	setJobWorkerAliveExec  func(ctx context.Context, args ...any) error
-	setJobWorkerAliveClose func() error
-)
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptReset, "", nil)
+		if err != nil {
+			return err
+		}
+
+		return db.Exec(ctx,
+			/*sql*/ `
+				update worker.job
+				set
+					start_at=$1,
+					started_at=null,
+					stopped_at=null,
+					error_msg=null,
+					error_data=null,
+					worker=null,
+					worker_alive_at=null,
+					cancel_requested_at=null,
+					cancelled_at=null,
+					updated_at=now()
+				where id = $2
+			`,
+			startAt, // $1
+			jobID,   // $2
+		)
+	})
+	return restartedJobsDuplicateError(ctx, err, uu.IDs{jobID})
+}
 
 // setJobWorkerAliveStmt returns the cached prepared heartbeat statement,
-// preparing it on first use. The returned exec func wraps a pool-safe *sql.Stmt
+// preparing it on first use. The heartbeat query is constant — only the job id
+// varies, as a bind parameter — so it is prepared once and reused until Close.
+// The returned exec func wraps a pool-safe *sql.Stmt
 // (database/sql re-prepares it per pooled connection) and is safe to call
 // concurrently, as the heartbeat fires from one goroutine per in-flight job. A
 // failed prepare leaves the cache nil so the next heartbeat retries. The
 // statement is released by closeCachedStmts on jobworkerDB.Close.
-func setJobWorkerAliveStmt(ctx context.Context) (func(context.Context, ...any) error, error) {
-	setJobWorkerAliveMtx.Lock()
-	defer setJobWorkerAliveMtx.Unlock()
+func (j *jobworkerDB) setJobWorkerAliveStmt(ctx context.Context) (func(context.Context, ...any) error, error) {
+	j.setJobWorkerAliveMtx.Lock()
+	defer j.setJobWorkerAliveMtx.Unlock()
 
-	if setJobWorkerAliveExec == nil {
+	if j.setJobWorkerAliveExec == nil {
 		// Only update worker_alive_at while the job is actually being processed
 		// (claimed but not yet stopped). The guard prevents a heartbeat that races
 		// with job completion from resurrecting worker_alive_at on a stopped job.
@@ -871,32 +1679,31 @@ func setJobWorkerAliveStmt(ctx context.Context) (func(context.Context, ...any) e
 		if err != nil {
 			return nil, err
 		}
-		setJobWorkerAliveExec = execFunc
-		setJobWorkerAliveClose = closeStmt
+		j.setJobWorkerAliveExec = execFunc
+		j.setJobWorkerAliveClose = closeStmt
 	}
-	return setJobWorkerAliveExec, nil
+	return j.setJobWorkerAliveExec, nil
 }
 
-// closeCachedStmts closes the package-level cached prepared statements (the claim
-// statement and the heartbeat statement) and resets them to nil so a later
-// InitJobQueue prepares them again. It is called from jobworkerDB.Close, after
-// the closed flag is set, so no new claim or heartbeat starts using them.
-func closeCachedStmts() error {
-	claimJobStmtMtx.Lock()
+// closeCachedStmts closes the cached prepared statements (the claim statement
+// and the heartbeat statement) and resets them to nil. It is called from Close,
+// after the closed flag is set, so no new claim or heartbeat starts using them.
+func (j *jobworkerDB) closeCachedStmts() error {
+	j.claimJobStmtMtx.Lock()
 	var errClaim error
-	if claimJobStmtClose != nil {
-		errClaim = claimJobStmtClose()
-		claimJobStmtQuery, claimJobStmtClose = nil, nil
+	if j.claimJobStmtClose != nil {
+		errClaim = j.claimJobStmtClose()
+		j.claimJobStmtQuery, j.claimJobStmtClose, j.claimJobStmtClaim = nil, nil, nil
 	}
-	claimJobStmtMtx.Unlock()
+	j.claimJobStmtMtx.Unlock()
 
-	setJobWorkerAliveMtx.Lock()
+	j.setJobWorkerAliveMtx.Lock()
 	var errAlive error
-	if setJobWorkerAliveClose != nil {
-		errAlive = setJobWorkerAliveClose()
-		setJobWorkerAliveExec, setJobWorkerAliveClose = nil, nil
+	if j.setJobWorkerAliveClose != nil {
+		errAlive = j.setJobWorkerAliveClose()
+		j.setJobWorkerAliveExec, j.setJobWorkerAliveClose = nil, nil
 	}
-	setJobWorkerAliveMtx.Unlock()
+	j.setJobWorkerAliveMtx.Unlock()
 
 	return errors.Join(errClaim, errAlive)
 }
@@ -911,38 +1718,81 @@ func (j *jobworkerDB) SetJobWorkerAlive(ctx context.Context, jobID uu.ID) (err e
 	// The heartbeat fires repeatedly (every HeartbeatInterval per in-flight job),
 	// so it runs as a cached prepared statement with the job id as its only
 	// bind parameter.
-	exec, err := setJobWorkerAliveStmt(ctx)
+	exec, err := j.setJobWorkerAliveStmt(ctx)
 	if err != nil {
 		return err
 	}
 	return exec(ctx, jobID) // $1 = jobID
 }
 
-func (j *jobworkerDB) ScheduleRetry(ctx context.Context, jobID uu.ID, startAt time.Time, retryCount int) (err error) {
+func (j *jobworkerDB) ScheduleRetry(ctx context.Context, jobID uu.ID, startAt time.Time, retryCount int, errorMsg string, errorData nullable.JSON) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, startAt, retryCount, errorMsg, errorData)
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptRetried, nullable.NonEmptyString(errorMsg), errorData)
+		if err != nil {
+			return err
+		}
+
+		return db.Exec(ctx,
+			/*sql*/ `
+				update worker.job
+				set
+					start_at=$1,
+					started_at=null,
+					stopped_at=null,
+					error_msg=null,
+					error_data=null,
+					worker=null,
+					worker_alive_at=null,
+					current_retry_count=$2,
+					updated_at=now()
+				where id = $3
+			`,
+			startAt,    // $1
+			retryCount, // $2
+			jobID,      // $3
+		)
+	})
+	return restartedJobsDuplicateError(ctx, err, uu.IDs{jobID})
+}
+
+func (j *jobworkerDB) SnoozeJob(ctx context.Context, jobID uu.ID, startAt time.Time) (err error) {
 	defer errs.WrapWithFuncParams(&err, ctx, jobID, startAt)
 
 	if j.closed.Load() {
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `
-			update worker.job
-			set
-				start_at=$1,
-				started_at=null,
-				stopped_at=null,
-				error_msg=null,
-				error_data=null,
-				worker_alive_at=null,
-				current_retry_count=$2,
-				updated_at=now()
-			where id = $3
-		`,
-		startAt,    // $1
-		retryCount, // $2
-		jobID,      // $3
-	)
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		err = insertJobAttempts(ctx, uu.IDs{jobID}, jobqueue.JobAttemptSnoozed, "", nil)
+		if err != nil {
+			return err
+		}
+
+		return db.Exec(ctx,
+			/*sql*/ `
+				update worker.job
+				set
+					start_at=$1,
+					started_at=null,
+					stopped_at=null,
+					error_msg=null,
+					error_data=null,
+					worker=null,
+					worker_alive_at=null,
+					updated_at=now()
+				where id = $2
+			`,
+			startAt, // $1
+			jobID,   // $2
+		)
+	})
+	return restartedJobsDuplicateError(ctx, err, uu.IDs{jobID})
 }
 
 func (j *jobworkerDB) DeleteJob(ctx context.Context, jobID uu.ID) (err error) {
@@ -952,9 +1802,15 @@ func (j *jobworkerDB) DeleteJob(ctx context.Context, jobID uu.ID) (err error) {
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job where id = $1`, jobID,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx, `f.id = $1`, jobID)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job where id = $1`, jobID,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteJobsFromOrigin(ctx context.Context, origin string) (err error) {
@@ -964,9 +1820,15 @@ func (j *jobworkerDB) DeleteJobsFromOrigin(ctx context.Context, origin string) (
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job where origin = $1`, origin,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx, `f.origin = $1`, origin)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job where origin = $1`, origin,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteJobsOfType(ctx context.Context, jobType string) (err error) {
@@ -976,9 +1838,15 @@ func (j *jobworkerDB) DeleteJobsOfType(ctx context.Context, jobType string) (err
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job where type = $1`, jobType,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx, `f."type" = $1`, jobType)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job where type = $1`, jobType,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteFinishedJobs(ctx context.Context) (err error) {
@@ -988,12 +1856,18 @@ func (j *jobworkerDB) DeleteFinishedJobs(ctx context.Context) (err error) {
 		return jobqueue.ErrClosed
 	}
 
+	// Deleting a cancelled job would cascade to the job_dependency rows
+	// of its dependents and unblock them
 	return db.Exec(ctx,
 		/*sql*/ `
 			delete from worker.job
 			where stopped_at is not null
 				and	error_msg is null
 				and	bundle_id is null
+				and	not (
+					cancelled_at is not null
+					and	exists (select from worker.job_dependency as d where d.depends_on_id = worker.job.id)
+				)
 		`,
 	)
 }
@@ -1043,9 +1917,15 @@ func (j *jobworkerDB) DeleteJobBundle(ctx context.Context, jobBundleID uu.ID) (e
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job_bundle where id = $1`, jobBundleID,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx, `f.bundle_id = $1`, jobBundleID)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job_bundle where id = $1`, jobBundleID,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteJobBundlesFromOrigin(ctx context.Context, origin string) (err error) {
@@ -1055,9 +1935,18 @@ func (j *jobworkerDB) DeleteJobBundlesFromOrigin(ctx context.Context, origin str
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job_bundle where origin = $1`, origin,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx,
+			`f.bundle_id in (select id from worker.job_bundle where origin = $1)`,
+			origin,
+		)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job_bundle where origin = $1`, origin,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteJobBundlesOfType(ctx context.Context, bundleType string) (err error) {
@@ -1067,9 +1956,18 @@ func (j *jobworkerDB) DeleteJobBundlesOfType(ctx context.Context, bundleType str
 		return jobqueue.ErrClosed
 	}
 
-	return db.Exec(ctx,
-		/*sql*/ `delete from worker.job_bundle where type = $1`, bundleType,
-	)
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		err = cancelDependentsOfDeletedJobs(ctx,
+			`f.bundle_id in (select id from worker.job_bundle where "type" = $1)`,
+			bundleType,
+		)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job_bundle where type = $1`, bundleType,
+		)
+	})
 }
 
 func (j *jobworkerDB) DeleteAllJobsAndBundles(ctx context.Context) (err error) {
@@ -3,10 +3,13 @@ package jobworkerdb
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/domonda/go-sqldb/pqconn"
+
+	"github.com/domonda/go-jobqueue/jobworker"
 )
 
 // TestBuildClaimJobQuery verifies the StartNextJobOrNil claim query is assembled
@@ -20,15 +23,21 @@ func TestBuildClaimJobQuery(t *testing.T) {
 	formatter := pqconn.QueryFormatter{}
 
 	t.Run("single job type", func(t *testing.T) {
-		query := buildClaimJobQuery([]string{"email"}, formatter)
+		query := buildClaimJobQuery([]string{"email"}, claimLimits{}, nil, true, "host:1", formatter)
 
 		// The static skeleton of the combined claim+update statement.
 		assert.Contains(t, query, "with claimed as (")
 		assert.Contains(t, query, "select id")
 		assert.Contains(t, query, "from worker.job")
 		assert.Contains(t, query, "where started_at is null")
+		assert.Contains(t, query, "and stopped_at is null")
 		assert.Contains(t, query, "start_at <= now()")
 		assert.Contains(t, query, `and "type" in ('email')`)
+		assert.Contains(t, query, "from worker.job as j")
+		assert.Contains(t, query, "and not exists (")
+		assert.Contains(t, query, "from worker.job_dependency as d")
+		assert.Contains(t, query, "where d.job_id = j.id")
+		assert.Contains(t, query, "j.on_dependency_failure = 'run'")
 		assert.Contains(t, query, "order by")
 		assert.Contains(t, query, "priority desc")
 		assert.Contains(t, query, "created_at asc")
@@ -36,21 +45,33 @@ func TestBuildClaimJobQuery(t *testing.T) {
 		assert.Contains(t, query, "for update skip locked")
 		assert.Contains(t, query, "update worker.job")
 		assert.Contains(t, query, "returning worker.job.*")
+		assert.Contains(t, query, "worker_alive_at = now()")
+		assert.Contains(t, query, "worker          = 'host:1'")
 
-		// The statement takes no bind parameters: job types are inlined and all
+		// The statement takes no bind parameters: job types and the worker are inlined and all
 		// timestamps use now(), so it can be cached as a prepared statement.
 		assert.NotContains(t, query, "$1")
 		assert.NotContains(t, query, "$2")
 	})
 
+	t.Run("worker is escaped as literal", func(t *testing.T) {
+		query := buildClaimJobQuery([]string{"email"}, claimLimits{}, nil, true, "o'host:1", formatter)
+		assert.Contains(t, query, "worker          = 'o''host:1'")
+	})
+
+	t.Run("worker_alive_at is null without heartbeat", func(t *testing.T) {
+		query := buildClaimJobQuery([]string{"email"}, claimLimits{}, nil, false, "host:1", formatter)
+		assert.Contains(t, query, "worker_alive_at = null")
+	})
+
 	t.Run("multiple job types are comma joined in slice order", func(t *testing.T) {
-		query := buildClaimJobQuery([]string{"a", "b", "c"}, formatter)
+		query := buildClaimJobQuery([]string{"a", "b", "c"}, claimLimits{}, nil, true, "host:1", formatter)
 		assert.Contains(t, query, `and "type" in ('a','b','c')`)
 	})
 
 	t.Run("single quotes are doubled so a payload cannot break out", func(t *testing.T) {
 		jobType := `weird'); drop table worker.job; --`
-		query := buildClaimJobQuery([]string{jobType}, formatter)
+		query := buildClaimJobQuery([]string{jobType}, claimLimits{}, nil, true, "host:1", formatter)
 
 		// The inlined literal must equal the formatter's quoted form, which doubles
 		// the single quote and keeps the whole payload inside one string literal.
@@ -61,7 +82,7 @@ func TestBuildClaimJobQuery(t *testing.T) {
 
 	t.Run("backslashes switch to C-style E'' escaping", func(t *testing.T) {
 		jobType := `back\slash`
-		query := buildClaimJobQuery([]string{jobType}, formatter)
+		query := buildClaimJobQuery([]string{jobType}, claimLimits{}, nil, true, "host:1", formatter)
 
 		want := formatter.FormatStringLiteral(jobType)
 		assert.Contains(t, query, "in ("+want+")")
@@ -70,7 +91,7 @@ func TestBuildClaimJobQuery(t *testing.T) {
 
 	t.Run("inlined literals match formatter output exactly", func(t *testing.T) {
 		jobTypes := []string{"plain", "with'quote", `with\backslash`}
-		query := buildClaimJobQuery(jobTypes, formatter)
+		query := buildClaimJobQuery(jobTypes, claimLimits{}, nil, true, "host:1", formatter)
 
 		quoted := make([]string, len(jobTypes))
 		for i, jt := range jobTypes {
@@ -78,4 +99,76 @@ func TestBuildClaimJobQuery(t *testing.T) {
 		}
 		assert.Contains(t, query, "in ("+strings.Join(quoted, ",")+")")
 	})
+
+	t.Run("batch claim takes the limit as parameter", func(t *testing.T) {
+		query := buildClaimJobsQuery([]string{"email"}, nil, true, "host:1", formatter)
+		assert.Contains(t, query, "limit $1")
+		assert.NotContains(t, query, "limit 1")
+		assert.NotContains(t, query, "saturated")
+		assert.Contains(t, query, `and "type" in ('email')`)
+		assert.Contains(t, query, "for update skip locked")
+		assert.Contains(t, query, "returning worker.job.*")
+	})
+
+	t.Run("batch claim defers limited job types", func(t *testing.T) {
+		query := buildClaimJobsQuery([]string{"a", "b"}, []string{"b"}, true, "host:1", formatter)
+		assert.Contains(t, query, `and "type" in ('a','b')`)
+		assert.Contains(t, query, "deferred as (")
+		assert.Contains(t, query, `where "type" in ('b')`)
+		assert.Contains(t, query, "started as (")
+		assert.Contains(t, query, "select from deferred as f")
+		assert.Contains(t, query, "select * from started")
+		assert.Contains(t, query, "where not exists (select from started)")
+		assert.NotContains(t, query, "saturated")
+	})
+
+	t.Run("no deferred CTE without deferred job types", func(t *testing.T) {
+		query := buildClaimJobQuery([]string{"a"}, claimLimits{}, nil, true, "host:1", formatter)
+		assert.NotContains(t, query, "deferred")
+		assert.NotContains(t, query, "started as (")
+	})
+
+	t.Run("no saturated CTE without limits", func(t *testing.T) {
+		query := buildClaimJobQuery([]string{"a", "b"}, claimLimitsOf([]string{"a", "b"}, &jobworker.Claim{}), nil, true, "host:1", formatter)
+		assert.NotContains(t, query, "saturated")
+	})
+
+	t.Run("cluster max concurrency", func(t *testing.T) {
+		limits := claimLimits{maxRunning: map[string]int{"c": 3, "a": 1}}
+		query := buildClaimJobQuery([]string{"a", "b", "c"}, limits, nil, true, "host:1", formatter)
+		assert.Contains(t, query, "saturated as (")
+		assert.Contains(t, query, `from (values ('a', 1),('c', 3)) as l("type", max_running)`)
+		assert.Contains(t, query, "and r.started_at is not null")
+		assert.Contains(t, query, "and r.stopped_at is null")
+		assert.Contains(t, query, `and "type" not in (select "type" from saturated)`)
+		assert.NotContains(t, query, "worker.rate_limit")
+	})
+
+	t.Run("rate limits", func(t *testing.T) {
+		limits := claimLimits{rateLimits: map[string]jobworker.RateLimit{"b": {N: 100, Per: time.Minute}}}
+		query := buildClaimJobQuery([]string{"a", "b"}, limits, nil, true, "host:1", formatter)
+		assert.Contains(t, query, `from (values ('b', 100, 60000000)) as l("type", max_started, per_us)`)
+		assert.Contains(t, query, "inner join worker.rate_limit as r on r.job_type = l.\"type\"")
+		assert.Contains(t, query, `and "type" not in (select "type" from saturated)`)
+		assert.NotContains(t, query, "max_running")
+		assert.NotContains(t, query, "union all")
+	})
+
+	t.Run("cluster max concurrency and rate limits", func(t *testing.T) {
+		limits := claimLimits{
+			maxRunning: map[string]int{"a": 1},
+			rateLimits: map[string]jobworker.RateLimit{"a": {N: 10, Per: time.Second}},
+		}
+		query := buildClaimJobQuery([]string{"a"}, limits, nil, true, "host:1", formatter)
+		assert.Contains(t, query, "max_running")
+		assert.Contains(t, query, "union all")
+		assert.Contains(t, query, "max_started")
+		assert.Equal(t, []string{"a"}, limits.jobTypes())
+	})
+}
+
+func TestLockJobTypeLimits(t *testing.T) {
+	query := lockJobTypeLimits([]string{"b", "a", "with'quote"}, pqconn.QueryFormatter{})
+	assert.Contains(t, query, "pg_advisory_xact_lock(hashtext('worker.job_type_limit'), hashtext(l.\"type\"))")
+	assert.Contains(t, query, `from (values ('a'),('b'),('with''quote')) as l("type")`, "sorted job types")
 }
@@ -0,0 +1,185 @@
+package jobworkerdb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/domonda/go-sqldb"
+	"github.com/domonda/go-sqldb/db"
+
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// claimLimits are the limits of the job types a claim selects from.
+// Claims of limited job types run in a transaction after lockJobTypeLimits,
+// see claimLimitedJob.
+type claimLimits struct {
+	maxRunning map[string]int                 // from jobworker.Claim.ClusterMaxConcurrency
+	rateLimits map[string]jobworker.RateLimit // from jobworker.Claim.RateLimits
+}
+
+// claimLimitsOf returns the limits of claim for jobTypes.
+func claimLimitsOf(jobTypes []string, claim *jobworker.Claim) claimLimits {
+	var limits claimLimits
+	for _, jobType := range jobTypes {
+		if n, ok := claim.ClusterMaxConcurrency[jobType]; ok {
+			if limits.maxRunning == nil {
+				limits.maxRunning = make(map[string]int)
+			}
+			limits.maxRunning[jobType] = n
+		}
+		if r, ok := claim.RateLimits[jobType]; ok {
+			if limits.rateLimits == nil {
+				limits.rateLimits = make(map[string]jobworker.RateLimit)
+			}
+			limits.rateLimits[jobType] = r
+		}
+	}
+	return limits
+}
+
+func (l claimLimits) isEmpty() bool {
+	return len(l.maxRunning) == 0 && len(l.rateLimits) == 0
+}
+
+// jobTypes returns the sorted job types with any limit.
+func (l claimLimits) jobTypes() []string {
+	jobTypes := slices.Collect(maps.Keys(l.maxRunning))
+	for jobType := range l.rateLimits {
+		if _, ok := l.maxRunning[jobType]; !ok {
+			jobTypes = append(jobTypes, jobType)
+		}
+	}
+	slices.Sort(jobTypes)
+	return jobTypes
+}
+
+// rateLimitValues returns the rate limits as sorted SQL values
+// for the columns ("type", max_started, per_us).
+func (l claimLimits) rateLimitValues(conn sqldb.QueryFormatter) string {
+	values := make([]string, 0, len(l.rateLimits))
+	for _, jobType := range slices.Sorted(maps.Keys(l.rateLimits)) {
+		r := l.rateLimits[jobType]
+		values = append(values, fmt.Sprintf("(%s, %d, %d)", conn.FormatStringLiteral(jobType), r.N, r.Per.Microseconds()))
+	}
+	return strings.Join(values, ",")
+}
+
+// saturatedQuery returns a query selecting the "type" of the limited
+// job types that reached a limit, or an empty string without limits.
+//
+// The count of running jobs and the window in worker.rate_limit
+// are only exact if the query runs after lockJobTypeLimits
+// in the same transaction.
+func (l claimLimits) saturatedQuery(conn sqldb.QueryFormatter) string {
+	var queries []string
+	if len(l.maxRunning) > 0 {
+		values := make([]string, 0, len(l.maxRunning))
+		for _, jobType := range slices.Sorted(maps.Keys(l.maxRunning)) {
+			values = append(values, fmt.Sprintf("(%s, %d)", conn.FormatStringLiteral(jobType), l.maxRunning[jobType]))
+		}
+		queries = append(queries, fmt.Sprintf(
+			/*sql*/ `
+				select l."type"
+				from (values %s) as l("type", max_running)
+				where (
+					select count(*)
+					from worker.job as r
+					where r."type" = l."type"
+						and r.started_at is not null
+						and r.stopped_at is null
+				) >= l.max_running
+			`,
+			strings.Join(values, ","), // for values %s
+		))
+	}
+	if len(l.rateLimits) > 0 {
+		queries = append(queries, fmt.Sprintf(
+			/*sql*/ `
+				select l."type"
+				from (values %s) as l("type", max_started, per_us)
+					inner join worker.rate_limit as r on r.job_type = l."type"
+				where r.window_start + l.per_us * interval '1 microsecond' > now() -- window not ended
+					and r.num_started >= l.max_started                             -- limit of the window reached
+			`,
+			l.rateLimitValues(conn), // for values %s
+		))
+	}
+	return strings.Join(queries, "union all")
+}
+
+// rateWindowEndsQuery returns a query selecting the ends of the windows
+// of the rate limited job types that reached their limit
+// while they have an available job, or an empty string without rate limits.
+func (l claimLimits) rateWindowEndsQuery(conn sqldb.QueryFormatter) string {
+	if len(l.rateLimits) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(
+		/*sql*/ `
+			select r.window_start + l.per_us * interval '1 microsecond'
+			from (values %s) as l("type", max_started, per_us)
+				inner join worker.rate_limit as r on r.job_type = l."type"
+			where r.window_start + l.per_us * interval '1 microsecond' > now()
+				and r.num_started >= l.max_started
+				and exists (
+					select 1
+					from worker.job as j
+					where j."type" = l."type"
+						and j.started_at is null
+						and j.stopped_at is null
+						and (j.start_at is null or j.start_at <= now())
+				)
+		`,
+		l.rateLimitValues(conn), // for values %s
+	)
+}
+
+// lockJobTypeLimits returns a statement taking a transaction level advisory
+// lock per job type, in sorted order so that concurrent claims of processes
+// with different limits don't deadlock. It serializes the claims of limited
+// job types across all processes so that the saturatedQuery of the following
+// claim statement sees every committed claim.
+func lockJobTypeLimits(jobTypes []string, conn sqldb.QueryFormatter) string {
+	values := make([]string, len(jobTypes))
+	for i, jobType := range slices.Sorted(slices.Values(jobTypes)) {
+		values[i] = "(" + conn.FormatStringLiteral(jobType) + ")"
+	}
+	return fmt.Sprintf(
+		/*sql*/ `
+			select pg_advisory_xact_lock(hashtext('worker.job_type_limit'), hashtext(l."type"))
+			from (values %s) as l("type")
+		`,
+		strings.Join(values, ","), // for values %s
+	)
+}
+
+// countRateLimitedJob counts a started job of a rate limited jobType
+// in the current window of worker.rate_limit,
+// or starts a new window if the current one ended.
+func countRateLimitedJob(ctx context.Context, jobType string, limit jobworker.RateLimit) error {
+	return db.Exec(ctx,
+		/*sql*/ `
+			insert into worker.rate_limit as r (job_type, window_start, num_started)
+			values ($1, now(), 1)
+			on conflict (job_type) do update
+			set
+				window_start=case
+					when r.window_start + $2::bigint * interval '1 microsecond' <= now()
+					then now()
+					else r.window_start
+				end,
+				num_started=case
+					when r.window_start + $2::bigint * interval '1 microsecond' <= now()
+					then 1
+					else r.num_started + 1
+				end,
+				updated_at=now()
+		`,
+		jobType,                  // $1
+		limit.Per.Microseconds(), // $2
+	)
+}
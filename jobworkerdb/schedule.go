@@ -0,0 +1,172 @@
+package jobworkerdb
+
+import (
+	"context"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func (j *jobworkerDB) SaveJobSchedule(ctx context.Context, schedule *jobqueue.JobSchedule) (saved *jobqueue.JobSchedule, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, schedule)
+
+	if j.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	err = schedule.CatchUp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		// The occurrence that was added in advance for a changed
+		// cron expression or time zone is replaced by the next
+		// occurrence of the new definition
+		err := db.Exec(ctx,
+			/*sql*/ `
+				delete from worker.job as j
+				using worker.job_schedule as s
+				where s.name = $1
+					and (s.cron_expr <> $2 or s.timezone <> $3)
+					and j.schedule_name = s.name
+					and j.schedule_fire_time > now()
+					and j.started_at is null
+					and j.stopped_at is null
+			`,
+			schedule.Name,     // $1
+			schedule.CronExpr, // $2
+			schedule.Timezone, // $3
+		)
+		if err != nil {
+			return err
+		}
+
+		saved, err = db.QueryRowAs[*jobqueue.JobSchedule](ctx,
+			/*sql*/ `
+				insert into worker.job_schedule (name, cron_expr, timezone, catch_up)
+				values ($1, $2, $3, $4)
+				on conflict (name) do update
+				set
+					cron_expr=excluded.cron_expr,
+					timezone=excluded.timezone,
+					catch_up=excluded.catch_up,
+					last_fire_time=case
+						when (worker.job_schedule.cron_expr, worker.job_schedule.timezone) <> (excluded.cron_expr, excluded.timezone)
+						then least(worker.job_schedule.last_fire_time, now())
+						else worker.job_schedule.last_fire_time
+					end,
+					updated_at=now()
+				returning *
+			`,
+			schedule.Name,                // $1
+			schedule.CronExpr,            // $2
+			schedule.Timezone,            // $3
+			schedule.CatchUp.OrDefault(), // $4
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (j *jobworkerDB) AddScheduledJobs(ctx context.Context, scheduleName string, jobs []*jobqueue.Job) (schedule *jobqueue.JobSchedule, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, scheduleName, jobs)
+
+	if j.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		// Locking the schedule row serializes concurrent schedulers
+		// of several worker processes, the one that comes second
+		// sees the last_fire_time updated by the first one
+		lastFireTime, err := db.QueryRowAs[nullable.Time](ctx,
+			/*sql*/ `
+				select last_fire_time
+				from worker.job_schedule
+				where name = $1
+				for update
+			`,
+			scheduleName, // $1
+		)
+		if err != nil {
+			return err
+		}
+
+		var maxFireTime nullable.Time
+		for _, job := range jobs {
+			if job.ScheduleName.Get() != scheduleName || job.ScheduleFireTime.IsNull() {
+				return errs.Errorf("job %s is not an occurrence of the schedule %q", job.ID, scheduleName)
+			}
+			fireTime := job.ScheduleFireTime.Get()
+			if lastFireTime.IsNotNull() && !fireTime.After(lastFireTime.Get()) {
+				continue
+			}
+			_, err = insertJob(ctx, job, jobqueue.DuplicateJobFail)
+			if err != nil {
+				return err
+			}
+			if maxFireTime.IsNull() || fireTime.After(maxFireTime.Get()) {
+				maxFireTime.Set(fireTime)
+			}
+		}
+
+		if maxFireTime.IsNull() {
+			schedule, err = db.QueryRowAs[*jobqueue.JobSchedule](ctx,
+				/*sql*/ `select * from worker.job_schedule where name = $1`,
+				scheduleName, // $1
+			)
+			return err
+		}
+		schedule, err = db.QueryRowAs[*jobqueue.JobSchedule](ctx,
+			/*sql*/ `
+				update worker.job_schedule
+				set last_fire_time=greatest(last_fire_time, $2), updated_at=now()
+				where name = $1
+				returning *
+			`,
+			scheduleName, // $1
+			maxFireTime,  // $2
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (j *jobworkerDB) DeleteJobSchedule(ctx context.Context, scheduleName string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, scheduleName)
+
+	if j.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		// Started and stopped jobs are kept with
+		// schedule_name set to NULL by the foreign key
+		err := db.Exec(ctx,
+			/*sql*/ `
+				delete from worker.job
+				where schedule_name = $1
+					and started_at is null
+					and stopped_at is null
+			`,
+			scheduleName, // $1
+		)
+		if err != nil {
+			return err
+		}
+		return db.Exec(ctx,
+			/*sql*/ `delete from worker.job_schedule where name = $1`,
+			scheduleName, // $1
+		)
+	})
+}
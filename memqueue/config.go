@@ -0,0 +1,47 @@
+package memqueue
+
+import (
+	"context"
+
+	"github.com/domonda/go-errs"
+	rootlog "github.com/domonda/golog/log"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+var log = rootlog.NewPackageLogger()
+
+// NewDataBase returns a new, empty in-memory implementation of
+// jobworker.DataBase (and therefore jobqueue.Service).
+//
+// Unlike InitJobQueue it does not register the returned value anywhere and
+// adds no ServiceListener, so independent instances can be used side by side,
+// for example one per test.
+func NewDataBase() jobworker.DataBase {
+	return newMemDB()
+}
+
+// InitJobQueue initializes the job queue with a new in-memory service,
+// the counterpart of jobworkerdb.InitJobQueue for tests and embedded use
+// without a PostgreSQL database.
+//
+// It adds the jobqueue.NewDefaultServiceListener to the new service and
+// registers it as the default for both the jobqueue and jobworker packages.
+// Jobs added before a previous InitJobQueue call are not carried over.
+func InitJobQueue(ctx context.Context) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	mem := newMemDB()
+
+	err = mem.AddListener(ctx, jobqueue.NewDefaultServiceListener(mem))
+	if err != nil {
+		return err
+	}
+
+	jobworker.SetDataBase(mem)
+
+	jobqueue.SetDefaultService(mem)
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package memqueue
+
+import (
+	"testing"
+
+	"github.com/domonda/go-jobqueue/dbtest"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func(t *testing.T) jobworker.DataBase {
+		db := NewDataBase()
+		t.Cleanup(func() { _ = db.Close() })
+		return db
+	})
+}
@@ -0,0 +1,184 @@
+package memqueue
+
+import (
+	"bytes"
+	"slices"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// compareIDs orders IDs like the PostgreSQL uuid type.
+func compareIDs(a, b uu.ID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// checkJobDependencies checks job.DependsOn and job.OnDependencyFailure
+// against the constraints of the worker.job and worker.job_dependency tables.
+// newJobIDs are the IDs of other jobs inserted together with job.
+// The caller must hold m.mtx.
+func (m *memDB) checkJobDependencies(job *jobqueue.Job, newJobIDs map[uu.ID]bool) error {
+	err := job.OnDependencyFailure.Validate()
+	if err != nil {
+		return err
+	}
+	for _, id := range job.DependsOn {
+		if id == job.ID {
+			return errs.Errorf("job %s depends on itself", job.ID)
+		}
+		if m.jobs[id] == nil && !newJobIDs[id] {
+			return errs.Errorf("job %s depends on job %s that does not exist", job.ID, id)
+		}
+	}
+	return nil
+}
+
+// dependenciesDone reports whether none of the dependencies of row
+// blocks it from being started, see the claim query of jobworkerdb.
+// The caller must hold m.mtx.
+func (m *memDB) dependenciesDone(row *jobRow) bool {
+	for _, id := range row.dependsOn {
+		dep := m.jobs[id]
+		if dep == nil {
+			continue
+		}
+		if dep.Succeeded() {
+			continue
+		}
+		if row.OnDependencyFailure == jobqueue.DependencyFailureRun && dep.IsFinished() {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// hasWaitingDependents reports whether a job that was not started yet
+// depends on the job with jobID. The caller must hold m.mtx.
+func (m *memDB) hasWaitingDependents(jobID uu.ID) bool {
+	for _, row := range m.jobs {
+		if row.StartedAt.IsNull() && row.StoppedAt.IsNull() && slices.Contains(row.dependsOn, jobID) {
+			return true
+		}
+	}
+	return false
+}
+
+// failedJobs returns the IDs of the jobs with jobIDs
+// that have finished without succeeding. The caller must hold m.mtx.
+func (m *memDB) failedJobs(jobIDs uu.IDs) uu.IDs {
+	var failed uu.IDs
+	for _, id := range jobIDs {
+		if row := m.jobs[id]; row != nil && row.IsFinished() && !row.Succeeded() {
+			failed = append(failed, id)
+		}
+	}
+	return failed
+}
+
+// dependentsToCancel returns the not started jobs with the
+// jobqueue.DependencyFailureCancel policy that depend on one of
+// failedJobIDs, directly or via other jobs returned by this function.
+// The caller must hold m.mtx.
+func (m *memDB) dependentsToCancel(failedJobIDs uu.IDs) []*jobRow {
+	var (
+		rows    []*jobRow
+		visited = make(map[uu.ID]bool)
+		queue   = slices.Clone(failedJobIDs)
+	)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, row := range m.jobs {
+			if !visited[row.ID] &&
+				row.OnDependencyFailure == jobqueue.DependencyFailureCancel &&
+				row.StartedAt.IsNull() &&
+				row.StoppedAt.IsNull() &&
+				slices.Contains(row.dependsOn, id) {
+				visited[row.ID] = true
+				rows = append(rows, row)
+				queue = append(queue, row.ID)
+			}
+		}
+	}
+	slices.SortFunc(rows, compareCreatedAt)
+	return rows
+}
+
+// cancelDependentsOfDeletedJobs cancels the not started jobs with the
+// jobqueue.DependencyFailureBlock or jobqueue.DependencyFailureCancel policy
+// that depend on a job matching filter that did not succeed,
+// together with the jobs returned by dependentsToCancel for them,
+// see cancelDependentsOfDeletedJobs of jobworkerdb.
+// Has to be called before deleting the jobs matching filter.
+// The caller must hold m.mtx.
+func (m *memDB) cancelDependentsOfDeletedJobs(filter func(*jobRow) bool, now time.Time, n *notifications) {
+	deleted := make(map[uu.ID]bool)
+	for id, row := range m.jobs {
+		if filter(row) && !row.Succeeded() {
+			deleted[id] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+	var (
+		rows []*jobRow
+		ids  uu.IDs
+	)
+	for _, row := range m.jobs {
+		if row.OnDependencyFailure != jobqueue.DependencyFailureRun &&
+			row.StartedAt.IsNull() &&
+			row.StoppedAt.IsNull() &&
+			slices.ContainsFunc(row.dependsOn, func(id uu.ID) bool { return deleted[id] }) {
+			rows = append(rows, row)
+			ids = append(ids, row.ID)
+		}
+	}
+	for _, row := range m.dependentsToCancel(ids) {
+		if !slices.Contains(rows, row) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, compareCreatedAt)
+	m.cancelJobs(rows, now, n)
+}
+
+// canCountJobsInBundles checks that stopping all rows at once would not
+// violate the num_jobs_stopped constraint of their bundles.
+// The caller must hold m.mtx.
+func (m *memDB) canCountJobsInBundles(rows []*jobRow) error {
+	deltas := make(map[uu.ID]int)
+	for _, row := range rows {
+		if row.BundleID.IsNull() {
+			continue
+		}
+		deltas[row.BundleID.Get()]++
+		err := m.canUpdateBundleNumJobsStopped(&row.Job, deltas[row.BundleID.Get()])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelJobs stops rows as cancelled and counts them in their bundles,
+// see cancelDependentsOfFailedJobs of jobworkerdb.
+// The caller must hold m.mtx.
+func (m *memDB) cancelJobs(rows []*jobRow, now time.Time, n *notifications) {
+	for _, row := range rows {
+		old := row.Job
+		if row.CancelRequestedAt.IsNull() {
+			row.CancelRequestedAt.Set(now)
+		}
+		row.StoppedAt.Set(now)
+		row.CancelledAt.Set(now)
+		row.UpdatedAt = now
+		m.afterJobUpdate(&old, row, now, n)
+
+		m.updateBundleNumJobsStopped(&row.Job, +1, now, n)
+	}
+}
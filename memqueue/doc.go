@@ -0,0 +1,41 @@
+/*
+Package memqueue provides an in-memory implementation of the jobqueue service.
+
+# Overview
+
+The memqueue package implements the jobqueue.Service and jobworker.DataBase
+interfaces without a database. It is meant for unit tests, examples and
+embedded use where jobs don't need to survive the process.
+
+Jobs and job bundles behave like they do with the jobworkerdb package:
+jobs are claimed by priority and creation time, start_at is respected,
+job bundles count their stopped jobs, and the job_available, job_stopped
+and job_bundle_stopped notifications of the PostgreSQL triggers are
+delivered to the registered listeners. The CHECK constraints of the
+worker schema are validated as well, so invalid jobs are rejected the
+same way.
+
+# Initialization
+
+Initialize the job queue with [InitJobQueue] which creates a new in-memory
+service and registers it as the default for both the jobqueue and jobworker
+packages:
+
+	err := memqueue.InitJobQueue(ctx)
+
+Use [NewDataBase] for an independent instance that is not registered anywhere:
+
+	db := memqueue.NewDataBase()
+	defer db.Close()
+
+# Differences to jobworkerdb
+
+  - All state is lost when the process ends.
+  - Listeners are called synchronously after the method that caused the
+    notification has released its lock, instead of asynchronously by a
+    LISTEN connection.
+  - Methods don't join a database transaction of the passed context.
+  - The jobworkerdb testing context utilities like
+    jobworkerdb.ContextWithSynchronousJobs are not supported.
+*/
+package memqueue
@@ -0,0 +1,91 @@
+package memqueue
+
+import (
+	"slices"
+	"time"
+
+	"github.com/domonda/go-errs"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// unfinishedJobWithUniqueKey returns the unfinished job other than except
+// with the type and unique key of job, or nil if job has no unique key or
+// there is no such job, see the worker_job_unique_key_idx index.
+// The caller must hold m.mtx.
+func (m *memDB) unfinishedJobWithUniqueKey(job *jobqueue.Job, except *jobRow) *jobRow {
+	if job.UniqueKey.IsNull() {
+		return nil
+	}
+	for _, row := range m.jobs {
+		if row != except &&
+			row.StoppedAt.IsNull() &&
+			row.Type == job.Type &&
+			row.UniqueKey == job.UniqueKey {
+			return row
+		}
+	}
+	return nil
+}
+
+// checkUniqueKeyOnRestart checks that making the stopped row unfinished
+// again would not violate the worker_job_unique_key_idx index.
+// The caller must hold m.mtx.
+func (m *memDB) checkUniqueKeyOnRestart(row *jobRow) error {
+	if row.StoppedAt.IsNull() {
+		return nil
+	}
+	if existing := m.unfinishedJobWithUniqueKey(&row.Job, row); existing != nil {
+		return newDuplicateJobError(&row.Job, existing)
+	}
+	return nil
+}
+
+func newDuplicateJobError(job *jobqueue.Job, existing *jobRow) error {
+	return &jobqueue.DuplicateJobError{
+		JobType:       job.Type,
+		UniqueKey:     job.UniqueKey.Get(),
+		ExistingJobID: existing.ID,
+	}
+}
+
+// checkAddDuplicateJob returns the error addDuplicateJob
+// would return without changing anything.
+func checkAddDuplicateJob(job *jobqueue.Job, existing *jobRow) error {
+	switch job.OnDuplicate.OrDefault() {
+	case jobqueue.DuplicateJobFail:
+		return newDuplicateJobError(job, existing)
+
+	case jobqueue.DuplicateJobUseExisting:
+		return nil
+
+	case jobqueue.DuplicateJobReplace:
+		if existing.StartedAt.IsNotNull() {
+			return newDuplicateJobError(job, existing)
+		}
+		if len(job.Payload) > 0 && !job.Payload.Valid() {
+			return errs.Errorf("job payload is not valid JSON: %#v", string(job.Payload))
+		}
+		return nil
+	}
+	return job.OnDuplicate.Validate()
+}
+
+// addDuplicateJob handles adding job while the unfinished job existing
+// has the same type and unique key, see insertJob of jobworkerdb.
+// The caller must hold m.mtx.
+func (m *memDB) addDuplicateJob(job *jobqueue.Job, existing *jobRow, now time.Time, n *notifications) error {
+	err := checkAddDuplicateJob(job, existing)
+	if err != nil {
+		return err
+	}
+	if job.OnDuplicate == jobqueue.DuplicateJobReplace {
+		old := existing.Job
+		existing.Payload = slices.Clone(job.Payload)
+		existing.StartAt = job.StartAt
+		existing.UpdatedAt = now
+		m.afterJobUpdate(&old, existing, now, n)
+	}
+	job.ID = existing.ID
+	return nil
+}
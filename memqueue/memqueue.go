@@ -0,0 +1,1571 @@
+package memqueue
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+var _ jobworker.DataBase = (*memDB)(nil)
+
+// jobRow is a stored worker.job row. seq records the insertion order and
+// breaks ties between jobs with the same created_at, which the database
+// leaves unordered. dependsOn holds the worker.job_dependency rows
+// of the job, Job.DependsOn is only filled by GetJob.
+type jobRow struct {
+	jobqueue.Job
+	seq       uint64
+	dependsOn uu.IDs
+	// attempts are the worker.job_attempt rows of the job,
+	// deleted together with it like the on delete cascade
+	attempts []*jobqueue.JobAttempt
+}
+
+// recordAttempt records the ended attempt of the job if it is running,
+// see insertJobAttempts of jobworkerdb.
+// The caller must hold m.mtx and call it before ending the attempt.
+func (row *jobRow) recordAttempt(outcome jobqueue.JobAttemptOutcome, errorMsg string, errorData nullable.JSON, now time.Time) {
+	if row.StartedAt.IsNull() || row.StoppedAt.IsNotNull() {
+		return
+	}
+	row.attempts = append(row.attempts, &jobqueue.JobAttempt{
+		JobID:      row.ID,
+		Attempt:    len(row.attempts) + 1,
+		RetryCount: row.CurrentRetryCount,
+		Outcome:    outcome,
+		Worker:     row.Worker.String(),
+		StartedAt:  row.StartedAt.Get(),
+		StoppedAt:  now,
+		ErrorMsg:   nullable.NonEmptyString(errorMsg),
+		ErrorData:  slices.Clone(errorData),
+	})
+}
+
+// notifications collects the events that the worker schema triggers would
+// NOTIFY while a method holds the mutex. They are dispatched by notify after
+// the mutex has been released, so listeners can call back into the service.
+type notifications struct {
+	jobAvailable        bool
+	jobsStopped         []*jobqueue.Job
+	jobsCancelRequested uu.IDs
+	bundlesStopped      []*jobqueue.JobBundle
+}
+
+type memDB struct {
+	mtx       sync.Mutex
+	jobs      map[uu.ID]*jobRow
+	bundles   map[uu.ID]*jobqueue.JobBundle
+	schedules map[string]*jobqueue.JobSchedule
+	// rateWindows are the worker.rate_limit rows by job type
+	rateWindows map[string]*rateWindow
+	lastSeq     uint64
+
+	// now returns the current time, the equivalent of the database now().
+	// Replaceable by internal tests.
+	now func() time.Time
+
+	serviceListeners           []jobqueue.ServiceListener
+	jobAvailableListener       func()
+	jobCancelRequestedListener func(jobID uu.ID)
+	listenersMtx               sync.Mutex
+	closed                     atomic.Bool
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		jobs:        make(map[uu.ID]*jobRow),
+		bundles:     make(map[uu.ID]*jobqueue.JobBundle),
+		schedules:   make(map[string]*jobqueue.JobSchedule),
+		rateWindows: make(map[string]*rateWindow),
+		now:         time.Now,
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Triggers and notifications
+
+// afterJobUpdate mirrors the AFTER UPDATE triggers on worker.job
+// (see schema/worker/job_triggers.sql) by comparing the row before and after
+// an update and recording the notifications the triggers would send.
+func (m *memDB) afterJobUpdate(old *jobqueue.Job, row *jobRow, now time.Time, n *notifications) {
+	// job_available_update_trigger
+	if old.StartedAt.IsNotNull() && row.StartedAt.IsNull() && startReached(&row.Job, now) {
+		n.jobAvailable = true
+	}
+	// job_stopped_trigger
+	if old.StoppedAt.IsNull() && row.StoppedAt.IsNotNull() {
+		n.jobsStopped = append(n.jobsStopped, cloneJob(&row.Job))
+	}
+	// job_dependents_available_trigger
+	if old.StoppedAt.IsNull() && row.StoppedAt.IsNotNull() && m.hasWaitingDependents(row.ID) {
+		n.jobAvailable = true
+	}
+	// job_cancel_requested_trigger
+	if old.CancelRequestedAt.IsNull() && row.CancelRequestedAt.IsNotNull() && row.StartedAt.IsNotNull() && row.StoppedAt.IsNull() {
+		n.jobsCancelRequested = append(n.jobsCancelRequested, row.ID)
+	}
+}
+
+// updateBundleNumJobsStopped adds delta to the num_jobs_stopped counter of the
+// bundle of job, if it has one, enforcing the CHECK constraint of the
+// worker.job_bundle table and mirroring its job_bundle_stopped_trigger.
+// Callers must check the constraint with canUpdateBundleNumJobsStopped before
+// changing any other state, so a violation leaves everything unchanged like a
+// rolled back transaction.
+func (m *memDB) updateBundleNumJobsStopped(job *jobqueue.Job, delta int, now time.Time, n *notifications) {
+	if job.BundleID.IsNull() {
+		return
+	}
+	bundle := m.bundles[job.BundleID.Get()]
+	if bundle == nil {
+		return
+	}
+	oldNumJobsStopped := bundle.NumJobsStopped
+	bundle.NumJobsStopped += delta
+	bundle.UpdatedAt = now
+	if bundle.NumJobsStopped == bundle.NumJobs && oldNumJobsStopped < bundle.NumJobs {
+		n.bundlesStopped = append(n.bundlesStopped, cloneBundle(bundle))
+	}
+}
+
+// canUpdateBundleNumJobsStopped checks that adding delta to the
+// num_jobs_stopped counter of the bundle of job would not violate the
+// CHECK(num_jobs_stopped >= 0 AND num_jobs_stopped <= num_jobs) constraint.
+func (m *memDB) canUpdateBundleNumJobsStopped(job *jobqueue.Job, delta int) error {
+	if job.BundleID.IsNull() {
+		return nil
+	}
+	bundle := m.bundles[job.BundleID.Get()]
+	if bundle == nil {
+		return nil
+	}
+	if numJobsStopped := bundle.NumJobsStopped + delta; numJobsStopped < 0 || numJobsStopped > bundle.NumJobs {
+		return errs.Errorf("job bundle %s num_jobs_stopped %d out of range [0, %d]", bundle.ID, numJobsStopped, bundle.NumJobs)
+	}
+	return nil
+}
+
+// notify dispatches the collected notifications. It must be called without
+// holding m.mtx. Like the PostgreSQL LISTEN callbacks of jobworkerdb, listener
+// panics are recovered and logged.
+func (m *memDB) notify(n *notifications) {
+	if m.closed.Load() {
+		return
+	}
+
+	m.listenersMtx.Lock()
+	listeners := m.serviceListeners
+	jobAvailableListener := m.jobAvailableListener
+	jobCancelRequestedListener := m.jobCancelRequestedListener
+	m.listenersMtx.Unlock()
+
+	if n.jobAvailable && jobAvailableListener != nil {
+		func() {
+			defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), "job_available")
+			jobAvailableListener()
+		}()
+	}
+
+	if jobCancelRequestedListener != nil {
+		for _, jobID := range n.jobsCancelRequested {
+			func() {
+				defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), "job_cancel_requested", jobID)
+				jobCancelRequestedListener(jobID)
+			}()
+		}
+	}
+
+	ctx := context.Background() // Like jobworkerdb, don't pass the ctx of the method that triggered the notification
+	for _, job := range n.jobsStopped {
+		willRetry := job.ErrorMsg.IsNotNull() && job.CurrentRetryCount < job.MaxRetryCount
+		for _, l := range listeners {
+			func() {
+				defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), "job_stopped", job.ID)
+				l.OnJobStopped(ctx, job.ID, job.Type, job.Origin, willRetry)
+			}()
+		}
+	}
+	for _, bundle := range n.bundlesStopped {
+		for _, l := range listeners {
+			func() {
+				defer errs.RecoverAndLogPanicWithFuncParams(log.ErrorWriter(), "job_bundle_stopped", bundle.ID)
+				l.OnJobBundleStopped(ctx, bundle.ID, bundle.Type, bundle.Origin)
+			}()
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Helpers
+
+func startReached(job *jobqueue.Job, now time.Time) bool {
+	return job.StartAt.IsNull() || !now.Before(job.StartAt.Get())
+}
+
+// countedInBundle reports whether job has been counted in the num_jobs_stopped
+// of its bundle by SetJobResult or SetJobError. See jobworkerDB.ResetJob.
+func countedInBundle(job *jobqueue.Job) bool {
+	return job.BundleID.IsNotNull() &&
+		job.StoppedAt.IsNotNull() &&
+		(job.ErrorMsg.IsNull() || job.CurrentRetryCount >= job.MaxRetryCount)
+}
+
+// compareClaimOrder orders jobs like the claim query of StartNextJobOrNil:
+// priority desc, created_at asc, and insertion order for equal created_at.
+func compareClaimOrder(a, b *jobRow) int {
+	return cmp.Or(
+		cmp.Compare(b.Priority, a.Priority),
+		a.CreatedAt.Compare(b.CreatedAt),
+		cmp.Compare(a.seq, b.seq),
+	)
+}
+
+// compareCreatedAt orders jobs by created_at and insertion order.
+func compareCreatedAt(a, b *jobRow) int {
+	return cmp.Or(
+		a.CreatedAt.Compare(b.CreatedAt),
+		cmp.Compare(a.seq, b.seq),
+	)
+}
+
+// compareNullableTime orders NULL before any non-NULL time,
+// like "order by x nulls first".
+func compareNullableTime(a, b nullable.Time) int {
+	switch {
+	case a.IsNull() && b.IsNull():
+		return 0
+	case a.IsNull():
+		return -1
+	case b.IsNull():
+		return 1
+	}
+	return a.Get().Compare(b.Get())
+}
+
+func cloneJob(job *jobqueue.Job) *jobqueue.Job {
+	c := *job
+	c.Payload = slices.Clone(job.Payload)
+	c.ErrorData = slices.Clone(job.ErrorData)
+	c.Result = slices.Clone(job.Result)
+	return &c
+}
+
+func cloneBundle(bundle *jobqueue.JobBundle) *jobqueue.JobBundle {
+	c := *bundle
+	c.Jobs = nil
+	return &c
+}
+
+// sortedJobs returns clones of all jobs matching filter sorted by compare.
+// The caller must hold m.mtx.
+func (m *memDB) sortedJobs(filter func(*jobRow) bool, compare func(a, b *jobRow) int) []*jobqueue.Job {
+	var rows []*jobRow
+	for _, row := range m.jobs {
+		if filter(row) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, compare)
+	jobs := make([]*jobqueue.Job, len(rows))
+	for i, row := range rows {
+		jobs[i] = cloneJob(&row.Job)
+	}
+	return jobs
+}
+
+// checkInsertJob checks job against the constraints of the worker.job table.
+// The caller must hold m.mtx.
+// newJobIDs are the IDs of other jobs inserted together with job.
+func (m *memDB) checkInsertJob(job *jobqueue.Job, bundleIDs, newJobIDs map[uu.ID]bool) error {
+	if job == nil {
+		return errs.New("<nil> job")
+	}
+	if _, exists := m.jobs[job.ID]; exists {
+		return errs.Errorf("duplicate job ID %s", job.ID)
+	}
+	if l := len(job.Type); l == 0 || l > 100 {
+		return errs.Errorf("job type length %d not in range [1, 100]", l)
+	}
+	if l := len(job.Origin); l == 0 || l > 100 {
+		return errs.Errorf("job origin length %d not in range [1, 100]", l)
+	}
+	if len(job.Payload) > 0 && !job.Payload.Valid() {
+		return errs.Errorf("job payload is not valid JSON: %#v", string(job.Payload))
+	}
+	if job.BundleID.IsNotNull() && m.bundles[job.BundleID.Get()] == nil && !bundleIDs[job.BundleID.Get()] {
+		return errs.Errorf("job bundle %s does not exist", job.BundleID.Get())
+	}
+	err := m.checkScheduledJob(job)
+	if err != nil {
+		return err
+	}
+	return m.checkJobDependencies(job, newJobIDs)
+}
+
+// insertJob inserts the columns of job that jobworkerdb inserts, all other
+// columns get their default values. The caller must hold m.mtx and have
+// checked job with checkInsertJob.
+func (m *memDB) insertJob(job *jobqueue.Job, now time.Time, n *notifications) {
+	m.lastSeq++
+	row := &jobRow{
+		Job: jobqueue.Job{
+			ID:            job.ID,
+			BundleID:      job.BundleID,
+			Type:          job.Type,
+			Payload:       slices.Clone(job.Payload),
+			Priority:      job.Priority,
+			Origin:        job.Origin,
+			MaxRetryCount: job.MaxRetryCount,
+			StartAt:       job.StartAt,
+			UpdatedAt:     now,
+			CreatedAt:     now,
+
+			OnDependencyFailure: job.OnDependencyFailure.OrDefault(),
+			UniqueKey:           job.UniqueKey,
+			ScheduleName:        job.ScheduleName,
+			ScheduleFireTime:    job.ScheduleFireTime,
+			TraceContext:        job.TraceContext,
+		},
+		seq: m.lastSeq,
+	}
+	// Like the primary key of worker.job_dependency
+	for _, id := range job.DependsOn {
+		if !slices.Contains(row.dependsOn, id) {
+			row.dependsOn = append(row.dependsOn, id)
+		}
+	}
+	m.jobs[job.ID] = row
+
+	// job_available_insert_trigger
+	if startReached(&row.Job, now) {
+		n.jobAvailable = true
+	}
+}
+
+// deleteJobsWhere deletes all jobs matching filter
+// and cascades the delete to the dependencies on them.
+// The caller must hold m.mtx.
+func (m *memDB) deleteJobsWhere(filter func(*jobRow) bool) {
+	for id, row := range m.jobs {
+		if filter(row) {
+			delete(m.jobs, id)
+		}
+	}
+	for _, row := range m.jobs {
+		row.dependsOn = slices.DeleteFunc(row.dependsOn, func(id uu.ID) bool {
+			return m.jobs[id] == nil
+		})
+	}
+}
+
+// insertJobs inserts jobs and cancels the ones with the
+// jobqueue.DependencyFailureCancel policy that depend on an
+// already failed job, see insertJobDependencies of jobworkerdb.
+// The caller must hold m.mtx and have checked the jobs with checkInsertJob.
+func (m *memDB) insertJobs(jobs []*jobqueue.Job, now time.Time, n *notifications) {
+	for _, job := range jobs {
+		m.insertJob(job, now, n)
+	}
+	for _, job := range jobs {
+		if job.OnDependencyFailure != jobqueue.DependencyFailureCancel {
+			continue
+		}
+		if failed := m.failedJobs(job.DependsOn); len(failed) > 0 {
+			m.cancelJobs(m.dependentsToCancel(failed), now, n)
+		}
+	}
+}
+
+// deleteBundlesWhere deletes all job bundles matching filter
+// and cascades the delete to their jobs after cancelling
+// their dependents with cancelDependentsOfDeletedJobs.
+// The caller must hold m.mtx.
+func (m *memDB) deleteBundlesWhere(filter func(*jobqueue.JobBundle) bool, now time.Time, n *notifications) {
+	m.cancelDependentsOfDeletedJobs(func(row *jobRow) bool {
+		if row.BundleID.IsNull() {
+			return false
+		}
+		bundle := m.bundles[row.BundleID.Get()]
+		return bundle != nil && filter(bundle)
+	}, now, n)
+	for id, bundle := range m.bundles {
+		if filter(bundle) {
+			delete(m.bundles, id)
+			m.deleteJobsWhere(func(row *jobRow) bool {
+				return row.BundleID.IsNotNull() && row.BundleID.Get() == id
+			})
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// jobqueue.Service methods
+
+func (m *memDB) AddListener(ctx context.Context, listener jobqueue.ServiceListener) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, listener)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+	if listener == nil {
+		return errs.New("<nil> jobqueue.ServiceListener")
+	}
+
+	m.listenersMtx.Lock()
+	defer m.listenersMtx.Unlock()
+
+	m.serviceListeners = append(m.serviceListeners, listener)
+	return nil
+}
+
+func (m *memDB) AddJob(ctx context.Context, job *jobqueue.Job) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, job)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if job != nil && job.UniqueKey.IsNotNull() {
+		err = job.OnDuplicate.Validate()
+		if err != nil {
+			return err
+		}
+		if existing := m.unfinishedJobWithUniqueKey(job, nil); existing != nil {
+			return m.addDuplicateJob(job, existing, m.now(), &n)
+		}
+	}
+	err = m.checkInsertJob(job, nil, nil)
+	if err != nil {
+		return err
+	}
+	if m.scheduledJobExists(job) {
+		// on conflict do nothing, see insertJob of jobworkerdb
+		return nil
+	}
+	m.insertJobs([]*jobqueue.Job{job}, m.now(), &n)
+	return nil
+}
+
+func (m *memDB) AddJobs(ctx context.Context, jobs []*jobqueue.Job) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, len(jobs))
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Check everything before adding anything,
+	// like the rollback of the jobworkerdb transaction.
+	newJobIDs := make(map[uu.ID]bool, len(jobs))
+	for _, job := range jobs {
+		if job == nil {
+			return errs.New("<nil> job")
+		}
+		if newJobIDs[job.ID] {
+			return errs.Errorf("duplicate job ID %s", job.ID)
+		}
+		newJobIDs[job.ID] = true
+	}
+	// Jobs of the batch that will be inserted by type and unique key
+	type typeAndKey struct{ jobType, uniqueKey string }
+	inserted := make(map[typeAndKey]*jobqueue.Job)
+	for _, job := range jobs {
+		if job.UniqueKey.IsNotNull() {
+			err = job.OnDuplicate.Validate()
+			if err != nil {
+				return err
+			}
+			key := typeAndKey{job.Type, job.UniqueKey.Get()}
+			existing := m.unfinishedJobWithUniqueKey(job, nil)
+			if existing == nil && inserted[key] != nil {
+				existing = &jobRow{Job: *inserted[key]}
+			}
+			if existing != nil {
+				err = checkAddDuplicateJob(job, existing)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			inserted[key] = job
+		}
+		err = m.checkInsertJob(job, nil, newJobIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add the jobs in order like AddJob,
+	// so a duplicate of an earlier job of the batch finds it
+	now := m.now()
+	for _, job := range jobs {
+		if existing := m.unfinishedJobWithUniqueKey(job, nil); existing != nil {
+			err = m.addDuplicateJob(job, existing, now, &n)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if m.scheduledJobExists(job) {
+			continue
+		}
+		m.insertJobs([]*jobqueue.Job{job}, now, &n)
+	}
+	return nil
+}
+
+func (m *memDB) AddJobBundle(ctx context.Context, jobBundle *jobqueue.JobBundle) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobBundle)
+
+	// Make sure jobs are initialized for bundle
+	for _, job := range jobBundle.Jobs {
+		job.BundleID.Set(jobBundle.ID)
+	}
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Check everything before inserting anything,
+	// like the rollback of the jobworkerdb transaction.
+	if _, exists := m.bundles[jobBundle.ID]; exists {
+		return errs.Errorf("duplicate job bundle ID %s", jobBundle.ID)
+	}
+	if jobBundle.Type == "" {
+		return errs.New("empty job bundle type")
+	}
+	if jobBundle.Origin == "" {
+		return errs.New("empty job bundle origin")
+	}
+	if jobBundle.NumJobs < 0 {
+		return errs.Errorf("negative job bundle NumJobs %d", jobBundle.NumJobs)
+	}
+	newJobIDs := make(map[uu.ID]bool, len(jobBundle.Jobs))
+	for _, job := range jobBundle.Jobs {
+		if newJobIDs[job.ID] {
+			return errs.Errorf("duplicate job ID %s", job.ID)
+		}
+		newJobIDs[job.ID] = true
+	}
+	for i, job := range jobBundle.Jobs {
+		err = m.checkInsertJob(job, map[uu.ID]bool{jobBundle.ID: true}, newJobIDs)
+		if err != nil {
+			return err
+		}
+		// A duplicate always fails the whole bundle, see jobworkerDB.AddJobBundle
+		if existing := m.unfinishedJobWithUniqueKey(job, nil); existing != nil {
+			return newDuplicateJobError(job, existing)
+		}
+		for _, other := range jobBundle.Jobs[:i] {
+			if job.UniqueKey.IsNotNull() && other.Type == job.Type && other.UniqueKey == job.UniqueKey {
+				return newDuplicateJobError(job, &jobRow{Job: *other})
+			}
+		}
+	}
+
+	now := m.now()
+	m.bundles[jobBundle.ID] = &jobqueue.JobBundle{
+		ID:        jobBundle.ID,
+		Type:      jobBundle.Type,
+		Origin:    jobBundle.Origin,
+		NumJobs:   jobBundle.NumJobs,
+		UpdatedAt: now,
+		CreatedAt: now,
+	}
+	m.insertJobs(jobBundle.Jobs, now, &n)
+	return nil
+}
+
+func (m *memDB) GetJob(ctx context.Context, jobID uu.ID) (job *jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		// Same error as a jobworkerdb query without a row
+		// so errs.IsErrNotFound and sqldb.ReplaceErrNoRows work for both.
+		return nil, sql.ErrNoRows
+	}
+	job = cloneJob(&row.Job)
+	if len(row.dependsOn) > 0 {
+		job.DependsOn = slices.SortedFunc(slices.Values(row.dependsOn), compareIDs)
+	}
+	return job, nil
+}
+
+func (m *memDB) GetJobAttempts(ctx context.Context, jobID uu.ID) (attempts []*jobqueue.JobAttempt, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		return nil, nil
+	}
+	attempts = make([]*jobqueue.JobAttempt, len(row.attempts))
+	for i, attempt := range row.attempts {
+		clone := *attempt
+		clone.ErrorData = slices.Clone(attempt.ErrorData)
+		attempts[i] = &clone
+	}
+	return attempts, nil
+}
+
+func (m *memDB) DeleteJob(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	filter := func(row *jobRow) bool { return row.ID == jobID }
+	m.cancelDependentsOfDeletedJobs(filter, m.now(), &n)
+	m.deleteJobsWhere(filter)
+	return nil
+}
+
+func (m *memDB) ResetJob(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	return m.resetJobs(uu.IDs{jobID})
+}
+
+func (m *memDB) ResetJobs(ctx context.Context, jobIDs uu.IDs) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobIDs)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	return m.resetJobs(jobIDs)
+}
+
+func (m *memDB) resetJobs(jobIDs uu.IDs) error {
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Decrement the bundle counters of jobs that were already counted as
+	// stopped, see jobworkerDB.ResetJob. Check all counters first so that a
+	// constraint violation leaves everything unchanged.
+	var rows []*jobRow
+	decrements := make(map[uu.ID]int)
+	for _, jobID := range jobIDs {
+		row := m.jobs[jobID]
+		if row == nil || slices.Contains(rows, row) {
+			continue
+		}
+		rows = append(rows, row)
+		err := m.checkUniqueKeyOnRestart(row)
+		if err != nil {
+			return err
+		}
+		if countedInBundle(&row.Job) {
+			decrements[row.BundleID.Get()]--
+			err := m.canUpdateBundleNumJobsStopped(&row.Job, decrements[row.BundleID.Get()])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	now := m.now()
+	for _, row := range rows {
+		if countedInBundle(&row.Job) {
+			m.updateBundleNumJobsStopped(&row.Job, -1, now, &n)
+		}
+		row.recordAttempt(jobqueue.JobAttemptReset, "", nil, now)
+		old := row.Job
+		row.StartedAt.SetNull()
+		row.StoppedAt.SetNull()
+		row.ErrorMsg.SetNull()
+		row.ErrorData = nil
+		row.Result = nil
+		row.Worker.SetNull()
+		row.WorkerAliveAt.SetNull()
+		row.CancelRequestedAt.SetNull()
+		row.CancelledAt.SetNull()
+		row.CurrentRetryCount = 0
+		row.UpdatedAt = now
+		m.afterJobUpdate(&old, row, now, &n)
+	}
+	return nil
+}
+
+func (m *memDB) CancelJob(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil || row.StoppedAt.IsNotNull() {
+		return nil
+	}
+	// A job that was not started yet is stopped right away,
+	// see jobworkerDB.CancelJob.
+	notStarted := row.StartedAt.IsNull()
+	var cancelDependents []*jobRow
+	if notStarted {
+		cancelDependents = m.dependentsToCancel(uu.IDs{jobID})
+		err = m.canCountJobsInBundles(append([]*jobRow{row}, cancelDependents...))
+		if err != nil {
+			return err
+		}
+	}
+
+	now := m.now()
+	old := row.Job
+	if row.CancelRequestedAt.IsNull() {
+		row.CancelRequestedAt.Set(now)
+	}
+	if notStarted {
+		row.StoppedAt.Set(now)
+		row.CancelledAt.Set(now)
+	}
+	row.UpdatedAt = now
+	m.afterJobUpdate(&old, row, now, &n)
+
+	if notStarted {
+		m.updateBundleNumJobsStopped(&row.Job, +1, now, &n)
+		m.cancelJobs(cancelDependents, now, &n)
+	}
+	return nil
+}
+
+func (m *memDB) GetJobBundle(ctx context.Context, jobBundleID uu.ID) (jobBundle *jobqueue.JobBundle, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobBundleID)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	bundle := m.bundles[jobBundleID]
+	if bundle == nil {
+		return nil, sql.ErrNoRows
+	}
+	jobBundle = cloneBundle(bundle)
+	jobBundle.Jobs = m.sortedJobs(
+		func(row *jobRow) bool {
+			return row.BundleID.IsNotNull() && row.BundleID.Get() == jobBundleID
+		},
+		compareCreatedAt,
+	)
+	return jobBundle, nil
+}
+
+func (m *memDB) DeleteJobBundle(ctx context.Context, jobBundleID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobBundleID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.deleteBundlesWhere(func(bundle *jobqueue.JobBundle) bool {
+		return bundle.ID == jobBundleID
+	}, m.now(), &n)
+	return nil
+}
+
+func (m *memDB) GetStatus(ctx context.Context) (status *jobqueue.Status, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	status = &jobqueue.Status{
+		NumJobs:       len(m.jobs),
+		NumJobBundles: len(m.bundles),
+		ByType:        make(map[string]jobqueue.JobStats),
+		ByOrigin:      make(map[string]jobqueue.JobStats),
+	}
+	now := m.now()
+	for _, row := range m.jobs {
+		byType := status.ByType[row.Type]
+		byOrigin := status.ByOrigin[row.Origin]
+		for _, stats := range []*jobqueue.JobStats{&status.Jobs, &byType, &byOrigin} {
+			addJobStats(stats, &row.Job, now)
+		}
+		status.ByType[row.Type] = byType
+		status.ByOrigin[row.Origin] = byOrigin
+	}
+	return status, nil
+}
+
+// addJobStats counts job in stats with its jobqueue.JobState.
+func addJobStats(stats *jobqueue.JobStats, job *jobqueue.Job, now time.Time) {
+	// Pending jobs and due retries can be started now
+	if !job.Stopped() && !job.Started() && (job.StartAt.IsNull() || !job.StartAt.Get().After(now)) {
+		availableAt := job.CreatedAt
+		if job.StartAt.IsNotNull() && job.StartAt.Get().After(availableAt) {
+			availableAt = job.StartAt.Get()
+		}
+		stats.OldestPendingAge = max(stats.OldestPendingAge, now.Sub(availableAt))
+	}
+
+	switch job.State(now) {
+	case jobqueue.JobStateRunning:
+		stats.Running++
+		if job.WorkerAliveAt.IsNotNull() {
+			stats.OldestHeartbeatAge = max(stats.OldestHeartbeatAge, now.Sub(job.WorkerAliveAt.Get()))
+		}
+	case jobqueue.JobStateRetrying:
+		stats.Retrying++
+	case jobqueue.JobStateDelayed:
+		stats.Delayed++
+	case jobqueue.JobStatePending:
+		stats.Pending++
+	case jobqueue.JobStateFailed:
+		stats.Failed++
+	case jobqueue.JobStateCancelled:
+		stats.Cancelled++
+	default:
+		stats.Succeeded++
+	}
+}
+
+func (m *memDB) GetAllJobsToDo(ctx context.Context) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sortedJobs(
+		func(row *jobRow) bool { return row.StoppedAt.IsNull() },
+		func(a, b *jobRow) int {
+			return cmp.Or(
+				compareNullableTime(a.StartAt, b.StartAt),
+				compareCreatedAt(a, b),
+			)
+		},
+	), nil
+}
+
+func (m *memDB) GetAllJobsStartedBefore(ctx context.Context, before time.Time) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sortedJobs(
+		func(row *jobRow) bool {
+			return row.StartedAt.IsNotNull() &&
+				row.StartedAt.Get().Before(before) &&
+				row.StoppedAt.IsNull()
+		},
+		func(a, b *jobRow) int {
+			return cmp.Or(
+				compareNullableTime(a.StartedAt, b.StartedAt),
+				compareCreatedAt(a, b),
+			)
+		},
+	), nil
+}
+
+func (m *memDB) GetAllJobsWithErrors(ctx context.Context) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sortedJobs(
+		func(row *jobRow) bool { return row.ErrorMsg.IsNotNull() },
+		func(a, b *jobRow) int {
+			return cmp.Or(
+				compareNullableTime(a.StoppedAt, b.StoppedAt),
+				compareCreatedAt(a, b),
+			)
+		},
+	), nil
+}
+
+func (m *memDB) GetJobs(ctx context.Context, filter *jobqueue.JobFilter) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, filter)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+	if err = filter.Validate(); err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = new(jobqueue.JobFilter)
+	}
+	errorRegexp := regexp.MustCompile(filter.ErrorPattern)
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	compare := compareCreatedAt
+	if filter.NewestFirst {
+		compare = func(a, b *jobRow) int { return compareCreatedAt(b, a) }
+	}
+	now := m.now()
+	jobs = m.sortedJobs(
+		func(row *jobRow) bool {
+			return (filter.Type == "" || row.Type == filter.Type) &&
+				(filter.Origin == "" || row.Origin == filter.Origin) &&
+				(filter.BundleID.IsNull() || row.BundleID == filter.BundleID) &&
+				(filter.State == "" || row.State(now) == filter.State) &&
+				(filter.ErrorPattern == "" || row.ErrorMsg.IsNotNull() && errorRegexp.MatchString(row.ErrorMsg.Get()))
+		},
+		compare,
+	)
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+func (m *memDB) DeleteFinishedJobs(ctx context.Context) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Cancelled jobs with dependents are kept like in jobworkerdb
+	// because deleting them would unblock their dependents
+	dependedOn := make(map[uu.ID]bool)
+	for _, row := range m.jobs {
+		for _, id := range row.dependsOn {
+			dependedOn[id] = true
+		}
+	}
+	m.deleteJobsWhere(func(row *jobRow) bool {
+		return row.StoppedAt.IsNotNull() && row.ErrorMsg.IsNull() && row.BundleID.IsNull() &&
+			!(row.Cancelled() && dependedOn[row.ID])
+	})
+	return nil
+}
+
+func (m *memDB) Close() (err error) {
+	defer errs.WrapWithFuncParams(&err)
+
+	if !m.closed.CompareAndSwap(false, true) {
+		return jobqueue.ErrClosed
+	}
+
+	m.listenersMtx.Lock()
+	defer m.listenersMtx.Unlock()
+
+	m.serviceListeners = nil
+	m.jobAvailableListener = nil
+	m.jobCancelRequestedListener = nil
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// jobworker.DataBase methods
+
+func (m *memDB) SetJobAvailableListener(ctx context.Context, callback func()) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, callback)
+
+	m.listenersMtx.Lock()
+	defer m.listenersMtx.Unlock()
+
+	m.jobAvailableListener = callback
+	return nil
+}
+
+func (m *memDB) SetJobCancelRequestedListener(ctx context.Context, callback func(jobID uu.ID)) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, callback)
+
+	m.listenersMtx.Lock()
+	defer m.listenersMtx.Unlock()
+
+	m.jobCancelRequestedListener = callback
+	return nil
+}
+
+func (m *memDB) StartNextJobOrNil(ctx context.Context, claim *jobworker.Claim, skipJobTypes ...string) (job *jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim, skipJobTypes)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	jobTypes := claim.JobTypes
+	if len(jobTypes) == 0 {
+		// No registered worker types: nothing this process can claim.
+		return nil, nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	next := m.startNextJobLocked(claim, skipJobTypes)
+	if next == nil {
+		return nil, nil
+	}
+	return cloneJob(&next.Job), nil
+}
+
+func (m *memDB) StartNextJobsOrNil(ctx context.Context, claim *jobworker.Claim, n int, skipJobTypes ...string) (jobs []*jobqueue.Job, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim, n, skipJobTypes)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	if len(claim.JobTypes) == 0 {
+		return nil, nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Every claim counts the jobs claimed before it for the limits
+	for range n {
+		next := m.startNextJobLocked(claim, skipJobTypes)
+		if next == nil {
+			break
+		}
+		jobs = append(jobs, cloneJob(&next.Job))
+	}
+	return jobs, nil
+}
+
+// startNextJobLocked starts and returns the next job
+// of claim without skipJobTypes or nil if there is none.
+// m.mtx must be locked.
+func (m *memDB) startNextJobLocked(claim *jobworker.Claim, skipJobTypes []string) *jobRow {
+	jobTypes := claim.JobTypes
+
+	// Same as the saturated CTE of the jobworkerdb claim statement
+	skipJobTypes = slices.Clone(skipJobTypes)
+	if limits := claim.ClusterMaxConcurrency; len(limits) > 0 {
+		running := make(map[string]int)
+		for _, row := range m.jobs {
+			if row.StartedAt.IsNotNull() && row.StoppedAt.IsNull() {
+				running[row.Type]++
+			}
+		}
+		for jobType, n := range limits {
+			if running[jobType] >= n {
+				skipJobTypes = append(skipJobTypes, jobType)
+			}
+		}
+	}
+	now := m.now()
+	for jobType, limit := range claim.RateLimits {
+		if _, reached := m.rateLimitReached(jobType, limit, now); reached {
+			skipJobTypes = append(skipJobTypes, jobType)
+		}
+	}
+
+	var next *jobRow
+	for _, row := range m.jobs {
+		if row.StartedAt.IsNull() &&
+			row.StoppedAt.IsNull() && // not cancelled before it was started
+			startReached(&row.Job, now) &&
+			slices.Contains(jobTypes, row.Type) &&
+			!slices.Contains(skipJobTypes, row.Type) &&
+			m.dependenciesDone(row) &&
+			(next == nil || compareClaimOrder(row, next) < 0) {
+			next = row
+		}
+	}
+	if next == nil {
+		return nil
+	}
+
+	next.StartedAt.Set(now)
+	next.Worker = nullable.NonEmptyString(claim.Worker)
+	// Same as the worker_alive_at of the jobworkerdb claim statement
+	if claim.Heartbeat {
+		next.WorkerAliveAt.Set(now)
+	} else {
+		next.WorkerAliveAt.SetNull()
+	}
+	next.UpdatedAt = now
+	if limit, ok := claim.RateLimits[next.Type]; ok {
+		m.countRateLimitedJob(next.Type, limit, now)
+	}
+	return next
+}
+
+func (m *memDB) UnclaimJobs(ctx context.Context, jobIDs uu.IDs) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobIDs)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+	for _, jobID := range jobIDs {
+		row := m.jobs[jobID]
+		if row == nil || !row.StartedAndNotStopped() {
+			continue
+		}
+		old := row.Job
+		row.StartedAt.SetNull()
+		row.Worker.SetNull()
+		row.WorkerAliveAt.SetNull()
+		row.UpdatedAt = now
+		m.afterJobUpdate(&old, row, now, &n)
+	}
+	return nil
+}
+
+func (m *memDB) GetNextJobStartDelay(ctx context.Context, claim *jobworker.Claim) (delay time.Duration, ok bool, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, claim)
+
+	if m.closed.Load() {
+		return 0, false, jobqueue.ErrClosed
+	}
+
+	jobTypes := claim.JobTypes
+	if len(jobTypes) == 0 {
+		return 0, false, nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+	var next nullable.Time
+	for _, row := range m.jobs {
+		if row.StartedAt.IsNull() &&
+			row.StoppedAt.IsNull() &&
+			!startReached(&row.Job, now) &&
+			slices.Contains(jobTypes, row.Type) &&
+			(next.IsNull() || row.StartAt.Get().Before(next.Get())) {
+			next = row.StartAt
+		}
+	}
+	// Rate limited job types that reached their limit
+	// become claimable again when their window ends
+	for jobType, limit := range claim.RateLimits {
+		windowEnd, reached := m.rateLimitReached(jobType, limit, now)
+		if !reached || !slices.Contains(jobTypes, jobType) || (next.IsNotNull() && !windowEnd.Before(next.Get())) {
+			continue
+		}
+		for _, row := range m.jobs {
+			if row.Type == jobType && row.StartedAt.IsNull() && row.StoppedAt.IsNull() && startReached(&row.Job, now) {
+				next.Set(windowEnd)
+				break
+			}
+		}
+	}
+	if next.IsNull() {
+		return 0, false, nil
+	}
+	return next.Get().Sub(now), true, nil
+}
+
+func (m *memDB) SetJobError(ctx context.Context, jobID uu.ID, errorMsg string, errorData nullable.JSON) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, errorMsg, errorData)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		return nil
+	}
+	// SetJobError is always terminal and counts the job in its bundle,
+	// see jobworkerDB.SetJobError.
+	cancelDependents := m.dependentsToCancel(uu.IDs{jobID})
+	err = m.canCountJobsInBundles(append([]*jobRow{row}, cancelDependents...))
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	row.recordAttempt(jobqueue.JobAttemptFailed, errorMsg, errorData, now)
+	old := row.Job
+	row.StoppedAt.Set(now)
+	row.ErrorMsg = nullable.NonEmptyString(errorMsg)
+	row.ErrorData = slices.Clone(errorData)
+	row.CurrentRetryCount = row.MaxRetryCount
+	row.Worker.SetNull()
+	row.WorkerAliveAt.SetNull()
+	row.UpdatedAt = now
+	m.afterJobUpdate(&old, row, now, &n)
+
+	m.updateBundleNumJobsStopped(&row.Job, +1, now, &n)
+	m.cancelJobs(cancelDependents, now, &n)
+	return nil
+}
+
+func (m *memDB) SetJobCancelled(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil || row.StoppedAt.IsNotNull() {
+		return nil
+	}
+	cancelDependents := m.dependentsToCancel(uu.IDs{jobID})
+	err = m.canCountJobsInBundles(append([]*jobRow{row}, cancelDependents...))
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	row.recordAttempt(jobqueue.JobAttemptCancelled, "", nil, now)
+	old := row.Job
+	if row.CancelRequestedAt.IsNull() {
+		row.CancelRequestedAt.Set(now)
+	}
+	row.StoppedAt.Set(now)
+	row.CancelledAt.Set(now)
+	row.ErrorMsg.SetNull()
+	row.ErrorData = nil
+	row.Result = nil
+	row.Worker.SetNull()
+	row.WorkerAliveAt.SetNull()
+	row.UpdatedAt = now
+	m.afterJobUpdate(&old, row, now, &n)
+
+	m.updateBundleNumJobsStopped(&row.Job, +1, now, &n)
+	m.cancelJobs(cancelDependents, now, &n)
+	return nil
+}
+
+func (m *memDB) SetJobResult(ctx context.Context, jobID uu.ID, result nullable.JSON) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, result)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	// if the result is `nil`, set an empty object so that the bundle knows the job existed correctly
+	if len(result) == 0 {
+		result = []byte("{}")
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		return nil
+	}
+	err = m.canUpdateBundleNumJobsStopped(&row.Job, +1)
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	row.recordAttempt(jobqueue.JobAttemptSucceeded, "", nil, now)
+	old := row.Job
+	row.Result = slices.Clone(result)
+	row.StoppedAt.Set(now)
+	row.CancelRequestedAt.SetNull()
+	row.Worker.SetNull()
+	row.WorkerAliveAt.SetNull()
+	row.UpdatedAt = now
+	row.ErrorMsg.SetNull()
+	row.ErrorData = nil
+	m.afterJobUpdate(&old, row, now, &n)
+
+	m.updateBundleNumJobsStopped(&row.Job, +1, now, &n)
+	return nil
+}
+
+func (m *memDB) SetJobStart(ctx context.Context, jobID uu.ID, startAt time.Time) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, startAt)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		return nil
+	}
+	err = m.checkUniqueKeyOnRestart(row)
+	if err != nil {
+		return err
+	}
+	// Like ResetJob
+	if countedInBundle(&row.Job) {
+		err = m.canUpdateBundleNumJobsStopped(&row.Job, -1)
+		if err != nil {
+			return err
+		}
+	}
+	now := m.now()
+	if countedInBundle(&row.Job) {
+		m.updateBundleNumJobsStopped(&row.Job, -1, now, &n)
+	}
+	row.recordAttempt(jobqueue.JobAttemptReset, "", nil, now)
+	old := row.Job
+	row.StartAt.Set(startAt)
+	row.StartedAt.SetNull()
+	row.StoppedAt.SetNull()
+	row.ErrorMsg.SetNull()
+	row.ErrorData = nil
+	row.Worker.SetNull()
+	row.WorkerAliveAt.SetNull()
+	row.CancelRequestedAt.SetNull()
+	row.CancelledAt.SetNull()
+	row.UpdatedAt = now
+	m.afterJobUpdate(&old, row, now, &n)
+	return nil
+}
+
+func (m *memDB) SetJobWorkerAlive(ctx context.Context, jobID uu.ID) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Only update worker_alive_at while the job is actually being processed,
+	// see setJobWorkerAliveStmt of jobworkerdb.
+	row := m.jobs[jobID]
+	if row == nil || row.StartedAt.IsNull() || row.StoppedAt.IsNotNull() {
+		return nil
+	}
+	now := m.now()
+	row.WorkerAliveAt.Set(now)
+	row.UpdatedAt = now
+	return nil
+}
+
+func (m *memDB) ScheduleRetry(ctx context.Context, jobID uu.ID, startAt time.Time, retryCount int, errorMsg string, errorData nullable.JSON) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, startAt, retryCount, errorMsg, errorData)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+	return m.rescheduleJob(jobID, startAt, retryCount, jobqueue.JobAttemptRetried, errorMsg, errorData)
+}
+
+func (m *memDB) SnoozeJob(ctx context.Context, jobID uu.ID, startAt time.Time) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobID, startAt)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+	return m.rescheduleJob(jobID, startAt, -1, jobqueue.JobAttemptSnoozed, "", nil)
+}
+
+// rescheduleJob implements ScheduleRetry and SnoozeJob.
+// A negative retryCount keeps the current retry count.
+func (m *memDB) rescheduleJob(jobID uu.ID, startAt time.Time, retryCount int, outcome jobqueue.JobAttemptOutcome, errorMsg string, errorData nullable.JSON) error {
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.jobs[jobID]
+	if row == nil {
+		return nil
+	}
+	err := m.checkUniqueKeyOnRestart(row)
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	row.recordAttempt(outcome, errorMsg, errorData, now)
+	old := row.Job
+	row.StartAt.Set(startAt)
+	row.StartedAt.SetNull()
+	row.StoppedAt.SetNull()
+	row.ErrorMsg.SetNull()
+	row.ErrorData = nil
+	row.Worker.SetNull()
+	row.WorkerAliveAt.SetNull()
+	if retryCount >= 0 {
+		row.CurrentRetryCount = retryCount
+	}
+	row.UpdatedAt = now
+	m.afterJobUpdate(&old, row, now, &n)
+	return nil
+}
+
+func (m *memDB) DeleteJobsFromOrigin(ctx context.Context, origin string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, origin)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	filter := func(row *jobRow) bool { return row.Origin == origin }
+	m.cancelDependentsOfDeletedJobs(filter, m.now(), &n)
+	m.deleteJobsWhere(filter)
+	return nil
+}
+
+func (m *memDB) DeleteJobsOfType(ctx context.Context, jobType string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, jobType)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	filter := func(row *jobRow) bool { return row.Type == jobType }
+	m.cancelDependentsOfDeletedJobs(filter, m.now(), &n)
+	m.deleteJobsWhere(filter)
+	return nil
+}
+
+func (m *memDB) DeleteJobBundlesFromOrigin(ctx context.Context, origin string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, origin)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.deleteBundlesWhere(func(bundle *jobqueue.JobBundle) bool { return bundle.Origin == origin }, m.now(), &n)
+	return nil
+}
+
+func (m *memDB) DeleteJobBundlesOfType(ctx context.Context, bundleType string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, bundleType)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.deleteBundlesWhere(func(bundle *jobqueue.JobBundle) bool { return bundle.Type == bundleType }, m.now(), &n)
+	return nil
+}
+
+func (m *memDB) DeleteAllJobsAndBundles(ctx context.Context) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	clear(m.bundles)
+	clear(m.jobs)
+	return nil
+}
@@ -0,0 +1,123 @@
+package memqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// registerNoopWorker registers a worker for jobType for the duration of the test.
+func registerNoopWorker(t *testing.T, jobType string) {
+	t.Helper()
+	jobworker.Register(jobType, func(context.Context, *jobqueue.Job) (any, error) { return nil, nil })
+	t.Cleanup(func() { jobworker.Unregister(jobType) })
+}
+
+func newTestJob(t *testing.T, jobType string, priority int64, startAt nullable.Time) *jobqueue.Job {
+	t.Helper()
+	job, err := jobqueue.NewJobWithPriority(uu.NewID(t.Context()), jobType, "memqueue-test", "{}", priority, startAt)
+	require.NoError(t, err)
+	return job
+}
+
+func TestStartNextJobOrNilStartAt(t *testing.T) {
+	const jobType = "memqueue-test-start-at"
+	registerNoopWorker(t, jobType)
+
+	m := newMemDB()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	var available int
+	require.NoError(t, m.SetJobAvailableListener(t.Context(), func() { available++ }))
+
+	later := newTestJob(t, jobType, 0, nullable.TimeFrom(now.Add(time.Hour)))
+	require.NoError(t, m.AddJob(t.Context(), later))
+	assert.Zero(t, available, "no job_available for a job starting in the future")
+
+	job, err := m.StartNextJobOrNil(t.Context(), jobworker.DefaultPool().Claim())
+	require.NoError(t, err)
+	assert.Nil(t, job, "job must not start before start_at")
+
+	delay, ok, err := m.GetNextJobStartDelay(t.Context(), jobworker.DefaultPool().Claim())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour, delay)
+
+	now = now.Add(time.Hour)
+	_, ok, err = m.GetNextJobStartDelay(t.Context(), jobworker.DefaultPool().Claim())
+	require.NoError(t, err)
+	assert.False(t, ok, "start_at reached")
+
+	job, err = m.StartNextJobOrNil(t.Context(), jobworker.DefaultPool().Claim())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, later.ID, job.ID)
+
+	// Resetting a started job makes it available again
+	require.NoError(t, m.ResetJob(t.Context(), later.ID))
+	assert.Equal(t, 1, available)
+}
+
+func TestAddJobConstraints(t *testing.T) {
+	m := newMemDB()
+
+	job := newTestJob(t, "memqueue-test-constraints", 0, nullable.Time{})
+	require.NoError(t, m.AddJob(t.Context(), job))
+	require.Error(t, m.AddJob(t.Context(), job), "duplicate ID")
+
+	job = newTestJob(t, "memqueue-test-constraints", 0, nullable.Time{})
+	job.Origin = ""
+	require.Error(t, m.AddJob(t.Context(), job), "empty origin")
+
+	job = newTestJob(t, "memqueue-test-constraints", 0, nullable.Time{})
+	job.BundleID.Set(uu.NewID(t.Context()))
+	require.Error(t, m.AddJob(t.Context(), job), "unknown bundle")
+
+	status, err := m.GetStatus(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.NumJobs)
+}
+
+func TestClosed(t *testing.T) {
+	m := newMemDB()
+	require.NoError(t, m.Close())
+	require.ErrorIs(t, m.Close(), jobqueue.ErrClosed)
+	require.ErrorIs(t, m.AddJob(t.Context(), newTestJob(t, "memqueue-test-closed", 0, nullable.Time{})), jobqueue.ErrClosed)
+	_, err := m.StartNextJobOrNil(t.Context(), jobworker.DefaultPool().Claim())
+	require.ErrorIs(t, err, jobqueue.ErrClosed)
+}
+
+func TestInitJobQueueWorkerThreads(t *testing.T) {
+	const jobType = "memqueue-test-worker-threads"
+	jobworker.Register(jobType, func(ctx context.Context, job *jobqueue.Job) (any, error) {
+		return map[string]string{"echo": job.Payload.String()}, nil
+	})
+	t.Cleanup(func() { jobworker.Unregister(jobType) })
+
+	require.NoError(t, InitJobQueue(t.Context()))
+	t.Cleanup(func() { _ = jobqueue.Close() })
+
+	require.NoError(t, jobworker.StartThreads(t.Context(), 2))
+	t.Cleanup(func() { jobworker.FinishThreads(context.Background()) })
+
+	job := newTestJob(t, jobType, 0, nullable.Time{})
+	require.NoError(t, jobqueue.Add(t.Context(), job))
+
+	require.Eventually(t, func() bool {
+		loaded, err := jobqueue.GetJob(t.Context(), job.ID)
+		return err == nil && loaded.Succeeded()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	loaded, err := jobqueue.GetJob(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"echo":"{}"}`, loaded.Result.String())
+}
@@ -0,0 +1,38 @@
+package memqueue
+
+import (
+	"time"
+
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// rateWindow is the equivalent of a worker.rate_limit row.
+type rateWindow struct {
+	start      time.Time
+	numStarted int
+}
+
+// rateLimitReached returns the end of the current window of jobType
+// if the window has not ended at now and limit is reached in it.
+// The caller must hold m.mtx.
+func (m *memDB) rateLimitReached(jobType string, limit jobworker.RateLimit, now time.Time) (windowEnd time.Time, reached bool) {
+	w := m.rateWindows[jobType]
+	if w == nil {
+		return time.Time{}, false
+	}
+	windowEnd = w.start.Add(limit.Per)
+	return windowEnd, windowEnd.After(now) && w.numStarted >= limit.N
+}
+
+// countRateLimitedJob counts a started job of a rate limited jobType
+// in its current window, or starts a new window if the current one ended
+// at now, see the jobworkerdb function of the same name.
+// The caller must hold m.mtx.
+func (m *memDB) countRateLimitedJob(jobType string, limit jobworker.RateLimit, now time.Time) {
+	w := m.rateWindows[jobType]
+	if w == nil || !w.start.Add(limit.Per).After(now) {
+		m.rateWindows[jobType] = &rateWindow{start: now, numStarted: 1}
+		return
+	}
+	w.numStarted++
+}
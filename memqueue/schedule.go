@@ -0,0 +1,172 @@
+package memqueue
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/domonda/go-errs"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// checkScheduledJob checks the foreign key of job.ScheduleName.
+// The caller must hold m.mtx.
+func (m *memDB) checkScheduledJob(job *jobqueue.Job) error {
+	if job.ScheduleName.IsNull() {
+		return nil
+	}
+	if m.schedules[job.ScheduleName.Get()] == nil {
+		return errs.Errorf("job schedule %q does not exist", job.ScheduleName.Get())
+	}
+	return nil
+}
+
+// scheduledJobExists reports whether a job for the same schedule occurrence
+// as job exists, see the worker_job_schedule_fire_time_idx index.
+// The caller must hold m.mtx.
+func (m *memDB) scheduledJobExists(job *jobqueue.Job) bool {
+	if job.ScheduleName.IsNull() {
+		return false
+	}
+	for _, row := range m.jobs {
+		if row.ScheduleName == job.ScheduleName && compareNullableTime(row.ScheduleFireTime, job.ScheduleFireTime) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneSchedule(schedule *jobqueue.JobSchedule) *jobqueue.JobSchedule {
+	c := *schedule
+	return &c
+}
+
+func (m *memDB) SaveJobSchedule(ctx context.Context, schedule *jobqueue.JobSchedule) (saved *jobqueue.JobSchedule, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, schedule)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if l := len(schedule.Name); l == 0 || l > 100 {
+		return nil, errs.Errorf("job schedule name length %d not in range [1, 100]", l)
+	}
+	if schedule.CronExpr == "" {
+		return nil, errs.New("empty job schedule cron expression")
+	}
+	err = schedule.CatchUp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	row := m.schedules[schedule.Name]
+	if row == nil {
+		row = &jobqueue.JobSchedule{
+			Name:      schedule.Name,
+			CreatedAt: now,
+		}
+		m.schedules[schedule.Name] = row
+	} else if row.CronExpr != schedule.CronExpr || row.Timezone != schedule.Timezone {
+		// Replace the occurrence added in advance, see jobworkerDB.SaveJobSchedule
+		m.deleteJobsWhere(func(job *jobRow) bool {
+			return job.ScheduleName.Get() == schedule.Name &&
+				job.ScheduleFireTime.IsNotNull() &&
+				job.ScheduleFireTime.Get().After(now) &&
+				job.StartedAt.IsNull() &&
+				job.StoppedAt.IsNull()
+		})
+		if row.LastFireTime.IsNull() || row.LastFireTime.Get().After(now) {
+			row.LastFireTime.Set(now)
+		}
+	}
+	row.CronExpr = schedule.CronExpr
+	row.Timezone = schedule.Timezone
+	row.CatchUp = schedule.CatchUp.OrDefault()
+	row.UpdatedAt = now
+	return cloneSchedule(row), nil
+}
+
+func (m *memDB) AddScheduledJobs(ctx context.Context, scheduleName string, jobs []*jobqueue.Job) (schedule *jobqueue.JobSchedule, err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, scheduleName, jobs)
+
+	if m.closed.Load() {
+		return nil, jobqueue.ErrClosed
+	}
+
+	var n notifications
+	defer m.notify(&n)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	row := m.schedules[scheduleName]
+	if row == nil {
+		// Same error as a jobworkerdb query without a row
+		return nil, sql.ErrNoRows
+	}
+
+	// Check everything before inserting anything,
+	// like the rollback of the jobworkerdb transaction.
+	var insert []*jobqueue.Job
+	for _, job := range jobs {
+		if job == nil {
+			return nil, errs.New("<nil> job")
+		}
+		if job.ScheduleName.Get() != scheduleName || job.ScheduleFireTime.IsNull() {
+			return nil, errs.Errorf("job %s is not an occurrence of the schedule %q", job.ID, scheduleName)
+		}
+		if row.LastFireTime.IsNotNull() && !job.ScheduleFireTime.Get().After(row.LastFireTime.Get()) {
+			continue
+		}
+		if m.scheduledJobExists(job) || slices.ContainsFunc(insert, func(other *jobqueue.Job) bool {
+			return other.ScheduleFireTime.Get().Equal(job.ScheduleFireTime.Get())
+		}) {
+			continue
+		}
+		err = m.checkInsertJob(job, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		insert = append(insert, job)
+	}
+	if len(insert) == 0 {
+		return cloneSchedule(row), nil
+	}
+
+	now := m.now()
+	m.insertJobs(insert, now, &n)
+	for _, job := range insert {
+		if row.LastFireTime.IsNull() || job.ScheduleFireTime.Get().After(row.LastFireTime.Get()) {
+			row.LastFireTime = job.ScheduleFireTime
+		}
+	}
+	row.UpdatedAt = now
+	return cloneSchedule(row), nil
+}
+
+func (m *memDB) DeleteJobSchedule(ctx context.Context, scheduleName string) (err error) {
+	defer errs.WrapWithFuncParams(&err, ctx, scheduleName)
+
+	if m.closed.Load() {
+		return jobqueue.ErrClosed
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.deleteJobsWhere(func(row *jobRow) bool {
+		return row.ScheduleName.Get() == scheduleName && row.StartedAt.IsNull() && row.StoppedAt.IsNull()
+	})
+	// on delete set null
+	for _, row := range m.jobs {
+		if row.ScheduleName.Get() == scheduleName {
+			row.ScheduleName.SetNull()
+		}
+	}
+	delete(m.schedules, scheduleName)
+	return nil
+}
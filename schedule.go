@@ -0,0 +1,96 @@
+package jobqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+)
+
+// ScheduleCatchUp defines which occurrences of a job schedule
+// are run that were missed because no worker process was running
+// the scheduler at their fire time.
+//
+// The next occurrence of a schedule is always added to the queue
+// in advance, so the first occurrence after the scheduler stopped
+// is not missed but runs as soon as workers are available again.
+// The catch-up policy applies to the occurrences after that one.
+type ScheduleCatchUp string
+
+const (
+	// ScheduleCatchUpSkip does not run missed occurrences.
+	ScheduleCatchUpSkip ScheduleCatchUp = "skip"
+
+	// ScheduleCatchUpOnce runs the latest missed occurrence once.
+	// This is the default used for an empty ScheduleCatchUp.
+	ScheduleCatchUpOnce ScheduleCatchUp = "once"
+
+	// ScheduleCatchUpAll runs every missed occurrence,
+	// up to MaxScheduleCatchUpRuns of the latest ones.
+	ScheduleCatchUpAll ScheduleCatchUp = "all"
+)
+
+// MaxScheduleCatchUpRuns limits the number of missed occurrences
+// that are run with ScheduleCatchUpAll.
+const MaxScheduleCatchUpRuns = 1000
+
+// Valid returns true if the policy is one of the defined constants
+// or empty, which is interpreted as ScheduleCatchUpOnce.
+func (c ScheduleCatchUp) Valid() bool {
+	switch c {
+	case "", ScheduleCatchUpSkip, ScheduleCatchUpOnce, ScheduleCatchUpAll:
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the policy is not Valid.
+func (c ScheduleCatchUp) Validate() error {
+	if !c.Valid() {
+		return fmt.Errorf("invalid ScheduleCatchUp %q", string(c))
+	}
+	return nil
+}
+
+// OrDefault returns ScheduleCatchUpOnce for an empty policy
+// and the policy itself otherwise.
+func (c ScheduleCatchUp) OrDefault() ScheduleCatchUp {
+	if c == "" {
+		return ScheduleCatchUpOnce
+	}
+	return c
+}
+
+// JobSchedule is a worker.job_schedule row describing
+// a recurring job registered with jobworker.RegisterSchedule.
+//
+// The occurrences of a schedule are added to the queue as normal jobs
+// with Job.ScheduleName set to the Name of the schedule
+// and Job.ScheduleFireTime and Job.StartAt set to the fire time.
+type JobSchedule struct {
+	db.TableName `db:"worker.job_schedule"`
+
+	Name     string          `db:"name,primarykey" json:"name"` // CHECK(length(name) > 0 AND length(name) <= 100)
+	CronExpr string          `db:"cron_expr"       json:"cronExpr"`
+	Timezone string          `db:"timezone"        json:"timezone"` // IANA time zone name used to interpret CronExpr
+	CatchUp  ScheduleCatchUp `db:"catch_up"        json:"catchUp"`
+
+	// LastFireTime is the latest fire time that was added to the queue
+	// as a job, or NULL if no occurrence was added yet.
+	// It is usually in the future because the next occurrence
+	// is added in advance.
+	LastFireTime nullable.Time `db:"last_fire_time" json:"lastFireTime"`
+
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"` // Time the row was last updated
+	CreatedAt time.Time `db:"created_at" json:"createdAt"` // Time the schedule was first registered
+}
+
+// String implements the fmt.Stringer interface.
+// Valid to call on a nil receiver.
+func (s *JobSchedule) String() string {
+	if s == nil {
+		return "nil JobSchedule"
+	}
+	return fmt.Sprintf("JobSchedule %s, cron '%s' in %s, catch-up %s", s.Name, s.CronExpr, s.Timezone, s.CatchUp.OrDefault())
+}
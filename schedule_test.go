@@ -0,0 +1,28 @@
+package jobqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+func TestScheduleCatchUp(t *testing.T) {
+	for _, catchUp := range []jobqueue.ScheduleCatchUp{
+		"",
+		jobqueue.ScheduleCatchUpSkip,
+		jobqueue.ScheduleCatchUpOnce,
+		jobqueue.ScheduleCatchUpAll,
+	} {
+		assert.True(t, catchUp.Valid(), "catch-up %q", catchUp)
+		assert.NoError(t, catchUp.Validate(), "catch-up %q", catchUp)
+	}
+
+	invalid := jobqueue.ScheduleCatchUp("latest")
+	assert.False(t, invalid.Valid())
+	assert.Error(t, invalid.Validate())
+
+	assert.Equal(t, jobqueue.ScheduleCatchUpOnce, jobqueue.ScheduleCatchUp("").OrDefault())
+	assert.Equal(t, jobqueue.ScheduleCatchUpAll, jobqueue.ScheduleCatchUpAll.OrDefault())
+}
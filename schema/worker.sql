@@ -6,7 +6,11 @@ BEGIN;
 CREATE EXTENSION "uuid-ossp";
 CREATE SCHEMA worker;
 \ir worker/job_bundle.sql
+\ir worker/job_schedule.sql
 \ir worker/job.sql
+\ir worker/job_dependency.sql
+\ir worker/job_attempt.sql
+\ir worker/rate_limit.sql
 \ir worker/job_triggers.sql
 
 COMMIT;
\ No newline at end of file
@@ -12,10 +12,23 @@ create table worker.job (
     current_retry_count int not null default 0,
     start_at            timestamptz, -- If NOT NULL, earliest time to start the job
 
+    on_dependency_failure text not null default 'block' check(on_dependency_failure in ('block', 'cancel', 'run')), -- What happens when a job of worker.job_dependency finally failed
+
+    unique_key text check(length(unique_key) > 0), -- If NOT NULL, unique over the unfinished jobs of the same type, see worker_job_unique_key_idx
+
+    schedule_name      text references worker.job_schedule(name) on delete set null, -- The worker.job_schedule that added the job, or NULL
+    schedule_fire_time timestamptz, -- Fire time of the schedule occurrence, see worker_job_schedule_fire_time_idx
+
     started_at      timestamptz, -- Time when started working on the job, or NULL when not started
+    worker          text,        -- jobworker.WorkerName of the process that claimed the job, NULL when not being processed
     worker_alive_at timestamptz, -- Heartbeat updated periodically while a worker processes the job; NULL when not being processed. A stale value while stopped_at IS NULL indicates the worker crashed.
     stopped_at      timestamptz, -- Time when working on job was stopped for any reason
 
+    cancel_requested_at timestamptz, -- Time when cancellation was requested with CancelJob, or NULL
+    cancelled_at        timestamptz, -- Time when the job was stopped as cancelled, or NULL
+
+    trace_context jsonb, -- W3C Trace Context {"traceparent", "tracestate"} of the span that added the job, or NULL
+
     error_msg  text,  -- If there was an error working off the job
     error_data jsonb, -- Optional error metadata
 	result     jsonb, -- Result if the job returned one
@@ -33,6 +46,18 @@ comment on table worker.job IS 'A `Job` to be worked out later.';
 -- bundle_id is NULL for all standalone jobs, which never participate in either.
 create index worker_job_bundle_id_idx  on worker.job(bundle_id) where bundle_id is not null;
 create index worker_job_type_idx       on worker.job("type");
+-- Partial unique index deduplicating jobs by their optional unique_key.
+-- Only unfinished jobs (stopped_at is null) take part, so a key can be used
+-- again once the job with the key has stopped. Also the conflict target of
+-- the "insert ... on conflict" statements used for jobs with a unique_key.
+create unique index worker_job_unique_key_idx on worker.job("type", unique_key)
+  where unique_key is not null and stopped_at is null;
+-- Partial unique index making sure that an occurrence of a schedule is only
+-- added once, even if several worker processes run the scheduler.
+-- Also the conflict target of the "insert ... on conflict do nothing"
+-- statement used for scheduled jobs.
+create unique index worker_job_schedule_fire_time_idx on worker.job(schedule_name, schedule_fire_time)
+  where schedule_name is not null;
 create index worker_job_start_at_idx   on worker.job(start_at);
 create index worker_job_started_at_idx on worker.job(started_at);
 create index worker_job_stopped_at_idx on worker.job(stopped_at);
@@ -54,6 +79,11 @@ create index worker_job_stopped_at_idx on worker.job(stopped_at);
 --     index streams in claim order instead of sorting the whole backlog.
 create index worker_job_claim_idx on worker.job("type", priority desc, created_at asc)
   where started_at is null;
+-- Partial index counting the running jobs of a type for the cluster-wide
+-- limits of jobworker.SetClusterMaxConcurrency in the claim query without
+-- scanning the finished jobs of the type.
+create index worker_job_running_type_idx on worker.job("type")
+  where started_at is not null and stopped_at is null;
 -- worker_alive_at is intentionally NOT indexed: the heartbeat rewrites it every
 -- HeartbeatInterval for each in-progress job, and indexing it would defeat
 -- Postgres HOT updates (an indexed column changing forces a new index entry
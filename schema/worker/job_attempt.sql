@@ -0,0 +1,17 @@
+create table worker.job_attempt (
+    job_id      uuid not null references worker.job(id) on delete cascade, -- The job the attempt belongs to
+    attempt     int not null check(attempt > 0),                           -- 1-based number of the attempt of the job
+    retry_count int not null check(retry_count >= 0),                      -- current_retry_count of the job during the attempt
+    outcome     text not null check(outcome in ('succeeded', 'failed', 'retried', 'snoozed', 'reset', 'cancelled', 'interrupted')),
+    worker      text not null,                                             -- Identity of the process that ran the attempt, worker.job.worker
+
+    started_at timestamptz not null,
+    stopped_at timestamptz not null,
+
+    error_msg  text,  -- Error of the attempt, or NULL
+    error_data jsonb, -- Optional error metadata
+
+    primary key (job_id, attempt)
+);
+
+comment on table worker.job_attempt IS 'History of the attempts to work off a `Job`, one row per started and ended attempt.';
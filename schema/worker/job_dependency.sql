@@ -0,0 +1,15 @@
+create table worker.job_dependency (
+    job_id        uuid not null references worker.job(id) on delete cascade, -- The dependent job
+    depends_on_id uuid not null references worker.job(id) on delete cascade, -- The job that has to succeed before job_id is started
+
+    primary key (job_id, depends_on_id),
+    check(job_id <> depends_on_id)
+);
+
+comment on table worker.job_dependency IS 'A `Job` that will only be started after all the jobs it depends on succeeded.';
+
+-- The primary key serves the claim's lookup of a job's dependencies by job_id.
+-- This index serves the reverse lookup of the dependents of a stopped job
+-- (job_dependents_available trigger, cancellation of dependents) and the
+-- ON DELETE CASCADE from a deleted dependency.
+create index worker_job_dependency_depends_on_id_idx on worker.job_dependency(depends_on_id);
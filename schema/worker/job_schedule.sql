@@ -0,0 +1,14 @@
+create table worker.job_schedule (
+    name text primary key check(length(name) > 0 and length(name) <= 100),
+
+    cron_expr text not null check(length(cron_expr) > 0),
+    timezone  text not null default 'UTC', -- IANA time zone name used to interpret cron_expr
+    catch_up  text not null default 'once' check(catch_up in ('skip', 'once', 'all')), -- Which missed occurrences are run
+
+    last_fire_time timestamptz, -- Latest fire time added to worker.job, or NULL if none was added yet
+
+    updated_at timestamptz not null default now(),
+    created_at timestamptz not null default now()
+);
+
+comment on table worker.job_schedule IS 'A recurring `Job` added to worker.job for every occurrence of a cron expression.';
@@ -1,6 +1,11 @@
 CREATE FUNCTION worker.job_available() RETURNS trigger AS
 $$
 BEGIN
+    -- Batch inserts of AddJobs and AddJobBundle
+    -- send a single notification after the last row
+    IF current_setting('worker.job_available_batch', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
     PERFORM pg_notify('job_available',
         json_build_object(
             'id',     NEW.id,
@@ -79,3 +84,76 @@ CREATE TRIGGER job_stopped_trigger
         )
     )
     EXECUTE PROCEDURE worker.job_stopped();
+
+----
+
+CREATE FUNCTION worker.job_cancel_requested() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('job_cancel_requested',
+        json_build_object(
+            'id',     NEW.id,
+            'type',   NEW."type",
+            'origin', NEW.origin
+        )::text
+    );
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+-- Fires when CancelJob requests the cancellation of a running job,
+-- so that the worker process running it can cancel the job's context.
+-- Jobs that were not started yet are stopped directly by CancelJob
+-- and reported by the job_stopped_trigger instead.
+CREATE TRIGGER job_cancel_requested_trigger
+    AFTER UPDATE ON worker.job
+    FOR EACH ROW
+    WHEN (
+        (
+            OLD.cancel_requested_at IS NULL
+        ) AND (
+            NEW.cancel_requested_at IS NOT NULL
+        ) AND (
+            NEW.started_at IS NOT NULL
+        ) AND (
+            NEW.stopped_at IS NULL
+        )
+    )
+    EXECUTE PROCEDURE worker.job_cancel_requested();
+
+----
+
+CREATE FUNCTION worker.job_dependents_available() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('job_available',
+        json_build_object(
+            'id',     j.id,
+            'type',   j."type",
+            'origin', j.origin
+        )::text
+    )
+    FROM worker.job_dependency AS d
+        INNER JOIN worker.job AS j ON j.id = d.job_id
+    WHERE d.depends_on_id = NEW.id
+        AND j.started_at IS NULL
+        AND j.stopped_at IS NULL;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+-- Fires when a job stops so that workers check
+-- for dependent jobs that might have been unblocked by it.
+CREATE TRIGGER job_dependents_available_trigger
+    AFTER UPDATE ON worker.job
+    FOR EACH ROW
+    WHEN (
+        (
+            OLD.stopped_at IS NULL
+        ) AND (
+            NEW.stopped_at IS NOT NULL
+        )
+    )
+    EXECUTE PROCEDURE worker.job_dependents_available();
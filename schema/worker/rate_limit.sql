@@ -0,0 +1,10 @@
+create table worker.rate_limit (
+    job_type text primary key check(length(job_type) > 0 and length(job_type) <= 100),
+
+    window_start timestamptz not null, -- Start of the current rate limit window of the job type
+    num_started  int not null default 0 check(num_started >= 0), -- Jobs of the type started in the current window
+
+    updated_at timestamptz not null default now()
+);
+
+comment on table worker.rate_limit IS 'The current window of a job type rate limited with jobworker.SetRateLimit.';
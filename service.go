@@ -2,8 +2,10 @@ package jobqueue
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/domonda/go-sqldb/db"
 	"github.com/domonda/go-types/uu"
 )
 
@@ -59,22 +61,46 @@ type Service interface {
 	AddListener(context.Context, ServiceListener) error
 
 	// AddJob adds a new job to the queue.
+	// Implementations backed by a database join the transaction
+	// of the context, see AddInTx.
 	AddJob(ctx context.Context, job *Job) error
 
+	// AddJobs adds multiple jobs to the queue, all or none of them.
+	// Unlike calling AddJob for every job, implementations backed by a database
+	// insert the jobs with as few statements as possible
+	// and notify the workers only once about all of them.
+	// Jobs can depend on other jobs of the same call.
+	AddJobs(ctx context.Context, jobs []*Job) error
+
 	// GetJob retrieves a job by its ID.
 	GetJob(ctx context.Context, jobID uu.ID) (*Job, error)
 
+	// GetJobAttempts returns the recorded attempts of a job ordered by JobAttempt.Attempt.
+	// A job that was never started or doesn't exist has no attempts.
+	GetJobAttempts(ctx context.Context, jobID uu.ID) ([]*JobAttempt, error)
+
 	// DeleteJob deletes a job from the queue.
+	// If the job did not succeed, the not started jobs depending on it
+	// are cancelled unless they use DependencyFailureRun.
 	DeleteJob(ctx context.Context, jobID uu.ID) error
 
 	// ResetJob resets the processing state of a job in the queue
 	// so that the job is ready to be re-processed.
+	// Returns a DuplicateJobError if an unfinished job
+	// with the same type and UniqueKey exists.
 	ResetJob(ctx context.Context, jobID uu.ID) error
 
 	// ResetJobs resets the processing state of multiple jobs in the queue
 	// so that they are ready to be re-processed.
 	ResetJobs(ctx context.Context, jobIDs uu.IDs) error
 
+	// CancelJob requests the cancellation of a job that has not stopped yet.
+	// A job that was not started yet is stopped as cancelled immediately.
+	// The context of a running job is cancelled by the worker process
+	// running it, which then stops the job as cancelled.
+	// Jobs that already stopped or don't exist are not changed.
+	CancelJob(ctx context.Context, jobID uu.ID) error
+
 	// AddJobBundle adds a new job bundle with all its jobs to the queue.
 	AddJobBundle(ctx context.Context, jobBundle *JobBundle) error
 
@@ -82,9 +108,11 @@ type Service interface {
 	GetJobBundle(ctx context.Context, jobBundleID uu.ID) (*JobBundle, error)
 
 	// DeleteJobBundle deletes a job bundle and all its jobs from the queue.
+	// Dependent jobs are cancelled like for DeleteJob.
 	DeleteJobBundle(ctx context.Context, jobBundleID uu.ID) error
 
-	// GetStatus returns the current queue status with job and bundle counts.
+	// GetStatus returns the current queue status with job and bundle counts
+	// and the JobStats of all jobs, per job type, and per origin.
 	GetStatus(context.Context) (*Status, error)
 
 	// GetAllJobsToDo returns all jobs that are ready to be processed.
@@ -96,7 +124,15 @@ type Service interface {
 	// GetAllJobsWithErrors returns all jobs that have errors.
 	GetAllJobsWithErrors(context.Context) ([]*Job, error)
 
+	// GetJobs returns the jobs selected by filter ordered by CreatedAt,
+	// see JobFilter.NewestFirst.
+	// A nil filter selects all jobs.
+	// An invalid filter returns the error of JobFilter.Validate.
+	GetJobs(ctx context.Context, filter *JobFilter) ([]*Job, error)
+
 	// DeleteFinishedJobs deletes all successfully completed jobs without errors.
+	// Cancelled jobs with dependent jobs are not deleted
+	// so that they keep blocking their dependents.
 	DeleteFinishedJobs(ctx context.Context) error
 
 	// Close closes the service and releases any resources.
@@ -104,15 +140,47 @@ type Service interface {
 }
 
 // Add adds a job to the queue using the service from the context or the default service.
+// A job without TraceContext gets the one of TraceContextFromContext(ctx).
 func Add(ctx context.Context, job *Job) error {
+	setTraceContextIfZero(ctx, job)
 	return GetService(ctx).AddJob(ctx, job)
 }
 
+// AddJobs adds multiple jobs to the queue using the service from the context or the default service.
+// See Service.AddJobs for details.
+// Jobs without TraceContext get the one of TraceContextFromContext(ctx).
+func AddJobs(ctx context.Context, jobs []*Job) error {
+	setTraceContextIfZero(ctx, jobs...)
+	return GetService(ctx).AddJobs(ctx, jobs)
+}
+
+// AddInTx adds a job to the queue like Add, but returns an error
+// wrapping sqldb.ErrNotWithinTransaction if the database connection
+// of the context is not a transaction, see db.Transaction.
+//
+// Use it for a transactional outbox: the job is inserted
+// within the transaction of the caller that writes the domain rows,
+// so it is only added if the transaction commits
+// and workers are only notified about it after the commit.
+func AddInTx(ctx context.Context, job *Job) error {
+	err := db.ValidateWithinTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("jobqueue.AddInTx: %w", err)
+	}
+	return Add(ctx, job)
+}
+
 // GetJob retrieves a job by its ID using the service from the context or the default service.
 func GetJob(ctx context.Context, jobID uu.ID) (*Job, error) {
 	return GetService(ctx).GetJob(ctx, jobID)
 }
 
+// GetJobAttempts returns the recorded attempts of a job using the service from the context or the default service.
+// See Service.GetJobAttempts for details.
+func GetJobAttempts(ctx context.Context, jobID uu.ID) ([]*JobAttempt, error) {
+	return GetService(ctx).GetJobAttempts(ctx, jobID)
+}
+
 // DeleteFinishedJobs deletes all successfully completed jobs using the service from the context or the default service.
 func DeleteFinishedJobs(ctx context.Context) error {
 	return GetService(ctx).DeleteFinishedJobs(ctx)
@@ -130,6 +198,13 @@ func ResetJobs(ctx context.Context, jobIDs uu.IDSlice) error {
 	return GetService(ctx).ResetJobs(ctx, jobIDs)
 }
 
+// CancelJob requests the cancellation of a job that has not stopped yet
+// using the service from the context or the default service.
+// See Service.CancelJob for details.
+func CancelJob(ctx context.Context, jobID uu.ID) error {
+	return GetService(ctx).CancelJob(ctx, jobID)
+}
+
 // DeleteJob deletes a job from the queue.
 func DeleteJob(ctx context.Context, jobID uu.ID) error {
 	return GetService(ctx).DeleteJob(ctx, jobID)
@@ -150,13 +225,23 @@ func GetAllJobsWithErrors(ctx context.Context) (jobs []*Job, err error) {
 	return GetService(ctx).GetAllJobsWithErrors(ctx)
 }
 
+// GetJobs returns the jobs selected by filter using the service from the context or the default service.
+// See Service.GetJobs for details.
+func GetJobs(ctx context.Context, filter *JobFilter) (jobs []*Job, err error) {
+	return GetService(ctx).GetJobs(ctx, filter)
+}
+
 // GetStatus returns the current queue status using the service from the context or the default service.
 func GetStatus(ctx context.Context) (status *Status, err error) {
 	return GetService(ctx).GetStatus(ctx)
 }
 
 // AddBundle adds a job bundle to the queue using the service from the context or the default service.
+// Jobs of the bundle without TraceContext get the one of TraceContextFromContext(ctx).
 func AddBundle(ctx context.Context, jobBundle *JobBundle) (err error) {
+	if jobBundle != nil {
+		setTraceContextIfZero(ctx, jobBundle.Jobs...)
+	}
 	return GetService(ctx).AddJobBundle(ctx, jobBundle)
 }
 
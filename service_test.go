@@ -0,0 +1,46 @@
+package jobqueue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/domonda/go-sqldb"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// addJobService records the jobs passed to AddJob
+// and returns an error from all other methods.
+type addJobService struct {
+	jobqueue.Service
+	added []*jobqueue.Job
+}
+
+func (s *addJobService) AddJob(ctx context.Context, job *jobqueue.Job) error {
+	s.added = append(s.added, job)
+	return nil
+}
+
+func TestAddInTx(t *testing.T) {
+	service := &addJobService{Service: jobqueue.ServiceWithError(jobqueue.ErrNotInitialized)}
+	ctx := jobqueue.ContextWithService(t.Context(), service)
+	ctx = db.ContextWithConn(ctx, db.NewMockConn(ctx))
+
+	job, err := jobqueue.NewJob(uu.NewID(ctx), "add-in-tx-test", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+
+	err = jobqueue.AddInTx(ctx, job)
+	require.ErrorIs(t, err, sqldb.ErrNotWithinTransaction)
+	assert.Empty(t, service.added, "job not added without transaction")
+
+	err = db.Transaction(ctx, func(ctx context.Context) error {
+		return jobqueue.AddInTx(ctx, job)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*jobqueue.Job{job}, service.added)
+}
@@ -2,6 +2,8 @@ package jobqueue
 
 import (
 	"fmt"
+	"slices"
+	"time"
 )
 
 // Status represents the current state of the job queue.
@@ -11,13 +13,20 @@ type Status struct {
 	// NumJobBundles is the total number of job bundles in the queue.
 	NumJobBundles int
 	// NumWorkerThreads int
+
+	// Jobs are the JobStats of all jobs in the queue.
+	Jobs JobStats
+	// ByType are the JobStats of the jobs per job type.
+	ByType map[string]JobStats
+	// ByOrigin are the JobStats of the jobs per origin.
+	ByOrigin map[string]JobStats
 }
 
 // IsZero returns true if the receiver is nil
 // or dereferenced equal to its zero value.
 // Valid to call on a nil receiver.
 func (s *Status) IsZero() bool {
-	return s == nil || *s == Status{}
+	return s == nil || (s.NumJobs == 0 && s.NumJobBundles == 0 && s.Jobs.IsZero() && len(s.ByType) == 0 && len(s.ByOrigin) == 0)
 }
 
 // String implements the fmt.Stringer interface.
@@ -29,3 +38,109 @@ func (s *Status) String() string {
 	// return fmt.Sprintf("Status{NumJobs: %d, NumJobBundles: %d, NumWorkerThreads: %d}", s.NumJobs, s.NumJobBundles, s.NumWorkerThreads)
 	return fmt.Sprintf("Status{NumJobs: %d, NumJobBundles: %d}", s.NumJobs, s.NumJobBundles)
 }
+
+// JobStats are the number of jobs in every lifecycle state,
+// the age of the oldest pending job, and the age of the oldest
+// heartbeat of a running job of a set of jobs.
+//
+// Every job is counted in exactly one state, so the counts add up
+// to the number of jobs. The states are evaluated
+// with the clock of the database at the time of the query.
+type JobStats struct {
+	// Pending jobs are not started yet and can start now,
+	// unless they wait for a dependency.
+	Pending int
+	// Delayed jobs are not started yet and have a start_at in the future.
+	Delayed int
+	// Running jobs are started and not stopped.
+	Running int
+	// Retrying jobs wait for a retry after a failed attempt.
+	Retrying int
+	// Failed jobs stopped with an error and will not be retried.
+	Failed int
+	// Cancelled jobs were stopped by CancelJob.
+	Cancelled int
+	// Succeeded jobs stopped without an error and were not deleted yet.
+	Succeeded int
+
+	// OldestPendingAge is the time since the oldest job that can be
+	// started now could have been started, zero if there is no such job.
+	// Besides the pending jobs it includes the retrying jobs
+	// whose retry time has passed, which wait to be claimed the same way.
+	OldestPendingAge time.Duration
+	// OldestHeartbeatAge is the time since the oldest worker_alive_at
+	// heartbeat of a running job, zero if no running job has a heartbeat.
+	// A value above the heartbeat interval of the workers
+	// indicates a crashed or blocked worker process.
+	OldestHeartbeatAge time.Duration
+}
+
+// NumJobs returns the number of jobs in all states.
+func (s JobStats) NumJobs() int {
+	return s.Pending + s.Delayed + s.Running + s.Retrying + s.Failed + s.Cancelled + s.Succeeded
+}
+
+// IsZero returns true if the receiver is equal to its zero value.
+func (s JobStats) IsZero() bool {
+	return s == JobStats{}
+}
+
+// String implements the fmt.Stringer interface.
+func (s JobStats) String() string {
+	return fmt.Sprintf(
+		"JobStats{Pending: %d, Delayed: %d, Running: %d, Retrying: %d, Failed: %d, Cancelled: %d, Succeeded: %d, OldestPendingAge: %s, OldestHeartbeatAge: %s}",
+		s.Pending, s.Delayed, s.Running, s.Retrying, s.Failed, s.Cancelled, s.Succeeded, s.OldestPendingAge, s.OldestHeartbeatAge,
+	)
+}
+
+// JobState is a lifecycle state of a job
+// as counted by JobStats, see there for the definitions.
+type JobState string
+
+// The JobStates of the fields of JobStats.
+const (
+	JobStatePending   JobState = "pending"
+	JobStateDelayed   JobState = "delayed"
+	JobStateRunning   JobState = "running"
+	JobStateRetrying  JobState = "retrying"
+	JobStateFailed    JobState = "failed"
+	JobStateCancelled JobState = "cancelled"
+	JobStateSucceeded JobState = "succeeded"
+)
+
+// JobStates are all valid JobStates in lifecycle order.
+var JobStates = []JobState{
+	JobStatePending,
+	JobStateDelayed,
+	JobStateRunning,
+	JobStateRetrying,
+	JobStateFailed,
+	JobStateCancelled,
+	JobStateSucceeded,
+}
+
+// Valid returns true if s is one of JobStates.
+func (s JobState) Valid() bool {
+	return slices.Contains(JobStates, s)
+}
+
+// Count returns the number of jobs in state or zero for an invalid state.
+func (s JobStats) Count(state JobState) int {
+	switch state {
+	case JobStatePending:
+		return s.Pending
+	case JobStateDelayed:
+		return s.Delayed
+	case JobStateRunning:
+		return s.Running
+	case JobStateRetrying:
+		return s.Retrying
+	case JobStateFailed:
+		return s.Failed
+	case JobStateCancelled:
+		return s.Cancelled
+	case JobStateSucceeded:
+		return s.Succeeded
+	}
+	return 0
+}
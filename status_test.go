@@ -24,3 +24,28 @@ func TestStatusString(t *testing.T) {
 		(&jobqueue.Status{NumJobs: 2, NumJobBundles: 3}).String(),
 	)
 }
+
+func TestStatusIsZeroWithStats(t *testing.T) {
+	assert.False(t, (&jobqueue.Status{Jobs: jobqueue.JobStats{Pending: 1}}).IsZero())
+	assert.False(t, (&jobqueue.Status{ByType: map[string]jobqueue.JobStats{"a": {}}}).IsZero())
+	assert.True(t, jobqueue.JobStats{}.IsZero())
+	assert.False(t, jobqueue.JobStats{OldestPendingAge: 1}.IsZero())
+}
+
+func TestJobStatsNumJobs(t *testing.T) {
+	stats := jobqueue.JobStats{Pending: 1, Delayed: 2, Running: 3, Retrying: 4, Failed: 5, Cancelled: 6, Succeeded: 7}
+	assert.Equal(t, 28, stats.NumJobs())
+}
+
+func TestJobStatsCount(t *testing.T) {
+	stats := jobqueue.JobStats{Pending: 1, Delayed: 2, Running: 3, Retrying: 4, Failed: 5, Cancelled: 6, Succeeded: 7}
+	sum := 0
+	for _, state := range jobqueue.JobStates {
+		assert.True(t, state.Valid(), state)
+		sum += stats.Count(state)
+	}
+	assert.Equal(t, stats.NumJobs(), sum, "JobStates cover all counts")
+	assert.Equal(t, 5, stats.Count(jobqueue.JobStateFailed))
+	assert.False(t, jobqueue.JobState("invalid").Valid())
+	assert.Zero(t, stats.Count("invalid"))
+}
@@ -36,21 +36,31 @@ func TestServiceMethodsAfterClose(t *testing.T) {
 		fn   func() error
 	}{
 		{"AddJob", func() error { return dbAPI.AddJob(t.Context(), job) }},
+		{"AddJobs", func() error { return dbAPI.AddJobs(t.Context(), []*jobqueue.Job{job}) }},
 		{"AddJobBundle", func() error { return dbAPI.AddJobBundle(t.Context(), bundle) }},
 		{"GetStatus", func() error { _, e := dbAPI.GetStatus(t.Context()); return e }},
 		{"GetAllJobsToDo", func() error { _, e := dbAPI.GetAllJobsToDo(t.Context()); return e }},
 		{"GetAllJobsStartedBefore", func() error { _, e := dbAPI.GetAllJobsStartedBefore(t.Context(), time.Now()); return e }},
 		{"GetAllJobsWithErrors", func() error { _, e := dbAPI.GetAllJobsWithErrors(t.Context()); return e }},
 		{"GetJob", func() error { _, e := dbAPI.GetJob(t.Context(), id); return e }},
+		{"GetJobAttempts", func() error { _, e := dbAPI.GetJobAttempts(t.Context(), id); return e }},
+		{"GetJobs", func() error { _, e := dbAPI.GetJobs(t.Context(), nil); return e }},
 		{"GetJobBundle", func() error { _, e := dbAPI.GetJobBundle(t.Context(), id); return e }},
-		{"StartNextJobOrNil", func() error { _, e := dbAPI.StartNextJobOrNil(t.Context()); return e }},
+		{"StartNextJobOrNil", func() error { _, e := dbAPI.StartNextJobOrNil(t.Context(), jobworker.DefaultPool().Claim()); return e }},
+		{"StartNextJobsOrNil", func() error {
+			_, e := dbAPI.StartNextJobsOrNil(t.Context(), jobworker.DefaultPool().Claim(), 2)
+			return e
+		}},
+		{"UnclaimJobs", func() error { return dbAPI.UnclaimJobs(t.Context(), uu.IDs{id}) }},
+		{"SetJobCancelled", func() error { return dbAPI.SetJobCancelled(t.Context(), id) }},
 		{"SetJobError", func() error { return dbAPI.SetJobError(t.Context(), id, "boom", nullable.JSON{}) }},
 		{"SetJobResult", func() error { return dbAPI.SetJobResult(t.Context(), id, nullable.JSON{}) }},
 		{"SetJobStart", func() error { return dbAPI.SetJobStart(t.Context(), id, time.Now()) }},
 		{"SetJobWorkerAlive", func() error { return dbAPI.SetJobWorkerAlive(t.Context(), id) }},
-		{"ScheduleRetry", func() error { return dbAPI.ScheduleRetry(t.Context(), id, time.Now(), 1) }},
+		{"ScheduleRetry", func() error { return dbAPI.ScheduleRetry(t.Context(), id, time.Now(), 1, "", nil) }},
 		{"ResetJob", func() error { return dbAPI.ResetJob(t.Context(), id) }},
 		{"ResetJobs", func() error { return dbAPI.ResetJobs(t.Context(), uu.IDSlice{id}) }},
+		{"CancelJob", func() error { return dbAPI.CancelJob(t.Context(), id) }},
 		{"DeleteJob", func() error { return dbAPI.DeleteJob(t.Context(), id) }},
 		{"DeleteFinishedJobs", func() error { return dbAPI.DeleteFinishedJobs(t.Context()) }},
 		{"DeleteJobsFromOrigin", func() error { return dbAPI.DeleteJobsFromOrigin(t.Context(), "test-closed") }},
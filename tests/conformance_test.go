@@ -0,0 +1,21 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/domonda/go-jobqueue"
+	"github.com/domonda/go-jobqueue/dbtest"
+	"github.com/domonda/go-jobqueue/jobworker"
+)
+
+// TestConformance runs the dbtest conformance suite against jobworkerdb,
+// the reference implementation every other DataBase is compared with.
+func TestConformance(t *testing.T) {
+	dbtest.RunConformance(t, func(t *testing.T) jobworker.DataBase {
+		// Close any previous service to unlisten channels before re-initializing.
+		_ = jobqueue.Close()
+		setupDBConn(t)
+		t.Cleanup(func() { _ = jobqueue.Close() })
+		return dataBaseAPI(t)
+	})
+}
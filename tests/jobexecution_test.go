@@ -29,165 +29,6 @@ import (
 func TestSimulateJobs(t *testing.T) {
 	setupDBConn(t)
 
-	t.Run("Job result is being stored on success", func(t *testing.T) {
-		// given
-		jobResult := "OK"
-		var worker jobworker.WorkerFunc = func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
-			return jobResult, nil
-		}
-
-		jobType := "8005f973-fa09-4159-8fcc-ad166a006c40"
-		jobID := uu.IDFrom("ac86612f-c93b-4589-843f-ab16065b668b")
-		retryCount := 0
-		var scheduleRetry jobworker.ScheduleRetryFunc
-		job, waiter := NewRegisteredJobWithWaiter(
-			t,
-			worker,
-			jobType,
-			jobID,
-			retryCount,
-			scheduleRetry,
-		)
-
-		// when
-		err := waiter.Wait()
-		require.NoError(t, err)
-
-		// then
-		job, err = jobqueue.GetJob(t.Context(), job.ID)
-		require.NoError(t, err)
-		assert.False(t, job.HasError())
-		assert.Equal(t, nullable.JSON(`"`+jobResult+`"`), job.Result)
-	})
-
-	t.Run("Job finishes before max retry count", func(t *testing.T) {
-		// given
-		var worker jobworker.WorkerFunc = func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
-			return nil, nil
-		}
-
-		jobType := "c7cd81f1-61de-43c4-84f4-8c1a368e6f32"
-		jobID := uu.IDFrom("125703f6-396d-447c-9286-facd91de44a7")
-		retryCount := 1
-		var scheduleRetry jobworker.ScheduleRetryFunc
-		_, waiter := NewRegisteredJobWithWaiter(
-			t,
-			worker,
-			jobType,
-			jobID,
-			retryCount,
-			scheduleRetry,
-		)
-
-		// when
-		err := waiter.Wait()
-
-		// then
-		require.NoError(t, err)
-	})
-
-	t.Run("Job error is being stored on failure", func(t *testing.T) {
-		// given
-		jobErr := errors.New("NUCLEAR_MELTDOWN")
-		var worker jobworker.WorkerFunc = func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
-			return nil, jobErr
-		}
-
-		jobType := "45f0684d-2993-4065-8dd2-f7abf1763ef0"
-		jobID := uu.IDFrom("4ac2792b-a12c-42b5-9a75-60ff316da013")
-		retryCount := 0
-		var scheduleRetry jobworker.ScheduleRetryFunc
-		job, waiter := NewRegisteredJobWithWaiter(
-			t,
-			worker,
-			jobType,
-			jobID,
-			retryCount,
-			scheduleRetry,
-		)
-
-		// when
-		err := waiter.Wait()
-		require.NoError(t, err)
-
-		// then
-		job, err = jobqueue.GetJob(t.Context(), job.ID)
-		require.NoError(t, err)
-		assert.True(t, job.HasError())
-		assert.Equal(t, nullable.NonEmptyString(jobErr.Error()), job.ErrorMsg)
-	})
-
-	t.Run("Job will be retried on failure when retry is configured", func(t *testing.T) {
-		// given
-		jobErr := errors.New("NUCLEAR_MELTDOWN")
-		maxRetryCount := 3
-
-		var worker jobworker.WorkerFunc = func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
-			if job.CurrentRetryCount >= maxRetryCount {
-				return nil, nil
-			}
-			return nil, jobErr
-		}
-
-		var scheduleRetry jobworker.ScheduleRetryFunc = func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
-			return time.Now(), nil
-		}
-
-		jobType := "7476c878-eec4-4d0c-9ff5-fea0f3dcaf1c"
-		jobID := uu.IDFrom("54b3c462-6d9b-45cb-8096-2d2f262881e1")
-		job, waiter := NewRegisteredJobWithWaiter(
-			t,
-			worker,
-			jobType,
-			jobID,
-			maxRetryCount,
-			scheduleRetry,
-		)
-
-		// when
-		err := waiter.Wait()
-		require.NoError(t, err)
-
-		// then
-		job, err = jobqueue.GetJob(t.Context(), job.ID)
-		require.NoError(t, err)
-		assert.False(t, job.HasError())
-	})
-
-	t.Run("Job fails if all attempts fail", func(t *testing.T) {
-		// given
-		jobErr := errors.New("NUCLEAR_MELTDOWN")
-		maxRetryCount := 3
-
-		var worker jobworker.WorkerFunc = func(ctx context.Context, job *jobqueue.Job) (result any, err error) {
-			return nil, jobErr
-		}
-
-		var scheduleRetry jobworker.ScheduleRetryFunc = func(ctx context.Context, job *jobqueue.Job) (time.Time, error) {
-			return time.Now(), nil
-		}
-
-		jobType := "3af846f5-bacb-4922-b5e5-8156005bcef2"
-		jobID := uu.IDFrom("8f4ee5f3-6aa6-4a4a-88b5-5c874a901428")
-		job, waiter := NewRegisteredJobWithWaiter(
-			t,
-			worker,
-			jobType,
-			jobID,
-			maxRetryCount,
-			scheduleRetry,
-		)
-
-		// when
-		err := waiter.Wait()
-		require.NoError(t, err)
-
-		// then
-		job, err = jobqueue.GetJob(t.Context(), job.ID)
-		require.NoError(t, err)
-		assert.Equal(t, nullable.NonEmptyString(jobErr.Error()), job.ErrorMsg)
-	})
-
 	t.Run("Logs warning if error happens not in last retry", func(t *testing.T) {
 		// given
 		jobErr := errors.New("NUCLEAR_MELTDOWN")
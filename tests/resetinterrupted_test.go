@@ -117,6 +117,18 @@ func TestResetInterruptedRetryableJobs(t *testing.T) {
 		assertReset(t, erroredStuck)
 	})
 
+	t.Run("interrupted attempt of a crashed job is recorded", func(t *testing.T) {
+		attempts, err := jobqueue.GetJobAttempts(t.Context(), crashedInProgress)
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		assert.Equal(t, jobqueue.JobAttemptInterrupted, attempts[0].Outcome)
+		assert.Equal(t, 0, attempts[0].RetryCount)
+
+		attempts, err = jobqueue.GetJobAttempts(t.Context(), erroredStuck)
+		require.NoError(t, err)
+		assert.Empty(t, attempts, "no attempt recorded for an already stopped job")
+	})
+
 	t.Run("live in-progress job with fresh heartbeat is kept", func(t *testing.T) {
 		j := get(t, liveInProgress)
 		assert.True(t, j.StartedAndNotStopped(), "a job with a live worker must not be rug-pulled")
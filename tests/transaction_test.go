@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domonda/go-errs"
+	"github.com/domonda/go-sqldb/db"
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+// TestAddJobInTransaction verifies that AddJob and AddJobBundle join the
+// transaction of the context, so a job is only added if the transaction
+// of the caller commits, and that job_available is only notified on commit.
+func TestAddJobInTransaction(t *testing.T) {
+	_ = jobqueue.Close()
+	setupDBConn(t)
+	t.Cleanup(func() { _ = jobqueue.Close() })
+
+	const (
+		jobType = "test-add-in-tx"
+		origin  = "test-add-in-tx"
+	)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = dataBaseAPI(t).DeleteJobBundlesFromOrigin(ctx, origin)
+		_ = dataBaseAPI(t).DeleteJobsFromOrigin(ctx, origin)
+	})
+
+	jobAvailable := make(chan struct{}, 10)
+	require.NoError(t, dataBaseAPI(t).SetJobAvailableListener(t.Context(), func() {
+		jobAvailable <- struct{}{}
+	}))
+	t.Cleanup(func() { _ = dataBaseAPI(t).SetJobAvailableListener(context.Background(), nil) })
+
+	newJob := func(t *testing.T) *jobqueue.Job {
+		t.Helper()
+		job, err := jobqueue.NewJob(uu.NewID(t.Context()), jobType, origin, `{}`, nullable.Time{})
+		require.NoError(t, err)
+		return job
+	}
+	requireNotFound := func(t *testing.T, jobID uu.ID) {
+		t.Helper()
+		_, err := jobqueue.GetJob(t.Context(), jobID)
+		require.True(t, errs.IsErrNotFound(err), "job %s must not exist, got error: %v", jobID, err)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		job := newJob(t)
+		err := db.Transaction(t.Context(), func(ctx context.Context) error {
+			err := jobqueue.AddInTx(ctx, job)
+			if err != nil {
+				return err
+			}
+			// Visible within the transaction, but not outside of it
+			_, err = jobqueue.GetJob(ctx, job.ID)
+			require.NoError(t, err)
+			requireNotFound(t, job.ID)
+
+			select {
+			case <-jobAvailable:
+				t.Error("job_available notified before commit")
+			case <-time.After(200 * time.Millisecond):
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		_, err = jobqueue.GetJob(t.Context(), job.ID)
+		require.NoError(t, err)
+		select {
+		case <-jobAvailable:
+		case <-time.After(5 * time.Second):
+			t.Error("job_available not notified after commit")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		job := newJob(t)
+		errRollback := errors.New("rollback")
+		err := db.Transaction(t.Context(), func(ctx context.Context) error {
+			require.NoError(t, jobqueue.AddInTx(ctx, job))
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		requireNotFound(t, job.ID)
+	})
+
+	t.Run("bundle rollback", func(t *testing.T) {
+		bundle, err := jobqueue.NewJobBundle(t.Context(), "test-add-in-tx-bundle", origin,
+			[]jobqueue.JobDesc{{Type: jobType, Payload: `{}`, Origin: origin}}, nullable.Time{})
+		require.NoError(t, err)
+		errRollback := errors.New("rollback")
+		err = db.Transaction(t.Context(), func(ctx context.Context) error {
+			require.NoError(t, jobqueue.AddBundle(ctx, bundle))
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		_, err = jobqueue.GetJobBundle(t.Context(), bundle.ID)
+		assert.True(t, errs.IsErrNotFound(err), "bundle must not exist")
+		requireNotFound(t, bundle.Jobs[0].ID)
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		job := newJob(t)
+		err := jobqueue.AddInTx(t.Context(), job)
+		require.Error(t, err)
+		requireNotFound(t, job.ID)
+	})
+}
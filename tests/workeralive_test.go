@@ -155,7 +155,7 @@ func TestWorkerAliveClearedOnTransitions(t *testing.T) {
 	t.Run("ScheduleRetry clears worker_alive_at", func(t *testing.T) {
 		id := uu.IDFrom("f47ac10b-58cc-4372-a567-d00000000012")
 		insertProcessingJob(t, id, 3, 0)
-		require.NoError(t, dbAPI.ScheduleRetry(t.Context(), id, time.Now().Add(time.Hour), 1))
+		require.NoError(t, dbAPI.ScheduleRetry(t.Context(), id, time.Now().Add(time.Hour), 1, "error", nil))
 		assertCleared(t, id)
 	})
 
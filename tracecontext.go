@@ -0,0 +1,158 @@
+package jobqueue
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// TraceContext is the W3C Trace Context of the span that added a job,
+// see https://www.w3.org/TR/trace-context/
+//
+// Add, AddJobs, and AddBundle set the TraceContext of jobs
+// that don't have one to TraceContextFromContext of their context,
+// and the worker threads pass it as link to the jobworker.Tracer
+// of the span covering the execution of the job,
+// so a trace doesn't end when a job is added.
+//
+// It is stored as JSON object in the worker.job.trace_context column.
+// A zero TraceContext is stored as NULL.
+type TraceContext struct {
+	// TraceParent is the value of the traceparent header,
+	// for example "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	TraceParent string `json:"traceparent"`
+	// TraceState is the optional value of the tracestate header
+	// with vendor specific trace information.
+	TraceState string `json:"tracestate,omitempty"`
+}
+
+// ExtractTraceContext returns the TraceContext of the current span of ctx
+// from a tracing library or a zero TraceContext if there is none.
+// It is used by TraceContextFromContext if not nil.
+//
+// Example for OpenTelemetry:
+//
+//	jobqueue.ExtractTraceContext = func(ctx context.Context) jobqueue.TraceContext {
+//		carrier := propagation.MapCarrier{}
+//		propagation.TraceContext{}.Inject(ctx, carrier)
+//		return jobqueue.TraceContext{TraceParent: carrier["traceparent"], TraceState: carrier["tracestate"]}
+//	}
+var ExtractTraceContext func(ctx context.Context) TraceContext
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a child of ctx with tc,
+// for example from the traceparent and tracestate headers
+// of an incoming request, that is returned by TraceContextFromContext.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the TraceContext for the jobs added with ctx:
+// the one returned by ExtractTraceContext if it is not nil and returns a valid one,
+// else the one added to ctx with ContextWithTraceContext if it is valid,
+// else a zero TraceContext.
+func TraceContextFromContext(ctx context.Context) TraceContext {
+	if ExtractTraceContext != nil {
+		if tc := ExtractTraceContext(ctx); tc.Validate() == nil {
+			return tc
+		}
+	}
+	if tc, ok := ctx.Value(traceContextKey{}).(TraceContext); ok && tc.Validate() == nil {
+		return tc
+	}
+	return TraceContext{}
+}
+
+// setTraceContextIfZero sets the TraceContext of the jobs
+// that don't have one to TraceContextFromContext(ctx).
+func setTraceContextIfZero(ctx context.Context, jobs ...*Job) {
+	tc := TraceContextFromContext(ctx)
+	if tc.IsZero() {
+		return
+	}
+	for _, job := range jobs {
+		if job != nil && job.TraceContext.IsZero() {
+			job.TraceContext = tc
+		}
+	}
+}
+
+// IsZero returns true if the TraceContext has no TraceParent.
+func (tc TraceContext) IsZero() bool {
+	return tc.TraceParent == ""
+}
+
+// Validate returns an error if the TraceParent is not
+// in the W3C traceparent format "version-traceid-parentid-flags"
+// with lowercase hex fields of 2, 32, 16, and 2 characters,
+// or if the version is the invalid "ff" or the trace or parent ID is all zeros.
+// Versions above "00" can have more fields after the flags.
+func (tc TraceContext) Validate() error {
+	const length = len("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s := tc.TraceParent
+	if len(s) < length || (len(s) > length && (s[:2] == "00" || s[length] != '-')) {
+		return fmt.Errorf("invalid traceparent %q", s)
+	}
+	version, traceID, parentID, flags := s[0:2], s[3:35], s[36:52], s[53:55]
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' ||
+		!isLowerHex(version) || !isLowerHex(traceID) || !isLowerHex(parentID) || !isLowerHex(flags) ||
+		version == "ff" || isAllZeros(traceID) || isAllZeros(parentID) {
+		return fmt.Errorf("invalid traceparent %q", s)
+	}
+	return nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range []byte(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllZeros(s string) bool {
+	for _, c := range []byte(s) {
+		if c != '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// Value implements the database/sql/driver.Valuer interface
+// by returning the TraceContext as JSON or nil if it is zero.
+func (tc TraceContext) Value() (driver.Value, error) {
+	if tc.IsZero() {
+		return nil, nil
+	}
+	return json.Marshal(tc)
+}
+
+// Scan implements the database/sql.Scanner interface
+// by unmarshalling JSON, NULL scans as a zero TraceContext.
+func (tc *TraceContext) Scan(value any) error {
+	switch x := value.(type) {
+	case nil:
+		*tc = TraceContext{}
+		return nil
+	case []byte:
+		return tc.unmarshal(x)
+	case string:
+		return tc.unmarshal([]byte(x))
+	default:
+		return fmt.Errorf("can't scan %T as jobqueue.TraceContext", value)
+	}
+}
+
+func (tc *TraceContext) unmarshal(data []byte) error {
+	var scanned TraceContext
+	err := json.Unmarshal(data, &scanned)
+	if err != nil {
+		return fmt.Errorf("can't scan %q as jobqueue.TraceContext: %w", data, err)
+	}
+	*tc = scanned
+	return nil
+}
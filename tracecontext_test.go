@@ -0,0 +1,99 @@
+package jobqueue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/domonda/go-types/nullable"
+	"github.com/domonda/go-types/uu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/domonda/go-jobqueue"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceContextValidate(t *testing.T) {
+	for _, valid := range []string{
+		testTraceParent,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future",
+	} {
+		assert.NoError(t, jobqueue.TraceContext{TraceParent: valid}.Validate(), valid)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+	} {
+		assert.Error(t, jobqueue.TraceContext{TraceParent: invalid}.Validate(), invalid)
+	}
+}
+
+func TestTraceContextValueScan(t *testing.T) {
+	tc := jobqueue.TraceContext{TraceParent: testTraceParent, TraceState: "vendor=value"}
+	value, err := tc.Value()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"traceparent":"`+testTraceParent+`","tracestate":"vendor=value"}`, string(value.([]byte)))
+
+	var scanned jobqueue.TraceContext
+	require.NoError(t, scanned.Scan(value))
+	assert.Equal(t, tc, scanned)
+
+	value, err = jobqueue.TraceContext{}.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value, "zero TraceContext is NULL")
+	require.NoError(t, scanned.Scan(nil))
+	assert.True(t, scanned.IsZero(), "NULL scans as zero")
+
+	assert.Error(t, scanned.Scan(42))
+	assert.Error(t, scanned.Scan(`not JSON`))
+}
+
+func TestTraceContextFromContext(t *testing.T) {
+	t.Cleanup(func() { jobqueue.ExtractTraceContext = nil })
+
+	tc := jobqueue.TraceContext{TraceParent: testTraceParent}
+	assert.True(t, jobqueue.TraceContextFromContext(t.Context()).IsZero())
+	ctx := jobqueue.ContextWithTraceContext(t.Context(), tc)
+	assert.Equal(t, tc, jobqueue.TraceContextFromContext(ctx))
+	invalidCtx := jobqueue.ContextWithTraceContext(t.Context(), jobqueue.TraceContext{TraceParent: "invalid"})
+	assert.True(t, jobqueue.TraceContextFromContext(invalidCtx).IsZero(), "invalid TraceContext is ignored")
+
+	extracted := jobqueue.TraceContext{TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+	jobqueue.ExtractTraceContext = func(context.Context) jobqueue.TraceContext { return extracted }
+	assert.Equal(t, extracted, jobqueue.TraceContextFromContext(ctx), "ExtractTraceContext before the context value")
+	jobqueue.ExtractTraceContext = func(context.Context) jobqueue.TraceContext { return jobqueue.TraceContext{} }
+	assert.Equal(t, tc, jobqueue.TraceContextFromContext(ctx), "context value if ExtractTraceContext returns none")
+}
+
+func TestAddSetsTraceContext(t *testing.T) {
+	service := &addJobService{Service: jobqueue.ServiceWithError(jobqueue.ErrNotInitialized)}
+	tc := jobqueue.TraceContext{TraceParent: testTraceParent}
+	ctx := jobqueue.ContextWithService(t.Context(), service)
+
+	untraced, err := jobqueue.NewJob(uu.NewID(ctx), "trace-context-test", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, jobqueue.Add(ctx, untraced))
+	assert.True(t, untraced.TraceContext.IsZero(), "no trace context in ctx")
+
+	ctx = jobqueue.ContextWithTraceContext(ctx, tc)
+	job, err := jobqueue.NewJob(uu.NewID(ctx), "trace-context-test", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	require.NoError(t, jobqueue.Add(ctx, job))
+	assert.Equal(t, tc, job.TraceContext)
+
+	own := jobqueue.TraceContext{TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+	job, err = jobqueue.NewJob(uu.NewID(ctx), "trace-context-test", "test", `{}`, nullable.Time{})
+	require.NoError(t, err)
+	job.TraceContext = own
+	require.NoError(t, jobqueue.Add(ctx, job))
+	assert.Equal(t, own, job.TraceContext, "existing TraceContext is kept")
+}